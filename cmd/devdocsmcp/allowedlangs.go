@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"devdocsmcp/internal/docs/manifest"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// AllowedLanguage is a documentation set the caller may use.
+type AllowedLanguage struct {
+	Slug    string `json:"slug"`
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// AllowedLangs returns the documentation sets the caller may use, sorted by slug, named after
+// the devdocs manifest when it is available.
+func AllowedLangs(ctx context.Context) []AllowedLanguage {
+	docsets, _ := loadManifest()
+	langs := []AllowedLanguage{}
+	for _, slug := range callerLanguages(ctx) {
+		lang := AllowedLanguage{Slug: slug}
+		if docset, ok := manifest.Find(docsets, slug); ok {
+			lang.Name, lang.Version = docset.Name, docset.Version
+		}
+		langs = append(langs, lang)
+	}
+	return langs
+}
+
+// serverInstructions tells connecting clients which language slugs the server serves.
+func serverInstructions() string {
+	return fmt.Sprintf("DevDocs MCP serves the documentation of devdocs.io. Every tool takes a language slug; "+
+		"this server serves: %s. Your access token may be limited to some of them, and the list can change "+
+		"while the server runs, so call allowed_langs for the slugs you may use right now. Use resolve_slug "+
+		"to map a name such as \"React 18\" to its slug.", strings.Join(sortedKeys(servedLanguages()), ", "))
+}
+
+func handleAllowedLangs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return newJSONResult(map[string]any{"langs": AllowedLangs(ctx)}, nil), nil
+}
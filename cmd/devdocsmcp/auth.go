@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"devdocsmcp/internal/config"
+)
+
+// authTokenEnv names the environment variable holding the bearer token when -auth-token is unset.
+const authTokenEnv = "DEVDOCSMCP_TOKEN"
+
+// authToken is the bearer token HTTP clients must present; empty disables authentication
+// unless the config file lists tokens.
+var authToken string
+
+// tokenGrant is what a bearer token may use.
+type tokenGrant struct {
+	// name identifies the token in usage reports; it is never the secret itself.
+	name  string
+	token string
+	// langs restricts the token to these languages; nil allows every served language.
+	langs map[string]bool
+}
+
+// tokenGrants are the accepted bearer tokens: authToken, which may use every served language,
+// followed by the tokens from the config file.
+var (
+	grantsMu    sync.RWMutex
+	tokenGrants []tokenGrant
+)
+
+type grantKey struct{}
+
+// initTokenGrants builds tokenGrants from authToken and the configured tokens. Languages a
+// token lists but the server doesn't serve are dropped with a warning.
+func initTokenGrants(tokens []config.Token) {
+	var grants []tokenGrant
+	if authToken != "" {
+		grants = append(grants, tokenGrant{name: "auth-token", token: authToken})
+	}
+	served := servedLanguages()
+	for _, t := range tokens {
+		grant := tokenGrant{name: t.Name, token: t.Token, langs: make(map[string]bool)}
+		for _, lang := range currentConfig().ExpandBundles(t.Langs) {
+			if !served[lang] {
+				log.Printf("Warning: token %q lists %s, which this server doesn't serve\n", t.Name, lang)
+				continue
+			}
+			grant.langs[lang] = true
+		}
+		grants = append(grants, grant)
+	}
+	grantsMu.Lock()
+	tokenGrants = grants
+	grantsMu.Unlock()
+}
+
+// currentGrants returns the accepted bearer tokens. The caller must not modify the slice.
+func currentGrants() []tokenGrant {
+	grantsMu.RLock()
+	defer grantsMu.RUnlock()
+	return tokenGrants
+}
+
+// requireBearer rejects requests that don't carry "Authorization: Bearer <token>" with one of
+// the current grants, and records the matching grant in the request context for
+// isLanguageAllowed. Without grants every request is let through.
+func requireBearer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		grants := currentGrants()
+		if len(grants) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		grant, ok := authenticate(r.Header.Get("Authorization"), grants)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="devdocsmcp"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), grantKey{}, grant)))
+	})
+}
+
+// authenticate returns the grant whose token an Authorization header carries. Every grant is
+// compared, in constant time, so the timing doesn't reveal which token was close.
+func authenticate(header string, grants []tokenGrant) (*tokenGrant, bool) {
+	scheme, credentials, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, false
+	}
+	credentials = strings.TrimSpace(credentials)
+	var match *tokenGrant
+	for i := range grants {
+		if subtle.ConstantTimeCompare([]byte(credentials), []byte(grants[i].token)) == 1 && match == nil {
+			match = &grants[i]
+		}
+	}
+	return match, match != nil
+}
+
+// hasFullAccess reports whether ctx may use every served language and administer the server:
+// requests over stdio, without authentication, or with the -auth-token token.
+func hasFullAccess(ctx context.Context) bool {
+	grant, ok := ctx.Value(grantKey{}).(*tokenGrant)
+	return !ok || grant.langs == nil
+}
+
+// isAdmin reports whether ctx was authenticated with the -auth-token token. Unlike
+// hasFullAccess it is false for calls without a token, over stdio or unauthenticated HTTP,
+// since those come from the very agent the language allow-list restricts.
+func isAdmin(ctx context.Context) bool {
+	grant, ok := ctx.Value(grantKey{}).(*tokenGrant)
+	return ok && grant.langs == nil
+}
+
+// grantName returns the name of the token that authenticated ctx, or "" without a token.
+func grantName(ctx context.Context) string {
+	if grant, ok := ctx.Value(grantKey{}).(*tokenGrant); ok {
+		return grant.name
+	}
+	return ""
+}
+
+// grantAllows reports whether the token that authenticated ctx may use lang. Requests without
+// a token (stdio, or HTTP without authentication) are not restricted.
+func grantAllows(ctx context.Context, lang string) bool {
+	grant, ok := ctx.Value(grantKey{}).(*tokenGrant)
+	if !ok || grant.langs == nil {
+		return true
+	}
+	return grant.langs[lang]
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Benchmark backends: where the searches and reads of the workload are served from.
+const (
+	// benchRemote downloads every index and page from the documentation host (a cold cache).
+	benchRemote = "remote"
+	// benchCache serves them from the disk cache, decoding the index on every search.
+	benchCache = "cache"
+	// benchMemory also keeps the parsed indexes in memory, as a running server does.
+	benchMemory = "memory"
+	// benchFullText searches the full-text index of docsets downloaded with 'download' and
+	// reads their pages from the download directory.
+	benchFullText = "fulltext"
+)
+
+var benchBackends = []string{benchRemote, benchCache, benchMemory, benchFullText}
+
+// BenchOp reports the latencies of one kind of operation on one backend.
+type BenchOp struct {
+	Backend string  `json:"backend"`
+	Op      string  `json:"op"`
+	Count   int     `json:"count"`
+	Errors  int     `json:"errors"`
+	P50MS   float64 `json:"p50_ms"`
+	P90MS   float64 `json:"p90_ms"`
+	P99MS   float64 `json:"p99_ms"`
+	MaxMS   float64 `json:"max_ms"`
+	// OpsPerSec is the throughput over the wall time of the operations.
+	OpsPerSec float64 `json:"ops_per_sec"`
+	// Skipped explains why the backend didn't run, e.g. a docset without full-text index.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// BenchReport is the result of the 'bench' command.
+type BenchReport struct {
+	Langs       []string  `json:"langs"`
+	Searches    int       `json:"searches"`
+	Reads       int       `json:"reads"`
+	Concurrency int       `json:"concurrency"`
+	Seed        uint64    `json:"seed"`
+	Results     []BenchOp `json:"results"`
+}
+
+// benchTask is one operation of the workload: a search for query, or a read of path, in lang.
+type benchTask struct {
+	lang, query, path string
+}
+
+// fullTextIndex is the full-text index of a downloaded docset, as opened by openSearchIndex.
+type fullTextIndex interface {
+	Search(query string) ([]pageHit, error)
+	Close() error
+}
+
+// runBench implements the 'bench' command: it runs a workload of searches and reads on the
+// selected backends and reports their latency percentiles and throughput.
+func runBench(args []string) {
+	cmd := flag.NewFlagSet("bench", flag.ExitOnError)
+	langs := cmd.String("lang", "", "Comma-separated list of language slugs or bundles the workload runs on")
+	searches := cmd.Int("searches", 50, "Number of searches in the workload")
+	reads := cmd.Int("reads", 20, "Number of page reads in the workload")
+	queries := cmd.String("queries", "", "Comma-separated search queries, used in turn (default: names of random entries)")
+	backends := cmd.String("backends", strings.Join(benchBackends, ","), "Comma-separated backends to compare: "+strings.Join(benchBackends, ", "))
+	concurrency := cmd.Int("concurrency", 1, "Number of operations run at once")
+	seed := cmd.Uint64("seed", 1, "Seed of the random choice of entries, so runs are comparable")
+	dest := cmd.String("dest", defaultMirrorDir(), "Directory holding the docsets downloaded with 'download', for the fulltext backend")
+	asJSON := cmd.Bool("json", false, "Print the report as JSON")
+	configPath := cmd.String("config", "", "Path to the config file (default: $DEVDOCSMCP_CONFIG or the user config directory)")
+	cmd.Parse(args)
+
+	if err := loadConfig(*configPath); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *langs == "" {
+		log.Fatal("Error: -lang is required for the bench command.")
+	}
+	if *searches < 0 || *reads < 0 || *searches+*reads == 0 {
+		log.Fatal("Error: -searches and -reads must not be negative, and not both 0.")
+	}
+	if *concurrency < 1 {
+		log.Fatal("Error: -concurrency must be at least 1.")
+	}
+	slugs := currentConfig().ExpandBundles(strings.Split(*langs, ","))
+	if err := validateLangs(slugs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	selected, err := parseBenchBackends(*backends)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	var queryList []string
+	if *queries != "" {
+		queryList = strings.Split(*queries, ",")
+	}
+
+	searchTasks, readTasks, err := benchWorkload(slugs, *searches, *reads, queryList, *seed)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	report := BenchReport{Langs: slugs, Searches: *searches, Reads: *reads, Concurrency: *concurrency, Seed: *seed}
+	for _, backend := range selected {
+		report.Results = append(report.Results, benchBackend(backend, slugs, searchTasks, readTasks, *concurrency, *dest)...)
+	}
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Printf("Benchmark of %s: %d searches, %d reads, concurrency %d\n", strings.Join(slugs, ", "), *searches, *reads, *concurrency)
+	fmt.Printf("%-9s %-7s %6s %6s %10s %10s %10s %10s %10s\n", "BACKEND", "OP", "COUNT", "ERRORS", "P50", "P90", "P99", "MAX", "OPS/S")
+	for _, op := range report.Results {
+		if op.Skipped != "" {
+			fmt.Printf("%-9s %-7s skipped: %s\n", op.Backend, op.Op, op.Skipped)
+			continue
+		}
+		fmt.Printf("%-9s %-7s %6d %6d %8.2fms %8.2fms %8.2fms %8.2fms %10.1f\n", op.Backend, op.Op, op.Count, op.Errors, op.P50MS, op.P90MS, op.P99MS, op.MaxMS, op.OpsPerSec)
+	}
+}
+
+// parseBenchBackends parses the -backends flag.
+func parseBenchBackends(value string) ([]string, error) {
+	var backends []string
+	for _, backend := range strings.Split(value, ",") {
+		backend = strings.TrimSpace(backend)
+		if backend == "" {
+			continue
+		}
+		known := false
+		for _, b := range benchBackends {
+			known = known || b == backend
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown backend %q (available: %s)", backend, strings.Join(benchBackends, ", "))
+		}
+		backends = append(backends, backend)
+	}
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no backend selected")
+	}
+	return backends, nil
+}
+
+// benchWorkload builds the searches and reads of the workload, spread over the docsets in
+// turn. Without queries, each search is for the name of a random entry; reads are of the pages
+// of random entries.
+func benchWorkload(slugs []string, searches, reads int, queries []string, seed uint64) ([]benchTask, []benchTask, error) {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	entries := make(map[string][]DocEntry, len(slugs))
+	for _, slug := range slugs {
+		doc, err := fetchIndex(context.Background(), slug)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load the index of %s: %w", slug, err)
+		}
+		if len(doc.Entries) == 0 {
+			return nil, nil, fmt.Errorf("the index of %s has no entries", slug)
+		}
+		entries[slug] = doc.Entries
+	}
+
+	searchTasks := make([]benchTask, searches)
+	for i := range searchTasks {
+		slug := slugs[i%len(slugs)]
+		query := ""
+		if len(queries) > 0 {
+			query = queries[i%len(queries)]
+		} else {
+			query = benchQuery(entries[slug][rng.IntN(len(entries[slug]))].Name)
+		}
+		searchTasks[i] = benchTask{lang: slug, query: query}
+	}
+	readTasks := make([]benchTask, reads)
+	for i := range readTasks {
+		slug := slugs[i%len(slugs)]
+		readTasks[i] = benchTask{lang: slug, path: stripFragment(entries[slug][rng.IntN(len(entries[slug]))].Path)}
+	}
+	return searchTasks, readTasks, nil
+}
+
+// benchQuery returns the search query for an entry name: its last word, which both the
+// entry matcher and the full-text query syntax accept.
+func benchQuery(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 127)
+	})
+	if len(words) == 0 {
+		return name
+	}
+	return words[len(words)-1]
+}
+
+// benchBackend runs the workload on one backend. The cache backends are warmed by a first,
+// untimed run; the remote backend disables both caches for the duration of its run.
+func benchBackend(backend string, slugs []string, searchTasks, readTasks []benchTask, concurrency int, dest string) []BenchOp {
+	savedTTL, savedEntries, savedMirrorDir := cacheTTL, indexCacheEntries, mirrorDir
+	defer func() {
+		cacheTTL, indexCacheEntries, mirrorDir = savedTTL, savedEntries, savedMirrorDir
+	}()
+	for _, slug := range slugs {
+		parsedIndexes.remove(slug)
+	}
+
+	search := func(t benchTask) error {
+		_, err := SearchDoc(context.Background(), t.lang, t.query, SearchOptions{})
+		return err
+	}
+	read := func(t benchTask) error {
+		_, err := ReadDocContent(context.Background(), t.lang, t.path)
+		return err
+	}
+	switch backend {
+	case benchRemote:
+		cacheTTL, indexCacheEntries = 0, 0
+	case benchCache:
+		indexCacheEntries = 0
+	case benchFullText:
+		indexes := make(map[string]fullTextIndex, len(slugs))
+		for _, slug := range slugs {
+			idx, err := openSearchIndex(dest, slug)
+			if err != nil {
+				for _, idx := range indexes {
+					idx.Close()
+				}
+				return skippedBench(backend, err.Error())
+			}
+			indexes[slug] = idx
+		}
+		defer func() {
+			for _, idx := range indexes {
+				idx.Close()
+			}
+		}()
+		mirrorDir = dest
+		search = func(t benchTask) error {
+			_, err := indexes[t.lang].Search(t.query)
+			return err
+		}
+		read = func(t benchTask) error {
+			_, err := readOfflinePage(t.lang, t.path)
+			return err
+		}
+	}
+
+	if backend == benchCache || backend == benchMemory {
+		runBenchTasks(searchTasks, search, concurrency)
+		runBenchTasks(readTasks, read, concurrency)
+	}
+	var ops []BenchOp
+	if len(searchTasks) > 0 {
+		ops = append(ops, runBenchTasks(searchTasks, search, concurrency).named(backend, "search"))
+	}
+	if len(readTasks) > 0 {
+		ops = append(ops, runBenchTasks(readTasks, read, concurrency).named(backend, "read"))
+	}
+	return ops
+}
+
+func skippedBench(backend, reason string) []BenchOp {
+	return []BenchOp{{Backend: backend, Op: "all", Skipped: reason}}
+}
+
+// benchTimings are the durations of a run of operations.
+type benchTimings struct {
+	durations []time.Duration
+	errors    int
+	wall      time.Duration
+}
+
+// runBenchTasks runs op on every task with the given concurrency and times each run.
+func runBenchTasks(tasks []benchTask, op func(benchTask) error, concurrency int) benchTimings {
+	timings := benchTimings{durations: make([]time.Duration, len(tasks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	next := make(chan int)
+	start := time.Now()
+	for range min(concurrency, len(tasks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				opStart := time.Now()
+				err := op(tasks[i])
+				timings.durations[i] = time.Since(opStart)
+				if err != nil {
+					mu.Lock()
+					timings.errors++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range tasks {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	timings.wall = time.Since(start)
+	return timings
+}
+
+// named summarizes the timings as the results of op on backend.
+func (t benchTimings) named(backend, op string) BenchOp {
+	sorted := append([]time.Duration{}, t.durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) float64 {
+		return milliseconds(sorted[min(len(sorted)-1, int(p*float64(len(sorted))))])
+	}
+	result := BenchOp{
+		Backend: backend,
+		Op:      op,
+		Count:   len(sorted),
+		Errors:  t.errors,
+		P50MS:   percentile(0.5),
+		P90MS:   percentile(0.9),
+		P99MS:   percentile(0.99),
+		MaxMS:   milliseconds(sorted[len(sorted)-1]),
+	}
+	if t.wall > 0 {
+		result.OpsPerSec = float64(len(sorted)) / t.wall.Seconds()
+	}
+	return result
+}
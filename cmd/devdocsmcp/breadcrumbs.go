@@ -0,0 +1,117 @@
+package main
+
+import (
+	"path"
+	"strings"
+
+	"devdocsmcp/internal/docs/manifest"
+	"devdocsmcp/internal/docs/page"
+)
+
+// Breadcrumb levels, outermost first.
+const (
+	crumbDocset  = "docset"
+	crumbSection = "section"
+	crumbPage    = "page"
+	crumbAnchor  = "anchor"
+)
+
+// Breadcrumb is one step of the location of an entry within its documentation set.
+type Breadcrumb struct {
+	Level string `json:"level"`
+	Label string `json:"label"`
+	Path  string `json:"path,omitempty"`
+}
+
+// docsetLabel returns the display name of a documentation set, e.g. "React 18".
+func docsetLabel(langSlug string) string {
+	docsets, err := loadManifest()
+	if err != nil {
+		return langSlug
+	}
+	docset, ok := manifest.Find(docsets, langSlug)
+	if !ok {
+		return langSlug
+	}
+	if docset.Version != "" {
+		return docset.Name + " " + docset.Version
+	}
+	return docset.Name
+}
+
+// addBreadcrumbs sets the breadcrumbs of every entry of doc: the docset, the entry type as the
+// section, the page the entry lives on and, for entries pointing into a page, the anchor.
+func addBreadcrumbs(langSlug string, doc *Doc) {
+	label := docsetLabel(langSlug)
+
+	// Name each page after the entry without a fragment that points at it
+	pageNames := make(map[string]string)
+	for _, entry := range doc.Entries {
+		if !strings.Contains(entry.Path, "#") {
+			if _, ok := pageNames[entry.Path]; !ok {
+				pageNames[entry.Path] = entry.Name
+			}
+		}
+	}
+
+	for i := range doc.Entries {
+		entry := &doc.Entries[i]
+		crumbs := []Breadcrumb{{Level: crumbDocset, Label: label}}
+		if entry.Type != "" {
+			crumbs = append(crumbs, Breadcrumb{Level: crumbSection, Label: entry.Type})
+		}
+		pagePath := stripFragment(entry.Path)
+		if pagePath == entry.Path {
+			crumbs = append(crumbs, Breadcrumb{Level: crumbPage, Label: entry.Name, Path: entry.Path})
+		} else {
+			pageName, ok := pageNames[pagePath]
+			if !ok {
+				pageName = path.Base(pagePath)
+			}
+			crumbs = append(crumbs,
+				Breadcrumb{Level: crumbPage, Label: pageName, Path: pagePath},
+				Breadcrumb{Level: crumbAnchor, Label: entry.Name, Path: entry.Path},
+			)
+		}
+		entry.Breadcrumbs = crumbs
+	}
+}
+
+// readBreadcrumbs locates a page that was read: the docset, the directories of its path as
+// sections, the page's title and, when entryPath has a #fragment, the headings leading to it.
+func readBreadcrumbs(langSlug, entryPath, content string) []Breadcrumb {
+	crumbs := []Breadcrumb{{Level: crumbDocset, Label: docsetLabel(langSlug)}}
+
+	pagePath := stripFragment(entryPath)
+	if dir := path.Dir(pagePath); dir != "." && dir != "/" {
+		crumbs = append(crumbs, Breadcrumb{Level: crumbSection, Label: dir, Path: dir})
+	}
+
+	sections, err := page.Sections(strings.NewReader(content))
+	if err != nil {
+		sections = nil
+	}
+	title := path.Base(pagePath)
+	for _, section := range sections {
+		if section.Level == 1 {
+			title = section.Heading
+			break
+		}
+	}
+	crumbs = append(crumbs, Breadcrumb{Level: crumbPage, Label: title, Path: pagePath})
+
+	if fragment := strings.TrimPrefix(entryPath[len(pagePath):], "#"); fragment != "" {
+		label := fragment
+		for _, section := range sections {
+			if section.Anchor == fragment {
+				label = strings.Join(section.Breadcrumbs, " › ")
+				if len(section.Breadcrumbs) > 1 && section.Breadcrumbs[0] == title {
+					label = strings.Join(section.Breadcrumbs[1:], " › ")
+				}
+				break
+			}
+		}
+		crumbs = append(crumbs, Breadcrumb{Level: crumbAnchor, Label: label, Path: entryPath})
+	}
+	return crumbs
+}
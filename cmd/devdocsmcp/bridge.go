@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"devdocsmcp/internal/config"
+	"devdocsmcp/internal/httpclient"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// bridgeToolSeparator joins a bridge name and a bridged tool name.
+const bridgeToolSeparator = "__"
+
+// bridgeConnectTimeout bounds how long connecting to and listing the tools of a bridge may take.
+const bridgeConnectTimeout = 30 * time.Second
+
+// startBridges connects to every configured bridge and registers its tools on s under the
+// bridge's namespace. Bridges that fail to connect, and network bridges in offline mode, are
+// logged and skipped. The returned clients must be closed when the server stops.
+func startBridges(s *server.MCPServer, bridges []config.Bridge) []*client.Client {
+	var clients []*client.Client
+	for _, bridge := range bridges {
+		if offline && bridge.URL != "" {
+			log.Printf("Bridge %s: skipped in offline mode\n", bridge.Name)
+			continue
+		}
+		c, count, err := connectBridge(s, bridge)
+		if err != nil {
+			log.Printf("Bridge %s: %v\n", bridge.Name, err)
+			continue
+		}
+		log.Printf("Bridge %s: federated %d tools\n", bridge.Name, count)
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+func connectBridge(s *server.MCPServer, bridge config.Bridge) (*client.Client, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bridgeConnectTimeout)
+	defer cancel()
+
+	c, err := newBridgeClient(bridge)
+	if err != nil {
+		return nil, 0, err
+	}
+	if bridge.URL != "" {
+		// Stdio clients are started on creation; network clients must be started explicitly
+		if err := c.Start(ctx); err != nil {
+			c.Close()
+			return nil, 0, fmt.Errorf("failed to connect: %w", err)
+		}
+	}
+
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "DevDocs MCP bridge", Version: buildInfo().Version}
+	if _, err := c.Initialize(ctx, initRequest); err != nil {
+		c.Close()
+		return nil, 0, fmt.Errorf("failed to initialize: %w", err)
+	}
+
+	tools, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		c.Close()
+		return nil, 0, fmt.Errorf("failed to list tools: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(bridge.Tools))
+	for _, name := range bridge.Tools {
+		wanted[name] = true
+	}
+
+	count := 0
+	for _, tool := range tools.Tools {
+		if len(wanted) > 0 && !wanted[tool.Name] {
+			continue
+		}
+		remoteName := tool.Name
+		tool.Name = bridge.Name + bridgeToolSeparator + remoteName
+		tool.Description = fmt.Sprintf("[%s] %s", bridge.Name, tool.Description)
+		s.AddTool(tool, bridgeHandler(c, remoteName))
+		count++
+	}
+	return c, count, nil
+}
+
+func newBridgeClient(bridge config.Bridge) (*client.Client, error) {
+	if bridge.Command != "" {
+		env := make([]string, 0, len(bridge.Env))
+		for key, value := range bridge.Env {
+			env = append(env, key+"="+value)
+		}
+		c, err := client.NewStdioMCPClient(bridge.Command, env, bridge.Args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start %s: %w", bridge.Command, err)
+		}
+		return c, nil
+	}
+
+	httpClient := http.DefaultClient
+	if bridge.TLS != nil {
+		tlsTransport, err := httpclient.NewTransport("bridge "+bridge.Name, *bridge.TLS)
+		if err != nil {
+			return nil, err
+		}
+		httpClient = &http.Client{Transport: tlsTransport}
+	}
+
+	switch bridge.Transport {
+	case "sse":
+		return client.NewSSEMCPClient(bridge.URL, transport.WithHeaders(bridge.Headers), transport.WithHTTPClient(httpClient))
+	case "", "streamable-http":
+		return client.NewStreamableHttpClient(bridge.URL, transport.WithHTTPHeaders(bridge.Headers), transport.WithHTTPBasicClient(httpClient))
+	default:
+		return nil, fmt.Errorf("unknown transport %q (expected sse or streamable-http)", bridge.Transport)
+	}
+}
+
+// bridgeHandler forwards a tool call to the bridged server, keeping the caller's arguments.
+func bridgeHandler(c *client.Client, remoteName string) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		forwarded := mcp.CallToolRequest{}
+		forwarded.Params.Name = remoteName
+		forwarded.Params.Arguments = request.GetArguments()
+		result, err := c.CallTool(ctx, forwarded)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("bridged call to %s failed: %v", remoteName, err)), nil
+		}
+		return result, nil
+	}
+}
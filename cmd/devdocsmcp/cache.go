@@ -0,0 +1,481 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"devdocsmcp/internal/cachestore"
+	"devdocsmcp/internal/store"
+)
+
+// cacheCountersPrefix prefixes the keys of the disk cache counters in the metadata store's
+// stats bucket; the rest of the key is the docset slug.
+const cacheCountersPrefix = "cache/"
+
+// CacheCounters counts the lookups of one documentation set in the disk cache.
+type CacheCounters struct {
+	IndexHits   int64 `json:"index_hits"`
+	IndexMisses int64 `json:"index_misses"`
+	PageHits    int64 `json:"page_hits"`
+	PageMisses  int64 `json:"page_misses"`
+}
+
+func (c *CacheCounters) add(other CacheCounters) {
+	c.IndexHits += other.IndexHits
+	c.IndexMisses += other.IndexMisses
+	c.PageHits += other.PageHits
+	c.PageMisses += other.PageMisses
+}
+
+// cacheLookups accumulates the disk cache counters of this process until they are flushed
+// into the metadata store, so lookups don't each cost a store write.
+var cacheLookups = struct {
+	sync.Mutex
+	pending map[string]*CacheCounters
+	once    sync.Once
+}{pending: make(map[string]*CacheCounters)}
+
+// countCacheLookup records a lookup of an index or page of a documentation set in the disk
+// cache.
+func countCacheLookup(langSlug string, isPage, hit bool) {
+	cacheLookups.once.Do(func() {
+		// Opening the store first registers its close before the flush, which therefore runs first
+		metadataStore()
+		onShutdown(flushCacheCounters)
+	})
+	cacheLookups.Lock()
+	defer cacheLookups.Unlock()
+	c := cacheLookups.pending[langSlug]
+	if c == nil {
+		c = &CacheCounters{}
+		cacheLookups.pending[langSlug] = c
+	}
+	switch {
+	case isPage && hit:
+		c.PageHits++
+	case isPage:
+		c.PageMisses++
+	case hit:
+		c.IndexHits++
+	default:
+		c.IndexMisses++
+	}
+}
+
+// flushCacheCounters adds the pending disk cache counters to those in the metadata store.
+func flushCacheCounters() {
+	cacheLookups.Lock()
+	pending := cacheLookups.pending
+	cacheLookups.pending = make(map[string]*CacheCounters)
+	cacheLookups.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	s := metadataStore()
+	if s == nil {
+		return
+	}
+	for lang, c := range pending {
+		var stored CacheCounters
+		if _, err := s.Get(store.Stats, cacheCountersPrefix+lang, &stored); err != nil {
+			log.Printf("Failed to read cache counters of %s: %v\n", lang, err)
+			continue
+		}
+		stored.add(*c)
+		if err := s.Put(store.Stats, cacheCountersPrefix+lang, stored); err != nil {
+			log.Printf("Failed to record cache counters of %s: %v\n", lang, err)
+		}
+	}
+}
+
+// lookupCacheCounters returns the disk cache counters of every documentation set: those in the
+// metadata store plus this process's pending ones.
+func lookupCacheCounters() map[string]CacheCounters {
+	counters := make(map[string]CacheCounters)
+	if s := metadataStore(); s != nil {
+		err := s.ForEach(store.Stats, func(key string, value []byte) error {
+			lang, ok := strings.CutPrefix(key, cacheCountersPrefix)
+			if !ok {
+				return nil
+			}
+			var c CacheCounters
+			if err := json.Unmarshal(value, &c); err == nil {
+				counters[lang] = c
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to read cache counters: %v\n", err)
+		}
+	}
+	cacheLookups.Lock()
+	defer cacheLookups.Unlock()
+	for lang, pending := range cacheLookups.pending {
+		c := counters[lang]
+		c.add(*pending)
+		counters[lang] = c
+	}
+	return counters
+}
+
+// runCache implements the 'cache' command.
+func runCache(args []string) {
+	const usage = "Error: usage: devdocsmcp cache stats|purge|gc [arguments]"
+	if len(args) < 1 {
+		log.Fatal(usage)
+	}
+	switch args[0] {
+	case "stats":
+		runCacheStats(args[1:])
+	case "purge":
+		runCachePurge(args[1:])
+	case "gc":
+		runCacheGC(args[1:])
+	default:
+		log.Fatal(usage)
+	}
+}
+
+// runCacheStats prints the size of the cache, broken down by documentation set, with the hit
+// and miss counters of each.
+func runCacheStats(args []string) {
+	cmd := flag.NewFlagSet("cache stats", flag.ExitOnError)
+	asJSON := cmd.Bool("json", false, "Print the statistics as JSON")
+	cmd.Parse(args)
+
+	stats := collectCacheStats()
+	if *asJSON {
+		out, _ := json.MarshalIndent(stats, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Printf("Cache directory: %s\n", stats.Dir)
+	fmt.Printf("Total: %d files, %s (metadata store %s, pages kept in %s)\n", stats.Files, formatBytes(stats.Bytes), formatBytes(stats.MetadataBytes), stats.PageBackend)
+	if len(stats.Indexes) == 0 {
+		fmt.Println("No documentation sets cached.")
+		return
+	}
+	fmt.Printf("%-24s %10s %7s %10s %10s %16s %16s\n", "LANGUAGE", "INDEX", "PAGES", "PAGE SIZE", "SNAPSHOTS", "INDEX HIT/MISS", "PAGE HIT/MISS")
+	for _, index := range stats.Indexes {
+		lang := index.Lang
+		if index.Pinned {
+			lang += " (pinned)"
+		}
+		var lookups CacheCounters
+		if index.Lookups != nil {
+			lookups = *index.Lookups
+		}
+		fmt.Printf("%-24s %10s %7d %10s %10s %16s %16s\n", lang, formatBytes(index.Bytes), index.Pages, formatBytes(index.PageBytes), formatBytes(index.SnapshotBytes),
+			fmt.Sprintf("%d/%d", lookups.IndexHits, lookups.IndexMisses), fmt.Sprintf("%d/%d", lookups.PageHits, lookups.PageMisses))
+	}
+	if metadataStore() == nil {
+		fmt.Println("Hit and miss counters are unavailable while a server holds the metadata store; see server_diagnostics instead.")
+	}
+}
+
+// runCachePurge removes everything cached for some documentation sets.
+func runCachePurge(args []string) {
+	cmd := flag.NewFlagSet("cache purge", flag.ExitOnError)
+	langs := cmd.String("lang", "", "Comma-separated list of language slugs or bundles to purge")
+	all := cmd.Bool("all", false, "Purge every cached documentation set")
+	snapshots := cmd.Bool("snapshots", false, "Also remove the snapshots saved with 'snapshot save'")
+	configPath := cmd.String("config", "", "Path to the config file (default: $DEVDOCSMCP_CONFIG or the user config directory)")
+	cmd.Parse(args)
+
+	if err := loadConfig(*configPath); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	var slugs []string
+	switch {
+	case *all && *langs != "":
+		log.Fatal("Error: -lang and -all are mutually exclusive.")
+	case *all:
+		slugs = cachedLangs(*snapshots)
+	case *langs != "":
+		slugs = currentConfig().ExpandBundles(strings.Split(*langs, ","))
+		if err := validateLangs(slugs); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	default:
+		log.Fatal("Error: -lang or -all is required for the cache purge command.")
+	}
+
+	failed := 0
+	for _, slug := range slugs {
+		if slug == "" {
+			continue
+		}
+		freed, err := PurgeCache(slug, *snapshots)
+		if err != nil {
+			log.Printf("Failed to purge %s: %v\n", slug, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Purged %s (%s)\n", slug, formatBytes(freed))
+	}
+	if failed > 0 {
+		log.Fatalf("Error: %d of %d docsets failed.", failed, len(slugs))
+	}
+}
+
+// cachedLangs returns the documentation sets with a cached index or pages, or snapshots if
+// withSnapshots is set, sorted.
+func cachedLangs(withSnapshots bool) []string {
+	subdirs := []string{"indexes"}
+	if withSnapshots {
+		subdirs = append(subdirs, "snapshots")
+	}
+	seen := make(map[string]bool)
+	var slugs []string
+	for _, sub := range subdirs {
+		dirs, _ := os.ReadDir(filepath.Join(cacheDir(), sub))
+		for _, dir := range dirs {
+			if dir.IsDir() && !seen[dir.Name()] {
+				seen[dir.Name()] = true
+				slugs = append(slugs, dir.Name())
+			}
+		}
+	}
+	langs, _ := pageStore().Langs()
+	for _, lang := range langs {
+		if !seen[lang] {
+			seen[lang] = true
+			slugs = append(slugs, lang)
+		}
+	}
+	sort.Strings(slugs)
+	return slugs
+}
+
+// PurgeCache removes the cached index and pages of a documentation set, pinned or not, and its
+// snapshots if withSnapshots is set, and forgets its metadata. It returns the bytes freed.
+func PurgeCache(langSlug string, withSnapshots bool) (int64, error) {
+	// The slug names directories removed below; never let it reach outside of them
+	if langSlug == "" {
+		return 0, fmt.Errorf("invalid documentation set %q", langSlug)
+	}
+	if err := checkSlugPath(langSlug); err != nil {
+		return 0, err
+	}
+	dirs := []string{indexCacheDir(langSlug)}
+	if withSnapshots {
+		dirs = append(dirs, snapshotsDir(langSlug))
+	}
+	unlock, _ := lockDocset(langSlug)
+	defer unlock()
+	var freed int64
+	for _, dir := range dirs {
+		size, _ := dirSize(dir)
+		tx, err := beginCacheTx(filepath.Dir(dir))
+		if err != nil {
+			return freed, err
+		}
+		if err := tx.Remove(filepath.Base(dir)); err != nil {
+			tx.Abort()
+			return freed, err
+		}
+		if err := tx.Commit(); err != nil {
+			return freed, err
+		}
+		freed += size
+	}
+	if cacheBackend() != backendFiles {
+		freed += pageBytes(langSlug)
+	}
+	if err := pageStore().DeleteLang(langSlug); err != nil {
+		return freed, err
+	}
+	parsedIndexes.remove(langSlug)
+
+	if s := metadataStore(); s != nil {
+		var validators []string
+		s.ForEach(store.ETags, func(key string, _ []byte) error {
+			if strings.HasPrefix(key, langSlug+"/") {
+				validators = append(validators, key)
+			}
+			return nil
+		})
+		for _, key := range validators {
+			forgetValidators(key)
+		}
+		for _, key := range []struct{ bucket, key string }{{store.Docsets, langSlug}, {store.Stats, cacheCountersPrefix + langSlug}} {
+			if err := s.Delete(key.bucket, key.key); err != nil {
+				log.Printf("Failed to drop %s/%s: %v\n", key.bucket, key.key, err)
+			}
+		}
+	}
+	return freed, nil
+}
+
+// cacheUnit is a piece of the cache garbage collection can remove: a page, or the index of a
+// documentation set (its JSON and gob forms).
+type cacheUnit struct {
+	lang     string
+	name     string // "index.json", or the path of a page
+	page     bool
+	bytes    int64
+	modified time.Time
+}
+
+// runCacheGC removes cached indexes and pages older than a number of days, then the least
+// recently downloaded ones while the cache is above a size cap.
+func runCacheGC(args []string) {
+	cmd := flag.NewFlagSet("cache gc", flag.ExitOnError)
+	days := cmd.Int("older-than-days", 0, "Remove cached indexes and pages downloaded more than this many days ago (0 for no age limit)")
+	maxMB := cmd.Int("max-mb", 0, "Then remove the oldest cached indexes and pages until the cache is below this many MiB (0 for no cap)")
+	dryRun := cmd.Bool("dry-run", false, "Only print what would be removed")
+	cmd.Parse(args)
+
+	if *days <= 0 && *maxMB <= 0 {
+		log.Fatal("Error: -older-than-days or -max-mb is required for the cache gc command.")
+	}
+	var cutoff time.Time
+	if *days > 0 {
+		cutoff = time.Now().AddDate(0, 0, -*days)
+	}
+	result, err := CollectGarbage(cutoff, int64(*maxMB)<<20, *dryRun)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	verb := "Removed"
+	if *dryRun {
+		verb = "Would remove"
+	}
+	fmt.Printf("%s %d indexes and %d pages (%s); the cache holds %s\n", verb, result.Indexes, result.Pages, formatBytes(result.Freed), formatBytes(result.Remaining))
+	if *maxMB > 0 && result.Remaining > int64(*maxMB)<<20 {
+		fmt.Println("The cache is still above -max-mb: snapshots, pinned indexes and the metadata store are never collected (see 'cache purge').")
+	}
+}
+
+// GCResult summarizes a garbage collection of the cache.
+type GCResult struct {
+	Indexes   int
+	Pages     int
+	Freed     int64
+	Remaining int64
+}
+
+// CollectGarbage removes the cached indexes and pages downloaded before cutoff (unless it is
+// zero), then, if maxBytes is positive, the least recently downloaded ones until the whole cache
+// directory fits in maxBytes. Indexes pinned by 'entries download' and snapshots are kept. With
+// dryRun nothing is removed.
+func CollectGarbage(cutoff time.Time, maxBytes int64, dryRun bool) (GCResult, error) {
+	var result GCResult
+	total, err := cacheSize()
+	if err != nil {
+		return result, err
+	}
+	units, err := collectableUnits()
+	if err != nil {
+		return result, err
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].modified.Before(units[j].modified) })
+
+	for _, unit := range units {
+		expired := !cutoff.IsZero() && unit.modified.Before(cutoff)
+		if !expired && (maxBytes <= 0 || total <= maxBytes) {
+			// Units are oldest first, so no later one is expired either
+			break
+		}
+		if !dryRun {
+			if err := removeCacheUnit(unit); err != nil {
+				return result, err
+			}
+		}
+		if unit.page {
+			result.Pages++
+		} else {
+			result.Indexes++
+		}
+		result.Freed += unit.bytes
+		total -= unit.bytes
+	}
+	result.Remaining = total
+	return result, nil
+}
+
+// collectableUnits lists the cached indexes, except pinned ones, and pages.
+func collectableUnits() ([]cacheUnit, error) {
+	var units []cacheUnit
+	for _, lang := range cachedLangs(false) {
+		dir := indexCacheDir(lang)
+		if info, err := os.Stat(filepath.Join(dir, "index.json")); err == nil && !isIndexPinned(lang) {
+			gobSize := fileSize(filepath.Join(dir, "index.gob"))
+			units = append(units, cacheUnit{lang: lang, name: "index.json", bytes: info.Size() + gobSize, modified: info.ModTime()})
+		}
+		err := pageStore().Walk(lang, func(page cachestore.Info) error {
+			units = append(units, cacheUnit{lang: lang, name: page.Name, page: true, bytes: page.Size, modified: page.Stored})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return units, nil
+}
+
+// removeCacheUnit removes a page, or an index together with its gob form, from the cache.
+func removeCacheUnit(unit cacheUnit) error {
+	if unit.page {
+		return pageStore().Delete(unit.lang, unit.name)
+	}
+	dir := indexCacheDir(unit.lang)
+	parsedIndexes.remove(unit.lang)
+	unlock, _ := lockDocset(unit.lang)
+	defer unlock()
+	tx, err := beginCacheTx(dir)
+	if err != nil {
+		return err
+	}
+	tx.Remove("index.json")
+	tx.Remove("index.gob")
+	return tx.Commit()
+}
+
+// dirSize returns the total size of the files under dir; it is 0 if dir doesn't exist.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure %s: %w", dir, err)
+	}
+	return size, nil
+}
+
+// formatBytes formats a byte count for people, in binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
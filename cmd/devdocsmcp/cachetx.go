@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"devdocsmcp/internal/cachetx"
+)
+
+// stagingRecoveryAge is how old an uncommitted cache transaction nobody holds the lock of must
+// be before another process discards it; younger ones may be just starting.
+const stagingRecoveryAge = 10 * time.Minute
+
+// cacheTxMeta is the metadata a cache transaction records in the metadata store once its files
+// are in place.
+type cacheTxMeta struct {
+	Docset *DocsetRecord `json:"docset,omitempty"`
+}
+
+// stagingDir returns the directory cache transactions are staged in.
+func stagingDir() string {
+	return filepath.Join(cacheDir(), "staging")
+}
+
+// beginCacheTx starts a transaction changing the cache directory dir. Its files are encrypted
+// like writeCacheFile's.
+func beginCacheTx(dir string) (*cachetx.Tx, error) {
+	tx, err := cachetx.Begin(stagingDir(), dir)
+	if err != nil {
+		return nil, err
+	}
+	if cipher := cacheCipher.Load(); cipher != nil {
+		tx.Seal = cipher.Seal
+	}
+	tx.ApplyMeta = replayCacheTxMeta
+	return tx, nil
+}
+
+// commitCacheTx commits tx with its metadata, which is applied before the transaction's journal
+// is released, so a crash in between leaves it for recoverCacheTxs to apply.
+func commitCacheTx(tx *cachetx.Tx, meta cacheTxMeta) error {
+	if err := tx.SetMeta(meta); err != nil {
+		tx.Abort()
+		return err
+	}
+	return tx.Commit()
+}
+
+// replayCacheTxMeta applies the metadata of a cache transaction. Storing a record again is
+// harmless, so it may be applied more than once.
+func replayCacheTxMeta(data json.RawMessage) error {
+	var meta cacheTxMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return fmt.Errorf("failed to decode cache transaction metadata: %w", err)
+	}
+	if meta.Docset != nil {
+		return putDocsetRecord(*meta.Docset)
+	}
+	return nil
+}
+
+// recoverCacheTxs completes the cache transactions a crashed process committed but didn't
+// apply, and discards those it never committed.
+func recoverCacheTxs() {
+	completed, err := cachetx.Recover(stagingDir(), stagingRecoveryAge, replayCacheTxMeta)
+	if completed > 0 {
+		log.Printf("Completed %d interrupted cache updates\n", completed)
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"devdocsmcp/internal/docs/page"
+	"devdocsmcp/internal/textdiff"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change.
+const diffContextLines = 3
+
+// VersionComparison is the result of comparing one entry across two documentation versions.
+type VersionComparison struct {
+	Path      string `json:"path"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Identical bool   `json:"identical"`
+	Diff      string `json:"diff"`
+}
+
+// CompareVersions fetches entryPath from two documentation sets (e.g. node~18 and node~20) and
+// returns a unified diff of their extracted text.
+func CompareVersions(ctx context.Context, fromSlug, toSlug, entryPath string) (*VersionComparison, []string, error) {
+	var warnings []string
+	fromText, err := readDocText(ctx, fromSlug, entryPath, &warnings)
+	if err != nil {
+		return nil, nil, err
+	}
+	toText, err := readDocText(ctx, toSlug, entryPath, &warnings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	diff := textdiff.Unified(
+		fmt.Sprintf("%s/%s", fromSlug, entryPath),
+		fmt.Sprintf("%s/%s", toSlug, entryPath),
+		textdiff.Lines(fromText),
+		textdiff.Lines(toText),
+		diffContextLines,
+	)
+	return &VersionComparison{
+		Path:      entryPath,
+		From:      fromSlug,
+		To:        toSlug,
+		Identical: diff == "",
+		Diff:      diff,
+	}, warnings, nil
+}
+
+// readDocText reads a documentation entry and extracts its text. A page that can't be parsed
+// yields its plain text, and a warning is added to warnings.
+func readDocText(ctx context.Context, langSlug, entryPath string, warnings *[]string) (string, error) {
+	content, err := ReadDocContent(ctx, langSlug, entryPath)
+	if err != nil {
+		return "", err
+	}
+	text, err := page.Text(content)
+	if err != nil {
+		*warnings = append(*warnings, fmt.Sprintf("failed to extract text from %s/%s (%v); compared its plain text", langSlug, entryPath, err))
+		return page.PlainText(content), nil
+	}
+	return text, nil
+}
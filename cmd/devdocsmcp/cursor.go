@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"devdocsmcp/internal/docs/match"
+)
+
+// resultKey orders search results. It depends only on the entry and the query, never on the
+// entry's position in index.json, so the order survives index refreshes: by match quality
+// first, then by full-text score, then by name and path, with the type breaking the last ties.
+type resultKey struct {
+	Rank int `json:"r"`
+	// Score is the full-text relevance of the entry's page, ordering entries of the same rank.
+	Score float64 `json:"sc,omitempty"`
+	Fold  string  `json:"-"`
+	Name  string  `json:"n"`
+	Path  string  `json:"p"`
+	Type  string  `json:"t,omitempty"`
+	// Lang tells apart equal entries of different docsets in a namespace-wide search.
+	Lang string `json:"l,omitempty"`
+}
+
+func (k resultKey) less(o resultKey) bool {
+	if k.Rank != o.Rank {
+		return k.Rank < o.Rank
+	}
+	if k.Score != o.Score {
+		return k.Score > o.Score
+	}
+	if k.Fold != o.Fold {
+		return k.Fold < o.Fold
+	}
+	if k.Name != o.Name {
+		return k.Name < o.Name
+	}
+	if k.Path != o.Path {
+		return k.Path < o.Path
+	}
+	if k.Type != o.Type {
+		return k.Type < o.Type
+	}
+	return k.Lang < o.Lang
+}
+
+// keyOf returns the sort key of a result of query in mode. The rank is the edit distance in
+// fuzzy mode; otherwise names equal to the query come first, then names starting with it, then
+// names containing it, then entries matching only by path.
+func keyOf(entry DocEntry, query, mode string) resultKey {
+	key := resultKey{Score: entry.Score, Fold: strings.ToLower(entry.Name), Name: entry.Name, Path: entry.Path, Type: entry.Type, Lang: entry.Lang}
+	if mode == modeFuzzy {
+		key.Rank, _ = match.Fuzzy(query, entry.Name, match.DefaultFuzziness(query))
+		return key
+	}
+	lowerQuery := strings.ToLower(query)
+	switch {
+	case key.Fold == lowerQuery:
+		key.Rank = 0
+	case strings.HasPrefix(key.Fold, lowerQuery):
+		key.Rank = 1
+	case strings.Contains(key.Fold, lowerQuery):
+		key.Rank = 2
+	default:
+		key.Rank = 3
+	}
+	return key
+}
+
+// sortResults puts search results in their deterministic order.
+func sortResults(results []DocEntry, query, mode string) {
+	keys := make([]resultKey, len(results))
+	for i, entry := range results {
+		keys[i] = keyOf(entry, query, mode)
+	}
+	sort.Sort(byResultKey{results, keys})
+}
+
+type byResultKey struct {
+	entries []DocEntry
+	keys    []resultKey
+}
+
+func (b byResultKey) Len() int           { return len(b.entries) }
+func (b byResultKey) Less(i, j int) bool { return b.keys[i].less(b.keys[j]) }
+func (b byResultKey) Swap(i, j int) {
+	b.entries[i], b.entries[j] = b.entries[j], b.entries[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
+
+// searchCursor is the decoded form of a search_doc cursor: the key of the last result returned
+// and how many results came before it. Resuming after a key rather than at an offset means a
+// refreshed index that gained or lost entries earlier in the order neither repeats nor skips
+// results.
+type searchCursor struct {
+	Search   string    `json:"s"`
+	After    resultKey `json:"a"`
+	Position int       `json:"o"`
+}
+
+var errCursorMismatch = errors.New("cursor belongs to a different search; repeat the search without cursor")
+
+// searchFingerprint identifies the search a cursor was issued for.
+func searchFingerprint(lang, query string, opts SearchOptions) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{lang, query, opts.Mode, opts.Kind, strings.ToLower(opts.Type), opts.PathPrefix, opts.Revision}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// encodeCursor returns the opaque cursor resuming a search after last, the position-th result.
+func encodeCursor(fingerprint string, last DocEntry, query, mode string, position int) string {
+	data, err := json.Marshal(searchCursor{Search: fingerprint, After: keyOf(last, query, mode), Position: position})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor and checks that it was issued for the search fingerprint.
+func decodeCursor(cursor, fingerprint string) (*searchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var c searchCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	if c.Search != fingerprint {
+		return nil, errCursorMismatch
+	}
+	c.After.Fold = strings.ToLower(c.After.Name)
+	return &c, nil
+}
+
+// seekCursor returns the index of the first result ordered after the cursor.
+func seekCursor(results []DocEntry, c *searchCursor, query, mode string) int {
+	return sort.Search(len(results), func(i int) bool {
+		return c.After.less(keyOf(results[i], query, mode))
+	})
+}
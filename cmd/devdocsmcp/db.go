@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"devdocsmcp/internal/store"
+)
+
+// runDB implements the 'db' maintenance command for the metadata store.
+func runDB(args []string) {
+	const usage = "Error: usage: devdocsmcp db inspect|compact|repair [-path <file>]"
+	if len(args) < 1 {
+		log.Fatal(usage)
+	}
+
+	dbCmd := flag.NewFlagSet("db "+args[0], flag.ExitOnError)
+	path := dbCmd.String("path", metadataPath(), "Path to the metadata store")
+	dbCmd.Parse(args[1:])
+
+	switch args[0] {
+	case "inspect":
+		info, err := store.Inspect(*path)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		out, _ := json.MarshalIndent(info, "", "  ")
+		fmt.Println(string(out))
+	case "compact":
+		before := fileSize(*path)
+		if err := store.Compact(*path); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("Compacted %s: %d -> %d bytes\n", *path, before, fileSize(*path))
+	case "repair":
+		problems, err := store.Repair(*path)
+		for _, problem := range problems {
+			fmt.Println("  " + problem)
+		}
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if len(problems) == 0 {
+			fmt.Printf("%s is consistent; nothing to repair.\n", *path)
+		} else {
+			fmt.Printf("Rebuilt %s from its readable keys; the damaged file was kept as %s.corrupt\n", *path, *path)
+		}
+	default:
+		log.Fatal(usage)
+	}
+}
+
+func fileSize(path string) int64 {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
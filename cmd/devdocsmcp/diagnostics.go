@@ -0,0 +1,481 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"devdocsmcp/internal/cachestore"
+	"devdocsmcp/internal/config"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxLogLines is how many recent log lines are kept for diagnostics.
+const maxLogLines = 500
+
+// redacted replaces secrets in diagnostics.
+const redacted = "[REDACTED]"
+
+// Diagnostics is a sanitized fingerprint of the environment, for bug reports: secrets are
+// replaced by redacted wherever they appear.
+type Diagnostics struct {
+	GeneratedAt string            `json:"generated_at"`
+	Build       BuildInfo         `json:"build"`
+	Runtime     RuntimeInfo       `json:"runtime"`
+	Settings    ServerSettings    `json:"settings"`
+	ConfigPath  string            `json:"config_path,omitempty"`
+	ConfigError string            `json:"config_error,omitempty"`
+	Config      *config.Config    `json:"config,omitempty"`
+	Environment map[string]string `json:"environment"`
+	Upstreams   []UpstreamStatus  `json:"upstreams"`
+	// Peers are the servers found on the local network with -peers.
+	Peers []UpstreamStatus `json:"peers,omitempty"`
+	Cache CacheStats       `json:"cache"`
+	// Latency and SlowCalls are empty outside a running server.
+	Latency   []ToolLatency `json:"latency"`
+	SlowCalls []SlowCall    `json:"slow_calls"`
+	Logs      []string      `json:"logs"`
+}
+
+// RuntimeInfo describes the process.
+type RuntimeInfo struct {
+	CPUs       int    `json:"cpus"`
+	Goroutines int    `json:"goroutines"`
+	HeapBytes  uint64 `json:"heap_bytes"`
+	Uptime     string `json:"uptime"`
+}
+
+// ServerSettings are the settings taken from flags rather than the config file.
+type ServerSettings struct {
+	Transports       []string `json:"transports,omitempty"`
+	Langs            []string `json:"langs,omitempty"`
+	Listen           string   `json:"listen,omitempty"`
+	Auth             bool     `json:"auth"`
+	TLS              bool     `json:"tls"`
+	CacheEncrypted   bool     `json:"cache_encrypted"`
+	MaxFetches       int      `json:"max_fetches"`
+	RateLimit        float64  `json:"rate_limit"`
+	RateBurst        int      `json:"rate_burst"`
+	MaxResponseBytes int      `json:"max_response_bytes"`
+	TruncationMarker string   `json:"truncation_marker"`
+	SLO              string   `json:"slo"`
+}
+
+// CacheStats describes the local cache.
+type CacheStats struct {
+	Dir string `json:"dir"`
+	// Files and Bytes count everything under Dir.
+	Files         int   `json:"files"`
+	Bytes         int64 `json:"bytes"`
+	MetadataBytes int64 `json:"metadata_bytes"`
+	// PageBackend is the page cache backend, "files" or "bbolt".
+	PageBackend string        `json:"page_backend"`
+	Indexes     []CachedIndex `json:"indexes"`
+	// ParsedIndexes describes the indexes kept parsed in memory.
+	ParsedIndexes ParsedIndexStats `json:"parsed_indexes"`
+}
+
+// CachedIndex describes the cached index of one documentation set.
+type CachedIndex struct {
+	Lang      string `json:"lang"`
+	Bytes     int64  `json:"bytes"`
+	Modified  string `json:"modified,omitempty"`
+	Entries   int    `json:"entries,omitempty"`
+	FetchedAt string `json:"fetched_at,omitempty"`
+	// Pinned is set for indexes kept by 'entries download'.
+	Pinned bool `json:"pinned,omitempty"`
+	// Pages and PageBytes count the cached pages, SnapshotBytes the saved snapshots.
+	Pages         int   `json:"pages"`
+	PageBytes     int64 `json:"page_bytes"`
+	SnapshotBytes int64 `json:"snapshot_bytes,omitempty"`
+	// Lookups counts hits and misses in the disk cache.
+	Lookups *CacheCounters `json:"lookups,omitempty"`
+}
+
+// logRing keeps the last lines written to the log.
+type logRing struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+// recentLogs receives the standard logger's output (see main).
+var recentLogs = &logRing{lines: make([]string, maxLogLines)}
+
+func (r *logRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		r.lines[r.next] = line
+		r.next = (r.next + 1) % len(r.lines)
+		r.full = r.full || r.next == 0
+	}
+	return len(p), nil
+}
+
+// snapshot returns the kept lines, oldest first.
+func (r *logRing) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]string{}, r.lines[:r.next]...)
+	}
+	return append(append([]string{}, r.lines[r.next:]...), r.lines[:r.next]...)
+}
+
+// collectDiagnostics gathers the diagnostics of this process. cfg is the configuration read
+// from configPath, or nil when reading it failed with configErr.
+func collectDiagnostics(cfg *config.Config, configPath string, configErr error, logs []string) Diagnostics {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	d := Diagnostics{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Build:       buildInfo(),
+		Runtime: RuntimeInfo{
+			CPUs:       runtime.NumCPU(),
+			Goroutines: runtime.NumGoroutine(),
+			HeapBytes:  mem.HeapAlloc,
+			Uptime:     time.Since(startedAt).Round(time.Second).String(),
+		},
+		Settings: ServerSettings{
+			Transports:       activeTransports,
+			Langs:            sortedKeys(servedLanguages()),
+			Listen:           listenAddr,
+			Auth:             authToken != "" || (cfg != nil && len(cfg.Tokens) > 0),
+			TLS:              tlsCertFile != "" || tlsSelfSigned,
+			CacheEncrypted:   cacheCipher.Load() != nil,
+			MaxFetches:       maxFetches,
+			RateLimit:        rateLimit,
+			RateBurst:        rateBurst,
+			MaxResponseBytes: maxResponseBytes,
+			TruncationMarker: truncationMarkerStyle,
+			SLO:              slo.String(),
+		},
+		ConfigPath:  configPath,
+		Config:      redactConfig(cfg),
+		Environment: redactedEnvironment(),
+		Upstreams:   upstreamStatuses(),
+		Peers:       peerStatuses(),
+		Cache:       collectCacheStats(),
+		Latency:     toolLatency.report(),
+		SlowCalls:   toolLatency.recentSlowCalls(),
+		Logs:        []string{},
+	}
+	if configErr != nil {
+		d.ConfigError = configErr.Error()
+	}
+	secrets := knownSecrets(cfg)
+	for i := range d.Upstreams {
+		d.Upstreams[i].URL = secrets.Replace(d.Upstreams[i].URL)
+		d.Upstreams[i].LastError = secrets.Replace(d.Upstreams[i].LastError)
+	}
+	for key, value := range d.Environment {
+		d.Environment[key] = secrets.Replace(value)
+	}
+	if d.Config != nil {
+		for i, base := range d.Config.DocsBaseURLs {
+			d.Config.DocsBaseURLs[i] = secrets.Replace(base)
+		}
+	}
+	for i := range d.SlowCalls {
+		d.SlowCalls[i].Arguments = redactArguments(d.SlowCalls[i].Arguments, secrets)
+		d.SlowCalls[i].Spans = append([]TraceSpan(nil), d.SlowCalls[i].Spans...)
+		for j := range d.SlowCalls[i].Spans {
+			d.SlowCalls[i].Spans[j].Detail = secrets.Replace(d.SlowCalls[i].Spans[j].Detail)
+		}
+	}
+	for _, line := range logs {
+		d.Logs = append(d.Logs, secrets.Replace(line))
+	}
+	return d
+}
+
+// redactConfig returns a copy of cfg without its secrets: token values, bridge headers and
+// bridge environment values. Passwords in URLs are removed by knownSecrets.
+func redactConfig(cfg *config.Config) *config.Config {
+	if cfg == nil {
+		return nil
+	}
+	clean := *cfg
+	clean.DocsBaseURLs = append([]string(nil), cfg.DocsBaseURLs...)
+	clean.Tokens = make([]config.Token, len(cfg.Tokens))
+	for i, token := range cfg.Tokens {
+		token.Token = redacted
+		clean.Tokens[i] = token
+	}
+	clean.Bridges = make([]config.Bridge, len(cfg.Bridges))
+	for i, bridge := range cfg.Bridges {
+		bridge.Headers = redactValues(bridge.Headers)
+		bridge.Env = redactValues(bridge.Env)
+		clean.Bridges[i] = bridge
+	}
+	return &clean
+}
+
+func redactValues(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	clean := make(map[string]string, len(values))
+	for key := range values {
+		clean[key] = redacted
+	}
+	return clean
+}
+
+// redactArguments returns a copy of a tool call's arguments with secrets removed from the
+// string values.
+func redactArguments(args map[string]any, secrets *strings.Replacer) map[string]any {
+	if args == nil {
+		return nil
+	}
+	clean := make(map[string]any, len(args))
+	for key, value := range args {
+		if s, ok := value.(string); ok {
+			value = secrets.Replace(s)
+		}
+		clean[key] = value
+	}
+	return clean
+}
+
+// redactedEnvironment returns the DEVDOCSMCP_* environment variables, with the values of
+// those holding secrets redacted.
+func redactedEnvironment() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, "DEVDOCSMCP_") {
+			continue
+		}
+		if key == authTokenEnv || key == cacheKeyEnv || key == peerTokenEnv {
+			value = redacted
+		}
+		env[key] = value
+	}
+	return env
+}
+
+// knownSecrets returns a replacer removing every secret this process knows of from text.
+func knownSecrets(cfg *config.Config) *strings.Replacer {
+	secrets := []string{authToken, os.Getenv(authTokenEnv), os.Getenv(cacheKeyEnv), peerToken, os.Getenv(peerTokenEnv)}
+	urls := strings.Split(docsBaseURLFlag+","+os.Getenv(docsBaseURLEnv), ",")
+	for _, status := range upstreamStatuses() {
+		urls = append(urls, status.URL)
+	}
+	if cfg != nil {
+		urls = append(urls, cfg.DocsBaseURLs...)
+		for _, token := range cfg.Tokens {
+			secrets = append(secrets, token.Token)
+		}
+		for _, bridge := range cfg.Bridges {
+			for _, value := range bridge.Headers {
+				secrets = append(secrets, value)
+			}
+			for _, value := range bridge.Env {
+				secrets = append(secrets, value)
+			}
+		}
+	}
+	for _, raw := range urls {
+		if u, err := url.Parse(strings.TrimSpace(raw)); err == nil {
+			if password, ok := u.User.Password(); ok {
+				secrets = append(secrets, password)
+			}
+		}
+	}
+	var pairs []string
+	for _, secret := range secrets {
+		// Very short values would redact ordinary words
+		if len(secret) >= 6 {
+			pairs = append(pairs, secret, redacted)
+		}
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// collectCacheStats measures the cache directory and breaks it down by documentation set.
+func collectCacheStats() CacheStats {
+	stats := CacheStats{Dir: cacheDir(), MetadataBytes: fileSize(metadataPath()), PageBackend: cacheBackend(), Indexes: []CachedIndex{}, ParsedIndexes: parsedIndexes.stats()}
+	filepath.WalkDir(stats.Dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			stats.Files++
+			stats.Bytes += info.Size()
+		}
+		return nil
+	})
+
+	langs := make(map[string]bool)
+	for _, sub := range []string{"indexes", "snapshots"} {
+		dirs, _ := os.ReadDir(filepath.Join(stats.Dir, sub))
+		for _, dir := range dirs {
+			if dir.IsDir() {
+				langs[dir.Name()] = true
+			}
+		}
+	}
+	pageLangs, _ := pageStore().Langs()
+	for _, lang := range pageLangs {
+		langs[lang] = true
+	}
+	counters := lookupCacheCounters()
+	for lang := range langs {
+		index := CachedIndex{Lang: lang, Bytes: fileSize(filepath.Join(indexCacheDir(lang), "index.json")), Pinned: isIndexPinned(lang)}
+		if info, err := os.Stat(filepath.Join(indexCacheDir(lang), "index.json")); err == nil {
+			index.Modified = info.ModTime().UTC().Format(time.RFC3339)
+		}
+		if record, ok := lookupDocset(lang); ok {
+			index.Entries = record.Entries
+			index.FetchedAt = record.FetchedAt.UTC().Format(time.RFC3339)
+		}
+		pageStore().Walk(lang, func(page cachestore.Info) error {
+			index.Pages++
+			index.PageBytes += page.Size
+			return nil
+		})
+		index.SnapshotBytes, _ = dirSize(snapshotsDir(lang))
+		if c, ok := counters[lang]; ok {
+			index.Lookups = &c
+		}
+		stats.Indexes = append(stats.Indexes, index)
+	}
+	sort.Slice(stats.Indexes, func(i, j int) bool { return stats.Indexes[i].Lang < stats.Indexes[j].Lang })
+	return stats
+}
+
+func handleServerDiagnostics(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !hasFullAccess(ctx) {
+		return mcp.NewToolResultError("server_diagnostics requires the server's admin token."), nil
+	}
+	return newJSONResult(collectDiagnostics(currentConfig(), configFilePath(serverConfigPath), nil, recentLogs.snapshot()), nil), nil
+}
+
+// configFilePath returns the config file read for path, which is empty for the default.
+func configFilePath(path string) string {
+	if path == "" {
+		path, _ = config.DefaultPath()
+	}
+	return path
+}
+
+// runDiagnostics implements the 'diagnostics bundle' command.
+func runDiagnostics(args []string) {
+	const usage = "Error: usage: devdocsmcp diagnostics bundle [-out <file.zip>] [-config <file>] [-log <file>]"
+	if len(args) < 1 || args[0] != "bundle" {
+		log.Fatal(usage)
+	}
+
+	bundleCmd := flag.NewFlagSet("diagnostics bundle", flag.ExitOnError)
+	out := bundleCmd.String("out", "devdocsmcp-diagnostics-"+time.Now().UTC().Format("20060102-150405")+".zip", "Path of the zip file to write")
+	configPath := bundleCmd.String("config", "", "Path to the config file (default: $DEVDOCSMCP_CONFIG or the user config directory)")
+	logFile := bundleCmd.String("log", "", "A server log file (e.g. its redirected stderr) whose last lines are included")
+	bundleCmd.Parse(args[1:])
+
+	cfg, configErr := readConfig(*configPath)
+	if configErr == nil {
+		if err := initUpstreams(cfg.DocsBaseURLs); err != nil {
+			configErr = err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamProbeTimeout)
+	probeUpstreams(ctx)
+	cancel()
+
+	logs := recentLogs.snapshot()
+	if *logFile != "" {
+		lines, err := tailFile(*logFile, maxLogLines)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		logs = append(lines, logs...)
+	}
+	if err := writeDiagnosticsBundle(*out, collectDiagnostics(cfg, configFilePath(*configPath), configErr, logs)); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("Wrote %s; secrets were redacted, but please review it before attaching it to a bug report.\n", *out)
+}
+
+// tailFile returns the last n lines of the file at path.
+func tailFile(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+	defer f.Close()
+	ring := &logRing{lines: make([]string, n)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		ring.Write(scanner.Bytes())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+	return ring.snapshot(), nil
+}
+
+// writeDiagnosticsBundle writes d to a zip file at path, one file per section.
+func writeDiagnosticsBundle(path string, d Diagnostics) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create diagnostics bundle: %w", err)
+	}
+	zw := zip.NewWriter(f)
+	sections := []struct {
+		name string
+		data any
+	}{
+		{"version.json", map[string]any{"generated_at": d.GeneratedAt, "build": d.Build, "runtime": d.Runtime}},
+		{"config.json", map[string]any{"path": d.ConfigPath, "config": d.Config, "settings": d.Settings, "environment": d.Environment, "upstreams": d.Upstreams}},
+		{"cache.json", d.Cache},
+	}
+	if d.ConfigError != "" {
+		sections[1].data.(map[string]any)["error"] = d.ConfigError
+	}
+	for _, section := range sections {
+		data, err := json.MarshalIndent(section.data, "", "  ")
+		if err == nil {
+			err = writeZipFile(zw, section.name, data)
+		}
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write diagnostics bundle: %w", err)
+		}
+	}
+	if err := writeZipFile(zw, "logs.txt", []byte(strings.Join(d.Logs, "\n")+"\n")); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write diagnostics bundle: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write diagnostics bundle: %w", err)
+	}
+	return f.Close()
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"devdocsmcp/internal/docs/manifest"
+)
+
+// DocInfo reports metadata and statistics about a documentation set.
+type DocInfo struct {
+	Slug          string         `json:"slug"`
+	Name          string         `json:"name"`
+	Version       string         `json:"version,omitempty"`
+	Release       string         `json:"release,omitempty"`
+	Mtime         int64          `json:"mtime,omitempty"`
+	UpdatedAt     string         `json:"updated_at,omitempty"`
+	DBSize        int64          `json:"db_size,omitempty"`
+	Entries       int            `json:"entries"`
+	Types         []DocTypeCount `json:"types"`
+	CachedLocally bool           `json:"cached_locally"`
+	// EntriesOnly is set when the index was downloaded for offline search while pages are
+	// still read from upstream.
+	EntriesOnly bool   `json:"entries_only,omitempty"`
+	LastFetched string `json:"last_fetched,omitempty"`
+}
+
+// DocTypeCount is the number of entries of one entry type.
+type DocTypeCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// GetDocInfo combines the docset's manifest record with statistics from its index.
+func GetDocInfo(ctx context.Context, langSlug string) (*DocInfo, error) {
+	docsets, err := loadManifest()
+	if err != nil {
+		return nil, err
+	}
+	docset, ok := manifest.Find(docsets, langSlug)
+	if !ok {
+		return nil, fmt.Errorf("documentation set %s is not listed in the devdocs manifest", langSlug)
+	}
+
+	doc, err := fetchIndex(ctx, langSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &DocInfo{
+		Slug:          docset.Slug,
+		Name:          docset.Name,
+		Version:       docset.Version,
+		Release:       docset.Release,
+		Mtime:         docset.Mtime,
+		DBSize:        docset.DBSize,
+		Entries:       len(doc.Entries),
+		Types:         countEntryTypes(doc.Entries),
+		CachedLocally: isCachedLocally(langSlug),
+		EntriesOnly:   isIndexPinned(langSlug),
+	}
+	if record, ok := lookupDocset(langSlug); ok {
+		info.LastFetched = record.FetchedAt.Format(time.RFC3339)
+	}
+	if docset.Mtime > 0 {
+		info.UpdatedAt = time.Unix(docset.Mtime, 0).UTC().Format(time.RFC3339)
+	}
+	return info, nil
+}
+
+// ListEntryTypes returns the entry types of a documentation set with their entry counts.
+// Types are taken from index.json, or counted from the entries when the index omits them.
+func ListEntryTypes(ctx context.Context, langSlug string) ([]DocType, error) {
+	doc, err := fetchIndex(ctx, langSlug)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Types) > 0 {
+		return doc.Types, nil
+	}
+	counts := countEntryTypes(doc.Entries)
+	types := make([]DocType, 0, len(counts))
+	for _, t := range counts {
+		types = append(types, DocType{Name: t.Name, Count: t.Count})
+	}
+	return types, nil
+}
+
+// countEntryTypes counts entries per type, most common first.
+func countEntryTypes(entries []DocEntry) []DocTypeCount {
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		counts[entry.Type]++
+	}
+	types := make([]DocTypeCount, 0, len(counts))
+	for name, count := range counts {
+		types = append(types, DocTypeCount{Name: name, Count: count})
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].Count != types[j].Count {
+			return types[i].Count > types[j].Count
+		}
+		return types[i].Name < types[j].Name
+	})
+	return types
+}
+
+// isCachedLocally reports whether the docset's index is served locally: from the disk cache,
+// while it is fresh or pinned, or from a copy in the mirror directory.
+func isCachedLocally(langSlug string) bool {
+	if info, err := os.Stat(filepath.Join(indexCacheDir(langSlug), "index.json")); err == nil && (time.Since(info.ModTime()) < cacheTTL || isIndexPinned(langSlug) || offline) {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(mirrorDir, langSlug, "index.json"))
+	return err == nil
+}
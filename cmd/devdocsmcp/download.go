@@ -0,0 +1,218 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"devdocsmcp/internal/docs/mirror"
+)
+
+// searchIndexMarker is written into a full-text index directory once the index is complete, so
+// an interrupted build is redone by the next download.
+const searchIndexMarker = "indexed"
+
+// searchIndexFormat is recorded in the marker and bumped whenever the index mapping changes, so
+// indexes built by an older version count as missing and are rebuilt by the next download.
+const searchIndexFormat = 8
+
+// runDownload implements the 'download' command: it downloads whole docsets (index.json,
+// db.json and every page) into a local mirror and builds their full-text indexes, for serving
+// them with 'server -offline'.
+func runDownload(args []string) {
+	cmd := flag.NewFlagSet("download", flag.ExitOnError)
+	langs := cmd.String("lang", "", "Comma-separated list of language slugs or bundles to download")
+	dest := cmd.String("dest", defaultMirrorDir(), "Directory to store the docsets in (serve it with 'server -offline -mirror-dir <dir>')")
+	configPath := cmd.String("config", "", "Path to the config file (default: $DEVDOCSMCP_CONFIG or the user config directory)")
+	cmd.Parse(args)
+
+	if err := loadConfig(*configPath); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *langs == "" {
+		log.Fatal("Error: -lang is required for the download command.")
+	}
+	slugs := currentConfig().ExpandBundles(strings.Split(*langs, ","))
+	if err := validateLangs(slugs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if failed := syncDownloads(*dest, slugs); failed > 0 {
+		log.Fatalf("Error: %d of %d docsets failed.", failed, len(slugs))
+	}
+}
+
+// runUpdate implements the 'update' command: it brings docsets downloaded with 'download' up
+// to date, fetching only those whose revision changed upstream.
+func runUpdate(args []string) {
+	cmd := flag.NewFlagSet("update", flag.ExitOnError)
+	langs := cmd.String("lang", "", "Comma-separated list of language slugs or bundles to update (default: every docset in -dest)")
+	dest := cmd.String("dest", defaultMirrorDir(), "Directory holding the downloaded docsets")
+	configPath := cmd.String("config", "", "Path to the config file (default: $DEVDOCSMCP_CONFIG or the user config directory)")
+	cmd.Parse(args)
+
+	if err := loadConfig(*configPath); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	var slugs []string
+	if *langs != "" {
+		slugs = currentConfig().ExpandBundles(strings.Split(*langs, ","))
+		if err := validateLangs(slugs); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	} else {
+		mirrored, err := mirror.NewMirror(*dest, docsBaseURL(), nil).Mirrored()
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if len(mirrored) == 0 {
+			fmt.Printf("No docsets downloaded in %s; run 'download -lang <languages>' first.\n", *dest)
+			return
+		}
+		slugs = mirrored
+	}
+	if failed := syncDownloads(*dest, slugs); failed > 0 {
+		log.Fatalf("Error: %d of %d docsets failed.", failed, len(slugs))
+	}
+}
+
+// syncDownloads downloads the docsets whose revision in the devdocs manifest differs from the
+// copy in dest, and brings their full-text indexes up to date unless this is the minimal
+// build, removing those of docsets no longer in dest. It returns how many failed.
+func syncDownloads(dest string, slugs []string) int {
+	m := mirror.NewMirror(dest, docsBaseURL(), slugs)
+	m.ManifestURL = manifestURL(docsBaseURL())
+	m.Cipher = cacheCipher.Load()
+	result, err := m.Sync()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	failed := 0
+	for _, slug := range slugs {
+		if err := result.Failed[slug]; err != nil {
+			log.Printf("Failed to download %s: %v\n", slug, err)
+			failed++
+			continue
+		}
+		changes, updated := result.Changes[slug]
+		report, err := indexDownload(dest, slug, changes, updated)
+		if err != nil {
+			log.Printf("Failed to index %s: %v\n", slug, err)
+			failed++
+			continue
+		}
+		fmt.Println(report)
+	}
+	pruneDownloadIndexes(dest)
+	return failed
+}
+
+// indexDownload is the hook run after a docset was synced into dest: unless this is the
+// minimal build, it brings the docset's full-text index up to date with the changes of the
+// sync (updated is false when the docset was already current) and describes the outcome.
+func indexDownload(dest, slug string, changes mirror.Changes, updated bool) (string, error) {
+	if !fullTextIndexing {
+		if updated {
+			return fmt.Sprintf("Downloaded %s to %s: %s (no full-text index in this minimal build)", slug, filepath.Join(dest, slug), changes), nil
+		}
+		return fmt.Sprintf("%s is up to date", slug), nil
+	}
+	// An index built with another analyzer than the one configured now is rebuilt
+	indexed := hasSearchIndex(dest, slug) && searchIndexAnalyzer(dest, slug) == indexAnalyzer(slug)
+	if !updated && indexed {
+		return fmt.Sprintf("%s is up to date", slug), nil
+	}
+	if updated && indexed {
+		if err := updateSearchIndex(dest, slug, changes.Written, changes.Deleted); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Updated %s: %s; full-text index updated with %d pages, %d removed", slug, changes, len(changes.Written), len(changes.Deleted)), nil
+	}
+	pages, err := buildSearchIndex(dest, slug)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Downloaded %s to %s (%d pages, full-text index in %s)", slug, filepath.Join(dest, slug), pages, searchIndexDir(dest, slug)), nil
+}
+
+// pruneDownloadIndexes removes the full-text indexes left in dest by docsets that are no
+// longer downloaded there, logging the outcome.
+func pruneDownloadIndexes(dest string) {
+	removed, err := pruneSearchIndexes(dest)
+	for _, slug := range removed {
+		fmt.Printf("Removed the full-text index of %s, which is no longer downloaded in %s\n", slug, dest)
+	}
+	if err != nil {
+		log.Printf("Failed to remove stale full-text indexes in %s: %v\n", dest, err)
+	}
+}
+
+// searchIndexRoot returns the directory holding the full-text indexes of the docsets
+// downloaded into dir, one shard per docset (see indexer.Shards). It is kept outside the
+// docset directories, whose layout matches the upstream host.
+func searchIndexRoot(dir string) string {
+	return filepath.Join(dir, ".search")
+}
+
+// searchIndexDir returns the directory of the full-text index of a docset downloaded into dir.
+func searchIndexDir(dir, slug string) string {
+	return filepath.Join(searchIndexRoot(dir), slug)
+}
+
+// hasSearchIndex reports whether a docset downloaded into dir has a complete full-text index
+// of the current format.
+func hasSearchIndex(dir, slug string) bool {
+	data, err := os.ReadFile(filepath.Join(searchIndexDir(dir, slug), searchIndexMarker))
+	return err == nil && strings.HasPrefix(string(data), fmt.Sprintf("format %d\n", searchIndexFormat))
+}
+
+// searchIndexAnalyzer returns the analyzer of the page text recorded in the marker of the
+// full-text index of a docset downloaded into dir, or "" if there is none.
+func searchIndexAnalyzer(dir, slug string) string {
+	marker, _ := readSearchIndexMarker(dir, slug)
+	return marker.analyzer
+}
+
+// searchIndexRecord is what the marker of a full-text index records.
+type searchIndexRecord struct {
+	format   int
+	analyzer string
+	// written is when the index was last built or updated.
+	written time.Time
+}
+
+// readSearchIndexMarker reads the marker of the full-text index of a docset downloaded into
+// dir, reporting whether there is one.
+func readSearchIndexMarker(dir, slug string) (searchIndexRecord, bool) {
+	var record searchIndexRecord
+	data, err := os.ReadFile(filepath.Join(searchIndexDir(dir, slug), searchIndexMarker))
+	if err != nil {
+		return record, false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if format, ok := strings.CutPrefix(line, "format "); ok {
+			record.format, _ = strconv.Atoi(format)
+		} else if analyzer, ok := strings.CutPrefix(line, "analyzer "); ok {
+			record.analyzer = analyzer
+		} else if written, err := time.Parse(time.RFC3339, line); err == nil {
+			record.written = written
+		}
+	}
+	return record, true
+}
+
+// markSearchIndex records the full-text index of a docset downloaded into dir, built with the
+// given analyzer, as complete.
+func markSearchIndex(dir, slug, analyzer string) error {
+	marker := fmt.Sprintf("format %d\nanalyzer %s\n%s\n", searchIndexFormat, analyzer, time.Now().UTC().Format(time.RFC3339))
+	if err := os.WriteFile(filepath.Join(searchIndexDir(dir, slug), searchIndexMarker), []byte(marker), 0644); err != nil {
+		return fmt.Errorf("failed to record the full-text index of %s: %w", slug, err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"devdocsmcp/internal/atrest"
+)
+
+// cacheKeyEnv holds the key that encrypts cached indexes and the mirror at rest: 32 bytes as
+// base64 or hex, or "keychain" to read it from the OS keychain.
+const cacheKeyEnv = "DEVDOCSMCP_CACHE_KEY"
+
+// cacheCipher encrypts files written to the cache and the mirror. It holds nil when
+// encryption at rest is disabled, and is replaced by a config reload while tools are running.
+var cacheCipher atomic.Pointer[atrest.Cipher]
+
+// plaintextIndexWarning makes warnPlaintextIndex warn once per process.
+var plaintextIndexWarning sync.Once
+
+// initCacheEncryption enables encryption at rest when a key is configured.
+func initCacheEncryption() error {
+	cipher, err := cacheCipherFromEnv()
+	if err != nil {
+		return err
+	}
+	cacheCipher.Store(cipher)
+	return nil
+}
+
+// warnPlaintextIndex warns, once, that the full-text indexes written under dir are not
+// encrypted although encryption at rest is enabled: bleve keeps them in its own files, which
+// hold the text of every indexed page.
+func warnPlaintextIndex(dir string) {
+	if cacheCipher.Load() == nil {
+		return
+	}
+	plaintextIndexWarning.Do(func() {
+		log.Printf("Warning: %s encrypts the downloaded pages but not their full-text indexes in %s, which hold the page text in plaintext; keep that directory on an encrypted file system or use the minimal build, which doesn't index\n", cacheKeyEnv, searchIndexRoot(dir))
+	})
+}
+
+// cacheCipherFromEnv returns the cipher of the key configured in $DEVDOCSMCP_CACHE_KEY, or nil
+// when none is.
+func cacheCipherFromEnv() (*atrest.Cipher, error) {
+	value := os.Getenv(cacheKeyEnv)
+	if value == "" {
+		return nil, nil
+	}
+	var key []byte
+	var err error
+	if value == "keychain" {
+		key, err = atrest.KeychainKey()
+	} else {
+		key, err = atrest.ParseKey(value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", cacheKeyEnv, err)
+	}
+	return atrest.New(key)
+}
+
+// readCacheFile reads a file written by writeCacheFile, decrypting it if encryption at rest is
+// enabled. Files that don't match the current setting (plain files while encryption is on, or
+// encrypted files while it is off) are reported as errors so they are treated as cache misses.
+func readCacheFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return openCacheData(path, data)
+}
+
+// sealCacheData encrypts data for the cache if encryption at rest is enabled.
+func sealCacheData(data []byte) []byte {
+	if cipher := cacheCipher.Load(); cipher != nil {
+		return cipher.Seal(data)
+	}
+	return data
+}
+
+// openCacheData decrypts data read from the cache entry name, like readCacheFile.
+func openCacheData(name string, data []byte) ([]byte, error) {
+	if cipher := cacheCipher.Load(); cipher != nil {
+		return cipher.Open(data)
+	}
+	if atrest.IsSealed(data) {
+		return nil, fmt.Errorf("%s is encrypted; set %s to read it", name, cacheKeyEnv)
+	}
+	return data, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"devdocsmcp/internal/docs/manifest"
+)
+
+// pinFile marks a cached index as downloaded with 'entries download': it is used at any age,
+// so the docset can be searched and navigated offline while its pages are still read from
+// upstream on demand.
+const pinFile = "pinned.json"
+
+// PinnedIndex describes an index downloaded in entries-only mode.
+type PinnedIndex struct {
+	Slug         string `json:"slug"`
+	Version      string `json:"version,omitempty"`
+	Mtime        int64  `json:"mtime,omitempty"`
+	Entries      int    `json:"entries"`
+	DownloadedAt string `json:"downloaded_at"`
+}
+
+// isIndexPinned reports whether the index of a documentation set was downloaded in
+// entries-only mode.
+func isIndexPinned(langSlug string) bool {
+	_, err := os.Stat(filepath.Join(indexCacheDir(langSlug), pinFile))
+	return err == nil
+}
+
+// DownloadEntries downloads the current index of a documentation set, without its pages, and
+// pins it in the index cache.
+func DownloadEntries(langSlug string) (*PinnedIndex, error) {
+	doc, err := downloadIndex(langSlug)
+	if err != nil {
+		return nil, err
+	}
+	pin := &PinnedIndex{
+		Slug:         langSlug,
+		Entries:      len(doc.Entries),
+		DownloadedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if docsets, err := loadManifest(); err == nil {
+		if docset, ok := manifest.Find(docsets, langSlug); ok {
+			pin.Version, pin.Mtime = docset.Version, docset.Mtime
+		}
+	}
+	data, err := json.Marshal(pin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode pin of %s: %w", langSlug, err)
+	}
+	if err := writeCacheFile(filepath.Join(indexCacheDir(langSlug), pinFile), data); err != nil {
+		return nil, fmt.Errorf("failed to pin index of %s: %w", langSlug, err)
+	}
+	return pin, nil
+}
+
+// ListPinnedIndexes returns the indexes downloaded in entries-only mode, sorted by slug.
+func ListPinnedIndexes() ([]PinnedIndex, error) {
+	dirs, err := os.ReadDir(filepath.Join(cacheDir(), "indexes"))
+	if errors.Is(err, os.ErrNotExist) {
+		return []PinnedIndex{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cached indexes: %w", err)
+	}
+	pins := []PinnedIndex{}
+	for _, dir := range dirs {
+		data, err := readCacheFile(filepath.Join(indexCacheDir(dir.Name()), pinFile))
+		if err != nil {
+			continue
+		}
+		var pin PinnedIndex
+		if err := json.Unmarshal(data, &pin); err != nil {
+			log.Printf("Ignoring corrupt pin of %s: %v\n", dir.Name(), err)
+			continue
+		}
+		pins = append(pins, pin)
+	}
+	sort.Slice(pins, func(i, j int) bool { return pins[i].Slug < pins[j].Slug })
+	return pins, nil
+}
+
+// unpinIndex turns an entries-only download back into an ordinary cached index, which expires
+// after cacheTTL.
+func unpinIndex(langSlug string) error {
+	err := os.Remove(filepath.Join(indexCacheDir(langSlug), pinFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("the index of %s was not downloaded with 'entries download'", langSlug)
+	}
+	return err
+}
+
+// runEntries implements the 'entries' command.
+func runEntries(args []string) {
+	if len(args) < 1 || (args[0] != "download" && args[0] != "list" && args[0] != "remove") {
+		log.Fatal("Error: usage: devdocsmcp entries download|list|remove [-lang <comma_separated_languages>]")
+	}
+
+	cmd := flag.NewFlagSet("entries "+args[0], flag.ExitOnError)
+	langs := cmd.String("lang", "", "Comma-separated list of language slugs or bundles")
+	configPath := cmd.String("config", "", "Path to the config file (default: $DEVDOCSMCP_CONFIG or the user config directory)")
+	cmd.Parse(args[1:])
+
+	if args[0] == "list" {
+		pins, err := ListPinnedIndexes()
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if len(pins) == 0 {
+			fmt.Println("No entries-only downloads.")
+		}
+		for _, pin := range pins {
+			fmt.Printf("  %s  %s  %d entries  downloaded %s\n", pin.Slug, pin.Version, pin.Entries, pin.DownloadedAt)
+		}
+		return
+	}
+
+	if err := loadConfig(*configPath); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *langs == "" {
+		log.Fatalf("Error: -lang is required for the entries %s command.", args[0])
+	}
+	slugs := currentConfig().ExpandBundles(strings.Split(*langs, ","))
+	if args[0] == "download" {
+		if err := validateLangs(slugs); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	for _, slug := range slugs {
+		if args[0] == "remove" {
+			if err := unpinIndex(slug); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			fmt.Printf("Unpinned %s\n", slug)
+			continue
+		}
+		pin, err := DownloadEntries(slug)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("Downloaded the index of %s (%d entries); pages are read from %s on demand\n", slug, pin.Entries, docsBaseURL())
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// EntryURL is the canonical devdocs.io link of an index entry.
+type EntryURL struct {
+	Lang string `json:"lang"`
+	Path string `json:"path"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Exact is false when the path is not an entry itself but lies on a page that has entries.
+	Exact bool `json:"exact"`
+}
+
+// GetEntryURL returns the devdocs.io URL of an entry after checking that the entry exists in
+// the documentation set's index.
+func GetEntryURL(ctx context.Context, langSlug, entryPath string) (*EntryURL, error) {
+	doc, err := fetchIndex(ctx, langSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	pagePath := stripFragment(entryPath)
+	var pageEntry *DocEntry
+	for i, entry := range doc.Entries {
+		if entry.Path == entryPath {
+			return &EntryURL{Lang: langSlug, Path: entryPath, Name: entry.Name, URL: devdocsURL + langSlug + "/" + entryPath, Exact: true}, nil
+		}
+		if pageEntry == nil && stripFragment(entry.Path) == pagePath {
+			pageEntry = &doc.Entries[i]
+		}
+	}
+	if pageEntry == nil {
+		return nil, fmt.Errorf("entry %q not found in the %s index; use search_doc to find its path", entryPath, langSlug)
+	}
+	return &EntryURL{Lang: langSlug, Path: entryPath, Name: pageEntry.Name, URL: devdocsURL + langSlug + "/" + entryPath}, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxFacetValues caps the values listed per facet; the rest are summed up in Other.
+const maxFacetValues = 20
+
+// FacetCount is how many matches of a search have one value of a facet.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facet counts the matches of a search by the values of a field, most frequent first. Other
+// counts the matches with values beyond the first maxFacetValues.
+type Facet struct {
+	Values []FacetCount `json:"values"`
+	Other  int          `json:"other,omitempty"`
+}
+
+// SearchFacets breaks down all the matches of a search, not only the returned page, so a
+// caller can narrow the search with the type argument or a single lang.
+type SearchFacets struct {
+	Type Facet `json:"type"`
+	Lang Facet `json:"lang"`
+}
+
+// searchFacets counts results by entry type and docset; lang is the docset of results
+// without their own (those of a single-docset search). Entries without a type aren't counted
+// in the type facet.
+func searchFacets(results []DocEntry, lang string) *SearchFacets {
+	types := make(map[string]int)
+	langs := make(map[string]int)
+	for _, entry := range results {
+		if entry.Type != "" {
+			types[entry.Type]++
+		}
+		if entry.Lang != "" {
+			langs[entry.Lang]++
+		} else {
+			langs[lang]++
+		}
+	}
+	return &SearchFacets{Type: newFacet(types), Lang: newFacet(langs)}
+}
+
+func newFacet(counts map[string]int) Facet {
+	values := make([]FacetCount, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	facet := Facet{Values: values}
+	if len(values) > maxFacetValues {
+		facet.Values = values[:maxFacetValues]
+		for _, value := range values[maxFacetValues:] {
+			facet.Other += value.Count
+		}
+	}
+	return facet
+}
+
+// String lists the counts of a facet, e.g. "Method 30, Guide 12, 3 other".
+func (f Facet) String() string {
+	parts := make([]string, 0, len(f.Values)+1)
+	for _, value := range f.Values {
+		parts = append(parts, fmt.Sprintf("%s %d", value.Value, value.Count))
+	}
+	if f.Other > 0 {
+		parts = append(parts, fmt.Sprintf("%d other", f.Other))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"devdocsmcp/internal/docs/mirror"
+)
+
+const (
+	// maxContentHits caps the pages a search takes from a full-text index.
+	maxContentHits = 200
+	// searchIndexTimeout bounds how long a search waits for a download writing the index.
+	searchIndexTimeout = time.Second
+)
+
+// Settings of full-text index builds and updates, set by the index command's -batch-size and
+// -workers flags.
+var (
+	// indexBatchSize is how many pages are written to a full-text index at once.
+	indexBatchSize = 500
+	// indexWorkers is how many pages have their text extracted in parallel.
+	indexWorkers = runtime.NumCPU()
+)
+
+// runIndex implements the 'index' command: it rebuilds the full-text indexes of docsets
+// downloaded with 'download' from their pages, e.g. after an interrupted or failed build.
+// Downloads index their docsets themselves.
+func runIndex(args []string) {
+	cmd := flag.NewFlagSet("index", flag.ExitOnError)
+	langs := cmd.String("lang", "", "Comma-separated list of language slugs or bundles to index (default: every docset in -dest)")
+	dest := cmd.String("dest", defaultMirrorDir(), "Directory holding the downloaded docsets")
+	configPath := cmd.String("config", "", "Path to the config file (default: $DEVDOCSMCP_CONFIG or the user config directory)")
+	cmd.IntVar(&indexBatchSize, "batch-size", indexBatchSize, "Number of pages written to the full-text index at once")
+	cmd.IntVar(&indexWorkers, "workers", indexWorkers, "Number of pages whose text is extracted in parallel")
+	cmd.Parse(args)
+
+	if err := loadConfig(*configPath); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if indexBatchSize < 1 || indexWorkers < 1 {
+		log.Fatal("Error: -batch-size and -workers must be at least 1.")
+	}
+	if !fullTextIndexing {
+		log.Fatal("Error: full-text indexing is not included in this minimal build.")
+	}
+	pruneDownloadIndexes(*dest)
+	var slugs []string
+	if *langs != "" {
+		slugs = currentConfig().ExpandBundles(strings.Split(*langs, ","))
+		if err := validateLangs(slugs); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	} else {
+		mirrored, err := mirror.NewMirror(*dest, docsBaseURL(), nil).Mirrored()
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if len(mirrored) == 0 {
+			fmt.Printf("No docsets downloaded in %s; run 'download -lang <languages>' first.\n", *dest)
+			return
+		}
+		slugs = mirrored
+	}
+
+	failed := 0
+	for _, slug := range slugs {
+		if _, err := os.Stat(filepath.Join(*dest, slug, "index.json")); err != nil {
+			log.Printf("Failed to index %s: not downloaded in %s; run 'download -lang %s -dest %s' first\n", slug, *dest, slug, *dest)
+			failed++
+			continue
+		}
+		pages, err := buildSearchIndex(*dest, slug)
+		if err != nil {
+			log.Printf("Failed to index %s: %v\n", slug, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Indexed %d pages of %s in %s\n", pages, slug, searchIndexDir(*dest, slug))
+	}
+	if failed > 0 {
+		log.Fatalf("Error: %d of %d docsets failed.", failed, len(slugs))
+	}
+}
+
+// pageHit is a page matching a search in the full-text index of a downloaded docset: its
+// docset, score, the title and entry type it was indexed with, and an excerpt marking the
+// match.
+type pageHit struct {
+	Lang    string
+	Path    string
+	Score   float64
+	Title   string
+	Type    string
+	Snippet string
+}
+
+// searchContent searches the page text of the docsets of langs that were downloaded with a
+// full-text index, all in one search, and returns the hits by docset. A failed search is
+// logged and leaves the search to entry names.
+func searchContent(langs []string, query, pathPrefix string) map[string][]pageHit {
+	result := make(map[string][]pageHit)
+	if !fullTextIndexing || strings.TrimSpace(query) == "" {
+		return result
+	}
+	var indexed []string
+	for _, lang := range langs {
+		if hasSearchIndex(mirrorDir, lang) {
+			indexed = append(indexed, lang)
+		}
+	}
+	if len(indexed) == 0 {
+		return result
+	}
+	hits, err := searchPageText(mirrorDir, indexed, query, strings.TrimPrefix(pathPrefix, "/"))
+	if err != nil {
+		log.Printf("Full-text search of %s failed, matching entry names only: %v\n", strings.Join(indexed, ", "), err)
+		return result
+	}
+	for _, hit := range hits {
+		result[hit.Lang] = append(result[hit.Lang], hit)
+	}
+	return result
+}
+
+// contentHits returns the full-text hits of a docset by the path of the entry standing for
+// each page (see pageEntries).
+func contentHits(hits []pageHit, entries []DocEntry) map[string]pageHit {
+	pages := pageEntries(entries)
+	result := make(map[string]pageHit, len(hits))
+	for _, hit := range hits {
+		if entry, ok := pages[hit.Path]; ok {
+			result[entry.Path] = hit
+		}
+	}
+	return result
+}
+
+// pageEntries returns the entry standing for each page of entries, by page path: the entry of
+// the page itself, or else its first entry.
+func pageEntries(entries []DocEntry) map[string]DocEntry {
+	pages := make(map[string]DocEntry)
+	for _, entry := range entries {
+		page := stripFragment(entry.Path)
+		if _, ok := pages[page]; !ok || entry.Path == page {
+			pages[page] = entry
+		}
+	}
+	return pages
+}
+
+// downloadedPages walks the page files of a docset downloaded into dir and returns their page
+// paths, sorted.
+func downloadedPages(dir, slug string) ([]string, error) {
+	docsetDir := filepath.Join(dir, slug)
+	var paths []string
+	err := filepath.WalkDir(docsetDir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".html") {
+			return nil
+		}
+		rel, err := filepath.Rel(docsetDir, file)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, strings.TrimSuffix(filepath.ToSlash(rel), ".html"))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the pages of %s: %w", slug, err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
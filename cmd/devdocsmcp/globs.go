@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Limits for glob paths.
+const (
+	// maxGlobReadPages is how many pages a read_doc_content call with a glob path returns.
+	maxGlobReadPages = maxReadManyEntries
+	// defaultExpandLimit and maxExpandLimit bound the paths listed by expand_paths.
+	defaultExpandLimit = 100
+	maxExpandLimit     = 1000
+)
+
+// isGlob reports whether an entry path is a glob pattern rather than a single page.
+func isGlob(entryPath string) bool {
+	return strings.ContainsAny(entryPath, "*?[")
+}
+
+// ExpandPaths returns the page paths of a documentation set's index matching a glob pattern,
+// sorted and without fragments. A '*' matches within one path segment, as in path.Match, and
+// a trailing "/**" matches every page below the prefix before it.
+func ExpandPaths(ctx context.Context, langSlug, pattern string) ([]string, error) {
+	match, err := globMatcher(pattern)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := fetchIndex(ctx, langSlug)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	paths := []string{}
+	for _, entry := range doc.Entries {
+		pagePath := stripFragment(entry.Path)
+		if seen[pagePath] || !match(pagePath) {
+			continue
+		}
+		seen[pagePath] = true
+		paths = append(paths, pagePath)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// globMatcher compiles a glob pattern for ExpandPaths, rejecting malformed ones.
+func globMatcher(pattern string) (func(string) bool, error) {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		if _, err := path.Match(prefix, ""); err != nil {
+			return nil, fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+		}
+		return func(pagePath string) bool {
+			for dir := path.Dir(pagePath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+				if ok, _ := path.Match(prefix, dir); ok {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	if strings.Contains(pattern, "**") {
+		return nil, fmt.Errorf("invalid path pattern %q: ** is only supported as the last path segment", pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+	}
+	return func(pagePath string) bool {
+		ok, _ := path.Match(pattern, pagePath)
+		return ok
+	}, nil
+}
+
+// readGlob answers a read_doc_content call whose path is a glob: the matching pages, at most
+// maxGlobReadPages, are read like read_many within the response limit and returned as one text,
+// each under a label naming it.
+func readGlob(ctx context.Context, lang, pattern string) (*mcp.CallToolResult, error) {
+	paths, err := ExpandPaths(ctx, lang, pattern)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if len(paths) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("no page of %s matches %s", lang, pattern)), nil
+	}
+	var warnings []string
+	if len(paths) > maxGlobReadPages {
+		warnings = append(warnings, fmt.Sprintf("%d pages match %s; returning the first %d (call expand_paths to list them all)", len(paths), pattern, maxGlobReadPages))
+		paths = paths[:maxGlobReadPages]
+	}
+
+	refs := make([]PageRef, len(paths))
+	for i, p := range paths {
+		refs[i] = PageRef{Lang: lang, Path: p}
+	}
+	budget := maxResponseBytes
+	if budget <= 0 {
+		budget = defaultReadManyBudget
+	}
+
+	var b strings.Builder
+	for i, p := range ReadMany(ctx, refs, budget) {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "==> %s/%s <==\n", p.Lang, p.Path)
+		if p.Error != "" {
+			fmt.Fprintf(&b, "(could not be read: %s)", p.Error)
+			warnings = append(warnings, fmt.Sprintf("%s/%s could not be read: %s", p.Lang, p.Path, p.Error))
+			continue
+		}
+		b.WriteString(p.Content)
+		if p.Truncated {
+			warnings = append(warnings, fmt.Sprintf("%s/%s truncated: returned %d of %d bytes", p.Lang, p.Path, len(p.Content), p.Bytes))
+			b.WriteString(formatTruncationMarker("pages matching the pattern share the response limit", len(p.Content), *p.Next))
+		}
+	}
+
+	result := newTextResult(b.String(), warnings)
+	result.Meta["paths"] = paths
+	return result, nil
+}
+
+func handleExpandPaths(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lang, err := request.RequireString("lang")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	pattern, err := request.RequireString("pattern")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if !isLanguageAllowed(ctx, lang) {
+		return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+	}
+	limit := request.GetInt("limit", defaultExpandLimit)
+	if limit <= 0 || limit > maxExpandLimit {
+		limit = maxExpandLimit
+	}
+
+	paths, err := ExpandPaths(ctx, lang, pattern)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	var warnings []string
+	total := len(paths)
+	if total > limit {
+		warnings = append(warnings, fmt.Sprintf("%d pages match; returning the first %d", total, limit))
+		paths = paths[:limit]
+	}
+	return newJSONResult(map[string]any{
+		"pattern":       pattern,
+		"total_matches": total,
+		"paths":         paths,
+	}, warnings), nil
+}
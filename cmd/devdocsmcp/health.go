@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Endpoints of the health probes, served without authentication next to the HTTP transports.
+const (
+	healthzEndpoint = "/healthz"
+	readyzEndpoint  = "/readyz"
+)
+
+// upstreamProbeTimeout bounds a readiness check of the documentation host, and
+// upstreamProbeTTL is how long its result is reused so frequent probes don't reach upstream.
+const (
+	upstreamProbeTimeout = 3 * time.Second
+	upstreamProbeTTL     = 15 * time.Second
+)
+
+// Readiness is the body of a /readyz response.
+type Readiness struct {
+	Ready         bool     `json:"ready"`
+	Upstream      bool     `json:"upstream"`
+	UpstreamError string   `json:"upstream_error,omitempty"`
+	Cached        []string `json:"cached"`
+	// Upstreams reports the health of each documentation host, in failover order.
+	Upstreams []UpstreamStatus `json:"upstreams"`
+	// Draining is set once the server is shutting down; it is then not ready.
+	Draining bool `json:"draining,omitempty"`
+}
+
+var (
+	upstreamProbeMu   sync.Mutex
+	upstreamProbeAt   time.Time
+	upstreamProbeErr  error
+	upstreamProbeDone bool
+)
+
+// healthHandler adds the health probes to the HTTP handler of the transports.
+func healthHandler(next http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(healthzEndpoint, handleHealthz)
+	mux.HandleFunc(readyzEndpoint, handleReadyz)
+	mux.Handle("/", next)
+	return mux
+}
+
+// handleHealthz reports that the process is up.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeProbe(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz reports whether tool calls can be answered: a documentation host is reachable
+// or at least one served docset has its index cached locally. When bearer tokens are
+// configured, only a request carrying the -auth-token token sees which languages are cached
+// and which hosts are used; everyone else gets the bare status.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	readiness := checkReadiness(r.Context())
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	if !readinessDetailAllowed(r) {
+		writeProbe(w, status, struct {
+			Ready    bool `json:"ready"`
+			Draining bool `json:"draining,omitempty"`
+		}{readiness.Ready, readiness.Draining})
+		return
+	}
+	writeProbe(w, status, readiness)
+}
+
+// readinessDetailAllowed reports whether r may see the detail of /readyz: always without
+// bearer tokens, otherwise only with the -auth-token token.
+func readinessDetailAllowed(r *http.Request) bool {
+	grants := currentGrants()
+	if len(grants) == 0 {
+		return true
+	}
+	grant, ok := authenticate(r.Header.Get("Authorization"), grants)
+	return ok && grant.langs == nil
+}
+
+func checkReadiness(ctx context.Context) Readiness {
+	readiness := Readiness{Cached: cachedLanguages()}
+	if err := probeUpstream(ctx); err != nil {
+		readiness.UpstreamError = err.Error()
+	} else {
+		readiness.Upstream = true
+	}
+	readiness.Upstreams = upstreamStatuses()
+	readiness.Draining = draining.Load()
+	readiness.Ready = (readiness.Upstream || len(readiness.Cached) > 0) && !readiness.Draining
+	return readiness
+}
+
+// probeUpstream checks that a documentation host answers, reusing a recent result.
+func probeUpstream(ctx context.Context) error {
+	if offline {
+		return errOffline
+	}
+	upstreamProbeMu.Lock()
+	defer upstreamProbeMu.Unlock()
+	if upstreamProbeDone && time.Since(upstreamProbeAt) < upstreamProbeTTL {
+		return upstreamProbeErr
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, upstreamProbeTimeout)
+	defer cancel()
+	upstreamProbeErr = probeUpstreams(ctx)
+	upstreamProbeAt, upstreamProbeDone = time.Now(), true
+	return upstreamProbeErr
+}
+
+// cachedLanguages returns the served languages whose index is cached locally (or, in offline
+// mode, mirrored or snapshotted), sorted.
+func cachedLanguages() []string {
+	cached := []string{}
+	for lang := range servedLanguages() {
+		if _, err := os.Stat(filepath.Join(indexCacheDir(lang), "index.json")); err == nil {
+			cached = append(cached, lang)
+		} else if offline && hasOfflineIndex(lang) {
+			cached = append(cached, lang)
+		}
+	}
+	sort.Strings(cached)
+	return cached
+}
+
+func writeProbe(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
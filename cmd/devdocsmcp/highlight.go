@@ -0,0 +1,81 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// Fields a search result can match on.
+const (
+	fieldName = "name"
+	fieldPath = "path"
+)
+
+// Highlight shows where a query matched a search result: the field and a fragment of it with
+// the matching text wrapped in <mark> tags, as bleve's highlighter does for full-text hits.
+type Highlight struct {
+	Field    string `json:"field"`
+	Fragment string `json:"fragment"`
+}
+
+// markAll wraps every case-insensitive occurrence of lowerQuery in text with <mark> tags.
+// It reports false when there is no occurrence.
+func markAll(text, lowerQuery string) (string, bool) {
+	lower := strings.ToLower(text)
+	if lowerQuery == "" || !strings.Contains(lower, lowerQuery) {
+		return "", false
+	}
+	if len(lower) != len(text) {
+		// Lowercasing changed byte offsets, so the span can't be located; mark the whole text
+		return "<mark>" + text + "</mark>", true
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(lower, lowerQuery)
+		if i < 0 {
+			break
+		}
+		b.WriteString(text[:i])
+		b.WriteString("<mark>" + text[i:i+len(lowerQuery)] + "</mark>")
+		text, lower = text[i+len(lowerQuery):], lower[i+len(lowerQuery):]
+	}
+	b.WriteString(text)
+	return b.String(), true
+}
+
+// markPrefix wraps the leading lowerQuery of text, which is known to match, in <mark> tags.
+func markPrefix(text, lowerQuery string) string {
+	if len(strings.ToLower(text)) != len(text) {
+		return "<mark>" + text + "</mark>"
+	}
+	return "<mark>" + text[:len(lowerQuery)] + "</mark>" + text[len(lowerQuery):]
+}
+
+// entryHighlights returns where query matched entry's name and path under the given search mode.
+func entryHighlights(entry DocEntry, lowerQuery, mode string) []Highlight {
+	var highlights []Highlight
+	switch mode {
+	case modeExact:
+		highlights = append(highlights, Highlight{Field: fieldName, Fragment: "<mark>" + entry.Name + "</mark>"})
+	case modePrefix:
+		if strings.HasPrefix(strings.ToLower(entry.Name), lowerQuery) {
+			highlights = append(highlights, Highlight{Field: fieldName, Fragment: markPrefix(entry.Name, lowerQuery)})
+		}
+		base := path.Base(entry.Path)
+		if strings.HasPrefix(strings.ToLower(base), lowerQuery) {
+			dir := strings.TrimSuffix(entry.Path, base)
+			highlights = append(highlights, Highlight{Field: fieldPath, Fragment: dir + markPrefix(base, lowerQuery)})
+		}
+	case modeFuzzy:
+		// An approximate match has no exact span to mark; the whole name is what matched
+		highlights = append(highlights, Highlight{Field: fieldName, Fragment: "<mark>" + entry.Name + "</mark>"})
+	default:
+		if fragment, ok := markAll(entry.Name, lowerQuery); ok {
+			highlights = append(highlights, Highlight{Field: fieldName, Fragment: fragment})
+		}
+		if fragment, ok := markAll(entry.Path, lowerQuery); ok {
+			highlights = append(highlights, Highlight{Field: fieldPath, Fragment: fragment})
+		}
+	}
+	return highlights
+}
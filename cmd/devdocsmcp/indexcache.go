@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// defaultCacheTTL is the default of the server's -cache-ttl flag.
+const defaultCacheTTL = 24 * time.Hour
+
+// cacheTTL is how long a cached index.json or page is used before it is downloaded again. It
+// is set by the server's -cache-ttl flag; 0 disables the cache.
+var cacheTTL = defaultCacheTTL
+
+// cacheWrites tracks background cache writes so short-lived commands can wait for them.
+var cacheWrites sync.WaitGroup
+
+// indexCacheDir returns the directory holding the cached index of a documentation set. Its
+// cached pages are kept in pageStore, below it in the layout of a snapshot with the files
+// backend.
+func indexCacheDir(langSlug string) string {
+	return filepath.Join(cacheDir(), "indexes", langSlug)
+}
+
+// loadCachedIndex returns the cached index of a documentation set if it is younger than
+// cacheTTL, or at any age if it was pinned by 'entries download' or in offline mode. The gob encoding is preferred
+// because it decodes several times faster than the raw JSON; when only the JSON is present,
+// the gob is written in the background. The time returned is when the index was downloaded.
+func loadCachedIndex(langSlug string) (*Doc, time.Time, bool) {
+	return readCachedIndex(langSlug, false)
+}
+
+// loadStaleIndex returns the cached index of a documentation set at any age, like
+// loadCachedIndex, to serve it while it is refreshed.
+func loadStaleIndex(langSlug string) (*Doc, time.Time, bool) {
+	return readCachedIndex(langSlug, true)
+}
+
+func readCachedIndex(langSlug string, anyAge bool) (*Doc, time.Time, bool) {
+	dir := indexCacheDir(langSlug)
+	jsonPath := filepath.Join(dir, "index.json")
+	info, err := os.Stat(jsonPath)
+	if err != nil || (time.Since(info.ModTime()) >= cacheTTL && !isIndexPinned(langSlug) && !offline && !anyAge) {
+		return nil, time.Time{}, false
+	}
+
+	start := time.Now()
+	if data, err := readCacheFile(filepath.Join(dir, "index.gob")); err == nil {
+		var doc Doc
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&doc); err == nil {
+			traceSpan("cache", langSlug, "index.gob", start)
+			return &doc, info.ModTime(), true
+		}
+		log.Printf("Ignoring corrupt index cache for %s: %v\n", langSlug, err)
+	}
+
+	data, err := readCacheFile(jsonPath)
+	if err != nil {
+		log.Printf("Ignoring index cache for %s: %v\n", langSlug, err)
+		return nil, time.Time{}, false
+	}
+	var doc Doc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		log.Printf("Ignoring corrupt index cache for %s: %v\n", langSlug, err)
+		return nil, time.Time{}, false
+	}
+	traceSpan("cache", langSlug, "index.json", start)
+	persistIndexGob(langSlug, &doc, info.ModTime())
+	return &doc, info.ModTime(), true
+}
+
+// storeIndex caches the raw index.json of a documentation set and persists its parsed form
+// in the background. doc must not be modified afterwards. The new index.json, the removal of
+// the previous revision's gob and the docset record are committed as one cache transaction, so
+// a crash can't leave the old gob shadowing the new index. The caller holds the docset's lock.
+// Nothing is written when -cache-ttl disables the cache.
+func storeIndex(langSlug string, raw []byte, doc *Doc) {
+	if cacheTTL <= 0 {
+		return
+	}
+	tx, err := beginCacheTx(indexCacheDir(langSlug))
+	if err == nil {
+		err = tx.Write("index.json", raw)
+		if err != nil {
+			tx.Abort()
+		}
+	}
+	if err == nil {
+		tx.Remove("index.gob")
+		record := newDocsetRecord(langSlug, doc)
+		err = commitCacheTx(tx, cacheTxMeta{Docset: &record})
+	}
+	if err != nil {
+		log.Printf("Failed to cache index for %s: %v\n", langSlug, err)
+		return
+	}
+	parsedIndexes.remove(langSlug)
+	if info, err := os.Stat(filepath.Join(indexCacheDir(langSlug), "index.json")); err == nil {
+		persistIndexGob(langSlug, doc, info.ModTime())
+	}
+}
+
+// persistIndexGob writes the gob encoding of doc, the index.json last modified at indexMod,
+// asynchronously. doc must not be modified afterwards. If the index.json has been replaced in
+// the meantime, e.g. by another process sharing the cache, the gob is not written.
+func persistIndexGob(langSlug string, doc *Doc, indexMod time.Time) {
+	cacheWrites.Add(1)
+	go func() {
+		defer cacheWrites.Done()
+		unlock, _ := lockDocset(langSlug)
+		defer unlock()
+		if info, err := os.Stat(filepath.Join(indexCacheDir(langSlug), "index.json")); err != nil || !info.ModTime().Equal(indexMod) {
+			return
+		}
+		var b bytes.Buffer
+		if err := gob.NewEncoder(&b).Encode(doc); err != nil {
+			log.Printf("Failed to encode index cache for %s: %v\n", langSlug, err)
+			return
+		}
+		if err := writeCacheFile(filepath.Join(indexCacheDir(langSlug), "index.gob"), b.Bytes()); err != nil {
+			log.Printf("Failed to cache parsed index for %s: %v\n", langSlug, err)
+		}
+	}()
+}
+
+// writeCacheFile atomically replaces path with data, encrypted if encryption at rest is enabled.
+func writeCacheFile(path string, data []byte) error {
+	data = sealCacheData(data)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// annotatedCopy returns a copy of doc with derived entry fields (kind, breadcrumbs) filled in,
+// leaving doc itself untouched for the background cache writer.
+func annotatedCopy(langSlug string, doc *Doc) *Doc {
+	start := time.Now()
+	defer traceSpan("annotate", langSlug, fmt.Sprintf("%d entries", len(doc.Entries)), start)
+	annotated := *doc
+	annotated.Entries = make([]DocEntry, len(doc.Entries))
+	copy(annotated.Entries, doc.Entries)
+	annotateEntries(langSlug, &annotated)
+	return &annotated
+}
+
+// invalidateIndex drops the cached index, in memory and on disk, the pages and the remembered
+// missing pages of a documentation set so they are downloaded again. A pin set by 'entries
+// download' is kept, so the new revision stays available offline once it has been fetched.
+func invalidateIndex(langSlug string) {
+	parsedIndexes.remove(langSlug)
+	missingPaths.forget(langSlug)
+	unlock, _ := lockDocset(langSlug)
+	defer unlock()
+	tx, err := beginCacheTx(indexCacheDir(langSlug))
+	if err == nil {
+		for _, name := range []string{"index.json", "index.gob"} {
+			tx.Remove(name)
+		}
+		err = tx.Commit()
+	}
+	if err == nil {
+		err = pageStore().DeleteLang(langSlug)
+	}
+	if err != nil {
+		log.Printf("Failed to drop index and page cache for %s: %v\n", langSlug, err)
+	}
+}
+
+// loadCachedPage returns the cached content of a page if it is younger than cacheTTL, or at any
+// age in offline mode.
+func loadCachedPage(langSlug, pagePath string) (string, bool) {
+	content, _, ok := readCachedPage(langSlug, pagePath, false)
+	return content, ok
+}
+
+// loadStalePage returns the cached content of a page at any age, and when it was downloaded,
+// to serve it while it is refreshed.
+func loadStalePage(langSlug, pagePath string) (string, time.Time, bool) {
+	return readCachedPage(langSlug, pagePath, true)
+}
+
+func readCachedPage(langSlug, pagePath string, anyAge bool) (string, time.Time, bool) {
+	store := pageStore()
+	info, err := store.Stat(langSlug, pagePath)
+	if err != nil || (time.Since(info.Stored) >= cacheTTL && !offline && !anyAge) {
+		return "", time.Time{}, false
+	}
+	start := time.Now()
+	data, stored, err := store.Get(langSlug, pagePath)
+	if err == nil {
+		data, err = openCacheData(langSlug+"/"+pagePath, data)
+	}
+	if err != nil {
+		log.Printf("Ignoring page cache for %s/%s: %v\n", langSlug, pagePath, err)
+		return "", time.Time{}, false
+	}
+	traceSpan("cache", langSlug, pagePath, start)
+	notePageUse(langSlug, pagePath)
+	return string(data), stored, true
+}
+
+// storePage caches the content of a page in the background, unless -cache-ttl disables the
+// cache.
+func storePage(langSlug, pagePath, content string) {
+	if cacheTTL <= 0 {
+		return
+	}
+	cacheWrites.Add(1)
+	go func() {
+		defer cacheWrites.Done()
+		if err := pageStore().Put(langSlug, pagePath, sealCacheData([]byte(content))); err != nil {
+			log.Printf("Failed to cache page %s/%s: %v\n", langSlug, pagePath, err)
+			return
+		}
+		notePageCached(langSlug, pagePath)
+	}()
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"devdocsmcp/internal/docs/entrypath"
+)
+
+// Defaults of the server's -index-cache-entries and -index-cache-mb flags.
+const (
+	defaultIndexCacheEntries = 8
+	defaultIndexCacheMB      = 256
+)
+
+// indexCacheEntries and indexCacheMB bound the parsed indexes kept in memory; they are set by
+// the server's flags. 0 entries disables the in-memory cache, 0 MB lifts the memory cap.
+var (
+	indexCacheEntries = defaultIndexCacheEntries
+	indexCacheMB      = defaultIndexCacheMB
+)
+
+// entryOverhead approximates the memory of a DocEntry besides its strings: the struct, string
+// and slice headers, and the breadcrumbs.
+const entryOverhead = 160
+
+// ParsedIndexStats reports the in-memory cache of parsed indexes.
+type ParsedIndexStats struct {
+	Langs  []string `json:"langs"`
+	Bytes  int64    `json:"bytes"`
+	Hits   int64    `json:"hits"`
+	Misses int64    `json:"misses"`
+}
+
+type parsedIndex struct {
+	lang   string
+	doc    *Doc
+	bytes  int64
+	loaded time.Time
+	// pages is the set of the index's pages, built by the first entry path resolved in it.
+	pages *entrypath.Pages
+}
+
+// indexLRU keeps the annotated indexes of recently used documentation sets, so back-to-back
+// searches in one language neither decode nor annotate its index again. It evicts the least
+// recently used index beyond indexCacheEntries or indexCacheMB. It is safe for concurrent use.
+type indexLRU struct {
+	mu           sync.Mutex
+	order        *list.List // of *parsedIndex, most recently used first
+	byLang       map[string]*list.Element
+	bytes        int64
+	hits, misses int64
+}
+
+var parsedIndexes = &indexLRU{order: list.New(), byLang: make(map[string]*list.Element)}
+
+// get returns the cached index of lang, unless it has outlived cacheTTL; pinned indexes and all
+// indexes in offline mode don't expire, as on disk. The returned Doc is shared and must not be modified.
+func (c *indexLRU) get(lang string) (*Doc, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.byLang[lang]
+	if ok && time.Since(elem.Value.(*parsedIndex).loaded) >= cacheTTL && !isIndexPinned(lang) && !offline {
+		c.removeElement(elem)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(elem)
+	return elem.Value.(*parsedIndex).doc, true
+}
+
+// add caches the annotated index of lang, loaded from a copy fetched at loaded. doc must not be
+// modified afterwards.
+func (c *indexLRU) add(lang string, doc *Doc, loaded time.Time) {
+	if indexCacheEntries <= 0 {
+		return
+	}
+	entry := &parsedIndex{lang: lang, doc: doc, bytes: docSize(doc), loaded: loaded}
+	maxBytes := int64(indexCacheMB) << 20
+	if maxBytes > 0 && entry.bytes > maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.byLang[lang]; ok {
+		c.removeElement(elem)
+	}
+	c.byLang[lang] = c.order.PushFront(entry)
+	c.bytes += entry.bytes
+	for c.order.Len() > indexCacheEntries || (maxBytes > 0 && c.bytes > maxBytes) {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// pages returns the set of the pages of doc, an index of lang, for resolving entry paths. It is
+// kept with doc while doc is the cached index of lang, and built again for any other index.
+func (c *indexLRU) pages(lang string, doc *Doc) *entrypath.Pages {
+	c.mu.Lock()
+	var entry *parsedIndex
+	if elem, ok := c.byLang[lang]; ok && elem.Value.(*parsedIndex).doc == doc {
+		entry = elem.Value.(*parsedIndex)
+		if entry.pages != nil {
+			c.mu.Unlock()
+			return entry.pages
+		}
+	}
+	c.mu.Unlock()
+
+	pages := docPages(doc)
+	if entry != nil {
+		c.mu.Lock()
+		entry.pages = pages
+		c.mu.Unlock()
+	}
+	return pages
+}
+
+// remove drops the cached index of lang.
+func (c *indexLRU) remove(lang string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.byLang[lang]; ok {
+		c.removeElement(elem)
+	}
+}
+
+func (c *indexLRU) removeElement(elem *list.Element) {
+	entry := c.order.Remove(elem).(*parsedIndex)
+	delete(c.byLang, entry.lang)
+	c.bytes -= entry.bytes
+}
+
+// stats reports the cached languages, most recently used first, and the cache's hit rate.
+func (c *indexLRU) stats() ParsedIndexStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := ParsedIndexStats{Langs: []string{}, Bytes: c.bytes, Hits: c.hits, Misses: c.misses}
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		stats.Langs = append(stats.Langs, elem.Value.(*parsedIndex).lang)
+	}
+	return stats
+}
+
+// docSize estimates the memory held by a parsed index.
+func docSize(doc *Doc) int64 {
+	size := int64(len(doc.Name) + len(doc.Version))
+	for _, entry := range doc.Entries {
+		size += int64(len(entry.Name)+len(entry.Path)+len(entry.Type)+len(entry.Kind)) + entryOverhead
+		for _, crumb := range entry.Breadcrumbs {
+			size += int64(len(crumb.Level) + len(crumb.Label) + len(crumb.Path))
+		}
+	}
+	for _, t := range doc.Types {
+		size += int64(len(t.Name)+len(t.Slug)) + entryOverhead
+	}
+	return size
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+
+	"devdocsmcp/internal/docs/mirror"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Limits of index_stats verification.
+const (
+	defaultVerifySample = 50
+	maxVerifySample     = 1000
+	// maxDriftExamples caps the paths listed for each kind of drift.
+	maxDriftExamples = 20
+)
+
+// States of a full-text index reported by index_stats.
+const (
+	indexReady      = "ready"
+	indexOutdated   = "outdated"
+	indexIncomplete = "incomplete"
+	indexMissing    = "missing"
+)
+
+// IndexStats describes the full-text index of a downloaded docset.
+type IndexStats struct {
+	Lang string `json:"lang"`
+	// State is ready; outdated when built by an older version or with another analyzer than
+	// the configured one, so the next download or update rebuilds it; incomplete when a build
+	// or update is running or was interrupted; or missing.
+	State     string `json:"state"`
+	Documents uint64 `json:"documents"`
+	// Pages counts the page files of the downloaded docset.
+	Pages     int    `json:"pages"`
+	SizeBytes int64  `json:"size_bytes"`
+	Size      string `json:"size"`
+	// Written is when the index was last built or updated.
+	Written            string       `json:"written,omitempty"`
+	Format             int          `json:"format,omitempty"`
+	Analyzer           string       `json:"analyzer,omitempty"`
+	ConfiguredAnalyzer string       `json:"configured_analyzer"`
+	Verify             *IndexVerify `json:"verify,omitempty"`
+	Error              string       `json:"error,omitempty"`
+}
+
+// IndexVerify is the result of checking a sample of an index against the page files.
+type IndexVerify struct {
+	Sampled int `json:"sampled"`
+	// MissingFiles are sampled documents whose page file is gone, Stale sampled documents whose
+	// page file changed after the index was last written, and Unindexed sampled page files
+	// without a document; each lists at most maxDriftExamples paths.
+	MissingFiles []string `json:"missing_files"`
+	Stale        []string `json:"stale"`
+	Unindexed    []string `json:"unindexed"`
+	Drift        bool     `json:"drift"`
+}
+
+// collectIndexStats describes the full-text index of a docset downloaded into dir, verifying
+// up to sample documents and page files when verify is set.
+func collectIndexStats(dir, slug string, verify bool, sample int) IndexStats {
+	stats := IndexStats{Lang: slug, State: indexMissing, ConfiguredAnalyzer: indexAnalyzer(slug)}
+	pages, err := downloadedPages(dir, slug)
+	if err != nil {
+		stats.Error = err.Error()
+		return stats
+	}
+	stats.Pages = len(pages)
+	indexDir := searchIndexDir(dir, slug)
+	if _, err := os.Stat(indexDir); err != nil {
+		return stats
+	}
+	stats.SizeBytes, _ = dirSize(indexDir)
+	stats.Size = formatBytes(stats.SizeBytes)
+
+	record, marked := readSearchIndexMarker(dir, slug)
+	switch {
+	case !marked:
+		stats.State = indexIncomplete
+	case record.format != searchIndexFormat || record.analyzer != stats.ConfiguredAnalyzer:
+		stats.State = indexOutdated
+	default:
+		stats.State = indexReady
+	}
+	if marked {
+		stats.Format, stats.Analyzer = record.format, record.analyzer
+		if !record.written.IsZero() {
+			stats.Written = record.written.Format(time.RFC3339)
+		}
+	}
+
+	count, paths, err := inspectSearchIndex(dir, slug, verify)
+	if err != nil {
+		stats.Error = err.Error()
+		return stats
+	}
+	stats.Documents = count
+	if verify {
+		stats.Verify = verifySearchIndex(dir, slug, paths, pages, record.written, sample)
+	}
+	return stats
+}
+
+// verifySearchIndex checks up to sample randomly chosen indexed documents against their page
+// files, and as many page files against the indexed paths.
+func verifySearchIndex(dir, slug string, indexed, pages []string, written time.Time, sample int) *IndexVerify {
+	result := &IndexVerify{MissingFiles: []string{}, Stale: []string{}, Unindexed: []string{}}
+	note := func(list *[]string, pagePath string) {
+		result.Drift = true
+		if len(*list) < maxDriftExamples {
+			*list = append(*list, pagePath)
+		}
+	}
+
+	for _, i := range samplePositions(len(indexed), sample) {
+		pagePath := indexed[i]
+		result.Sampled++
+		file, err := mirror.PageFile(filepath.Join(dir, slug), pagePath)
+		if err != nil {
+			note(&result.MissingFiles, pagePath)
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			note(&result.MissingFiles, pagePath)
+			continue
+		}
+		// The marker records whole seconds
+		if !written.IsZero() && info.ModTime().Truncate(time.Second).After(written) {
+			note(&result.Stale, pagePath)
+		}
+	}
+
+	isIndexed := make(map[string]bool, len(indexed))
+	for _, pagePath := range indexed {
+		isIndexed[pagePath] = true
+	}
+	for _, i := range samplePositions(len(pages), sample) {
+		result.Sampled++
+		if !isIndexed[pages[i]] {
+			note(&result.Unindexed, pages[i])
+		}
+	}
+	return result
+}
+
+// samplePositions returns up to sample distinct random positions below n.
+func samplePositions(n, sample int) []int {
+	positions := rand.Perm(n)
+	return positions[:min(sample, n)]
+}
+
+func handleIndexStats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !fullTextIndexing {
+		return mcp.NewToolResultError("index_stats: full-text indexing is not included in this minimal build."), nil
+	}
+	verify := request.GetBool("verify", false)
+	sample := request.GetInt("sample", defaultVerifySample)
+	if sample < 1 || sample > maxVerifySample {
+		return mcp.NewToolResultError(fmt.Sprintf("sample must be between 1 and %d", maxVerifySample)), nil
+	}
+
+	var slugs []string
+	if lang := request.GetString("lang", ""); lang != "" {
+		if !isLanguageAllowed(ctx, lang) {
+			return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+		}
+		slugs = []string{lang}
+	} else {
+		mirrored, err := mirror.NewMirror(mirrorDir, docsBaseURL(), nil).Mirrored()
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		for _, slug := range mirrored {
+			if isLanguageAllowed(ctx, slug) {
+				slugs = append(slugs, slug)
+			}
+		}
+	}
+
+	stats := make([]IndexStats, 0, len(slugs))
+	for _, slug := range slugs {
+		stats = append(stats, collectIndexStats(mirrorDir, slug, verify, sample))
+	}
+	return newJSONResult(map[string]any{"mirror_dir": mirrorDir, "indexes": stats}, nil), nil
+}
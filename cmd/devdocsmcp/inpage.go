@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"devdocsmcp/internal/docs/page"
+)
+
+// PageSearch holds the sections of a page that match a query.
+type PageSearch struct {
+	Lang          string         `json:"lang"`
+	Path          string         `json:"path"`
+	Query         string         `json:"query"`
+	TotalSections int            `json:"total_sections"`
+	Sections      []page.Section `json:"sections"`
+}
+
+// SearchInPage splits an entry's page into sections by heading and returns only the sections
+// containing every term of query.
+func SearchInPage(ctx context.Context, langSlug, entryPath, query string) (*PageSearch, []string, error) {
+	content, err := ReadDocContent(ctx, langSlug, entryPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	var warnings []string
+	sections, err := page.Sections(strings.NewReader(content))
+	if err != nil {
+		sections = page.PlainSections(content)
+		warnings = append(warnings, fmt.Sprintf("failed to split %s/%s into sections (%v); searched its plain text as one section", langSlug, entryPath, err))
+	}
+	return &PageSearch{
+		Lang:          langSlug,
+		Path:          entryPath,
+		Query:         query,
+		TotalSections: len(sections),
+		Sections:      page.MatchSections(sections, query),
+	}, warnings, nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Language grants let an operator enable one extra language for a short time, e.g. a one-off
+// lookup in a docset the server doesn't serve or the caller's token doesn't cover. The admin
+// mints a grant with the grant_language tool, which only the -auth-token token may call, and
+// hands its secret to the caller, who passes it as the grant argument of the tool calls that
+// need the language. The first session to use a grant owns it; every use, refusal and
+// revocation is logged and kept for language_grants.
+const (
+	grantArgument       = "grant"
+	defaultGrantTTL     = 15 * time.Minute
+	maxGrantTTL         = 24 * time.Hour
+	grantAuditRetention = 24 * time.Hour
+	maxGrantAuditEvents = 1000
+)
+
+// LanguageGrant describes a temporary language grant, without its secret.
+type LanguageGrant struct {
+	ID        string `json:"id"`
+	Lang      string `json:"lang"`
+	Reason    string `json:"reason"`
+	IssuedBy  string `json:"issued_by"`
+	IssuedAt  string `json:"issued_at"`
+	ExpiresAt string `json:"expires_at"`
+	// MaxCalls caps the tool calls the grant may be used for; 0 means no cap.
+	MaxCalls int `json:"max_calls,omitempty"`
+	Calls    int `json:"calls"`
+	// Session is the MCP session the grant is bound to, once used.
+	Session string `json:"session,omitempty"`
+	State   string `json:"state"`
+}
+
+// GrantEvent is an entry of the language grant audit trail.
+type GrantEvent struct {
+	Time    string `json:"time"`
+	Grant   string `json:"grant"`
+	Event   string `json:"event"`
+	Lang    string `json:"lang,omitempty"`
+	Session string `json:"session,omitempty"`
+	Token   string `json:"token,omitempty"`
+	Tool    string `json:"tool,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+type languageGrant struct {
+	id, secret, lang, reason, issuedBy string
+	issued, expires                    time.Time
+	maxCalls, calls                    int
+	session                            string
+	bound, revoked                     bool
+}
+
+func (g *languageGrant) state(now time.Time) string {
+	switch {
+	case g.revoked:
+		return "revoked"
+	case !now.Before(g.expires):
+		return "expired"
+	case g.maxCalls > 0 && g.calls >= g.maxCalls:
+		return "used up"
+	}
+	return "active"
+}
+
+func (g *languageGrant) describe(now time.Time) LanguageGrant {
+	return LanguageGrant{
+		ID:        g.id,
+		Lang:      g.lang,
+		Reason:    g.reason,
+		IssuedBy:  g.issuedBy,
+		IssuedAt:  g.issued.UTC().Format(time.RFC3339),
+		ExpiresAt: g.expires.UTC().Format(time.RFC3339),
+		MaxCalls:  g.maxCalls,
+		Calls:     g.calls,
+		Session:   g.session,
+		State:     g.state(now),
+	}
+}
+
+// grantRegistry holds the language grants and their audit trail. It is safe for concurrent use.
+type grantRegistry struct {
+	mu     sync.Mutex
+	grants map[string]*languageGrant // by secret
+	events []GrantEvent
+}
+
+var languageGrants = &grantRegistry{grants: make(map[string]*languageGrant)}
+
+type languageGrantKey struct{}
+
+// audit logs an event and adds it to the audit trail. The caller must hold r.mu.
+func (r *grantRegistry) audit(now time.Time, event GrantEvent) {
+	event.Time = now.UTC().Format(time.RFC3339)
+	log.Printf("Audit: language grant %s %s (lang %s, session %q, token %q, tool %q) %s\n", event.Grant, event.Event, event.Lang, event.Session, event.Token, event.Tool, event.Detail)
+	r.events = append(r.events, event)
+	if len(r.events) > maxGrantAuditEvents {
+		r.events = r.events[len(r.events)-maxGrantAuditEvents:]
+	}
+}
+
+// prune forgets the grants that ended more than grantAuditRetention ago. The caller must hold r.mu.
+func (r *grantRegistry) prune(now time.Time) {
+	for secret, g := range r.grants {
+		if now.Sub(g.expires) > grantAuditRetention {
+			delete(r.grants, secret)
+		}
+	}
+}
+
+// issue mints a grant of lang for ttl.
+func (r *grantRegistry) issue(lang, reason, issuedBy string, ttl time.Duration, maxCalls int, now time.Time) (*languageGrant, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate grant: %w", err)
+	}
+	g := &languageGrant{
+		id:       hex.EncodeToString(secret[:4]),
+		secret:   "dlg_" + hex.EncodeToString(secret),
+		lang:     lang,
+		reason:   reason,
+		issuedBy: issuedBy,
+		issued:   now,
+		expires:  now.Add(ttl),
+		maxCalls: maxCalls,
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune(now)
+	r.grants[g.secret] = g
+	r.audit(now, GrantEvent{Grant: g.id, Event: "issued", Lang: lang, Token: issuedBy, Detail: fmt.Sprintf("until %s, max calls %d: %s", g.expires.UTC().Format(time.RFC3339), maxCalls, reason)})
+	return g, nil
+}
+
+// redeem checks that secret is a grant the session may use for one more call, and counts the call.
+func (r *grantRegistry) redeem(secret, session, token, tool string, now time.Time) (*languageGrant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.grants[secret]
+	if !ok {
+		r.audit(now, GrantEvent{Grant: "unknown", Event: "refused", Session: session, Token: token, Tool: tool, Detail: "no such grant"})
+		return nil, fmt.Errorf("invalid language grant")
+	}
+	refuse := func(reason string) (*languageGrant, error) {
+		r.audit(now, GrantEvent{Grant: g.id, Event: "refused", Lang: g.lang, Session: session, Token: token, Tool: tool, Detail: reason})
+		return nil, fmt.Errorf("language grant %s is %s", g.id, reason)
+	}
+	if state := g.state(now); state != "active" {
+		return refuse(state)
+	}
+	if g.bound && g.session != session {
+		return refuse("bound to another session")
+	}
+	g.bound, g.session = true, session
+	g.calls++
+	r.audit(now, GrantEvent{Grant: g.id, Event: "used", Lang: g.lang, Session: session, Token: token, Tool: tool, Detail: fmt.Sprintf("call %d", g.calls)})
+	return g, nil
+}
+
+// revoke ends the grant with the given id.
+func (r *grantRegistry) revoke(id, by string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, g := range r.grants {
+		if g.id == id && !g.revoked {
+			g.revoked = true
+			r.audit(now, GrantEvent{Grant: g.id, Event: "revoked", Lang: g.lang, Token: by})
+			return true
+		}
+	}
+	return false
+}
+
+// report lists the grants, newest first, and the audit trail.
+func (r *grantRegistry) report(now time.Time) ([]LanguageGrant, []GrantEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune(now)
+	grants := []LanguageGrant{}
+	for _, g := range r.grants {
+		grants = append(grants, g.describe(now))
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].IssuedAt > grants[j].IssuedAt })
+	return grants, append([]GrantEvent{}, r.events...)
+}
+
+// grantedTemporarily reports whether the call of ctx carries a language grant of lang.
+func grantedTemporarily(ctx context.Context, lang string) bool {
+	g, ok := ctx.Value(languageGrantKey{}).(*languageGrant)
+	return ok && g.lang == lang
+}
+
+// redeemLanguageGrant is a tool handler middleware checking the grant argument of a call and,
+// when it is valid, letting the call use the granted language.
+func redeemLanguageGrant(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		secret := request.GetString(grantArgument, "")
+		if secret == "" {
+			return next(ctx, request)
+		}
+		var sessionID string
+		if session := server.ClientSessionFromContext(ctx); session != nil {
+			sessionID = session.SessionID()
+		}
+		g, err := languageGrants.redeem(secret, sessionID, grantName(ctx), request.Params.Name, time.Now())
+		if err != nil {
+			return mcp.NewToolResultError(err.Error() + "; ask the server's operator for a new one."), nil
+		}
+		return next(context.WithValue(ctx, languageGrantKey{}, g), request)
+	}
+}
+
+// advertiseGrantArgument is a tool filter adding the grant argument to the tools taking a language.
+func advertiseGrantArgument(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	for i, tool := range tools {
+		if _, ok := tool.InputSchema.Properties["lang"]; !ok {
+			continue
+		}
+		properties := make(map[string]any, len(tool.InputSchema.Properties)+1)
+		for name, property := range tool.InputSchema.Properties {
+			properties[name] = property
+		}
+		properties[grantArgument] = map[string]any{
+			"type":        "string",
+			"description": "A temporary language grant from the server's operator, enabling a language you are otherwise not allowed to use.",
+		}
+		tools[i].InputSchema.Properties = properties
+	}
+	return tools
+}
+
+func handleGrantLanguage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !isAdmin(ctx) {
+		return mcp.NewToolResultError("grant_language requires the server's -auth-token token."), nil
+	}
+	lang, err := request.RequireString("lang")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	reason, err := request.RequireString("reason")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	ttl := defaultGrantTTL
+	if value := request.GetString("expires_in", ""); value != "" {
+		if ttl, err = time.ParseDuration(value); err != nil || ttl <= 0 || ttl > maxGrantTTL {
+			return mcp.NewToolResultError(fmt.Sprintf("expires_in must be a duration between 1s and %s, e.g. 30m", maxGrantTTL)), nil
+		}
+	}
+	maxCalls := request.GetInt("max_calls", 0)
+	if maxCalls < 0 {
+		return mcp.NewToolResultError("max_calls must not be negative"), nil
+	}
+	if err := validateLangs([]string{lang}); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	issuedBy := grantName(ctx)
+	now := time.Now()
+	g, err := languageGrants.issue(lang, reason, issuedBy, ttl, maxCalls, now)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return newJSONResult(map[string]any{
+		"grant":       g.secret,
+		"description": g.describe(now),
+		"usage":       fmt.Sprintf("Pass {\"%s\": %q} with lang %q in the tool calls that need it. The first session to use the grant owns it.", grantArgument, g.secret, lang),
+	}, nil), nil
+}
+
+func handleLanguageGrants(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !isAdmin(ctx) {
+		return mcp.NewToolResultError("language_grants requires the server's -auth-token token."), nil
+	}
+	now := time.Now()
+	if id := request.GetString("revoke", ""); id != "" {
+		if !languageGrants.revoke(id, grantName(ctx), now) {
+			return mcp.NewToolResultError(fmt.Sprintf("no language grant %q to revoke", id)), nil
+		}
+	}
+	grants, events := languageGrants.report(now)
+	return newJSONResult(map[string]any{"grants": grants, "audit": events}, nil), nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// defaultSLO is the default of the server's -slo flag.
+const defaultSLO = 2 * time.Second
+
+// Bounds of the latency bookkeeping.
+const (
+	// latencySamples is how many recent durations are kept per tool for the percentiles.
+	latencySamples = 1000
+	// maxSlowCalls is how many traces of slow calls are kept.
+	maxSlowCalls = 50
+	// maxTraceSpans caps the spans recorded for one call.
+	maxTraceSpans = 200
+)
+
+// slo is the latency objective of a tool call, set by the server's -slo flag. Calls taking
+// longer are logged and traced; 0 disables the tracing.
+var slo = defaultSLO
+
+// ToolLatency reports the latency of one tool over its recent calls.
+type ToolLatency struct {
+	Tool  string `json:"tool"`
+	Calls int64  `json:"calls"`
+	// Slow counts the calls that exceeded the SLO since the server started.
+	Slow  int64   `json:"slow"`
+	P50MS float64 `json:"p50_ms"`
+	P90MS float64 `json:"p90_ms"`
+	P99MS float64 `json:"p99_ms"`
+	MaxMS float64 `json:"max_ms"`
+}
+
+// SlowCall is the trace of a tool call that exceeded the SLO.
+type SlowCall struct {
+	Tool       string         `json:"tool"`
+	Arguments  map[string]any `json:"arguments,omitempty"`
+	StartedAt  string         `json:"started_at"`
+	DurationMS float64        `json:"duration_ms"`
+	SLOMS      float64        `json:"slo_ms"`
+	Error      bool           `json:"error,omitempty"`
+	// Spans are the upstream fetches, decoding and parsing done for the call, in start order.
+	Spans []TraceSpan `json:"spans"`
+	// RenderMS is how long encoding the result takes, and ResultBytes its size.
+	RenderMS    float64 `json:"render_ms"`
+	ResultBytes int     `json:"result_bytes"`
+}
+
+// TraceSpan is one timed step of a traced call.
+type TraceSpan struct {
+	// Kind is upstream, decode, annotate, cache or parse.
+	Kind   string `json:"kind"`
+	Lang   string `json:"lang,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	// OffsetMS is when the step started, relative to the start of the call.
+	OffsetMS   float64 `json:"offset_ms"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// latencyStats keeps the recent durations of every tool and the traces of slow calls. It is
+// safe for concurrent use.
+type latencyStats struct {
+	mu        sync.Mutex
+	tools     map[string]*toolSamples
+	slowCalls []SlowCall // oldest first
+}
+
+type toolSamples struct {
+	calls, slow int64
+	recent      []time.Duration // ring of the latest latencySamples durations
+	next        int
+}
+
+var toolLatency = &latencyStats{tools: make(map[string]*toolSamples)}
+
+func (l *latencyStats) record(tool string, d time.Duration, slow bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	samples, ok := l.tools[tool]
+	if !ok {
+		samples = &toolSamples{}
+		l.tools[tool] = samples
+	}
+	samples.calls++
+	if slow {
+		samples.slow++
+	}
+	if len(samples.recent) < latencySamples {
+		samples.recent = append(samples.recent, d)
+	} else {
+		samples.recent[samples.next] = d
+		samples.next = (samples.next + 1) % latencySamples
+	}
+}
+
+func (l *latencyStats) addSlowCall(call SlowCall) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.slowCalls) == maxSlowCalls {
+		l.slowCalls = append(l.slowCalls[:0], l.slowCalls[1:]...)
+	}
+	l.slowCalls = append(l.slowCalls, call)
+}
+
+// report returns the latency of every tool, sorted by name.
+func (l *latencyStats) report() []ToolLatency {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	report := []ToolLatency{}
+	for tool, samples := range l.tools {
+		sorted := append([]time.Duration(nil), samples.recent...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		report = append(report, ToolLatency{
+			Tool:  tool,
+			Calls: samples.calls,
+			Slow:  samples.slow,
+			P50MS: milliseconds(percentile(sorted, 50)),
+			P90MS: milliseconds(percentile(sorted, 90)),
+			P99MS: milliseconds(percentile(sorted, 99)),
+			MaxMS: milliseconds(percentile(sorted, 100)),
+		})
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Tool < report[j].Tool })
+	return report
+}
+
+// recentSlowCalls returns the kept slow call traces, newest first.
+func (l *latencyStats) recentSlowCalls() []SlowCall {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	calls := make([]SlowCall, len(l.slowCalls))
+	for i, call := range l.slowCalls {
+		calls[len(calls)-1-i] = call
+	}
+	return calls
+}
+
+// percentile returns the p-th percentile of sorted durations (nearest rank).
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// activeCall collects the spans of a tool call in progress. Spans are attributed by docset:
+// the steps of concurrent calls on the same docset (e.g. one index download both wait for) are
+// recorded in each of them, and calls without a lang argument receive every span.
+type activeCall struct {
+	lang  string
+	start time.Time
+	mu    sync.Mutex
+	spans []TraceSpan
+}
+
+var (
+	activeCallsMu sync.Mutex
+	activeCalls   = make(map[*activeCall]bool)
+)
+
+// traceSpan records a step that began at start and ends now in the calls in progress on lang.
+// It costs next to nothing while no call is traced.
+func traceSpan(kind, lang, detail string, start time.Time) {
+	end := time.Now()
+	activeCallsMu.Lock()
+	defer activeCallsMu.Unlock()
+	for call := range activeCalls {
+		if call.lang != "" && call.lang != lang {
+			continue
+		}
+		call.mu.Lock()
+		if len(call.spans) < maxTraceSpans {
+			call.spans = append(call.spans, TraceSpan{
+				Kind:       kind,
+				Lang:       lang,
+				Detail:     detail,
+				OffsetMS:   milliseconds(start.Sub(call.start)),
+				DurationMS: milliseconds(end.Sub(start)),
+			})
+		}
+		call.mu.Unlock()
+	}
+}
+
+// langOfPath returns the docset of an upstream path such as "go/index.json".
+func langOfPath(path string) string {
+	lang, _, _ := strings.Cut(path, "/")
+	return lang
+}
+
+// upstreamDetail describes a fetch from a documentation host for its span.
+func upstreamDetail(url string, resp *http.Response, err error) string {
+	if err != nil {
+		return fmt.Sprintf("%s: %v", url, err)
+	}
+	return fmt.Sprintf("%s: %s", url, resp.Status)
+}
+
+// traceLatency is a tool handler middleware recording the latency of every call and tracing
+// the calls that exceed the SLO.
+func traceLatency(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if slo <= 0 {
+			start := time.Now()
+			result, err := next(ctx, request)
+			toolLatency.record(request.Params.Name, time.Since(start), false)
+			return result, err
+		}
+
+		call := &activeCall{lang: request.GetString("lang", ""), start: time.Now()}
+		activeCallsMu.Lock()
+		activeCalls[call] = true
+		activeCallsMu.Unlock()
+
+		result, err := next(ctx, request)
+		elapsed := time.Since(call.start)
+
+		activeCallsMu.Lock()
+		delete(activeCalls, call)
+		activeCallsMu.Unlock()
+
+		slow := elapsed > slo
+		toolLatency.record(request.Params.Name, elapsed, slow)
+		if slow {
+			log.Printf("Slow call: %s took %v (SLO %v)\n", request.Params.Name, elapsed.Round(time.Millisecond), slo)
+			toolLatency.addSlowCall(slowCallTrace(call, request, result, elapsed))
+		}
+		return result, err
+	}
+}
+
+// slowCallTrace builds the trace of a slow call, timing the encoding of its result.
+func slowCallTrace(call *activeCall, request mcp.CallToolRequest, result *mcp.CallToolResult, elapsed time.Duration) SlowCall {
+	trace := SlowCall{
+		Tool:       request.Params.Name,
+		Arguments:  request.GetArguments(),
+		StartedAt:  call.start.UTC().Format(time.RFC3339Nano),
+		DurationMS: milliseconds(elapsed),
+		SLOMS:      milliseconds(slo),
+		Error:      result == nil || result.IsError,
+		Spans:      call.spans,
+	}
+	if trace.Spans == nil {
+		trace.Spans = []TraceSpan{}
+	}
+	sort.SliceStable(trace.Spans, func(i, j int) bool { return trace.Spans[i].OffsetMS < trace.Spans[j].OffsetMS })
+	if result != nil {
+		start := time.Now()
+		data, _ := json.Marshal(result)
+		trace.RenderMS = milliseconds(time.Since(start))
+		trace.ResultBytes = len(data)
+	}
+	return trace
+}
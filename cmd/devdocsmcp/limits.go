@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Defaults of the server's -max-fetches, -rate-limit and -rate-burst flags.
+const (
+	defaultMaxFetches = 8
+	defaultRateLimit  = 5.0
+	defaultRateBurst  = 20
+)
+
+// idleBucketTTL is how long the rate limiter remembers a session that made no calls.
+const idleBucketTTL = 10 * time.Minute
+
+// maxFetches, rateLimit and rateBurst are set by the server's flags.
+var (
+	maxFetches = defaultMaxFetches
+	rateLimit  = defaultRateLimit
+	rateBurst  = defaultRateBurst
+)
+
+// fetchSlots bounds the upstream fetches in flight. A fetch holds its slot until the response
+// body is closed, so the limit also bounds the response bodies held in memory.
+var (
+	fetchSlotsOnce sync.Once
+	fetchSlots     chan struct{}
+)
+
+// acquireFetchSlot waits for a free fetch slot, giving up when ctx is done. The returned
+// function releases the slot; it is nil when fetches are not limited.
+func acquireFetchSlot(ctx context.Context) (func(), error) {
+	fetchSlotsOnce.Do(func() {
+		if maxFetches > 0 {
+			fetchSlots = make(chan struct{}, maxFetches)
+		}
+	})
+	if fetchSlots == nil {
+		return nil, nil
+	}
+	select {
+	case fetchSlots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-fetchSlots }) }, nil
+}
+
+// releasingBody releases a fetch slot when the response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// limitedFetch performs req within a fetch slot.
+func limitedFetch(req *http.Request) (*http.Response, error) {
+	release, err := acquireFetchSlot(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if release == nil {
+		return resp, err
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// tokenBucket holds the calls a session may still make.
+type tokenBucket struct {
+	tokens   float64
+	last     time.Time
+	lastCall time.Time
+}
+
+// sessionLimiter keeps one token bucket per MCP session.
+type sessionLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+// toolRateLimiter limits the tool calls of each session; nil disables rate limiting.
+var toolRateLimiter *sessionLimiter
+
+// newSessionLimiter returns a limiter allowing rate calls per second per session with bursts
+// of up to burst calls, or nil if rate is not positive.
+func newSessionLimiter(rate float64, burst int) *sessionLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &sessionLimiter{rate: rate, burst: math.Max(1, float64(burst)), buckets: make(map[string]*tokenBucket)}
+}
+
+// allow takes a token from the session's bucket. When the bucket is empty it returns how long
+// until the next token is available.
+func (l *sessionLimiter) allow(sessionID string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[sessionID]
+	if !ok {
+		l.prune(now)
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[sessionID] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+	bucket.lastCall = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// prune forgets sessions idle for longer than idleBucketTTL. Callers hold l.mu.
+func (l *sessionLimiter) prune(now time.Time) {
+	for id, bucket := range l.buckets {
+		if now.Sub(bucket.lastCall) > idleBucketTTL {
+			delete(l.buckets, id)
+		}
+	}
+}
+
+// limitRate is a tool handler middleware rejecting the calls of sessions that exceed their
+// rate limit.
+func limitRate(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if toolRateLimiter == nil {
+			return next(ctx, request)
+		}
+		var sessionID string
+		if session := server.ClientSessionFromContext(ctx); session != nil {
+			sessionID = session.SessionID()
+		}
+		if ok, wait := toolRateLimiter.allow(sessionID, time.Now()); !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("Rate limit exceeded for this session (%g calls per second); retry in %s.", toolRateLimiter.rate, wait.Round(time.Millisecond))), nil
+		}
+		return next(ctx, request)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unixScheme prefixes -listen addresses naming a Unix domain socket.
+const unixScheme = "unix://"
+
+// defaultSocketMode lets only the owner of the server process connect to its socket.
+const defaultSocketMode = "0600"
+
+// Listener settings of the HTTP transports, set by the server's -listen and -socket-mode flags.
+var (
+	listenAddr string
+	socketMode = defaultSocketMode
+)
+
+// httpListenAddr returns the address the HTTP transports listen on: -listen if set, otherwise
+// every interface on port.
+func httpListenAddr(port string) string {
+	if listenAddr != "" {
+		return listenAddr
+	}
+	return ":" + port
+}
+
+// listenHTTP opens the listener of the HTTP transports. unix:///path/to.sock listens on a Unix
+// domain socket whose permissions are set from -socket-mode; anything else is a TCP address.
+func listenHTTP(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixScheme)
+	if !ok {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		return ln, nil
+	}
+
+	if path == "" {
+		return nil, fmt.Errorf("invalid -listen %q: expected unix:///path/to.sock", addr)
+	}
+	mode, err := strconv.ParseUint(socketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		return nil, fmt.Errorf("invalid -socket-mode %q: expected octal permissions such as 0600", socketMode)
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	// The socket is created in a directory only the owner can enter and moved into place once
+	// its permissions are set, so it never accepts connections with looser ones
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".sock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the socket %s: %w", path, err)
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "s")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	ln.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, fs.FileMode(mode)); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to set permissions on %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to create the socket %s: %w", path, err)
+	}
+	return unixListener{ln, path}, nil
+}
+
+// unixListener removes its socket when closed, as net.Listen's does; the socket was moved after
+// it was created, so the listener no longer knows its name.
+type unixListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l unixListener) Close() error {
+	err := l.UnixListener.Close()
+	if rmErr := os.Remove(l.path); err == nil && rmErr != nil && !errors.Is(rmErr, fs.ErrNotExist) {
+		err = rmErr
+	}
+	return err
+}
+
+// removeStaleSocket removes a socket left behind by a server that did not shut down cleanly.
+// It refuses to remove a socket another server is still accepting on, or a file that is not a
+// socket.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", path, err)
+	}
+	if info.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("another server is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+	}
+	return nil
+}
+
+// listenURL describes addr for the log: scheme://host:port for TCP, or the socket URL.
+func listenURL(scheme, addr string) string {
+	if strings.HasPrefix(addr, unixScheme) {
+		return addr
+	}
+	return scheme + "://" + addr
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"path/filepath"
+	"time"
+
+	"devdocsmcp/internal/filelock"
+)
+
+const (
+	// docsetLockWait bounds how long a process waits for another one updating the same docset
+	// before going ahead without the lock.
+	docsetLockWait = 2 * time.Minute
+	// docsetLockPoll is how often a held docset lock is tried again.
+	docsetLockPoll = 50 * time.Millisecond
+)
+
+// lockDocset takes the lock serializing updates of the cached index of a documentation set
+// across the processes (and goroutines) sharing the cache directory, so they don't download it
+// at the same time or write a stale gob next to a newer index. waited reports whether the lock
+// was held by someone else first, who may have just refreshed the index. unlock must be called.
+func lockDocset(langSlug string) (unlock func(), waited bool) {
+	path := filepath.Join(cacheDir(), "locks", langSlug+".lock")
+	deadline := time.Now().Add(docsetLockWait)
+	for {
+		lock, err := filelock.TryLock(path)
+		if err == nil {
+			return func() { lock.Unlock() }, waited
+		}
+		if !errors.Is(err, filelock.ErrLocked) {
+			log.Printf("Updating %s without the cache lock: %v\n", langSlug, err)
+			return func() {}, waited
+		}
+		if time.Now().After(deadline) {
+			log.Printf("Updating %s without the cache lock: still held after %v\n", langSlug, docsetLockWait)
+			return func() {}, waited
+		}
+		waited = true
+		time.Sleep(docsetLockPoll)
+	}
+}
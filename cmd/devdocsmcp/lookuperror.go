@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Limits of a lookup_error call.
+const (
+	maxErrorSymbols       = 10
+	maxErrorLookupLangs   = 5
+	defaultErrorLookupMax = 10
+)
+
+// ErrorLookup is the result of a lookup_error call.
+type ErrorLookup struct {
+	Symbols []string     `json:"symbols"`
+	Langs   []string     `json:"langs"`
+	Matches []ErrorMatch `json:"matches"`
+}
+
+// ErrorMatch is an entry documenting one of the symbols found in an error message.
+type ErrorMatch struct {
+	Symbol string `json:"symbol"`
+	Lang   string `json:"lang"`
+	SymbolResult
+}
+
+var (
+	// quotedSymbol matches names quoted in error messages: 'foo', `foo`, "foo" or ‘foo’.
+	quotedSymbol = regexp.MustCompile("['`\"‘“]([A-Za-z_$][\\w$.:]*(?:\\(\\))?)['`\"’”]")
+	// errorType matches exception and error class names such as TypeError or IOException.
+	errorType = regexp.MustCompile(`\b([A-Z]\w*(?:Error|Exception))\b`)
+	// qualifiedName matches dotted or ::-separated names such as os.path.join or Vec::new.
+	qualifiedName = regexp.MustCompile(`\b[A-Za-z_$][\w$]*(?:(?:\.|::)[A-Za-z_$][\w$]*)+`)
+	// calledName matches the name of a call such as parseInt( in a message or stack frame.
+	calledName = regexp.MustCompile(`\b([A-Za-z_$][\w$]*)\(`)
+	// frameName matches the function of a JavaScript or Python stack frame: "at foo (" or
+	// ", in foo".
+	frameName = regexp.MustCompile(`(?m)(?:^\s*at (?:new )?([\w$.]+) \(|, in ([A-Za-z_]\w*)$)`)
+	// sourceFile matches names that are source files rather than symbols.
+	sourceFile = regexp.MustCompile(`\.(?:py|pyc|js|mjs|cjs|jsx|ts|tsx|go|rb|php|rs|java|kt|c|cc|cpp|h|hpp|cs|swift|ex|exs|erl|hs|lua|pl|sh|html|css|json|yml|yaml)$`)
+)
+
+// errorStopwords are words that look like identifiers in stack traces but never name a symbol.
+var errorStopwords = map[string]bool{
+	"at": true, "in": true, "line": true, "file": true, "from": true, "main": true, "func": true,
+	"function": true, "error": true, "traceback": true, "goroutine": true, "panic": true,
+	"anonymous": true, "module": true, "object": true, "null": true, "undefined": true,
+	"none": true, "nil": true, "self": true, "this": true, "new": true, "type": true,
+}
+
+// errorLangHints map patterns in error messages to the docsets (slugs without a version) they
+// point to.
+var errorLangHints = []struct {
+	pattern *regexp.Regexp
+	slugs   []string
+}{
+	{regexp.MustCompile(`Traceback \(most recent call last\)|\.py", line \d+`), []string{"python", "django"}},
+	{regexp.MustCompile(`goroutine \d+ \[|\bpanic: |\.go:\d+`), []string{"go"}},
+	{regexp.MustCompile(`\.(?:m|c)?jsx?:\d+|at .+ \(.+\.js:\d+:\d+\)|ReferenceError|is not a function`), []string{"javascript", "node", "react"}},
+	{regexp.MustCompile(`\.tsx?[(:]\d+|\bTS\d{4}\b`), []string{"typescript", "react"}},
+	{regexp.MustCompile(`\.rb:\d+|NoMethodError|NameError: uninitialized constant`), []string{"ruby", "rails"}},
+	{regexp.MustCompile(`\.php(?: on line |:)\d+|PHP (?:Fatal|Warning|Notice)`), []string{"php", "laravel"}},
+	{regexp.MustCompile(`error\[E\d{4}\]|\.rs:\d+:\d+|thread '.+' panicked`), []string{"rust"}},
+	{regexp.MustCompile(`\.java:\d+\)|Exception in thread "`), []string{"openjdk"}},
+	{regexp.MustCompile(`SQLSTATE|ERROR: .+ at character \d+|psql:`), []string{"postgresql"}},
+}
+
+// LookupError extracts the symbols named in a compiler or runtime error and finds the entries
+// documenting them. Without langs, the docsets are guessed from the message (file extensions,
+// stack trace formats) among those the caller may use, falling back to all of them.
+func LookupError(ctx context.Context, message string, langs []string, limit int) (*ErrorLookup, []string, error) {
+	result := &ErrorLookup{Symbols: extractErrorSymbols(message), Langs: []string{}, Matches: []ErrorMatch{}}
+	if len(result.Symbols) == 0 {
+		return result, []string{"no symbols found in the error message; try search_doc with a keyword"}, nil
+	}
+
+	if len(langs) == 0 {
+		langs = guessErrorLangs(message, callerLanguages(ctx))
+	}
+	var warnings []string
+	if len(langs) > maxErrorLookupLangs {
+		warnings = append(warnings, fmt.Sprintf("only the first %d of %d docsets were searched; pass lang to choose", maxErrorLookupLangs, len(langs)))
+		langs = langs[:maxErrorLookupLangs]
+	}
+	result.Langs = langs
+
+	type ranked struct {
+		match  ErrorMatch
+		symbol int
+		tier   int
+	}
+	var found []ranked
+	for _, lang := range langs {
+		doc, err := fetchIndex(ctx, lang)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("skipped %s: %v", lang, err))
+			continue
+		}
+		for i, symbol := range result.Symbols {
+			symbolMatch := findSymbolIn(doc.Entries, symbol, 0)
+			if symbolMatch.Best == nil {
+				// Qualified names are often documented by their last part: os.path.join as join
+				if last := lastSegment(symbol); last != symbol {
+					symbolMatch = findSymbolIn(doc.Entries, last, 0)
+				}
+			}
+			if symbolMatch.Best == nil {
+				continue
+			}
+			found = append(found, ranked{
+				match:  ErrorMatch{Symbol: symbol, Lang: lang, SymbolResult: SymbolResult{*symbolMatch.Best, symbolMatch.Match}},
+				symbol: i,
+				tier:   symbolTierRank(symbolMatch.Match),
+			})
+		}
+	}
+
+	// Symbols named earlier in the message come first, then better matches
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].symbol != found[j].symbol {
+			return found[i].symbol < found[j].symbol
+		}
+		return found[i].tier < found[j].tier
+	})
+	for _, f := range found {
+		if limit > 0 && len(result.Matches) >= limit {
+			warnings = append(warnings, fmt.Sprintf("matches truncated to %d", limit))
+			break
+		}
+		result.Matches = append(result.Matches, f.match)
+	}
+	if len(result.Matches) == 0 {
+		warnings = append(warnings, fmt.Sprintf("none of the symbols %v is documented in %v", result.Symbols, langs))
+	}
+	return result, warnings, nil
+}
+
+// extractErrorSymbols returns the likely symbols in an error message, most specific first:
+// quoted names and error types, then qualified names, then called functions and stack frames.
+func extractErrorSymbols(message string) []string {
+	var symbols []string
+	seen := make(map[string]bool)
+	add := func(symbol string) {
+		symbol = strings.TrimSuffix(strings.Trim(symbol, ".:"), "()")
+		if len(symbols) >= maxErrorSymbols || len(symbol) < 2 || seen[symbol] ||
+			errorStopwords[strings.ToLower(symbol)] || sourceFile.MatchString(symbol) {
+			return
+		}
+		seen[symbol] = true
+		symbols = append(symbols, symbol)
+	}
+
+	for _, m := range quotedSymbol.FindAllStringSubmatch(message, -1) {
+		add(m[1])
+	}
+	for _, m := range errorType.FindAllStringSubmatch(message, -1) {
+		add(m[1])
+	}
+	for _, m := range qualifiedName.FindAllString(message, -1) {
+		add(m)
+	}
+	for _, m := range calledName.FindAllStringSubmatch(message, -1) {
+		add(m[1])
+	}
+	for _, m := range frameName.FindAllStringSubmatch(message, -1) {
+		add(m[1] + m[2])
+	}
+	return symbols
+}
+
+// guessErrorLangs returns the docsets among allowed that the message points to, or all of
+// allowed if it gives no hint.
+func guessErrorLangs(message string, allowed []string) []string {
+	var langs []string
+	seen := make(map[string]bool)
+	for _, hint := range errorLangHints {
+		if !hint.pattern.MatchString(message) {
+			continue
+		}
+		for _, slug := range hint.slugs {
+			for _, lang := range allowed {
+				name, _, _ := strings.Cut(lang, "~")
+				if name == slug && !seen[lang] {
+					seen[lang] = true
+					langs = append(langs, lang)
+				}
+			}
+		}
+	}
+	if len(langs) == 0 {
+		return allowed
+	}
+	return langs
+}
+
+// callerLanguages returns the served languages the caller may use, sorted.
+func callerLanguages(ctx context.Context) []string {
+	var langs []string
+	for lang := range servedLanguages() {
+		if isLanguageAllowed(ctx, lang) {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// lastSegment returns the part of a qualified name after its last "." or "::".
+func lastSegment(name string) string {
+	if i := strings.LastIndexAny(name, ".:"); i >= 0 && i+1 < len(name) {
+		return name[i+1:]
+	}
+	return name
+}
+
+// symbolTierRank orders find_symbol match tiers, best first.
+func symbolTierRank(tier string) int {
+	switch tier {
+	case symbolExact:
+		return 0
+	case symbolCaseInsensitive:
+		return 1
+	default:
+		return 2
+	}
+}
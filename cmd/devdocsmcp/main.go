@@ -3,27 +3,58 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"devdocsmcp/internal/config"
+	"devdocsmcp/internal/docs/classify"
+	"devdocsmcp/internal/docs/entrypath"
+	"devdocsmcp/internal/docs/page"
+	"devdocsmcp/internal/httpclient"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/sirupsen/logrus"
 )
 
-const (
-	docsBaseURL = "https://documents.devdocs.io/"
-)
-
 // DocEntry represents a single entry within a documentation set
 type DocEntry struct {
 	Name string `json:"name"`
 	Path string `json:"path"`
+	Type string `json:"type,omitempty"`
+	// Kind classifies the entry as reference or guide content; it is derived, not part of index.json
+	Kind string `json:"kind,omitempty"`
+	// Breadcrumbs locate the entry within its documentation set; also derived
+	Breadcrumbs []Breadcrumb `json:"breadcrumbs,omitempty"`
+	// Highlights show where a search query matched the entry; only set on search results
+	Highlights []Highlight `json:"highlights,omitempty"`
+	// Lang is the docset of the entry; only set on results of a namespace-wide search
+	Lang string `json:"lang,omitempty"`
+	// Score is the relevance of the entry's page in the docset's local full-text index, and
+	// Snippet an excerpt of the page with the matches in <mark> tags; only set on fulltext
+	// search results when the docset was downloaded with its index
+	Score   float64 `json:"score,omitempty"`
+	Snippet string  `json:"snippet,omitempty"`
+}
+
+// DocType represents an entry type of a documentation set (e.g. Method, Event, Property)
+type DocType struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	Slug  string `json:"slug"`
 }
 
 // Doc represents a documentation index (from index.json)
@@ -31,69 +62,261 @@ type Doc struct {
 	Name    string     `json:"name"`
 	Version string     `json:"version"`
 	Entries []DocEntry `json:"entries"`
+	Types   []DocType  `json:"types"`
 }
 
 var allowedLanguages map[string]bool
 
+// allowedMu guards allowedLanguages, which a config reload replaces while tools are running.
+// The map itself is never modified after it is built.
+var allowedMu sync.RWMutex
+
+// activeConfig holds the settings loaded from the config file, replaced by a config reload
+// while tools are running; read it with currentConfig.
+var activeConfig atomic.Pointer[config.Config]
+
+// currentConfig returns the active configuration, nil before it is loaded.
+func currentConfig() *config.Config {
+	return activeConfig.Load()
+}
+
 func main() {
+	// Keep the recent log lines for diagnostics
+	log.SetOutput(io.MultiWriter(os.Stderr, recentLogs))
+	logrus.SetOutput(io.MultiWriter(os.Stderr, recentLogs))
+
 	// Define subcommands
 	searchCmd := flag.NewFlagSet("search", flag.ExitOnError)
 	searchLang := searchCmd.String("lang", "", "Language slug to search within (e.g., html, angularjs~1.8)")
 	searchQuery := searchCmd.String("query", "", "Search query")
+	searchMode := searchCmd.String("mode", modeFulltext, "Match mode: exact, prefix, fuzzy or fulltext")
+	searchLimit := searchCmd.Int("limit", 0, "Maximum number of results to print (0 for all)")
+	searchOffset := searchCmd.Int("offset", 0, "Number of results to skip")
+	searchKind := searchCmd.String("kind", "", "Only return entries of this kind: reference or guide")
+	searchType := searchCmd.String("type", "", "Only return entries of this entry type (e.g. Method, Event)")
+	searchPathPrefix := searchCmd.String("path-prefix", "", "Only return entries whose path starts with this prefix (e.g. net/http)")
+	searchRevision := searchCmd.String("revision", "", "Search a stored snapshot: a revision mtime or a date (e.g. 2024-01-31)")
+	searchCmd.BoolVar(&offline, "offline", false, "Never access the network; search only locally downloaded documentation")
+	searchCmd.StringVar(&mirrorDir, "mirror-dir", mirrorDir, "Local mirror used in offline mode, and whose full-text indexes are searched")
 
 	readCmd := flag.NewFlagSet("read", flag.ExitOnError)
 	readLang := readCmd.String("lang", "", "Language slug to read from")
 	readPath := readCmd.String("path", "", "Path to the documentation entry (e.g., reference/elements/a)")
-	
+	readOffset := readCmd.Int("offset", 0, "Byte offset to start reading from")
+	readLength := readCmd.Int("length", 500, "Maximum number of bytes to print (0 for the whole page)")
+	readRevision := readCmd.String("revision", "", "Read a stored snapshot: a revision mtime or a date (e.g. 2024-01-31)")
+	readOmitExamples := readCmd.Bool("omit-examples", false, "Drop the code examples, leaving the prose")
+	readExamplesOnly := readCmd.Bool("examples-only", false, "Print only the code examples, under their headings")
+	readOmitTables := readCmd.Bool("omit-tables", false, "Drop tables")
+	readCmd.BoolVar(&offline, "offline", false, "Never access the network; read only locally downloaded documentation")
+	readCmd.StringVar(&mirrorDir, "mirror-dir", mirrorDir, "Local mirror used in offline mode")
+
 	serverCmd := flag.NewFlagSet("server", flag.ExitOnError)
 	serverPort := serverCmd.String("port", "8080", "Port for the HTTP server to listen on")
+	serverTransport := serverCmd.String("transport", transportStdio, "Transport to serve MCP over: stdio, sse or streamable-http (the HTTP transports listen on -port or -listen)")
+	serverCmd.StringVar(&listenAddr, "listen", "", "Address for the HTTP transports instead of -port: host:port, or unix:///path/to.sock for a Unix domain socket")
+	serverCmd.StringVar(&socketMode, "socket-mode", defaultSocketMode, "Octal permissions of the -listen Unix socket (e.g. 0660 to let the group connect)")
+	serverCmd.StringVar(&docsBaseURLFlag, "docs-base-url", "", "Comma-separated documentation hosts tried in order, failing over to the next when one is down (default: $DEVDOCSMCP_DOCS_BASE_URL, the config's docs_base_urls or "+defaultDocsBaseURL+")")
+	serverCmd.StringVar(&authToken, "auth-token", "", "Bearer token HTTP clients must send in the Authorization header (default: $DEVDOCSMCP_TOKEN)")
+	serverCmd.StringVar(&tlsCertFile, "tls-cert", "", "PEM certificate to serve the HTTP transports over HTTPS")
+	serverCmd.StringVar(&tlsKeyFile, "tls-key", "", "PEM private key of -tls-cert")
+	serverCmd.BoolVar(&tlsSelfSigned, "tls-self-signed", false, "Serve HTTPS with a generated self-signed certificate (for development)")
+	serverTransports := serverCmd.String("transports", "", "Comma-separated list of transports to serve at the same time, e.g. stdio,streamable-http (overrides -transport)")
 	serverLangs := serverCmd.String("lang", "", "Comma-separated list of language slugs to serve (e.g., html,css)")
+	serverConfig := serverCmd.String("config", "", "Path to the config file (default: $DEVDOCSMCP_CONFIG or the user config directory)")
+	serverBridge := serverCmd.Bool("bridge", false, "Connect to the MCP servers listed under 'bridges' in the config file and federate their tools")
+	serverCmd.BoolVar(&webUI, "web", false, "Also serve a minimal HTML UI on "+webEndpoint+" to search and read the served docs in a browser (needs an HTTP transport)")
+	serverTruncationMarker := serverCmd.String("truncation-marker", markerJSON, "How truncated output announces the call to continue: json, text or off")
+	serverCmd.IntVar(&maxResponseBytes, "max-response-bytes", defaultMaxResponseBytes, "Largest page read_doc_content returns in one call; longer pages are cut at a section boundary with a next_offset to continue (0 for no limit)")
+	serverCmd.DurationVar(&drainTimeout, "drain-timeout", defaultDrainTimeout, "How long in-flight requests may finish after SIGINT or SIGTERM before they are cancelled")
+	serverCmd.DurationVar(&revisionCheckInterval, "refresh-interval", defaultRevisionCheckInterval, "How often docsets with subscribed resources are checked for a new revision")
+	serverCmd.BoolVar(&prewarm, "prewarm", false, "Fetch and cache the index of every served language in the background at startup, so the first calls don't wait for the documentation host")
+	serverCmd.IntVar(&prewarmPages, "prewarm-pages", 0, "With -prewarm, also cache this many of the most linked pages of each language")
+	serverCmd.BoolVar(&staleWhileRevalidate, "stale-while-revalidate", false, "Serve expired cached indexes and pages immediately, marked stale, while they are refreshed in the background")
+	serverCmd.DurationVar(&negativeCacheTTL, "negative-cache-ttl", defaultNegativeCacheTTL, "How long a page or docset the documentation host answered 404 for is reported missing without asking again (0 disables the negative cache)")
+	serverCmd.Var(&cacheMaxSize, "cache-max-size", "Largest size of the cache directory, e.g. 2GB; above it the least recently used cached pages are evicted, never indexes (default 0, no limit)")
+	serverCmd.StringVar(&cacheBackendFlag, "cache-backend", "", "Where downloaded pages are cached: files, one per page, or bbolt, a single database file that is easier to back up (default: $DEVDOCSMCP_CACHE_BACKEND, the config's cache_backend or files)")
+	serverCmd.DurationVar(&cacheTTL, "cache-ttl", defaultCacheTTL, "How long downloaded indexes and pages are served from the disk cache before they are fetched again (0 disables the cache)")
+	serverCmd.IntVar(&indexCacheEntries, "index-cache-entries", defaultIndexCacheEntries, "Number of parsed indexes kept in memory for back-to-back searches (0 disables the in-memory cache)")
+	serverCmd.IntVar(&indexCacheMB, "index-cache-mb", defaultIndexCacheMB, "Approximate memory cap, in MiB, of the parsed indexes kept in memory (0 for no cap)")
+	serverCmd.DurationVar(&slo, "slo", defaultSLO, "Latency objective of a tool call; slower calls are logged and traced for server_diagnostics (0 disables the tracing)")
+	serverCmd.BoolVar(&offline, "offline", false, "Never access the network: serve only documentation downloaded beforehand (disk cache at any age, -mirror-dir, snapshots)")
+	serverCmd.StringVar(&mirrorDir, "mirror-dir", mirrorDir, "Local mirror, as written by 'mirror sync' or 'download', served in offline mode; its full-text indexes are searched by search_doc")
+	serverCmd.BoolVar(&peerSharing, "peers", false, "Discover other devdocsmcp servers on the local network (mDNS) and fetch missing indexes and pages from them before the documentation host, sharing this server's cache with them in turn")
+	serverCmd.StringVar(&peerListen, "peer-listen", "", "Address the cache is shared with peers on when -peers is set (default: port "+defaultPeerPort+" on the host of -listen)")
+	serverCmd.StringVar(&peerToken, "peer-token", "", "Secret shared by the peers of -peers, authenticating their requests and answers (default: $"+peerTokenEnv+")")
+	serverCmd.IntVar(&maxFetches, "max-fetches", defaultMaxFetches, "Maximum number of simultaneous fetches from the documentation host (0 for no limit)")
+	serverCmd.Float64Var(&rateLimit, "rate-limit", defaultRateLimit, "Tool calls per second allowed per MCP session (0 disables rate limiting)")
+	serverCmd.IntVar(&rateBurst, "rate-burst", defaultRateBurst, "Number of tool calls a session may make in a burst above -rate-limit")
 
 	allowedLangsCmd := flag.NewFlagSet("allowed-langs", flag.ExitOnError)
 
+	// Let background cache writes finish before a short-lived command exits, and keep the cache
+	// counters it recorded
+	defer flushCacheCounters()
+	defer cacheWrites.Wait()
+
 	// Parse the main command-line arguments
 	if len(os.Args) < 2 {
 		printUsage()
 		return
 	}
+	if err := initCacheEncryption(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	recoverCacheTxs()
+	if err := initUpstreams(nil); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
 
 	switch os.Args[1] {
 	case "search":
 		searchCmd.Parse(os.Args[2:])
+		enforceOffline()
 		if *searchLang == "" || *searchQuery == "" {
 			log.Fatal("Error: -lang and -query are required for search command.")
 		}
-		searchResults, err := SearchDoc(*searchLang, *searchQuery)
+		if err := validateLangs([]string{*searchLang}); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		searchOpts := SearchOptions{Mode: *searchMode, Kind: *searchKind, Type: *searchType, PathPrefix: *searchPathPrefix, Revision: *searchRevision}
+		searchResults, err := SearchDoc(context.Background(), *searchLang, *searchQuery, searchOpts)
 		if err != nil {
 			log.Printf("Error searching docs: %v\n", err)
 		} else if len(searchResults) == 0 {
 			fmt.Println("No results found.")
+			if suggestions := suggestQueries(context.Background(), []string{*searchLang}, *searchQuery, searchOpts); len(suggestions) > 0 {
+				fmt.Printf("Did you mean %s?\n", quoteJoin(suggestions))
+			}
 		} else {
-			fmt.Printf("Search results for '%s' in %s:\n", *searchQuery, *searchLang)
-			for _, entry := range searchResults {
+			page := paginate(searchResults, *searchOffset, *searchLimit, 0)
+			fmt.Printf("Search results for '%s' in %s (%d matches):\n", *searchQuery, *searchLang, page.TotalMatches)
+			if facets := searchFacets(searchResults, *searchLang); len(facets.Type.Values) > 0 {
+				fmt.Printf("By type: %s\n", facets.Type)
+			}
+			for _, entry := range page.Results {
 				fmt.Printf("  - %s (Path: %s)\n", entry.Name, entry.Path)
+				if entry.Snippet != "" {
+					fmt.Printf("      %s\n", strings.Join(strings.Fields(entry.Snippet), " "))
+				}
+			}
+			if page.hasMore {
+				fmt.Printf("(more results, continue with -offset %d)\n", page.Offset+len(page.Results))
 			}
 		}
 	case "read":
 		readCmd.Parse(os.Args[2:])
+		enforceOffline()
 		if *readLang == "" || *readPath == "" {
 			log.Fatal("Error: -lang and -path are required for read command.")
 		}
-		content, err := ReadDocContent(*readLang, *readPath)
+		if err := validateLangs([]string{*readLang}); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		content, err := ReadDocContentAt(context.Background(), *readLang, *readPath, *readRevision)
+		if err == nil {
+			content, err = page.Filter{OmitExamples: *readOmitExamples, ExamplesOnly: *readExamplesOnly, OmitTables: *readOmitTables}.Apply(content)
+		}
 		if err != nil {
 			log.Printf("Error reading doc content: %v\n", err)
 		} else {
 			fmt.Printf("Content for %s/%s:\n", *readLang, *readPath)
 			// Print only a snippet to avoid flooding the console
-			fmt.Printf("\n--- Content Snippet ---\n%s\n...\n", content[:500])
+			snippet, next := sliceContent(content, *readOffset, *readLength)
+			fmt.Printf("\n--- Content Snippet ---\n%s\n", snippet)
+			if next > 0 {
+				resume := fmt.Sprintf("devdocsmcp read -lang %s -path %s -offset %d -length %d", *readLang, *readPath, next, *readLength)
+				if *readRevision != "" {
+					resume += " -revision " + *readRevision
+				}
+				if *readOmitExamples {
+					resume += " -omit-examples"
+				}
+				if *readExamplesOnly {
+					resume += " -examples-only"
+				}
+				if *readOmitTables {
+					resume += " -omit-tables"
+				}
+				fmt.Printf("...\n(truncated, continue with: %s)\n", resume)
+			}
 		}
 	case "server":
 		serverCmd.Parse(os.Args[2:])
+		if err := loadConfig(*serverConfig); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		serverConfigPath, serverLangFlag = *serverConfig, *serverLangs
+		if *serverLangs == "" {
+			*serverLangs = strings.Join(currentConfig().Langs, ",")
+		}
 		if *serverLangs == "" {
 			log.Fatal("Error: -lang is required for the server command. Please specify a comma-separated list of languages.")
 		}
-		initAllowedLanguages(*serverLangs)
-		startMcpServer(*serverPort)
+		if err := checkCacheBackend(); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := setTruncationMarkerStyle(*serverTruncationMarker); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if *serverTransports == "" {
+			*serverTransports = *serverTransport
+		}
+		transports, err := parseTransports(*serverTransports)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if webUI && len(transports) == 1 && transports[0] == transportStdio {
+			log.Fatal("Error: -web needs an HTTP transport (sse or streamable-http).")
+		}
+		if authToken == "" {
+			authToken = os.Getenv(authTokenEnv)
+		}
+		if peerToken == "" {
+			peerToken = os.Getenv(peerTokenEnv)
+		}
+		if peerSharing && !offline && peerToken == "" {
+			log.Fatalf("Error: -peers needs a secret shared by the peers in -peer-token or $%s.", peerTokenEnv)
+		}
+		langs := currentConfig().ExpandBundles(strings.Split(*serverLangs, ","))
+		if err := validateLangs(langs); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		initAllowedLanguages(strings.Join(langs, ","))
+		initTokenGrants(currentConfig().Tokens)
+		startMcpServer(*serverPort, transports, *serverBridge)
+	case "mirror":
+		runMirror(os.Args[2:])
+	case "download":
+		runDownload(os.Args[2:])
+	case "update":
+		runUpdate(os.Args[2:])
+	case "index":
+		runIndex(os.Args[2:])
+	case "export":
+		runExport(os.Args[2:])
+	case "import":
+		runImport(os.Args[2:])
+	case "cache":
+		runCache(os.Args[2:])
+	case "bench":
+		runBench(os.Args[2:])
+	case "db":
+		runDB(os.Args[2:])
+	case "snapshot":
+		runSnapshot(os.Args[2:])
+	case "entries":
+		runEntries(os.Args[2:])
+	case "mcp-config":
+		runMcpConfig(os.Args[2:])
+	case "import-prefs":
+		runImportPrefs(os.Args[2:])
+	case "version":
+		runVersion()
+	case "diagnostics":
+		runDiagnostics(os.Args[2:])
+	case "self-update":
+		runSelfUpdate(os.Args[2:])
 	case "allowed-langs":
 		allowedLangsCmd.Parse(os.Args[2:])
 		// This command is meant to be run after the server has been configured with --lang
@@ -103,6 +326,7 @@ func main() {
 		// or we'll print a message if it's not.
 		if allowedLanguages == nil {
 			fmt.Println("No specific languages configured. All languages are allowed.")
+			fmt.Println("(This command cannot see a running server's configuration; connected clients can call its allowed_langs tool.)")
 		} else if len(allowedLanguages) == 0 {
 			fmt.Println("No languages are explicitly allowed.")
 		} else {
@@ -119,58 +343,441 @@ func main() {
 func printUsage() {
 	fmt.Println("Usage: devdocsmcp <command> [arguments]")
 	fmt.Println("Commands:")
-	fmt.Println("  search   -lang <language_slug> -query <search_query>")
-	fmt.Println("  read     -lang <language_slug> -path <entry_path>")
-	fmt.Println("  server   [-port <port_number>] -lang <comma_separated_languages> (starts HTTP server)")
+	fmt.Println("  search   -lang <language_slug> -query <search_query> [-mode exact|prefix|fuzzy|fulltext] [-limit <n>] [-offset <n>] [-kind reference|guide] [-type <entry_type>] [-path-prefix <prefix>] [-revision <mtime_or_date>] [-offline [-mirror-dir <dir>]]")
+	fmt.Println("  read     -lang <language_slug> -path <entry_path> [-offset <bytes>] [-length <bytes>] [-revision <mtime_or_date>] [-omit-examples | -examples-only] [-omit-tables] [-offline [-mirror-dir <dir>]]")
+	fmt.Println("  server   [-transport stdio|sse|streamable-http] [-transports <comma_separated_transports>] [-port <port_number> | -listen <host:port|unix:///path.sock> [-socket-mode <octal>]] [-docs-base-url <comma_separated_urls>] [-auth-token <token>] [-tls-cert <file> -tls-key <file> | -tls-self-signed] -lang <comma_separated_languages> [-config <file>] [-bridge] [-web] [-truncation-marker json|text|off] [-max-response-bytes <n>] [-cache-ttl <duration>] [-cache-max-size <size>] [-cache-backend files|bbolt] [-prewarm [-prewarm-pages <n>]] [-stale-while-revalidate] [-negative-cache-ttl <duration>] [-index-cache-entries <n>] [-index-cache-mb <mib>] [-slo <duration>] [-refresh-interval <duration>] [-offline [-mirror-dir <dir>]] [-peers -peer-token <secret> [-peer-listen <host:port>]] [-drain-timeout <duration>] [-max-fetches <n>] [-rate-limit <calls_per_second>] [-rate-burst <n>] (starts HTTP server)")
+	fmt.Println("  download -lang <comma_separated_languages> [-dest <dir>] (downloads whole docsets with a full-text index for offline use)")
+	fmt.Println("  update   [-lang <comma_separated_languages>] [-dest <dir>] (re-downloads the downloaded docsets that changed upstream)")
+	fmt.Println("  index    [-lang <comma_separated_languages>] [-dest <dir>] [-batch-size <pages>] [-workers <n>] (rebuilds the full-text indexes of downloaded docsets)")
+	fmt.Println("  export   -lang <comma_separated_languages> [-dest <dir>] [-out <file.tar.zst>] (packs downloaded docsets into an archive)")
+	fmt.Println("  import   -file <file.tar.zst> [-dest <dir>] (installs a doc pack written by export)")
+	fmt.Println("  mirror sync [-dest <dir>] [-lang <comma_separated_languages>] [-interval <duration>] [-listen <addr>] [-index] (maintains a local mirror)")
+	fmt.Println("  mcp-config -client claude|cursor|vscode|zed -lang <comma_separated_languages> [server flags] (prints client configuration)")
+	fmt.Println("  import-prefs -file <devdocs_settings.json> [-config <file>] [-mirror] [-dest <dir>] (imports the docsets selected on devdocs.io)")
+	fmt.Println("  entries  download|list|remove [-lang <comma_separated_languages>] (keeps docset indexes for offline search, reading pages remotely)")
+	fmt.Println("  snapshot save|list -lang <comma_separated_languages> (keeps historical docset revisions)")
+	fmt.Println("  bench    -lang <comma_separated_languages> [-searches <n>] [-reads <n>] [-queries <comma_separated_queries>] [-backends remote,cache,memory,fulltext] [-concurrency <n>] [-seed <n>] [-dest <dir>] [-json] (compares the latency of the backends on a workload)")
+	fmt.Println("  cache    stats [-json] | purge -lang <comma_separated_languages>|-all [-snapshots] | gc [-older-than-days <n>] [-max-mb <mib>] [-dry-run] (inspects and trims the cache)")
+	fmt.Println("  db       inspect|compact|repair [-path <file>] (maintains the metadata store)")
+	fmt.Println("  version  (prints the version, commit and build date)")
+	fmt.Println("  diagnostics bundle [-out <file.zip>] [-config <file>] [-log <file>] (writes a sanitized zip to attach to bug reports)")
+	fmt.Println("  self-update [-check] [-version <tag>] [-force] (installs the latest verified release)")
 	fmt.Println("  allowed-langs (displays languages allowed by the server configuration)")
 }
 
+// loadConfig loads the config file at path, or at the default location when path is empty.
+func loadConfig(path string) error {
+	cfg, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	return applyConfig(cfg)
+}
+
+// readConfig reads the config file at path, or at the default location when path is empty.
+func readConfig(path string) (*config.Config, error) {
+	if path == "" {
+		defaultPath, err := config.DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+		path = defaultPath
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ResolveMember = resolveBundleMember
+	return cfg, nil
+}
+
+// applyConfig makes cfg the active configuration. It may run while tools are running: the
+// configuration, transports and upstreams are all replaced atomically.
+func applyConfig(cfg *config.Config) error {
+	if offline {
+		enforceOffline()
+	} else if err := httpclient.InstallSources(cfg.SourceTLS); err != nil {
+		return err
+	}
+	if err := initUpstreams(cfg.DocsBaseURLs); err != nil {
+		return err
+	}
+	activeConfig.Store(cfg)
+	return nil
+}
+
 func initAllowedLanguages(langs string) {
-	allowedLanguages = make(map[string]bool)
+	allowed := make(map[string]bool)
 	if langs == "" {
 		// This case should now be caught by the flag parsing in main, but as a safeguard
 		log.Fatal("Error: initAllowedLanguages called with empty language list.")
 	}
 	for _, lang := range strings.Split(langs, ",") {
-		allowedLanguages[strings.TrimSpace(lang)] = true
+		allowed[strings.TrimSpace(lang)] = true
 	}
+	allowedMu.Lock()
+	allowedLanguages = allowed
+	allowedMu.Unlock()
 	log.Printf("Server will serve documentation for languages: %v\n", strings.Split(langs, ","))
 }
 
-func isLanguageAllowed(lang string) bool {
-	if allowedLanguages == nil { // This case should ideally not be reached if initAllowedLanguages enforces non-empty
+// servedLanguages returns the set of languages the server currently serves. The caller must
+// not modify it.
+func servedLanguages() map[string]bool {
+	allowedMu.RLock()
+	defer allowedMu.RUnlock()
+	return allowedLanguages
+}
+
+// isLanguageAllowed reports whether lang is served and the request's bearer token, if any,
+// may use it, or whether the call carries a temporary grant of lang (see grant_language).
+func isLanguageAllowed(ctx context.Context, lang string) bool {
+	if grantedTemporarily(ctx, lang) {
+		return true
+	}
+	if !grantAllows(ctx, lang) {
+		return false
+	}
+	allowed := servedLanguages()
+	if allowed == nil { // This case should ideally not be reached if initAllowedLanguages enforces non-empty
 		return true // Fallback: if somehow not initialized, allow all
 	}
-	return allowedLanguages[lang]
+	return allowed[lang]
 }
 
-func startMcpServer(port string) {
+func startMcpServer(port string, transports []string, bridge bool) {
 	log.Printf("Starting DevDocsMCP server on port %s...\n", port)
+	if offline {
+		log.Printf("Offline mode: serving only local documentation (cache, %s, snapshots)\n", mirrorDir)
+	}
+	toolRateLimiter = newSessionLimiter(rateLimit, rateBurst)
+	activeTransports = transports
 
 	s := server.NewMCPServer(
 		"DevDocs MCP",
-		"1.0.0",
+		buildInfo().Version,
 		server.WithToolCapabilities(false),
+		server.WithResourceCapabilities(false, false),
 		server.WithRecovery(),
+		server.WithInstructions(serverInstructions()),
+		server.WithToolHandlerMiddleware(refuseWhileDraining),
+		server.WithToolHandlerMiddleware(trackInFlight),
+		server.WithToolHandlerMiddleware(recordUsage),
+		server.WithToolHandlerMiddleware(traceLatency),
+		server.WithToolHandlerMiddleware(reportStale),
+		server.WithToolHandlerMiddleware(limitRate),
+		server.WithToolHandlerMiddleware(redeemLanguageGrant),
+		server.WithToolFilter(advertiseGrantArgument),
+	)
+
+	// Expose docsets and pages as resources
+	registerResources(s)
+
+	// Define and add the allowed_langs tool
+	allowedLangsTool := mcp.NewTool("allowed_langs",
+		mcp.WithDescription("Lists the language slugs (documentation sets) you may use with this server's tools, with their names and versions."),
 	)
+	s.AddTool(allowedLangsTool, handleAllowedLangs)
 
 	// Define and add the search_doc tool
 	searchDocTool := mcp.NewTool("search_doc",
-		mcp.WithDescription("Searches for a query within the documentation entries of a specific language."),
+		mcp.WithDescription("Searches for a query within the documentation entries of a specific language, or of every language of a namespace."),
 		mcp.WithString("lang",
-			mcp.Required(),
-			mcp.Description("The language slug (e.g., html, angularjs~1.8)."),
+			mcp.Description("The language slug (e.g., html, angularjs~1.8). Required unless namespace is set."),
+		),
+		mcp.WithString("namespace",
+			mcp.Description("Scope the search to a namespace from list_namespaces (e.g. frontend). Without lang, every docset of the namespace is searched and each result carries its lang."),
 		),
 		mcp.WithString("query",
 			mcp.Required(),
-			mcp.Description("The search query."),
+			mcp.Description("The search query. In fulltext mode it may use \"quoted phrases\", AND, OR, NOT, parentheses and the field prefixes title:, type:, path: and content: (e.g. title:ParseInt AND NOT type:method); terms without an operator must all match."),
+		),
+		mcp.WithString("mode",
+			mcp.Description("How the query is matched against entry names: exact, prefix, fuzzy (typo-tolerant) or fulltext (substring of name or path, plus the page text when the docset has a local full-text index; the default)."),
+			mcp.Enum(modeExact, modePrefix, modeFuzzy, modeFulltext),
+		),
+		mcp.WithString("kind",
+			mcp.Description("Only return entries of this kind: reference (API pages) or guide (tutorials and explanations)."),
+			mcp.Enum(classify.Reference, classify.Guide),
+		),
+		mcp.WithString("type",
+			mcp.Description("Only return entries of this entry type (e.g. Method, Event); see list_entry_types."),
+		),
+		mcp.WithString("path_prefix",
+			mcp.Description("Only return entries whose path starts with this prefix (e.g. net/http), to scope a search to one module or section."),
+		),
+		mcp.WithString("revision",
+			mcp.Description("Serve a stored snapshot instead of the current docs: a revision mtime from list_revisions, or a date (2024-01-31) to get the newest snapshot published by then."),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of results to return (default %d).", defaultSearchLimit)),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of matches to skip, for paging (default 0). Prefer cursor."),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("Opaque next_cursor of the previous page, to continue the same search after its last result. Unlike offset, it never repeats or skips results when the index is refreshed between pages."),
+		),
+		mcp.WithNumber("max_results",
+			mcp.Description(fmt.Sprintf("Upper bound on how deep paging may go; matches past this position are never returned (default %d).", defaultMaxResults)),
 		),
 	)
 	s.AddTool(searchDocTool, handleSearchDoc)
 
+	// Define and add the search_batch tool
+	searchBatchTool := mcp.NewTool("search_batch",
+		mcp.WithDescription("Searches for several queries within the documentation entries of a specific language in one call, returning results grouped by query."),
+		mcp.WithString("lang",
+			mcp.Required(),
+			mcp.Description("The language slug (e.g., html, angularjs~1.8)."),
+		),
+		mcp.WithArray("queries",
+			mcp.Required(),
+			mcp.Description("The search queries (e.g., [\"useEffect\", \"useMemo\", \"useRef\"])."),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("mode",
+			mcp.Description("How each query is matched against entry names: exact, prefix, fuzzy or fulltext (the default)."),
+			mcp.Enum(modeExact, modePrefix, modeFuzzy, modeFulltext),
+		),
+		mcp.WithString("kind",
+			mcp.Description("Only return entries of this kind: reference (API pages) or guide (tutorials and explanations)."),
+			mcp.Enum(classify.Reference, classify.Guide),
+		),
+		mcp.WithString("type",
+			mcp.Description("Only return entries of this entry type (e.g. Method, Event); see list_entry_types."),
+		),
+		mcp.WithString("path_prefix",
+			mcp.Description("Only return entries whose path starts with this prefix (e.g. net/http), to scope a search to one module or section."),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of results per query (default %d).", defaultBatchLimit)),
+		),
+	)
+	s.AddTool(searchBatchTool, handleSearchBatch)
+
+	// Define and add the find_symbol tool
+	findSymbolTool := mcp.NewTool("find_symbol",
+		mcp.WithDescription("Finds the documentation entry for a symbol by exact name, falling back to case-insensitive and whole-word matches. Returns the best entry plus alternates."),
+		mcp.WithString("lang",
+			mcp.Required(),
+			mcp.Description("The language slug (e.g., html, angularjs~1.8)."),
+		),
+		mcp.WithString("symbol",
+			mcp.Required(),
+			mcp.Description("The symbol name (e.g., map, Array.prototype.map, useEffect)."),
+		),
+		mcp.WithNumber("alternates",
+			mcp.Description(fmt.Sprintf("Maximum number of alternate entries to return (default %d).", defaultSymbolAlternates)),
+		),
+	)
+	s.AddTool(findSymbolTool, handleFindSymbol)
+
+	// Define and add the lookup_error tool
+	lookupErrorTool := mcp.NewTool("lookup_error",
+		mcp.WithDescription("Turns a raw compiler or runtime error (message or stack trace) into documentation lookups: extracts the symbols it names, picks the relevant docsets, and returns the entries documenting those symbols."),
+		mcp.WithString("error",
+			mcp.Required(),
+			mcp.Description("The error message or stack trace, verbatim."),
+		),
+		mcp.WithString("lang",
+			mcp.Description("Comma-separated language slugs to search (default: guessed from the error among the allowed languages)."),
+		),
+		mcp.WithString("namespace",
+			mcp.Description("Scope the lookup to a namespace from list_namespaces; without lang, the docsets are guessed among the namespace's."),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of entries to return (default %d).", defaultErrorLookupMax)),
+		),
+	)
+	s.AddTool(lookupErrorTool, handleLookupError)
+
 	// Define and add the read_doc_content tool
 	readDocContentTool := mcp.NewTool("read_doc_content",
 		mcp.WithDescription("Reads the content of a specific documentation HTML file."),
+		mcp.WithString("lang",
+			mcp.Description("The language slug (e.g., html, angularjs~1.8). Required unless namespace is set."),
+		),
+		mcp.WithString("namespace",
+			mcp.Description("Scope the read to a namespace from list_namespaces. Without lang, the page is read from the first docset of the namespace that has it."),
+		),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description(fmt.Sprintf("The path to the documentation entry (e.g., reference/elements/a), or a glob matching several pages (e.g., reference/global_objects/array/*; a trailing /** matches every page below). A glob returns up to %d matching pages in one response, each under a '==> lang/path <==' label; it requires lang and excludes the other options. See expand_paths.", maxGlobReadPages)),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Byte offset to start reading from (default 0)."),
+		),
+		mcp.WithNumber("max_length",
+			mcp.Description("Maximum number of bytes to return (default 0, meaning the whole page up to the server's response size limit)."),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: 'html' returns the raw page (default); 'structured' returns JSON with the title, sections (heading, level, text, code blocks) and page metadata."),
+			mcp.Enum(formatHTML, formatStructured),
+		),
+		mcp.WithString("revision",
+			mcp.Description("Serve a stored snapshot instead of the current docs: a revision mtime from list_revisions, or a date (2024-01-31) to get the newest snapshot published by then."),
+		),
+		mcp.WithBoolean("omit_examples",
+			mcp.Description("Drop the code examples and return only the prose (default false). Offsets refer to the filtered page."),
+		),
+		mcp.WithBoolean("examples_only",
+			mcp.Description("Return only the code examples, each under the headings it appears below (default false). Excludes omit_examples."),
+		),
+		mcp.WithBoolean("omit_tables",
+			mcp.Description("Drop tables (default false)."),
+		),
+	)
+	s.AddTool(readDocContentTool, handleReadDocContent)
+
+	// Define and add the expand_paths tool
+	expandPathsTool := mcp.NewTool("expand_paths",
+		mcp.WithDescription("Lists the pages of a documentation set whose paths match a glob pattern, e.g. reference/global_objects/array/*. Use it to see what a glob passed to read_doc_content would read."),
+		mcp.WithString("lang",
+			mcp.Required(),
+			mcp.Description("The language slug (e.g., html, angularjs~1.8)."),
+		),
+		mcp.WithString("pattern",
+			mcp.Required(),
+			mcp.Description("The glob: '*' and '?' match within one path segment, [abc] matches a character class, and a trailing /** matches every page below the prefix."),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of paths to return (default %d, at most %d).", defaultExpandLimit, maxExpandLimit)),
+		),
+	)
+	s.AddTool(expandPathsTool, handleExpandPaths)
+
+	// Define and add the list_namespaces tool
+	listNamespacesTool := mcp.NewTool("list_namespaces",
+		mcp.WithDescription("Lists the namespaces configured on this server (e.g. frontend, backend of a monorepo): the docsets each scopes search_doc, read_doc_content and lookup_error to, and the prefixes of the bridged tools serving its own documentation."),
+	)
+	s.AddTool(listNamespacesTool, handleListNamespaces)
+
+	// Define and add the server_info tool
+	serverInfoTool := mcp.NewTool("server_info",
+		mcp.WithDescription("Reports the server's version, commit, build date, uptime, transports and the languages you may use. Include it when reporting a problem with the server."),
+	)
+	s.AddTool(serverInfoTool, handleServerInfo)
+
+	// Define and add the session_usage tool
+	sessionUsageTool := mcp.NewTool("session_usage",
+		mcp.WithDescription("Admin: reports per-session usage since the server started: tool calls by tool, errors, bytes served, languages requested, and the token and client of each session. Not available to tokens restricted to some languages."),
+	)
+	s.AddTool(sessionUsageTool, handleSessionUsage)
+
+	// Define and add the server_diagnostics tool
+	serverDiagnosticsTool := mcp.NewTool("server_diagnostics",
+		mcp.WithDescription("Admin: returns a sanitized fingerprint of the server for bug reports: version, runtime, settings, config with secrets redacted, documentation host health, cache and index statistics, and recent log lines. Same data as 'devdocsmcp diagnostics bundle'. Not available to tokens restricted to some languages."),
+	)
+	s.AddTool(serverDiagnosticsTool, handleServerDiagnostics)
+
+	// Define and add the reload_config tool
+	reloadConfigTool := mcp.NewTool("reload_config",
+		mcp.WithDescription("Admin: re-reads the server's config file (language list, bundles, tokens, source TLS) and cache key, and applies them without dropping sessions. Same as sending SIGHUP. Not available to tokens restricted to some languages."),
+	)
+	s.AddTool(reloadConfigTool, handleReloadConfig)
+
+	// Minting grants needs a real admin credential: without -auth-token the agent the
+	// language allow-list restricts could grant itself any language
+	if authToken != "" {
+		// Define and add the grant_language tool
+		grantLanguageTool := mcp.NewTool("grant_language",
+			mcp.WithDescription("Admin: mints a short-lived grant enabling one extra language, e.g. for a one-off lookup in a docset the server doesn't serve or a token doesn't cover. The caller passes the returned grant as the grant argument of its tool calls; the first session to use it owns it. Every use is audited (see language_grants). Requires the server's -auth-token token."),
+			mcp.WithString("lang",
+				mcp.Required(),
+				mcp.Description("The language slug to enable (e.g., rust)."),
+			),
+			mcp.WithString("reason",
+				mcp.Required(),
+				mcp.Description("Why the grant is issued, recorded in the audit trail."),
+			),
+			mcp.WithString("expires_in",
+				mcp.Description(fmt.Sprintf("How long the grant is valid, as a duration (default %s, at most %s).", defaultGrantTTL, maxGrantTTL)),
+			),
+			mcp.WithNumber("max_calls",
+				mcp.Description("Maximum number of tool calls the grant may be used for (default 0, no limit)."),
+			),
+		)
+		s.AddTool(grantLanguageTool, handleGrantLanguage)
+
+		// Define and add the language_grants tool
+		languageGrantsTool := mcp.NewTool("language_grants",
+			mcp.WithDescription("Admin: lists the temporary language grants with their state, calls and owning session, and the audit trail of their issue, uses, refusals and revocations. Requires the server's -auth-token token."),
+			mcp.WithString("revoke",
+				mcp.Description("The id of a grant to revoke first."),
+			),
+		)
+		s.AddTool(languageGrantsTool, handleLanguageGrants)
+	}
+
+	// Define and add the get_docset_toc tool
+	getDocsetTOCTool := mcp.NewTool("get_docset_toc",
+		mcp.WithDescription("Returns the table of contents of a documentation set: a tree with one section per entry type, holding its pages arranged by path and the entries inside each page. Use it to get a map of the documentation before searching."),
+		mcp.WithString("lang",
+			mcp.Required(),
+			mcp.Description("The language slug (e.g., html, angularjs~1.8)."),
+		),
+		mcp.WithString("type",
+			mcp.Description("Only return the section of this entry type (e.g. Method), see list_entry_types."),
+		),
+		mcp.WithNumber("max_depth",
+			mcp.Description(fmt.Sprintf("Maximum depth of the tree, sections being depth 1 (default %d, 0 for the full tree).", defaultTOCDepth)),
+		),
+	)
+	s.AddTool(getDocsetTOCTool, handleGetDocsetTOC)
+
+	// Define and add the list_revisions tool
+	listRevisionsTool := mcp.NewTool("list_revisions",
+		mcp.WithDescription("Lists the historical snapshots of a documentation set stored on this server, newest first. Pass a revision to search_doc or read_doc_content to use one."),
+		mcp.WithString("lang",
+			mcp.Required(),
+			mcp.Description("The language slug (e.g., html, angularjs~1.8)."),
+		),
+	)
+	s.AddTool(listRevisionsTool, handleListRevisions)
+
+	// Define and add the read_many tool
+	readManyTool := mcp.NewTool("read_many",
+		mcp.WithDescription("Reads several documentation pages in one call within a total size budget. Pages are fetched concurrently; when they don't fit, the budget is shared fairly and long pages are cut at section boundaries. Truncated pages are flagged and carry the read_doc_content call that continues them."),
+		mcp.WithArray("entries",
+			mcp.Required(),
+			mcp.Description(fmt.Sprintf("The pages to read, as objects with lang and path (at most %d).", maxReadManyEntries)),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"lang": map[string]any{"type": "string", "description": "The language slug (e.g., html)."},
+					"path": map[string]any{"type": "string", "description": "The path to the documentation entry."},
+				},
+				"required": []string{"lang", "path"},
+			}),
+		),
+		mcp.WithNumber("max_total_bytes",
+			mcp.Description(fmt.Sprintf("Maximum combined size of the returned content in bytes (default %d, 0 for no limit).", defaultReadManyBudget)),
+		),
+	)
+	s.AddTool(readManyTool, handleReadMany)
+
+	// Define and add the search_in_page tool
+	searchInPageTool := mcp.NewTool("search_in_page",
+		mcp.WithDescription("Searches within a single documentation page: splits it into sections by heading and returns only the sections matching the query, with heading breadcrumbs. Useful for very long pages."),
+		mcp.WithString("lang",
+			mcp.Required(),
+			mcp.Description("The language slug (e.g., html, angularjs~1.8)."),
+		),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("The path to the documentation entry (e.g., display)."),
+		),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The terms to look for; a section matches when it contains all of them."),
+		),
+	)
+	s.AddTool(searchInPageTool, handleSearchInPage)
+
+	// Define and add the summarize_entry tool
+	summarizeEntryTool := mcp.NewTool("summarize_entry",
+		mcp.WithDescription("Returns the title and first paragraph (short description) of a documentation entry, with a link to the full page. Much cheaper than reading the whole page."),
 		mcp.WithString("lang",
 			mcp.Required(),
 			mcp.Description("The language slug (e.g., html, angularjs~1.8)."),
@@ -180,114 +787,927 @@ func startMcpServer(port string) {
 			mcp.Description("The path to the documentation entry (e.g., reference/elements/a)."),
 		),
 	)
-	s.AddTool(readDocContentTool, handleReadDocContent)
+	s.AddTool(summarizeEntryTool, handleSummarizeEntry)
+
+	// Define and add the get_entry_url tool
+	getEntryURLTool := mcp.NewTool("get_entry_url",
+		mcp.WithDescription("Returns the canonical https://devdocs.io/<slug>/<path> link of a documentation entry, after checking that the entry exists, so it can be shown to the user as a clickable reference."),
+		mcp.WithString("lang",
+			mcp.Required(),
+			mcp.Description("The language slug (e.g., html, angularjs~1.8)."),
+		),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("The path to the documentation entry (e.g., reference/elements/a)."),
+		),
+	)
+	s.AddTool(getEntryURLTool, handleGetEntryURL)
+
+	// Define and add the related_entries tool
+	relatedEntriesTool := mcp.NewTool("related_entries",
+		mcp.WithDescription("Lists entries related to a documentation entry: siblings under the same path prefix and entries linked from its page."),
+		mcp.WithString("lang",
+			mcp.Required(),
+			mcp.Description("The language slug (e.g., html, angularjs~1.8)."),
+		),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("The path to the documentation entry (e.g., reference/elements/a)."),
+		),
+	)
+	s.AddTool(relatedEntriesTool, handleRelatedEntries)
+
+	// Define and add the compare_versions tool
+	compareVersionsTool := mcp.NewTool("compare_versions",
+		mcp.WithDescription("Compares the same documentation entry across two versions of a documentation set (e.g. node~18 and node~20) and returns a unified diff of the page text."),
+		mcp.WithString("from",
+			mcp.Required(),
+			mcp.Description("The language slug of the older version (e.g., node~18)."),
+		),
+		mcp.WithString("to",
+			mcp.Required(),
+			mcp.Description("The language slug of the newer version (e.g., node~20)."),
+		),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("The path to the documentation entry (e.g., fs)."),
+		),
+	)
+	s.AddTool(compareVersionsTool, handleCompareVersions)
+
+	// Define and add the resolve_slug tool
+	resolveSlugTool := mcp.NewTool("resolve_slug",
+		mcp.WithDescription("Resolves a human name and optional version (e.g. \"React 18\", \"Postgres 15\", \"python latest\") to the canonical devdocs language slug (e.g. react~18, postgresql~15)."),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("The technology name, optionally followed by a version or 'latest'."),
+		),
+	)
+	s.AddTool(resolveSlugTool, handleResolveSlug)
+
+	// Define and add the list_entry_types tool
+	listEntryTypesTool := mcp.NewTool("list_entry_types",
+		mcp.WithDescription("Lists the entry types of a documentation set (e.g. Method, Event, Property for dom) with entry counts, for use as the search_doc type filter."),
+		mcp.WithString("lang",
+			mcp.Required(),
+			mcp.Description("The language slug (e.g., html, angularjs~1.8)."),
+		),
+	)
+	s.AddTool(listEntryTypesTool, handleListEntryTypes)
+
+	// Define and add the doc_info tool
+	docInfoTool := mcp.NewTool("doc_info",
+		mcp.WithDescription("Reports metadata and statistics for a documentation set: version, release, last update, entry counts per type, and whether it is cached locally."),
+		mcp.WithString("lang",
+			mcp.Required(),
+			mcp.Description("The language slug (e.g., html, angularjs~1.8)."),
+		),
+	)
+	s.AddTool(docInfoTool, handleDocInfo)
+
+	// Define and add the index_stats tool
+	indexStatsTool := mcp.NewTool("index_stats",
+		mcp.WithDescription("Reports the health of the full-text indexes of the docsets downloaded into the server's -mirror-dir: state (ready, outdated, incomplete or missing), documents, page files, size on disk, when each was last written and its analyzer. With verify, samples documents and page files to detect drift between the index and the files."),
+		mcp.WithString("lang",
+			mcp.Description("Only report this language slug (default: every downloaded docset you may use)."),
+		),
+		mcp.WithBoolean("verify",
+			mcp.Description("Check a random sample of indexed documents against their page files (missing or changed since the index was written) and of page files against the index (not indexed)."),
+		),
+		mcp.WithNumber("sample",
+			mcp.Description(fmt.Sprintf("How many documents and as many page files verify checks per docset (default %d, at most %d).", defaultVerifySample, maxVerifySample)),
+		),
+	)
+	s.AddTool(indexStatsTool, handleIndexStats)
+
+	// Define and add the subscribe_resource and unsubscribe_resource tools
+	subscribeResourceTool := mcp.NewTool("subscribe_resource",
+		mcp.WithDescription("Subscribes this session to changes of a devdocs:// resource (a docset devdocs://<slug> or a page devdocs://<slug>/<path>). When a background check finds a new revision of the docset, the server sends notifications/resources/updated for the resource, meaning previously fetched content is stale."),
+		mcp.WithString("uri",
+			mcp.Required(),
+			mcp.Description("The resource URI (e.g., devdocs://html/element/a)."),
+		),
+	)
+	s.AddTool(subscribeResourceTool, handleSubscribeResource)
+
+	unsubscribeResourceTool := mcp.NewTool("unsubscribe_resource",
+		mcp.WithDescription("Stops notifications for a resource subscribed with subscribe_resource."),
+		mcp.WithString("uri",
+			mcp.Required(),
+			mcp.Description("The resource URI."),
+		),
+	)
+	s.AddTool(unsubscribeResourceTool, handleUnsubscribeResource)
+	go watchRevisions(s, revisionCheckInterval)
+
+	if bridge {
+		for _, c := range startBridges(s, currentConfig().Bridges) {
+			onShutdown(func() { c.Close() })
+		}
+	}
 
-	// Start the server in Stdio mode (as per MCP server configuration)
-	if err := server.ServeStdio(s); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go reloadOnHangup(ctx)
+	if prewarm {
+		go prewarmLanguages(ctx)
+	}
+	if cacheMaxSize > 0 {
+		go enforceCacheQuota(ctx)
+	}
+	if peerSharing && !offline {
+		if err := startPeerSharing(ctx); err != nil {
+			log.Printf("Peer sharing disabled: %v\n", err)
+		}
+	}
+
+	// Start the server on the selected transports (stdio by default, as per MCP server configuration)
+	if err := serve(ctx, s, transports, port); err != nil {
 		logrus.Printf("Server error: %v", err)
 	}
+	log.Println("Shutting down")
+	drain()
 }
 
 func handleSearchDoc(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	lang, err := request.RequireString("lang")
-	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+	lang := request.GetString("lang", "")
+	namespace := request.GetString("namespace", "")
+	if lang == "" && namespace == "" {
+		return mcp.NewToolResultError("lang is required unless namespace is set"), nil
 	}
 	query, err := request.RequireString("query")
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	if !isLanguageAllowed(lang) {
-		return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+	var langs []string
+	if lang == "" {
+		if langs, err = namespaceLangs(ctx, namespace); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	} else {
+		if !isLanguageAllowed(ctx, lang) {
+			return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+		}
+		if err := checkNamespace(ctx, namespace, lang); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 	}
 
-	results, err := SearchDoc(lang, query)
-	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+	mode := request.GetString("mode", modeFulltext)
+	kind := request.GetString("kind", "")
+	entryType := request.GetString("type", "")
+	pathPrefix := request.GetString("path_prefix", "")
+	revision := request.GetString("revision", "")
+	limit := request.GetInt("limit", defaultSearchLimit)
+	offset := request.GetInt("offset", 0)
+	cursor := request.GetString("cursor", "")
+	maxResults := request.GetInt("max_results", defaultMaxResults)
+
+	opts := SearchOptions{Mode: mode, Kind: kind, Type: entryType, PathPrefix: pathPrefix, Revision: revision}
+	scope := lang
+	if lang == "" {
+		scope = "namespace:" + namespace
+	}
+	fingerprint := searchFingerprint(scope, query, opts)
+	var after *searchCursor
+	if cursor != "" {
+		if after, err = decodeCursor(cursor, fingerprint); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 	}
 
-	jsonResults, err := json.Marshal(results)
+	var results []DocEntry
+	var warnings []string
+	if lang == "" {
+		results, warnings, err = SearchNamespace(ctx, langs, query, opts)
+	} else {
+		results, err = SearchDoc(ctx, lang, query, opts)
+	}
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	return mcp.NewToolResultText(string(jsonResults)), nil
-}
+	if mode == modeFuzzy && len(results) > 0 {
+		warnings = append(warnings, "fuzzy match used: results may not contain the query verbatim")
+	}
 
-func handleReadDocContent(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	lang, err := request.RequireString("lang")
-	if err != nil {
+	var page SearchPage
+	if after != nil {
+		page = pageAt(results, seekCursor(results, after, query, mode), after.Position, limit, maxResults)
+	} else {
+		page = paginate(results, offset, limit, maxResults)
+	}
+	if len(results) > 0 {
+		page.Facets = searchFacets(results, lang)
+	} else if lang != "" {
+		page.DidYouMean = suggestQueries(ctx, []string{lang}, query, opts)
+	} else {
+		page.DidYouMean = suggestQueries(ctx, langs, query, opts)
+	}
+	if page.hasMore {
+		last := page.Results[len(page.Results)-1]
+		page.NextCursor = encodeCursor(fingerprint, last, query, mode, page.Offset+len(page.Results))
+	}
+	if page.hasMore {
+		warnings = append(warnings, fmt.Sprintf("results truncated: returned %d of %d matches starting at offset %d", len(page.Results), page.TotalMatches, page.Offset))
+	} else if maxResults > 0 && page.TotalMatches > maxResults {
+		warnings = append(warnings, fmt.Sprintf("results truncated: only the first %d of %d matches can be paged (max_results)", maxResults, page.TotalMatches))
+	}
+	if page.hasMore && truncationMarkerStyle != markerOff {
+		page.Next = &ResumeCall{
+			Name: "search_doc",
+			Arguments: map[string]any{
+				"query":       query,
+				"mode":        mode,
+				"kind":        kind,
+				"type":        entryType,
+				"path_prefix": pathPrefix,
+				"limit":       page.Limit,
+				"cursor":      page.NextCursor,
+				"max_results": maxResults,
+			},
+		}
+		if lang != "" {
+			page.Next.Arguments["lang"] = lang
+		}
+		if namespace != "" {
+			page.Next.Arguments["namespace"] = namespace
+		}
+		if revision != "" {
+			page.Next.Arguments["revision"] = revision
+		}
+	}
+
+	return newJSONResult(page, warnings), nil
+}
+
+func handleSearchBatch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lang, err := request.RequireString("lang")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	queries, err := request.RequireStringSlice("queries")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if len(queries) == 0 {
+		return mcp.NewToolResultError("queries must contain at least one query"), nil
+	}
+	if len(queries) > maxBatchQueries {
+		return mcp.NewToolResultError(fmt.Sprintf("too many queries: %d (maximum %d)", len(queries), maxBatchQueries)), nil
+	}
+
+	if !isLanguageAllowed(ctx, lang) {
+		return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+	}
+
+	mode := request.GetString("mode", modeFulltext)
+	kind := request.GetString("kind", "")
+	entryType := request.GetString("type", "")
+	pathPrefix := request.GetString("path_prefix", "")
+	limit := request.GetInt("limit", defaultBatchLimit)
+
+	results, err := SearchBatch(ctx, lang, queries, SearchOptions{Mode: mode, Kind: kind, Type: entryType, PathPrefix: pathPrefix}, limit)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var warnings []string
+	for _, result := range results {
+		if len(result.Results) < result.TotalMatches {
+			warnings = append(warnings, fmt.Sprintf("results for %q truncated: returned %d of %d matches", result.Query, len(result.Results), result.TotalMatches))
+		}
+	}
+	if mode == modeFuzzy {
+		warnings = append(warnings, "fuzzy match used: results may not contain the queries verbatim")
+	}
+
+	return newJSONResult(struct {
+		Results []BatchResult `json:"results"`
+	}{results}, warnings), nil
+}
+
+func handleFindSymbol(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lang, err := request.RequireString("lang")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	symbol, err := request.RequireString("symbol")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if !isLanguageAllowed(ctx, lang) {
+		return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+	}
+
+	alternates := request.GetInt("alternates", defaultSymbolAlternates)
+
+	result, err := FindSymbol(ctx, lang, symbol, alternates)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var warnings []string
+	if result.Best == nil {
+		warnings = append(warnings, fmt.Sprintf("no entry named %q; try search_doc with mode fuzzy", symbol))
+	} else if result.Match != symbolExact {
+		warnings = append(warnings, fmt.Sprintf("no exact match for %q; best entry matched by %s", symbol, result.Match))
+	}
+
+	return newJSONResult(result, warnings), nil
+}
+
+func handleListRevisions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lang, err := request.RequireString("lang")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if !isLanguageAllowed(ctx, lang) {
+		return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+	}
+
+	revisions, err := ListRevisions(lang)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var warnings []string
+	if len(revisions) == 0 {
+		warnings = append(warnings, fmt.Sprintf("no snapshots of %s are stored on this server", lang))
+	}
+	return newJSONResult(map[string]any{"lang": lang, "revisions": revisions}, warnings), nil
+}
+
+func handleLookupError(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	message, err := request.RequireString("error")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	namespace := request.GetString("namespace", "")
+	var langs []string
+	if list := request.GetString("lang", ""); list != "" {
+		for _, lang := range strings.Split(list, ",") {
+			lang = strings.TrimSpace(lang)
+			if lang == "" {
+				continue
+			}
+			if !isLanguageAllowed(ctx, lang) {
+				return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+			}
+			if err := checkNamespace(ctx, namespace, lang); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			langs = append(langs, lang)
+		}
+	}
+	if len(langs) == 0 && namespace != "" {
+		scoped, err := namespaceLangs(ctx, namespace)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		langs = guessErrorLangs(message, scoped)
+	}
+
+	result, warnings, err := LookupError(ctx, message, langs, request.GetInt("limit", defaultErrorLookupMax))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return newJSONResult(result, warnings), nil
+}
+
+func handleReadDocContent(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lang := request.GetString("lang", "")
+	namespace := request.GetString("namespace", "")
+	if lang == "" && namespace == "" {
+		return mcp.NewToolResultError("lang is required unless namespace is set"), nil
+	}
+	path, err := request.RequireString("path")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if lang == "" && isGlob(path) {
+		return mcp.NewToolResultError("lang is required with a glob path"), nil
+	}
+	if lang == "" {
+		langs, err := namespaceLangs(ctx, namespace)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if lang, err = namespaceLangOf(ctx, langs, path); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	} else {
+		if !isLanguageAllowed(ctx, lang) {
+			return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+		}
+		if err := checkNamespace(ctx, namespace, lang); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+
+	if isGlob(path) {
+		for _, option := range []string{"offset", "max_length", "format", "revision", "omit_examples", "examples_only", "omit_tables"} {
+			if _, ok := request.GetArguments()[option]; ok {
+				return mcp.NewToolResultError(fmt.Sprintf("%s can't be used with a glob path; read the pages one by one instead", option)), nil
+			}
+		}
+		return readGlob(ctx, lang, path)
+	}
+
+	offset := request.GetInt("offset", 0)
+	maxLength := request.GetInt("max_length", 0)
+	format := request.GetString("format", formatHTML)
+	if format != formatHTML && format != formatStructured {
+		return mcp.NewToolResultError(fmt.Sprintf("unknown format %q (expected %s or %s)", format, formatHTML, formatStructured)), nil
+	}
+
+	revision := request.GetString("revision", "")
+	filter := page.Filter{
+		OmitExamples: request.GetBool("omit_examples", false),
+		ExamplesOnly: request.GetBool("examples_only", false),
+		OmitTables:   request.GetBool("omit_tables", false),
+	}
+
+	content, err := ReadDocContentAt(ctx, lang, path, revision)
+	if err != nil {
+		return notFoundResult(err), nil
+	}
+	var warnings []string
+	if filtered, err := filter.Apply(content); errors.Is(err, page.ErrConflictingFilters) {
+		return mcp.NewToolResultError(err.Error()), nil
+	} else if err != nil {
+		warnings = append(warnings, fmt.Sprintf("failed to filter the page (%v); returning it unfiltered", err))
+	} else {
+		content = filtered
+	}
+
+	if format == formatStructured {
+		structured, structureWarnings := StructurePage(lang, path, content)
+		warnings = append(warnings, structureWarnings...)
+		if offset != 0 || maxLength != 0 {
+			warnings = append(warnings, "offset and max_length are ignored with the structured format")
+		}
+		return newJSONResult(structured, warnings), nil
+	}
+
+	chunk, next := sliceContent(content, offset, maxLength)
+	reason := "max_length reached"
+	chunk, next, capped := capChunk(content, chunk, next)
+	if capped {
+		reason = fmt.Sprintf("page exceeds the server's %d-byte response limit; cut at a section boundary", maxResponseBytes)
+	}
+	if next > 0 {
+		warnings = append(warnings, fmt.Sprintf("content truncated: returned bytes %d-%d of %d", next-len(chunk), next, len(content)))
+		resume := ResumeCall{
+			Name: "read_doc_content",
+			Arguments: map[string]any{
+				"lang":       lang,
+				"path":       path,
+				"offset":     next,
+				"max_length": maxLength,
+			},
+		}
+		if revision != "" {
+			resume.Arguments["revision"] = revision
+		}
+		addFilterArguments(resume.Arguments, filter)
+		chunk += formatTruncationMarker(reason, next, resume)
+	}
+
+	result := newTextResult(chunk, warnings)
+	if next > 0 {
+		result.Meta["next_offset"] = next
+	}
+	result.Meta["breadcrumbs"] = readBreadcrumbs(lang, path, content)
+	if namespace != "" {
+		result.Meta["lang"] = lang
+	}
+	return result, nil
+}
+
+func handleReadMany(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	rawEntries, ok := request.GetArguments()["entries"].([]any)
+	if !ok || len(rawEntries) == 0 {
+		return mcp.NewToolResultError("entries must be a non-empty array of {lang, path} objects"), nil
+	}
+	if len(rawEntries) > maxReadManyEntries {
+		return mcp.NewToolResultError(fmt.Sprintf("too many entries: %d (maximum %d)", len(rawEntries), maxReadManyEntries)), nil
+	}
+	refs := make([]PageRef, 0, len(rawEntries))
+	for i, raw := range rawEntries {
+		entry, _ := raw.(map[string]any)
+		lang, _ := entry["lang"].(string)
+		path, _ := entry["path"].(string)
+		if lang == "" || path == "" {
+			return mcp.NewToolResultError(fmt.Sprintf("entries[%d] must have a lang and a path", i)), nil
+		}
+		refs = append(refs, PageRef{Lang: lang, Path: path})
+	}
+
+	maxTotalBytes := request.GetInt("max_total_bytes", defaultReadManyBudget)
+
+	pages := ReadMany(ctx, refs, maxTotalBytes)
+
+	var warnings []string
+	totalBytes := 0
+	for _, p := range pages {
+		totalBytes += len(p.Content)
+		if p.Truncated {
+			warnings = append(warnings, fmt.Sprintf("%s/%s truncated: returned %d of %d bytes", p.Lang, p.Path, len(p.Content), p.Bytes))
+		}
+		if p.Error != "" {
+			warnings = append(warnings, fmt.Sprintf("%s/%s could not be read: %s", p.Lang, p.Path, p.Error))
+		}
+	}
+
+	return newJSONResult(struct {
+		TotalBytes    int        `json:"total_bytes"`
+		MaxTotalBytes int        `json:"max_total_bytes"`
+		Pages         []ManyPage `json:"pages"`
+	}{totalBytes, maxTotalBytes, pages}, warnings), nil
+}
+
+func handleSearchInPage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lang, err := request.RequireString("lang")
+	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 	path, err := request.RequireString("path")
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
+	query, err := request.RequireString("query")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
-	if !isLanguageAllowed(lang) {
+	if !isLanguageAllowed(ctx, lang) {
 		return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
 	}
 
-	content, err := ReadDocContent(lang, path)
+	result, warnings, err := SearchInPage(ctx, lang, path, query)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	return mcp.NewToolResultText(content), nil
+	return newJSONResult(result, warnings), nil
 }
 
-// fetchIndex fetches the index.json for a given language slug.
-func fetchIndex(langSlug string) (*Doc, error) {
-	indexURL := fmt.Sprintf("%s%s/index.json", docsBaseURL, langSlug)
-	log.Printf("Fetching index.json from: %s\n", indexURL)
-	resp, err := http.Get(indexURL)
+func handleSummarizeEntry(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lang, err := request.RequireString("lang")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	path, err := request.RequireString("path")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if !isLanguageAllowed(ctx, lang) {
+		return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+	}
+
+	summary, warnings, err := SummarizeEntry(ctx, lang, path)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if summary.Summary == "" {
+		warnings = append(warnings, "no descriptive paragraph found on the page; read the full entry instead")
+	}
+
+	return newJSONResult(summary, warnings), nil
+}
+
+func handleGetEntryURL(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lang, err := request.RequireString("lang")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	path, err := request.RequireString("path")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if !isLanguageAllowed(ctx, lang) {
+		return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+	}
+
+	entryURL, err := GetEntryURL(ctx, lang, path)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var warnings []string
+	if !entryURL.Exact {
+		warnings = append(warnings, fmt.Sprintf("%q is not an index entry itself; the link points at its page %s", path, stripFragment(path)))
+	}
+
+	return newJSONResult(entryURL, warnings), nil
+}
+
+func handleRelatedEntries(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lang, err := request.RequireString("lang")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	path, err := request.RequireString("path")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if !isLanguageAllowed(ctx, lang) {
+		return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+	}
+
+	related, err := FindRelatedEntries(ctx, lang, path)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return newJSONResult(related, nil), nil
+}
+
+func handleCompareVersions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	from, err := request.RequireString("from")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	to, err := request.RequireString("to")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	path, err := request.RequireString("path")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	for _, lang := range []string{from, to} {
+		if !isLanguageAllowed(ctx, lang) {
+			return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+		}
+	}
+
+	comparison, warnings, err := CompareVersions(ctx, from, to, path)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return newJSONResult(comparison, warnings), nil
+}
+
+func handleResolveSlug(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := request.RequireString("name")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	resolution, err := ResolveSlug(ctx, name)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var warnings []string
+	if len(resolution.Candidates) == 0 {
+		warnings = append(warnings, fmt.Sprintf("no documentation set matches %q", name))
+	} else if resolution.Slug == "" {
+		warnings = append(warnings, "matching documentation sets exist but none is allowed by this server configuration")
+	}
+
+	return newJSONResult(resolution, warnings), nil
+}
+
+func handleListEntryTypes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lang, err := request.RequireString("lang")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if !isLanguageAllowed(ctx, lang) {
+		return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+	}
+
+	types, err := ListEntryTypes(ctx, lang)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return newJSONResult(struct {
+		Lang  string    `json:"lang"`
+		Types []DocType `json:"types"`
+	}{lang, types}, nil), nil
+}
+
+func handleDocInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lang, err := request.RequireString("lang")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if !isLanguageAllowed(ctx, lang) {
+		return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+	}
+
+	info, err := GetDocInfo(ctx, lang)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return newJSONResult(info, nil), nil
+}
+
+// fetchIndex fetches the index.json for a given language slug. Recently used indexes are
+// served from memory; the returned Doc is shared and must not be modified. In offline mode the
+// index comes from local copies only. With -stale-while-revalidate, an expired cached index is
+// served as is while it is refreshed in the background, and noted in the tool call of ctx.
+func fetchIndex(ctx context.Context, langSlug string) (*Doc, error) {
+	if doc, ok := parsedIndexes.get(langSlug); ok {
+		return doc, nil
+	}
+	doc, loaded, ok := loadCachedIndex(langSlug)
+	countCacheLookup(langSlug, false, ok)
+	if !ok && staleWhileRevalidate && !offline {
+		if stale, downloaded, found := loadStaleIndex(langSlug); found {
+			path := langSlug + "/index.json"
+			noteStale(ctx, langSlug, path, downloaded)
+			refreshInBackground(path, func() error {
+				_, err := downloadIndex(langSlug)
+				return err
+			})
+			// Not kept in memory, so the refreshed index is picked up as soon as it is stored
+			return annotatedCopy(langSlug, stale), nil
+		}
+	}
+	if !ok {
+		var err error
+		if offline {
+			doc, err = loadOfflineIndex(langSlug)
+		} else {
+			doc, err = downloadIndex(langSlug)
+		}
+		if err != nil {
+			return nil, err
+		}
+		loaded = time.Now()
+	}
+	annotated := annotatedCopy(langSlug, doc)
+	parsedIndexes.add(langSlug, annotated, loaded)
+	return annotated, nil
+}
+
+// downloadIndex downloads the index.json of a documentation set and caches it. A stale cached
+// index that upstream reports unchanged is reused instead, and so is an index another process
+// downloaded while this one waited for the docset's lock. The returned Doc is shared with the
+// background cache writer and must not be modified.
+func downloadIndex(langSlug string) (*Doc, error) {
+	path := langSlug + "/index.json"
+	if missingPaths.has(path) {
+		return nil, docsetNotFound(langSlug)
+	}
+	requested := time.Now()
+	unlock, waited := lockDocset(langSlug)
+	defer unlock()
+	if waited {
+		// Another process sharing the cache may have just downloaded it
+		if doc, downloaded, ok := loadCachedIndex(langSlug); ok && !downloaded.Before(requested) {
+			return doc, nil
+		}
+	}
+	log.Printf("Fetching index.json of %s\n", langSlug)
+	resp, err := revalidate(path, cachedFile(filepath.Join(indexCacheDir(langSlug), "index.json")))
+	if err == nil && resp == nil {
+		if doc, _, ok := loadCachedIndex(langSlug); ok {
+			return doc, nil
+		}
+		resp, err = fetchUpstream(path)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch index.json for %s: %w", langSlug, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		missingPaths.add(path)
+		return nil, docsetNotFound(langSlug)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("failed to fetch index.json for %s: status code %d - %s", langSlug, resp.StatusCode, resp.Status)
 	}
 
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index.json for %s: %w", langSlug, err)
+	}
 	var doc Doc
-	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+	start := time.Now()
+	if err := json.Unmarshal(raw, &doc); err != nil {
 		return nil, fmt.Errorf("failed to decode index.json for %s: %w", langSlug, err)
 	}
+	traceSpan("decode", langSlug, fmt.Sprintf("index.json, %d bytes", len(raw)), start)
+	storeIndex(langSlug, raw, &doc)
+	recordValidators(path, resp)
 	return &doc, nil
 }
 
-// SearchDoc searches for a query within the documentation entries of a specific language.
-func SearchDoc(langSlug, query string) ([]DocEntry, error) {
-	var results []DocEntry
+// annotateEntries fills in the derived fields of every entry.
+func annotateEntries(langSlug string, doc *Doc) {
+	for i := range doc.Entries {
+		doc.Entries[i].Kind = classify.Kind(doc.Entries[i].Name, doc.Entries[i].Path, doc.Entries[i].Type)
+	}
+	addBreadcrumbs(langSlug, doc)
+}
 
-	doc, err := fetchIndex(langSlug)
+// SearchDoc searches for a query within the documentation entries of a specific language.
+func SearchDoc(ctx context.Context, langSlug, query string, opts SearchOptions) ([]DocEntry, error) {
+	doc, err := fetchIndexAt(ctx, langSlug, opts.Revision)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkQuery(query, opts); err != nil {
+		return nil, err
+	}
+	if (opts.Mode == modeFulltext || opts.Mode == "") && opts.Revision == "" {
+		if opts.pageHits == nil {
+			opts.pageHits = searchContent([]string{langSlug}, query, opts.PathPrefix)
+		}
+		opts.contentHits = contentHits(opts.pageHits[langSlug], doc.Entries)
+	}
 
-	lowerQuery := strings.ToLower(query)
+	return matchEntries(doc.Entries, query, opts)
+}
 
-	for _, entry := range doc.Entries {
-		if strings.Contains(strings.ToLower(entry.Name), lowerQuery) || strings.Contains(strings.ToLower(entry.Path), lowerQuery) {
-			results = append(results, entry)
-		}
+// ReadDocContent reads the content of a specific documentation HTML file, served from the
+// page cache while it is fresh. A stale cached page is revalidated rather than downloaded again
+// or, with -stale-while-revalidate, served as is while it is refreshed in the background. The
+// entry path is read as written first, with only its syntax normalized; when no such page
+// exists it is resolved for the docset's type (see pagePathOf) and read again, so reading a
+// cached page never loads the docset's index.
+func ReadDocContent(ctx context.Context, langSlug, entryPath string) (string, error) {
+	pagePath := entrypath.Clean(entryPath)
+	content, err := readPage(ctx, langSlug, pagePath)
+	var notFound *NotFoundError
+	if err == nil || !(errors.As(err, &notFound) || errors.Is(err, errOffline)) {
+		return content, err
+	}
+	if resolved := pagePathOf(ctx, langSlug, entryPath, ""); resolved != pagePath {
+		return readPage(ctx, langSlug, resolved)
 	}
+	return content, err
+}
 
-	return results, nil
+// readPage reads a page of a documentation set by its exact page path, see ReadDocContent.
+func readPage(ctx context.Context, langSlug, entryPath string) (string, error) {
+	content, ok := loadCachedPage(langSlug, entryPath)
+	countCacheLookup(langSlug, true, ok)
+	if ok {
+		return content, nil
+	}
+	if offline {
+		return readOfflinePage(langSlug, entryPath)
+	}
+	if staleWhileRevalidate {
+		if content, downloaded, found := loadStalePage(langSlug, entryPath); found {
+			path := fmt.Sprintf("%s/%s.html", langSlug, entryPath)
+			noteStale(ctx, langSlug, path, downloaded)
+			refreshInBackground(path, func() error {
+				_, err := downloadPage(ctx, langSlug, entryPath)
+				return err
+			})
+			return content, nil
+		}
+	}
+	return downloadPage(ctx, langSlug, entryPath)
 }
 
-// ReadDocContent reads the content of a specific documentation HTML file.
-func ReadDocContent(langSlug, entryPath string) (string, error) {
-	contentURL := fmt.Sprintf("%s%s/%s.html", docsBaseURL, langSlug, entryPath)
-	log.Printf("Fetching content from: %s\n", contentURL)
-	resp, err := http.Get(contentURL)
+// downloadPage downloads a page of a documentation set and caches it. A stale cached page that
+// upstream reports unchanged is reused instead.
+func downloadPage(ctx context.Context, langSlug, entryPath string) (string, error) {
+	contentURL := fmt.Sprintf("%s/%s.html", langSlug, entryPath)
+	if missingPaths.has(contentURL) {
+		return "", pageNotFound(ctx, langSlug, entryPath)
+	}
+	log.Printf("Fetching content of %s\n", contentURL)
+	resp, err := revalidate(contentURL, cachedPageCopy{langSlug, entryPath})
+	if err == nil && resp == nil {
+		if content, ok := loadCachedPage(langSlug, entryPath); ok {
+			return content, nil
+		}
+		resp, err = fetchUpstream(contentURL)
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch doc content from %s: %w", contentURL, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		missingPaths.add(contentURL)
+		return "", pageNotFound(ctx, langSlug, entryPath)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("failed to fetch doc content from %s: status code %d - %s", contentURL, resp.StatusCode, resp.Status)
 	}
@@ -297,5 +1717,7 @@ func ReadDocContent(langSlug, entryPath string) (string, error) {
 		return "", fmt.Errorf("failed to read response body from %s: %w", contentURL, err)
 	}
 
+	storePage(langSlug, entryPath, string(data))
+	recordValidators(contentURL, resp)
 	return string(data), nil
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"devdocsmcp/internal/docs/manifest"
+)
+
+// manifestTTL is how long the cached docs.json is used before it is fetched again.
+const manifestTTL = 24 * time.Hour
+
+// cacheDir returns the devdocsmcp cache directory ($XDG_CACHE_HOME/devdocsmcp on Linux).
+func cacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(".devdocsmcp", "cache")
+	}
+	return filepath.Join(dir, "devdocsmcp")
+}
+
+// loadManifest returns the devdocs manifest, served from the local cache when it is fresh, or
+// from local copies only in offline mode.
+func loadManifest() ([]manifest.Docset, error) {
+	if offline {
+		return loadOfflineManifest()
+	}
+	return loadUpstreamManifest(filepath.Join(cacheDir(), "docs.json"), manifestTTL)
+}
+
+// resolveBundleMember maps a bundle member to the docset it stands for in the manifest, so a
+// bundle naming a family follows its current release. It returns "" when the manifest is
+// unavailable or doesn't know the member, keeping the member as written.
+func resolveBundleMember(member string) string {
+	docsets, err := loadManifest()
+	if err != nil {
+		return ""
+	}
+	if d, ok := manifest.Latest(docsets, member); ok {
+		return d.Slug
+	}
+	return ""
+}
+
+// checkSlugPath rejects a slug that can't name a directory of the cache or the mirror: one
+// that is "." or ".." or holds a path separator, and would reach outside of it.
+func checkSlugPath(slug string) error {
+	if slug == "." || slug == ".." || strings.ContainsAny(slug, `/\`) {
+		return fmt.Errorf("invalid documentation set %q", slug)
+	}
+	return nil
+}
+
+// validateLangs checks that every slug names a documentation set in the manifest, failing fast
+// with the closest valid slugs. If the manifest can't be loaded, only the shape of the slugs is
+// checked, so that an unreachable devdocs.io doesn't block commands that may still work.
+func validateLangs(slugs []string) error {
+	for _, slug := range slugs {
+		if err := checkSlugPath(slug); err != nil {
+			return err
+		}
+	}
+	docsets, err := loadManifest()
+	if err != nil {
+		log.Printf("Warning: skipping language validation, manifest unavailable: %v\n", err)
+		return nil
+	}
+
+	known := make(map[string]bool, len(docsets))
+	for _, d := range docsets {
+		known[d.Slug] = true
+	}
+
+	var problems []string
+	for _, slug := range slugs {
+		if slug == "" || known[slug] {
+			continue
+		}
+		problem := fmt.Sprintf("unknown documentation set %q", slug)
+		if suggestions := manifest.Suggest(docsets, slug, 3); len(suggestions) > 0 {
+			problem += fmt.Sprintf(" (did you mean %s?)", quoteJoin(suggestions))
+		}
+		problems = append(problems, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// quoteJoin formats items as a readable list of quoted values.
+func quoteJoin(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = fmt.Sprintf("%q", item)
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Clients that mcp-config can generate configuration for.
+const (
+	clientClaude = "claude"
+	clientCursor = "cursor"
+	clientVSCode = "vscode"
+	clientZed    = "zed"
+)
+
+// clientConfigFiles tells the user where each client's snippet goes.
+var clientConfigFiles = map[string]string{
+	clientClaude: "claude_desktop_config.json (Claude Desktop) or .mcp.json in a project",
+	clientCursor: "~/.cursor/mcp.json or .cursor/mcp.json in a project",
+	clientVSCode: ".vscode/mcp.json in a workspace",
+	clientZed:    "Zed's settings.json",
+}
+
+// runMcpConfig implements the 'mcp-config' command.
+func runMcpConfig(args []string) {
+	cmd := flag.NewFlagSet("mcp-config", flag.ExitOnError)
+	client := cmd.String("client", "", "Client to generate configuration for: claude, cursor, vscode or zed")
+	name := cmd.String("name", "devdocs", "Name of the server entry in the client configuration")
+	langs := cmd.String("lang", "", "Comma-separated list of language slugs or bundles the server should serve")
+	configPath := cmd.String("config", "", "Path to the config file to bake into the server arguments")
+	bridge := cmd.Bool("bridge", false, "Enable bridging of the MCP servers listed in the config file")
+	marker := cmd.String("truncation-marker", markerJSON, "Truncation marker style: json, text or off")
+	refresh := cmd.Duration("refresh-interval", defaultRevisionCheckInterval, "Revision check interval for subscribed resources")
+	cmd.Parse(args)
+
+	if *langs == "" {
+		log.Fatal("Error: -lang is required for the mcp-config command, as the server needs it.")
+	}
+	if _, ok := clientConfigFiles[*client]; !ok {
+		log.Fatalf("Error: unknown client %q (expected %s, %s, %s or %s)", *client, clientClaude, clientCursor, clientVSCode, clientZed)
+	}
+	if err := setTruncationMarkerStyle(*marker); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	command, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Error: failed to locate the devdocsmcp binary: %v", err)
+	}
+	serverArgs := []string{"server", "-lang", *langs}
+	if *configPath != "" {
+		abs, err := filepath.Abs(*configPath)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		serverArgs = append(serverArgs, "-config", abs)
+	}
+	if *bridge {
+		serverArgs = append(serverArgs, "-bridge")
+	}
+	if *marker != markerJSON {
+		serverArgs = append(serverArgs, "-truncation-marker", *marker)
+	}
+	if *refresh != defaultRevisionCheckInterval {
+		serverArgs = append(serverArgs, "-refresh-interval", refresh.String())
+	}
+	env := map[string]string{}
+	if path := os.Getenv("DEVDOCSMCP_CONFIG"); path != "" && *configPath == "" {
+		env["DEVDOCSMCP_CONFIG"] = path
+	}
+
+	snippet, err := clientConfig(*client, *name, command, serverArgs, env)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Add this to %s:\n", clientConfigFiles[*client])
+	fmt.Println(snippet)
+}
+
+// clientConfig renders the configuration block registering the server with a client.
+func clientConfig(client, name, command string, args []string, env map[string]string) (string, error) {
+	var block map[string]any
+	switch client {
+	case clientClaude, clientCursor:
+		block = map[string]any{"mcpServers": map[string]any{
+			name: map[string]any{"command": command, "args": args, "env": env},
+		}}
+	case clientVSCode:
+		block = map[string]any{"servers": map[string]any{
+			name: map[string]any{"type": "stdio", "command": command, "args": args, "env": env},
+		}}
+	case clientZed:
+		block = map[string]any{"context_servers": map[string]any{
+			name: map[string]any{"source": "custom", "command": command, "args": args, "env": env},
+		}}
+	default:
+		return "", fmt.Errorf("unknown client %q", client)
+	}
+	data, err := json.MarshalIndent(block, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	return string(data), nil
+}
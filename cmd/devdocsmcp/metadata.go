@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"devdocsmcp/internal/store"
+)
+
+var (
+	metaStoreOnce sync.Once
+	metaStore     *store.Store
+)
+
+// metadataPath returns the location of the metadata store.
+func metadataPath() string {
+	return filepath.Join(cacheDir(), "metadata.db")
+}
+
+// metadataStore opens the metadata store on first use. It returns nil when the store cannot be
+// opened (for example while another process holds it); metadata is then simply not recorded.
+func metadataStore() *store.Store {
+	metaStoreOnce.Do(func() {
+		s, err := store.Open(metadataPath())
+		if err != nil {
+			log.Printf("Metadata store unavailable: %v\n", err)
+			return
+		}
+		metaStore = s
+		onShutdown(func() {
+			if err := s.Close(); err != nil {
+				log.Printf("Failed to close metadata store: %v\n", err)
+			}
+		})
+	})
+	return metaStore
+}
+
+// DocsetRecord is what the metadata store remembers about a downloaded documentation set.
+type DocsetRecord struct {
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	Version   string    `json:"version,omitempty"`
+	Entries   int       `json:"entries"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// newDocsetRecord returns the record of a freshly downloaded index.
+func newDocsetRecord(langSlug string, doc *Doc) DocsetRecord {
+	return DocsetRecord{
+		Slug:      langSlug,
+		Name:      doc.Name,
+		Version:   doc.Version,
+		Entries:   len(doc.Entries),
+		FetchedAt: time.Now().UTC(),
+	}
+}
+
+// putDocsetRecord stores the record of a documentation set. Without a metadata store the
+// record is simply not kept.
+func putDocsetRecord(record DocsetRecord) error {
+	s := metadataStore()
+	if s == nil {
+		return nil
+	}
+	if err := s.Put(store.Docsets, record.Slug, record); err != nil {
+		return fmt.Errorf("failed to record docset %s: %w", record.Slug, err)
+	}
+	return nil
+}
+
+// lookupDocset returns the stored record of a documentation set, if any.
+func lookupDocset(langSlug string) (*DocsetRecord, bool) {
+	s := metadataStore()
+	if s == nil {
+		return nil, false
+	}
+	var record DocsetRecord
+	ok, err := s.Get(store.Docsets, langSlug, &record)
+	if err != nil {
+		log.Printf("Failed to read docset record %s: %v\n", langSlug, err)
+		return nil, false
+	}
+	return &record, ok
+}
+
+// Validators are the cache validators upstream sent with a file, used to ask whether a cached
+// copy is still current instead of downloading it again.
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// header returns the conditional request headers for v; it is nil when v is empty.
+func (v Validators) header() http.Header {
+	if v == (Validators{}) {
+		return nil
+	}
+	header := http.Header{}
+	if v.ETag != "" {
+		header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		header.Set("If-Modified-Since", v.LastModified)
+	}
+	return header
+}
+
+// recordValidators stores the validators of a file downloaded from upstream path, or forgets
+// the previous ones if the response carries none.
+func recordValidators(path string, resp *http.Response) {
+	s := metadataStore()
+	if s == nil {
+		return
+	}
+	v := Validators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if v == (Validators{}) {
+		forgetValidators(path)
+		return
+	}
+	if err := s.Put(store.ETags, path, v); err != nil {
+		log.Printf("Failed to record validators of %s: %v\n", path, err)
+	}
+}
+
+// lookupValidators returns the validators recorded for upstream path, if any.
+func lookupValidators(path string) (Validators, bool) {
+	var v Validators
+	s := metadataStore()
+	if s == nil {
+		return v, false
+	}
+	ok, err := s.Get(store.ETags, path, &v)
+	if err != nil {
+		log.Printf("Failed to read validators of %s: %v\n", path, err)
+		return v, false
+	}
+	return v, ok
+}
+
+// forgetValidators drops the validators recorded for upstream path.
+func forgetValidators(path string) {
+	if s := metadataStore(); s != nil {
+		if err := s.Delete(store.ETags, path); err != nil {
+			log.Printf("Failed to drop validators of %s: %v\n", path, err)
+		}
+	}
+}
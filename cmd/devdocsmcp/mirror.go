@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"devdocsmcp/internal/docs/mirror"
+)
+
+// runMirror implements the 'mirror' command.
+func runMirror(args []string) {
+	if len(args) < 1 || args[0] != "sync" {
+		log.Fatal("Error: usage: devdocsmcp mirror sync [-dest <dir>] [-lang <comma_separated_languages>] [-interval <duration>] [-listen <addr>] [-index]")
+	}
+
+	syncCmd := flag.NewFlagSet("mirror sync", flag.ExitOnError)
+	dest := syncCmd.String("dest", defaultMirrorDir(), "Directory holding the mirror")
+	langs := syncCmd.String("lang", "", "Comma-separated list of language slugs or bundles to mirror (default: every docset)")
+	interval := syncCmd.Duration("interval", 0, "Re-sync on this schedule, e.g. 24h (default: sync once and exit unless -listen is set)")
+	listen := syncCmd.String("listen", "", "Address to serve the mirror on over HTTP, e.g. :8090")
+	index := syncCmd.Bool("index", false, "Keep a full-text index of each mirrored docset, as 'download' does, for serving the mirror with 'server -mirror-dir'")
+	configPath := syncCmd.String("config", "", "Path to the config file (default: $DEVDOCSMCP_CONFIG or the user config directory)")
+	syncCmd.Parse(args[1:])
+
+	if err := loadConfig(*configPath); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	var slugs []string
+	if *langs != "" {
+		slugs = currentConfig().ExpandBundles(strings.Split(*langs, ","))
+		if err := validateLangs(slugs); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	m := mirror.NewMirror(*dest, docsBaseURL(), slugs)
+	m.ManifestURL = manifestURL(docsBaseURL())
+	m.Cipher = cacheCipher.Load()
+	syncOnce := func() {
+		result, err := m.Sync()
+		if err != nil {
+			log.Printf("Mirror sync failed: %v\n", err)
+			return
+		}
+		failed := make([]string, 0, len(result.Failed))
+		for slug := range result.Failed {
+			failed = append(failed, slug)
+		}
+		sort.Strings(failed)
+		var pages mirror.Changes
+		for _, changes := range result.Changes {
+			pages.Added += changes.Added
+			pages.Modified += changes.Modified
+			pages.Removed += changes.Removed
+			pages.Unchanged += changes.Unchanged
+		}
+		log.Printf("Mirror sync finished: %d updated, %d up to date, %d failed %v; pages: %s\n", len(result.Updated), len(result.Current), len(failed), failed, pages)
+		if !*index {
+			return
+		}
+		synced := append(append([]string{}, result.Updated...), result.Current...)
+		sort.Strings(synced)
+		for _, slug := range synced {
+			changes, updated := result.Changes[slug]
+			report, err := indexDownload(*dest, slug, changes, updated)
+			if err != nil {
+				log.Printf("Failed to index %s: %v\n", slug, err)
+				continue
+			}
+			log.Println(report)
+		}
+		pruneDownloadIndexes(*dest)
+	}
+
+	if *listen == "" && *interval == 0 {
+		syncOnce()
+		return
+	}
+
+	if *listen != "" {
+		go func() {
+			log.Printf("Serving mirror %s on %s\n", *dest, *listen)
+			if err := http.ListenAndServe(*listen, m.Handler()); err != nil {
+				log.Fatalf("Mirror server error: %v", err)
+			}
+		}()
+	}
+
+	syncOnce()
+	if *interval == 0 {
+		select {}
+	}
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		syncOnce()
+	}
+}
+
+// defaultMirrorDir returns ~/.devdocsmcp/mirror, or a relative path if the home directory is unknown.
+func defaultMirrorDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".devdocsmcp", "mirror")
+	}
+	return filepath.Join(home, ".devdocsmcp", "mirror")
+}
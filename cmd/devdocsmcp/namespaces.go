@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// NamespaceInfo describes a namespace of the config file as the caller may use it.
+type NamespaceInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Langs are the namespace's docsets the caller may use, in the namespace's order.
+	Langs []string `json:"langs"`
+	// ToolPrefixes prefix the bridged tools serving the namespace's own documentation.
+	ToolPrefixes []string `json:"tool_prefixes,omitempty"`
+}
+
+// ListNamespaces returns the configured namespaces, sorted by name.
+func ListNamespaces(ctx context.Context) []NamespaceInfo {
+	namespaces := []NamespaceInfo{}
+	cfg := currentConfig()
+	if cfg == nil {
+		return namespaces
+	}
+	for name := range cfg.Namespaces {
+		info, _ := namespaceInfo(ctx, name)
+		namespaces = append(namespaces, *info)
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Name < namespaces[j].Name })
+	return namespaces
+}
+
+// namespaceInfo resolves a namespace to the docsets the caller may use, with bundles expanded.
+func namespaceInfo(ctx context.Context, name string) (*NamespaceInfo, error) {
+	cfg := currentConfig()
+	if cfg == nil {
+		return nil, fmt.Errorf("unknown namespace %q: no namespaces are configured", name)
+	}
+	namespace, ok := cfg.Namespaces[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown namespace %q; call list_namespaces for the configured ones", name)
+	}
+	info := &NamespaceInfo{Name: name, Description: namespace.Description, Langs: []string{}}
+	for _, lang := range cfg.ExpandBundles(namespace.Langs) {
+		if isLanguageAllowed(ctx, lang) {
+			info.Langs = append(info.Langs, lang)
+		}
+	}
+	for _, bridge := range namespace.Bridges {
+		info.ToolPrefixes = append(info.ToolPrefixes, bridge+bridgeToolSeparator)
+	}
+	return info, nil
+}
+
+// namespaceLangs returns the docsets of a namespace the caller may use. It fails when there
+// are none, since a search scoped to the namespace could find nothing.
+func namespaceLangs(ctx context.Context, name string) ([]string, error) {
+	info, err := namespaceInfo(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.Langs) == 0 {
+		return nil, fmt.Errorf("namespace %q has no docsets you may use", name)
+	}
+	return info.Langs, nil
+}
+
+// checkNamespace verifies that lang belongs to a namespace; an empty namespace allows any lang.
+func checkNamespace(ctx context.Context, name, lang string) error {
+	if name == "" {
+		return nil
+	}
+	langs, err := namespaceLangs(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, l := range langs {
+		if l == lang {
+			return nil
+		}
+	}
+	return fmt.Errorf("language '%s' is not part of namespace %q (%v)", lang, name, langs)
+}
+
+// SearchNamespace runs a search in every docset of a namespace and merges the results in
+// their deterministic order, each tagged with its docset. The full-text indexes of the
+// docsets are searched together, so their pages are ranked against each other.
+func SearchNamespace(ctx context.Context, langs []string, query string, opts SearchOptions) ([]DocEntry, []string, error) {
+	if err := checkQuery(query, opts); err != nil {
+		return nil, nil, err
+	}
+	if (opts.Mode == modeFulltext || opts.Mode == "") && opts.Revision == "" {
+		opts.pageHits = searchContent(langs, query, opts.PathPrefix)
+	}
+	var merged []DocEntry
+	var warnings []string
+	for _, lang := range langs {
+		results, err := SearchDoc(ctx, lang, query, opts)
+		if err != nil {
+			if len(langs) == 1 {
+				return nil, nil, err
+			}
+			warnings = append(warnings, fmt.Sprintf("skipped %s: %v", lang, err))
+			continue
+		}
+		for _, entry := range results {
+			entry.Lang = lang
+			merged = append(merged, entry)
+		}
+	}
+	if len(warnings) == len(langs) {
+		return nil, nil, fmt.Errorf("every docset of the namespace failed: %v", warnings)
+	}
+	sortResults(merged, query, opts.Mode)
+	return merged, warnings, nil
+}
+
+// namespaceLangOf returns the first docset of a namespace whose index has an entry at path.
+func namespaceLangOf(ctx context.Context, langs []string, path string) (string, error) {
+	target := stripFragment(path)
+	for _, lang := range langs {
+		doc, err := fetchIndex(ctx, lang)
+		if err != nil {
+			continue
+		}
+		for _, entry := range doc.Entries {
+			if stripFragment(entry.Path) == target {
+				return lang, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no docset of the namespace (%v) has an entry at %s; pass lang", langs, path)
+}
+
+func handleListNamespaces(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return newJSONResult(map[string]any{"namespaces": ListNamespaces(ctx)}, nil), nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+
+	"devdocsmcp/internal/docs/entrypath"
+	"devdocsmcp/internal/docs/manifest"
+)
+
+// pagePathOf returns the page of a documentation set (at a revision, or the current one) that
+// an entry path stands for, fixing the quirks of its docset type: fragments, directories and
+// "index" pages, qualified class names, and missing module or section prefixes. Without an
+// index to check against, only the syntax of the path is normalized.
+func pagePathOf(ctx context.Context, langSlug, entryPath, revision string) string {
+	doc, err := fetchIndexAt(ctx, langSlug, revision)
+	if err != nil {
+		return entrypath.Clean(entryPath)
+	}
+	docType := ""
+	if docsets, err := loadManifest(); err == nil {
+		if docset, ok := manifest.Find(docsets, langSlug); ok {
+			docType = docset.Type
+		}
+	}
+	pagePath, _ := parsedIndexes.pages(langSlug, doc).Resolve(docType, entryPath)
+	return pagePath
+}
+
+// docPages returns the set of the pages the entries of an index stand for.
+func docPages(doc *Doc) *entrypath.Pages {
+	paths := make([]string, 0, len(doc.Entries))
+	for _, entry := range doc.Entries {
+		paths = append(paths, stripFragment(entry.Path))
+	}
+	return entrypath.NewPages(paths)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"devdocsmcp/internal/docs/manifest"
+	"devdocsmcp/internal/docs/match"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultNegativeCacheTTL is the default of the server's -negative-cache-ttl flag.
+const defaultNegativeCacheTTL = 5 * time.Minute
+
+// maxMissingPaths bounds the number of remembered 404s.
+const maxMissingPaths = 10000
+
+// maxNotFoundSuggestions is how many nearest matches a not-found error suggests.
+const maxNotFoundSuggestions = 3
+
+// negativeCacheTTL is how long a path upstream answered 404 for is reported missing without
+// asking again. It is set by the server's -negative-cache-ttl flag; 0 disables the negative
+// cache.
+var negativeCacheTTL = defaultNegativeCacheTTL
+
+// missingPaths remembers the upstream paths (e.g. "go/fmt/nope.html") that were not found.
+var missingPaths = &negativeCache{expires: make(map[string]time.Time)}
+
+type negativeCache struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+// has reports whether upstream path was not found less than negativeCacheTTL ago.
+func (c *negativeCache) has(upstreamPath string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiry, ok := c.expires[upstreamPath]
+	if ok && time.Now().After(expiry) {
+		delete(c.expires, upstreamPath)
+		return false
+	}
+	return ok
+}
+
+// add remembers that upstream path was not found.
+func (c *negativeCache) add(upstreamPath string) {
+	if negativeCacheTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.expires) >= maxMissingPaths {
+		for p, expiry := range c.expires {
+			if now.After(expiry) {
+				delete(c.expires, p)
+			}
+		}
+		if len(c.expires) >= maxMissingPaths {
+			c.expires = make(map[string]time.Time)
+		}
+	}
+	c.expires[upstreamPath] = now.Add(negativeCacheTTL)
+}
+
+// forget drops the remembered 404s of a documentation set, e.g. when it has a new revision.
+func (c *negativeCache) forget(langSlug string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p := range c.expires {
+		if strings.HasPrefix(p, langSlug+"/") {
+			delete(c.expires, p)
+		}
+	}
+}
+
+// NotFoundError reports a documentation set, or a page of one, that the documentation host
+// doesn't have, with the nearest existing ones.
+type NotFoundError struct {
+	Lang string `json:"lang"`
+	// Path is the missing page; it is empty when the documentation set itself is unknown.
+	Path        string   `json:"path,omitempty"`
+	Suggestions []string `json:"suggestions"`
+}
+
+func (e *NotFoundError) Error() string {
+	var msg string
+	if e.Path == "" {
+		msg = fmt.Sprintf("documentation set %q not found", e.Lang)
+	} else {
+		msg = fmt.Sprintf("entry %q not found in %s", e.Path, e.Lang)
+	}
+	if len(e.Suggestions) > 0 {
+		msg += fmt.Sprintf("; did you mean %s?", quoteJoin(e.Suggestions))
+	}
+	return msg
+}
+
+// toolResult returns the error as a structured tool error.
+func (e *NotFoundError) toolResult() *mcp.CallToolResult {
+	result := newJSONResult(map[string]any{
+		"error":       "not_found",
+		"message":     e.Error(),
+		"lang":        e.Lang,
+		"path":        e.Path,
+		"suggestions": e.Suggestions,
+	}, nil)
+	result.IsError = true
+	return result
+}
+
+// notFoundResult returns the tool result of a failed read or lookup: a structured error for a
+// NotFoundError, the plain message otherwise.
+func notFoundResult(err error) *mcp.CallToolResult {
+	var notFound *NotFoundError
+	if errors.As(err, &notFound) {
+		return notFound.toolResult()
+	}
+	return mcp.NewToolResultError(err.Error())
+}
+
+// docsetNotFound returns the error for an unknown documentation set, suggesting the nearest
+// slugs of the manifest.
+func docsetNotFound(langSlug string) *NotFoundError {
+	e := &NotFoundError{Lang: langSlug, Suggestions: []string{}}
+	if docsets, err := loadManifest(); err == nil {
+		e.Suggestions = manifest.Suggest(docsets, langSlug, maxNotFoundSuggestions)
+	}
+	return e
+}
+
+// pageNotFound returns the error for a missing page, suggesting the nearest page paths of the
+// documentation set's index.
+func pageNotFound(ctx context.Context, langSlug, pagePath string) *NotFoundError {
+	e := &NotFoundError{Lang: langSlug, Path: pagePath, Suggestions: []string{}}
+	if doc, err := fetchIndex(ctx, langSlug); err == nil {
+		e.Suggestions = suggestPaths(doc.Entries, pagePath, maxNotFoundSuggestions)
+	}
+	return e
+}
+
+// suggestPaths returns up to n page paths of entries closest to pagePath, by edit distance of
+// the whole path or, for pages in the same directory, of the last segment. Entries whose name
+// is close to the last segment are suggested with their fragment, so "fmt/printf" finds
+// "fmt/index#Printf".
+func suggestPaths(entries []DocEntry, pagePath string, n int) []string {
+	type candidate struct {
+		path  string
+		score int
+	}
+	want := strings.ToLower(stripFragment(pagePath))
+	wantBase := path.Base(want)
+	maxDistance := max(2, len(want)/3)
+
+	best := make(map[string]int)
+	consider := func(p string, score int) {
+		if p == pagePath || score > maxDistance {
+			return
+		}
+		if previous, ok := best[p]; !ok || score < previous {
+			best[p] = score
+		}
+	}
+	for _, entry := range entries {
+		p := stripFragment(entry.Path)
+		lower := strings.ToLower(p)
+		score := match.Distance(want, lower)
+		if path.Dir(want) == path.Dir(lower) {
+			score = min(score, match.Distance(wantBase, path.Base(lower))+1)
+		}
+		consider(p, score)
+		if p != entry.Path {
+			name := strings.ToLower(entry.Name)
+			if i := strings.LastIndexAny(name, "./:"); i >= 0 {
+				name = name[i+1:]
+			}
+			consider(entry.Path, match.Distance(wantBase, name)+1)
+		}
+	}
+
+	candidates := make([]candidate, 0, len(best))
+	for p, score := range best {
+		candidates = append(candidates, candidate{p, score})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score < candidates[j].score
+		}
+		return candidates[i].path < candidates[j].path
+	})
+
+	suggestions := []string{}
+	for _, c := range candidates {
+		if len(suggestions) == n {
+			break
+		}
+		suggestions = append(suggestions, c.path)
+	}
+	return suggestions
+}
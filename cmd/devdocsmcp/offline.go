@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"devdocsmcp/internal/docs/manifest"
+	"devdocsmcp/internal/docs/mirror"
+)
+
+// offline is set by the -offline flag: no network access at all, documentation is served from
+// the disk cache (at any age), the local mirror and stored snapshots only.
+var offline bool
+
+// mirrorDir is the local mirror read in offline mode, set by the -mirror-dir flag.
+var mirrorDir = defaultMirrorDir()
+
+var errOffline = errors.New("offline mode: network access is disabled")
+
+// offlineTransport refuses every request.
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("%w (refused request to %s)", errOffline, req.URL.Host)
+}
+
+// enforceOffline makes http.DefaultClient, which every documentation fetch goes through,
+// refuse all requests when offline mode is on. applyConfig leaves the client alone when
+// offline, so the transport is only written once, before the client is used concurrently.
+func enforceOffline() {
+	if _, ok := http.DefaultClient.Transport.(offlineTransport); offline && !ok {
+		http.DefaultClient.Transport = offlineTransport{}
+	}
+}
+
+// loadOfflineIndex returns the index of a documentation set from the local mirror or, failing
+// that, from its newest snapshot.
+func loadOfflineIndex(langSlug string) (*Doc, error) {
+	files := []string{filepath.Join(mirrorDir, langSlug, "index.json")}
+	if revisions, err := ListRevisions(langSlug); err == nil && len(revisions) > 0 {
+		files = append(files, filepath.Join(snapshotDir(langSlug, revisions[0].Mtime), "index.json"))
+	}
+	for _, file := range files {
+		data, err := readCacheFile(file)
+		if err != nil {
+			continue
+		}
+		var doc Doc
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+		}
+		return &doc, nil
+	}
+	return nil, fmt.Errorf("%w: no local copy of the index of %s; download it beforehand with 'entries download -lang %s', 'snapshot save' or 'mirror sync'", errOffline, langSlug, langSlug)
+}
+
+// hasOfflineIndex reports whether loadOfflineIndex has a local copy to read.
+func hasOfflineIndex(langSlug string) bool {
+	if _, err := os.Stat(filepath.Join(mirrorDir, langSlug, "index.json")); err == nil {
+		return true
+	}
+	revisions, err := ListRevisions(langSlug)
+	return err == nil && len(revisions) > 0
+}
+
+// readOfflinePage returns a page of a documentation set from the local mirror or, failing
+// that, from its newest snapshot.
+func readOfflinePage(langSlug, entryPath string) (string, error) {
+	var files []string
+	if file, err := mirror.PageFile(filepath.Join(mirrorDir, langSlug), entryPath); err == nil {
+		files = append(files, file)
+	}
+	if revisions, err := ListRevisions(langSlug); err == nil && len(revisions) > 0 {
+		if file, err := snapshotPageFile(snapshotDir(langSlug, revisions[0].Mtime), entryPath); err == nil {
+			files = append(files, file)
+		}
+	}
+	for _, file := range files {
+		if data, err := readCacheFile(file); err == nil {
+			return string(data), nil
+		}
+	}
+	return "", fmt.Errorf("%w: no local copy of page %s of %s", errOffline, entryPath, langSlug)
+}
+
+// loadOfflineManifest returns the devdocs manifest from the cache, at any age, or from the
+// local mirror.
+func loadOfflineManifest() ([]manifest.Docset, error) {
+	sources := []struct {
+		path string
+		read func(string) ([]byte, error)
+	}{
+		// The manifest cache is never encrypted; the mirror is when encryption at rest is on
+		{filepath.Join(cacheDir(), "docs.json"), os.ReadFile},
+		{filepath.Join(mirrorDir, "docs.json"), readCacheFile},
+	}
+	for _, source := range sources {
+		data, err := source.read(source.path)
+		if err != nil {
+			continue
+		}
+		var docsets []manifest.Docset
+		if err := json.Unmarshal(data, &docsets); err != nil {
+			return nil, fmt.Errorf("failed to decode manifest %s: %w", source.path, err)
+		}
+		return docsets, nil
+	}
+	return nil, fmt.Errorf("%w: no local copy of the devdocs manifest", errOffline)
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"devdocsmcp/internal/cachetx"
+	"devdocsmcp/internal/docs/manifest"
+)
+
+// packManifestName is the first file of a doc pack, describing and checksumming the rest.
+const packManifestName = "devdocsmcp-pack.json"
+
+// packFormat is the version of the doc pack layout written by this build.
+const packFormat = 1
+
+// PackManifest describes a doc pack: the docsets it holds and the SHA-256 of every file.
+type PackManifest struct {
+	Format    int               `json:"format"`
+	CreatedAt string            `json:"created_at"`
+	Generator string            `json:"generator"`
+	Docsets   []manifest.Docset `json:"docsets"`
+	// Files maps the path of every other file of the pack to its SHA-256, in hex. Paths are
+	// relative to the download directory, e.g. go/index.json or .search/go/CURRENT.
+	Files map[string]string `json:"files"`
+}
+
+// zstdMagic starts every zstd frame, gzipMagic every gzip stream.
+var (
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// runExport implements the 'export' command.
+func runExport(args []string) {
+	cmd := flag.NewFlagSet("export", flag.ExitOnError)
+	langs := cmd.String("lang", "", "Comma-separated list of language slugs or bundles to export")
+	dest := cmd.String("dest", defaultMirrorDir(), "Directory the docsets were downloaded to with 'download'")
+	out := cmd.String("out", "", "Archive to write: .tar.zst (needs the zstd command), .tar.gz or .tar (default: devdocs-<langs>.tar.zst)")
+	configPath := cmd.String("config", "", "Path to the config file (default: $DEVDOCSMCP_CONFIG or the user config directory)")
+	cmd.Parse(args)
+
+	if err := loadConfig(*configPath); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *langs == "" {
+		log.Fatal("Error: -lang is required for the export command.")
+	}
+	slugs := currentConfig().ExpandBundles(strings.Split(*langs, ","))
+	if *out == "" {
+		*out = "devdocs-" + strings.ReplaceAll(strings.Join(slugs, "-"), "~", "_") + ".tar.zst"
+	}
+	pack, err := ExportPack(*dest, slugs, *out)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("Wrote %s (%d docsets, %d files)\n", *out, len(pack.Docsets), len(pack.Files))
+}
+
+// runImport implements the 'import' command.
+func runImport(args []string) {
+	cmd := flag.NewFlagSet("import", flag.ExitOnError)
+	file := cmd.String("file", "", "Doc pack written by 'export' (.tar.zst, .tar.gz or .tar)")
+	dest := cmd.String("dest", defaultMirrorDir(), "Directory to install the docsets in (serve it with 'server -offline -mirror-dir <dir>')")
+	cmd.Parse(args)
+
+	if *file == "" {
+		log.Fatal("Error: -file is required for the import command.")
+	}
+	pack, err := ImportPack(*file, *dest)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	for _, docset := range pack.Docsets {
+		fmt.Printf("Imported %s %s into %s\n", docset.Slug, docset.Version, filepath.Join(*dest, docset.Slug))
+	}
+}
+
+// ExportPack writes the docsets downloaded into dir, with their full-text indexes (which the
+// minimal build doesn't require), to a doc pack at out. Files encrypted at rest are decrypted, so the pack can be imported with
+// another key or none.
+func ExportPack(dir string, slugs []string, out string) (*PackManifest, error) {
+	// Checking the compression first saves reading every file only to fail on a missing zstd
+	if err := checkPackName(out); err != nil {
+		return nil, err
+	}
+	docsets, err := readPackDocsets(dir, slugs)
+	if err != nil {
+		return nil, err
+	}
+
+	// The manifest goes first, so the files are read twice: once to checksum them
+	files := make(map[string]string)
+	var names []string
+	for _, slug := range slugs {
+		roots := []string{slug}
+		if hasSearchIndex(dir, slug) {
+			roots = append(roots, path.Join(".search", slug))
+		} else if fullTextIndexing {
+			return nil, fmt.Errorf("%s has no complete full-text index in %s; run 'download -lang %s -dest %s' first", slug, dir, slug, dir)
+		}
+		for _, root := range roots {
+			err := filepath.WalkDir(filepath.Join(dir, filepath.FromSlash(root)), func(file string, entry fs.DirEntry, err error) error {
+				if err != nil || entry.IsDir() {
+					return err
+				}
+				rel, _ := filepath.Rel(dir, file)
+				name := filepath.ToSlash(rel)
+				data, err := readPackSource(dir, name)
+				if err != nil {
+					return err
+				}
+				files[name] = sha256Hex(data)
+				names = append(names, name)
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", root, err)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	pack := &PackManifest{
+		Format:    packFormat,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Generator: "devdocsmcp " + buildInfo().Version,
+		Docsets:   docsets,
+		Files:     files,
+	}
+	meta, err := json.MarshalIndent(pack, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode pack manifest: %w", err)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", out, err)
+	}
+	w, err := compressPack(f, out)
+	if err != nil {
+		f.Close()
+		os.Remove(out)
+		return nil, err
+	}
+	tw := tar.NewWriter(w)
+	err = writeTarFile(tw, packManifestName, meta)
+	for _, name := range names {
+		if err != nil {
+			break
+		}
+		var data []byte
+		if data, err = readPackSource(dir, name); err == nil {
+			if sha256Hex(data) != files[name] {
+				err = fmt.Errorf("%s changed while exporting; retry once no download or update is running", name)
+			} else {
+				err = writeTarFile(tw, name, data)
+			}
+		}
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out)
+		return nil, fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	return pack, nil
+}
+
+// readPackDocsets returns the manifest entries of docsets downloaded into dir.
+func readPackDocsets(dir string, slugs []string) ([]manifest.Docset, error) {
+	data, err := readCacheFile(filepath.Join(dir, "docs.json"))
+	if err != nil {
+		return nil, fmt.Errorf("no downloaded docsets in %s: %w", dir, err)
+	}
+	var all []manifest.Docset
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", filepath.Join(dir, "docs.json"), err)
+	}
+	docsets := make([]manifest.Docset, 0, len(slugs))
+	for _, slug := range slugs {
+		docset, ok := manifest.Find(all, slug)
+		if !ok {
+			return nil, fmt.Errorf("%s is not listed in %s", slug, filepath.Join(dir, "docs.json"))
+		}
+		docsets = append(docsets, docset)
+	}
+	return docsets, nil
+}
+
+// readPackSource reads a file of a download directory as it goes into a pack: docset files
+// are decrypted, full-text index files are never encrypted.
+func readPackSource(dir, name string) ([]byte, error) {
+	file := filepath.Join(dir, filepath.FromSlash(name))
+	if strings.HasPrefix(name, ".search/") {
+		return os.ReadFile(file)
+	}
+	return readCacheFile(file)
+}
+
+// ImportPack verifies the doc pack at file and installs its docsets into dir, replacing the
+// previous copies. Files are staged and checked against the pack's checksums before anything
+// in dir changes, and the docsets are moved into place in one cache transaction.
+func ImportPack(file, dir string) (*PackManifest, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", file, err)
+	}
+	defer f.Close()
+	r, err := decompressPack(f)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	header, err := tr.Next()
+	if err != nil || header.Name != packManifestName {
+		return nil, fmt.Errorf("%s is not a doc pack: it doesn't start with %s", file, packManifestName)
+	}
+	var pack PackManifest
+	if err := json.NewDecoder(tr).Decode(&pack); err != nil {
+		return nil, fmt.Errorf("failed to decode the manifest of %s: %w", file, err)
+	}
+	if pack.Format != packFormat {
+		return nil, fmt.Errorf("%s has pack format %d; this build reads format %d", file, pack.Format, packFormat)
+	}
+	roots := make(map[string]bool)
+	for _, docset := range pack.Docsets {
+		if docset.Slug == "" || strings.ContainsAny(docset.Slug, `/\`) || strings.HasPrefix(docset.Slug, ".") {
+			return nil, fmt.Errorf("%s lists an invalid docset slug %q", file, docset.Slug)
+		}
+		roots[docset.Slug] = true
+		roots[path.Join(".search", docset.Slug)] = true
+	}
+
+	tx, err := cachetx.Begin(filepath.Join(dir, ".staging"), dir)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Abort()
+	seen := make(map[string]bool, len(pack.Files))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		sum, ok := pack.Files[header.Name]
+		if !ok || !inPackRoots(header.Name, roots) {
+			return nil, fmt.Errorf("%s holds %s, which its manifest doesn't list", file, header.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if sha256Hex(data) != sum {
+			return nil, fmt.Errorf("checksum mismatch for %s in %s; the pack is corrupt", header.Name, file)
+		}
+		if !strings.HasPrefix(header.Name, ".search/") {
+			data = sealCacheData(data)
+		}
+		if err := tx.Write(header.Name, data); err != nil {
+			return nil, err
+		}
+		seen[header.Name] = true
+	}
+	for name := range pack.Files {
+		if !seen[name] {
+			return nil, fmt.Errorf("%s is missing %s; the pack is truncated", file, name)
+		}
+	}
+
+	// Files of the previous copies that the pack doesn't have are removed
+	for root := range roots {
+		filepath.WalkDir(filepath.Join(dir, filepath.FromSlash(root)), func(file string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return nil
+			}
+			rel, _ := filepath.Rel(dir, file)
+			if name := filepath.ToSlash(rel); !seen[name] {
+				tx.Remove(name)
+			}
+			return nil
+		})
+	}
+	docs, err := mergePackDocsets(dir, pack.Docsets)
+	if err != nil {
+		return nil, err
+	}
+	docs = sealCacheData(docs)
+	if err := tx.Write("docs.json", docs); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &pack, nil
+}
+
+// inPackRoots reports whether a pack file belongs to one of the pack's docsets.
+func inPackRoots(name string, roots map[string]bool) bool {
+	if path.Clean(name) != name || strings.HasPrefix(name, "../") {
+		return false
+	}
+	for root := range roots {
+		if strings.HasPrefix(name, root+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mergePackDocsets returns the docs.json of dir with the entries of the imported docsets
+// added or replaced.
+func mergePackDocsets(dir string, imported []manifest.Docset) ([]byte, error) {
+	var docsets []manifest.Docset
+	if data, err := readCacheFile(filepath.Join(dir, "docs.json")); err == nil {
+		if err := json.Unmarshal(data, &docsets); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", filepath.Join(dir, "docs.json"), err)
+		}
+	}
+	for _, docset := range imported {
+		replaced := false
+		for i := range docsets {
+			if docsets[i].Slug == docset.Slug {
+				docsets[i], replaced = docset, true
+			}
+		}
+		if !replaced {
+			docsets = append(docsets, docset)
+		}
+	}
+	data, err := json.Marshal(docsets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return data, nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Now(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkPackName reports whether a pack can be written to name: its extension must pick a
+// compression compressPack knows, and .tar.zst needs the zstd command.
+func checkPackName(name string) error {
+	switch {
+	case strings.HasSuffix(name, ".tar.zst") || strings.HasSuffix(name, ".tzst"):
+		_, err := lookZstd()
+		return err
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"), strings.HasSuffix(name, ".tar"):
+		return nil
+	}
+	return fmt.Errorf("unsupported archive name %s: use .tar.zst, .tar.gz or .tar", name)
+}
+
+// compressPack returns a writer compressing to w as the extension of name asks: zstd through
+// the zstd command, since the standard library has no encoder, gzip, or none for .tar.
+func compressPack(w io.Writer, name string) (io.WriteCloser, error) {
+	switch {
+	case strings.HasSuffix(name, ".tar.zst") || strings.HasSuffix(name, ".tzst"):
+		return zstdCommand(w, nil, "-q", "-c", "-")
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		return gzip.NewWriter(w), nil
+	case strings.HasSuffix(name, ".tar"):
+		return nopWriteCloser{w}, nil
+	}
+	return nil, fmt.Errorf("unsupported archive name %s: use .tar.zst, .tar.gz or .tar", name)
+}
+
+// decompressPack returns a reader of the tar stream in r, detecting its compression.
+func decompressPack(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, zstdMagic):
+		pr, pw := io.Pipe()
+		cmd, err := zstdCommand(pw, br, "-d", "-q", "-c", "-")
+		if err != nil {
+			return nil, err
+		}
+		go func() { pw.CloseWithError(cmd.Close()) }()
+		return pr, nil
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	}
+	return io.NopCloser(br), nil
+}
+
+// lookZstd returns the path of the zstd command, which packs compressed with zstd need since
+// the standard library has no zstd codec.
+func lookZstd() (string, error) {
+	bin, err := exec.LookPath("zstd")
+	if err != nil {
+		return "", errors.New("the zstd command is needed for .tar.zst packs: install it (e.g. 'apt install zstd' or 'brew install zstd') or export with '-out <name>.tar.gz'")
+	}
+	return bin, nil
+}
+
+// zstdProcess is a running zstd command. Close waits for it to exit.
+type zstdProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+// zstdCommand starts the zstd command with args, writing its output to w. If r is nil the
+// process reads what is written to it, otherwise it reads r.
+func zstdCommand(w io.Writer, r io.Reader, args ...string) (*zstdProcess, error) {
+	bin, err := lookZstd()
+	if err != nil {
+		return nil, err
+	}
+	p := &zstdProcess{cmd: exec.Command(bin, args...)}
+	p.cmd.Stdout = w
+	p.cmd.Stderr = os.Stderr
+	if r != nil {
+		p.cmd.Stdin = r
+	} else if p.stdin, err = p.cmd.StdinPipe(); err != nil {
+		return nil, fmt.Errorf("failed to start zstd: %w", err)
+	}
+	if err := p.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start zstd: %w", err)
+	}
+	return p, nil
+}
+
+func (p *zstdProcess) Write(data []byte) (int, error) {
+	return p.stdin.Write(data)
+}
+
+func (p *zstdProcess) Close() error {
+	if p.stdin != nil {
+		p.stdin.Close()
+	}
+	if err := p.cmd.Wait(); err != nil {
+		return fmt.Errorf("zstd failed: %w", err)
+	}
+	return nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"devdocsmcp/internal/cachestore"
+)
+
+// Page cache backends, selected by the server's -cache-backend flag, $DEVDOCSMCP_CACHE_BACKEND
+// or the config's cache_backend.
+const (
+	backendFiles = "files"
+	backendBolt  = "bbolt"
+)
+
+// cacheBackendEnv selects the page cache backend of every command when the server's
+// -cache-backend flag doesn't.
+const cacheBackendEnv = "DEVDOCSMCP_CACHE_BACKEND"
+
+// cacheBackendFlag is set by the server's -cache-backend flag.
+var cacheBackendFlag string
+
+var (
+	pageStoreOnce sync.Once
+	pages         cachestore.Store
+)
+
+// cacheBackend returns the name of the configured page cache backend.
+func cacheBackend() string {
+	cfg := currentConfig()
+	switch {
+	case cacheBackendFlag != "":
+		return cacheBackendFlag
+	case os.Getenv(cacheBackendEnv) != "":
+		return os.Getenv(cacheBackendEnv)
+	case cfg != nil && cfg.CacheBackend != "":
+		return cfg.CacheBackend
+	}
+	return backendFiles
+}
+
+// checkCacheBackend reports an unknown page cache backend.
+func checkCacheBackend() error {
+	switch backend := cacheBackend(); backend {
+	case backendFiles, backendBolt:
+		return nil
+	default:
+		return fmt.Errorf("unknown cache backend %q (expected %s or %s)", backend, backendFiles, backendBolt)
+	}
+}
+
+// pageDBPath returns the database of the bbolt page cache backend.
+func pageDBPath() string {
+	return filepath.Join(cacheDir(), "pages.db")
+}
+
+// pageStore opens the page cache on first use, in the backend chosen by cacheBackend. Both
+// backends may be shared by processes using the same cache directory. When the backend cannot
+// be opened, pages are neither read from nor written to the cache.
+func pageStore() cachestore.Store {
+	pageStoreOnce.Do(func() {
+		pages = cachestore.Discard{}
+		if err := checkCacheBackend(); err != nil {
+			log.Printf("Page cache unavailable: %v\n", err)
+			return
+		}
+		if cacheBackend() == backendFiles {
+			pages = &cachestore.Files{Root: filepath.Join(cacheDir(), "indexes")}
+			return
+		}
+		s, err := cachestore.OpenBolt(pageDBPath())
+		if err != nil {
+			log.Printf("Page cache unavailable: %v\n", err)
+			return
+		}
+		pages = s
+		onShutdown(func() {
+			if err := s.Close(); err != nil {
+				log.Printf("Failed to close page cache: %v\n", err)
+			}
+		})
+	})
+	return pages
+}
+
+// pageBytes returns the size of the cached pages of a documentation set.
+func pageBytes(langSlug string) int64 {
+	var size int64
+	pageStore().Walk(langSlug, func(page cachestore.Info) error {
+		size += page.Size
+		return nil
+	})
+	return size
+}
+
+// cacheSize returns the size of the cache directory, counting the pages of the bbolt backend
+// rather than its database, which keeps the space of removed pages for reuse.
+func cacheSize() (int64, error) {
+	total, err := dirSize(cacheDir())
+	if err != nil || cacheBackend() != backendBolt {
+		return total, err
+	}
+	total -= fileSize(pageDBPath())
+	langs, _ := pageStore().Langs()
+	for _, lang := range langs {
+		total += pageBytes(lang)
+	}
+	return total, nil
+}
+
+// cachedPageCopy is the cached copy of a page, for revalidate.
+type cachedPageCopy struct {
+	lang, path string
+}
+
+func (c cachedPageCopy) exists() bool {
+	_, err := pageStore().Stat(c.lang, c.path)
+	return err == nil
+}
+
+func (c cachedPageCopy) touch() error {
+	return pageStore().Touch(c.lang, c.path)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"devdocsmcp/internal/docs/mirror"
+	"devdocsmcp/internal/peers"
+)
+
+// Peer cache sharing.
+const (
+	// defaultPeerPort is the port peers are served on when -peer-listen is unset.
+	defaultPeerPort = "7437"
+	// peerTokenEnv names the environment variable holding the peer secret when -peer-token is
+	// unset.
+	peerTokenEnv = "DEVDOCSMCP_PEER_TOKEN"
+	// peerSignatureHeader carries the HMAC-SHA256 of an answer to a peer (see peerSignature).
+	peerSignatureHeader = "X-Devdocsmcp-Peer-Signature"
+	// maxPeerFileBytes bounds the files read from peers, which are held in memory to be verified.
+	maxPeerFileBytes = 256 << 20
+	// peerEndpoint is where a daemon serves its cached files to peers, in the layout of the
+	// documentation host ("go/index.json", "go/fmt/index.html").
+	peerEndpoint = "/peer/"
+	// peerBrowseInterval is how often the network is queried for peers, and peerBrowseWait
+	// how long each query waits for answers.
+	peerBrowseInterval = time.Minute
+	peerBrowseWait     = 2 * time.Second
+	// peerFetchTimeout bounds a fetch from a peer, which should be much faster than upstream.
+	peerFetchTimeout = 3 * time.Second
+)
+
+// peerSharing is set by the server's -peers flag.
+var peerSharing bool
+
+// peerListen is set by the server's -peer-listen flag; empty means the host of -listen on
+// defaultPeerPort.
+var peerListen string
+
+// peerToken is the secret shared by the peers, set by the server's -peer-token flag or
+// $DEVDOCSMCP_PEER_TOKEN. Peers send it with every request and sign every answer with it, so
+// only daemons holding it can read this cache or feed theirs.
+var peerToken string
+
+// peerClient fetches from peers; they are on the local network, so no proxy applies.
+var peerClient = &http.Client{Timeout: peerFetchTimeout}
+
+var (
+	peerHostsMu sync.RWMutex
+	// peerHosts are the peers found by the last query, with their health.
+	peerHosts []*upstream
+)
+
+// startPeerSharing serves the disk cache and local mirror to peers on -peer-listen, advertises
+// this daemon on the local network and keeps looking for peers, until ctx is cancelled.
+func startPeerSharing(ctx context.Context) error {
+	if peerToken == "" {
+		return fmt.Errorf("-peers needs a shared secret in -peer-token or $%s", peerTokenEnv)
+	}
+	addr := peerListenAddr()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for peers on %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(peerEndpoint, handlePeerFile)
+	httpServer := &http.Server{Handler: mux}
+	go httpServer.Serve(ln)
+	go func() {
+		<-ctx.Done()
+		httpServer.Close()
+	}()
+
+	instance, err := peerInstanceName()
+	if err != nil {
+		return err
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	go func() {
+		if err := peers.Advertise(ctx, instance, port); err != nil {
+			log.Printf("Peer discovery: %v; this server is not advertised\n", err)
+		}
+	}()
+	go browsePeers(ctx, instance)
+	log.Printf("Sharing the cache with peers on %s%s as %s\n", ln.Addr(), peerEndpoint, instance)
+	return nil
+}
+
+// peerListenAddr returns the address peers are served on: -peer-listen if set, otherwise
+// defaultPeerPort on the host the HTTP transports are bound to with -listen, or on the loopback
+// interface when they listen on a Unix socket.
+func peerListenAddr() string {
+	if peerListen != "" {
+		return peerListen
+	}
+	if strings.HasPrefix(listenAddr, unixScheme) {
+		return net.JoinHostPort("127.0.0.1", defaultPeerPort)
+	}
+	host, _, _ := net.SplitHostPort(listenAddr)
+	return net.JoinHostPort(host, defaultPeerPort)
+}
+
+// peerSignature returns the hex HMAC-SHA256, keyed with the peer secret, of a file served to
+// peers and the upstream path it was asked for.
+func peerSignature(path string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(peerToken))
+	mac.Write([]byte(path))
+	mac.Write([]byte{0})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyPeerAnswer reads the body of a peer's answer to path and checks its signature,
+// returning a response whose body can be read again.
+func verifyPeerAnswer(path string, resp *http.Response) (*http.Response, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPeerFileBytes+1))
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(body) > maxPeerFileBytes {
+		return nil, fmt.Errorf("%s is larger than %d bytes", path, maxPeerFileBytes)
+	}
+	signature := resp.Header.Get(peerSignatureHeader)
+	if !hmac.Equal([]byte(signature), []byte(peerSignature(path, body))) {
+		return nil, fmt.Errorf("the answer for %s is not signed with the peer secret", path)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
+
+// peerInstanceName returns the name this daemon advertises: the host name and a random suffix,
+// so several daemons on one host stay distinct.
+func peerInstanceName() (string, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the host name: %w", err)
+	}
+	host, _, _ = strings.Cut(host, ".")
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	name := "devdocsmcp-" + host
+	// A DNS label holds at most 63 bytes
+	return name[:min(len(name), 54)] + "-" + hex.EncodeToString(id[:]), nil
+}
+
+// browsePeers queries the network for peers every peerBrowseInterval. Peers found again keep
+// their health; self is this daemon's instance name, which is ignored.
+func browsePeers(ctx context.Context, self string) {
+	ticker := time.NewTicker(peerBrowseInterval)
+	defer ticker.Stop()
+	for {
+		found, err := peers.Browse(ctx, peerBrowseWait)
+		if err != nil {
+			log.Printf("Peer discovery failed: %v\n", err)
+		} else {
+			setPeerHosts(found, self)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func setPeerHosts(found []peers.Peer, self string) {
+	peerHostsMu.Lock()
+	defer peerHostsMu.Unlock()
+	previous := make(map[string]*upstream, len(peerHosts))
+	for _, u := range peerHosts {
+		previous[u.url] = u
+	}
+	var next []*upstream
+	for _, peer := range found {
+		if peer.Instance == self {
+			continue
+		}
+		url := "http://" + peer.Addr + peerEndpoint
+		if u, ok := previous[url]; ok {
+			next = append(next, u)
+			continue
+		}
+		log.Printf("Found peer %s at %s\n", peer.Instance, peer.Addr)
+		next = append(next, &upstream{url: url})
+	}
+	peerHosts = next
+}
+
+// peerStatuses reports the health of the peers found.
+func peerStatuses() []UpstreamStatus {
+	peerHostsMu.RLock()
+	defer peerHostsMu.RUnlock()
+	now := time.Now()
+	statuses := make([]UpstreamStatus, len(peerHosts))
+	for i, u := range peerHosts {
+		statuses[i] = u.status(now)
+	}
+	return statuses
+}
+
+// isPeerPath reports whether peers can serve the upstream path: the index or a page of a
+// documentation set.
+func isPeerPath(path string) bool {
+	lang, file, ok := strings.Cut(path, "/")
+	return ok && lang != "" && !strings.HasPrefix(lang, ".") && !strings.Contains(lang, `\`) && (file == "index.json" || strings.HasSuffix(file, ".html"))
+}
+
+// fetchFromPeers GETs path from the healthy peers in turn and returns the first copy found, or
+// nil if no peer has it.
+func fetchFromPeers(path string) *http.Response {
+	if !peerSharing || !isPeerPath(path) {
+		return nil
+	}
+	peerHostsMu.RLock()
+	hosts := append([]*upstream{}, peerHosts...)
+	peerHostsMu.RUnlock()
+
+	now := time.Now()
+	for _, u := range hosts {
+		if !u.healthy(now) {
+			continue
+		}
+		req, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, u.url+path, nil)
+		if err != nil {
+			continue
+		}
+		req.Header.Set("Authorization", "Bearer "+peerToken)
+		start := time.Now()
+		resp, err := peerClient.Do(req)
+		traceSpan("peer", langOfPath(path), upstreamDetail(u.url+path, resp, err), start)
+		if err == nil && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+			resp.Body.Close()
+			err = fmt.Errorf("%s answered %s", u.url, resp.Status)
+		}
+		if err != nil {
+			if fetchCtx.Err() != nil {
+				return nil
+			}
+			u.failed(err)
+			continue
+		}
+		u.succeeded()
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			continue
+		}
+		if resp, err = verifyPeerAnswer(path, resp); err != nil {
+			// An answer that isn't signed is never used: the peer counts as failing
+			log.Printf("Rejected an answer of peer %s: %v\n", u.url, err)
+			u.failed(err)
+			continue
+		}
+		log.Printf("Fetched %s from peer %s\n", path, u.url)
+		return resp
+	}
+	return nil
+}
+
+// handlePeerFile serves a file to a peer from the disk cache, while it is fresh, or the local
+// mirror. It never fetches upstream: a peer that doesn't get the file asks the next one, then
+// the documentation host itself. Only peers sending the peer secret are served, and every
+// file is signed with it.
+func handlePeerFile(w http.ResponseWriter, r *http.Request) {
+	credentials, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || peerToken == "" || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(credentials)), []byte(peerToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, peerEndpoint)
+	if !isPeerPath(path) {
+		http.NotFound(w, r)
+		return
+	}
+	data, ok := localPeerCopy(path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if strings.HasSuffix(path, ".json") {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	w.Header().Set(peerSignatureHeader, peerSignature(path, data))
+	w.Write(data)
+}
+
+// localPeerCopy returns the local copy of an upstream path served to peers.
+func localPeerCopy(path string) ([]byte, bool) {
+	lang, file, _ := strings.Cut(path, "/")
+	if file == "index.json" {
+		cached := filepath.Join(indexCacheDir(lang), "index.json")
+		if info, err := os.Stat(cached); err == nil && (time.Since(info.ModTime()) < cacheTTL || isIndexPinned(lang)) {
+			if data, err := readCacheFile(cached); err == nil {
+				return data, true
+			}
+		}
+		data, err := readCacheFile(filepath.Join(mirrorDir, lang, "index.json"))
+		return data, err == nil
+	}
+	pagePath := strings.TrimSuffix(file, ".html")
+	if content, ok := loadCachedPage(lang, pagePath); ok {
+		return []byte(content), true
+	}
+	mirrored, err := mirror.PageFile(filepath.Join(mirrorDir, lang), pagePath)
+	if err != nil {
+		return nil, false
+	}
+	data, err := readCacheFile(mirrored)
+	return data, err == nil
+}
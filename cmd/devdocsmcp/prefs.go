@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"devdocsmcp/internal/config"
+	"devdocsmcp/internal/docs/mirror"
+)
+
+// devdocsPrefs is the part of the settings file exported from the devdocs.io web app
+// (Preferences → Export) that devdocsmcp understands.
+type devdocsPrefs struct {
+	// Docs lists the enabled docsets. The web app stores it as a single "/"-separated string
+	// (e.g. "css/html/javascript"); a JSON array is accepted as well.
+	Docs json.RawMessage `json:"docs"`
+}
+
+// parsePrefsDocs returns the docset slugs enabled in an exported devdocs settings file.
+func parsePrefsDocs(data []byte) ([]string, error) {
+	var prefs devdocsPrefs
+	if err := json.Unmarshal(data, &prefs); err != nil {
+		return nil, fmt.Errorf("failed to parse devdocs settings: %w", err)
+	}
+	if len(prefs.Docs) == 0 {
+		return nil, fmt.Errorf("devdocs settings contain no 'docs' selection")
+	}
+
+	var raw []string
+	var joined string
+	if err := json.Unmarshal(prefs.Docs, &joined); err == nil {
+		raw = strings.Split(joined, "/")
+	} else if err := json.Unmarshal(prefs.Docs, &raw); err != nil {
+		return nil, fmt.Errorf("unexpected format of 'docs' in devdocs settings: %s", prefs.Docs)
+	}
+
+	var slugs []string
+	seen := make(map[string]bool)
+	for _, slug := range raw {
+		slug = strings.TrimSpace(slug)
+		if slug != "" && !seen[slug] {
+			seen[slug] = true
+			slugs = append(slugs, slug)
+		}
+	}
+	if len(slugs) == 0 {
+		return nil, fmt.Errorf("devdocs settings select no docsets")
+	}
+	return slugs, nil
+}
+
+// runImportPrefs implements the 'import-prefs' command.
+func runImportPrefs(args []string) {
+	cmd := flag.NewFlagSet("import-prefs", flag.ExitOnError)
+	file := cmd.String("file", "", "Settings file exported from devdocs.io (Preferences → Export)")
+	configPath := cmd.String("config", "", "Path to the config file to update (default: $DEVDOCSMCP_CONFIG or the user config directory)")
+	download := cmd.Bool("mirror", false, "Also download the imported docsets into the local mirror")
+	dest := cmd.String("dest", defaultMirrorDir(), "Mirror directory used with -mirror")
+	cmd.Parse(args)
+
+	if *file == "" {
+		log.Fatal("Error: -file is required for the import-prefs command.")
+	}
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	slugs, err := parsePrefsDocs(data)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateLangs(slugs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	path := *configPath
+	if path == "" {
+		if path, err = config.DefaultPath(); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	if err := config.SaveLangs(path, slugs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("Imported %d docsets into %s: %s\n", len(slugs), path, strings.Join(slugs, ", "))
+	fmt.Println("'devdocsmcp server' now serves them when started without -lang.")
+
+	if *download {
+		m := mirror.NewMirror(*dest, docsBaseURL(), slugs)
+		m.ManifestURL = manifestURL(docsBaseURL())
+		result, err := m.Sync()
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("Mirrored into %s: %d updated, %d up to date, %d failed\n", *dest, len(result.Updated), len(result.Current), len(result.Failed))
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// prewarm is set by the server's -prewarm flag, and prewarmPages by -prewarm-pages.
+var (
+	prewarm      bool
+	prewarmPages int
+)
+
+// prewarmLanguages fetches and caches the index of every served language in the background,
+// with its prewarmPages most linked pages, so the first calls don't wait for the documentation
+// host. It gives up on the remaining work once ctx is cancelled.
+func prewarmLanguages(ctx context.Context) {
+	var wg sync.WaitGroup
+	for lang := range servedLanguages() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prewarmLanguage(ctx, lang)
+		}()
+	}
+	wg.Wait()
+}
+
+func prewarmLanguage(ctx context.Context, lang string) {
+	start := time.Now()
+	doc, err := fetchIndex(ctx, lang)
+	if err != nil {
+		log.Printf("Failed to prewarm %s: %v\n", lang, err)
+		return
+	}
+	warmed := 0
+	if !offline {
+		for _, pagePath := range mostLinkedPages(doc.Entries, prewarmPages) {
+			if ctx.Err() != nil {
+				return
+			}
+			if _, err := ReadDocContent(ctx, lang, pagePath); err != nil {
+				log.Printf("Failed to prewarm page %s of %s: %v\n", pagePath, lang, err)
+				continue
+			}
+			warmed++
+		}
+	}
+	log.Printf("Prewarmed %s: index of %d entries and %d pages in %v\n", lang, len(doc.Entries), warmed, time.Since(start).Round(time.Millisecond))
+}
+
+// mostLinkedPages returns the n pages the most index entries link to, e.g. the page of a
+// package documenting all its functions, most linked first.
+func mostLinkedPages(entries []DocEntry, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	links := make(map[string]int)
+	for _, entry := range entries {
+		links[stripFragment(entry.Path)]++
+	}
+	pages := make([]string, 0, len(links))
+	for p := range links {
+		pages = append(pages, p)
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		if links[pages[i]] != links[pages[j]] {
+			return links[pages[i]] > links[pages[j]]
+		}
+		return pages[i] < pages[j]
+	})
+	return pages[:min(n, len(pages))]
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheMaxSize is set by the server's -cache-max-size flag; 0 disables the quota.
+var cacheMaxSize byteSize
+
+const (
+	// quotaCheckInterval is how often the quota is checked besides after each cached page.
+	quotaCheckInterval = time.Minute
+	// quotaLowWater is the share of -cache-max-size eviction brings the cache down to, so it
+	// doesn't run again for every page cached at the limit.
+	quotaLowWater = 0.9
+)
+
+// byteSize is a flag value holding a size such as "512MB" or "2GB" (binary units; "KiB",
+// "MiB", "GiB" and "TiB" are accepted too, and a bare number is bytes).
+type byteSize int64
+
+func (b *byteSize) String() string {
+	if *b == 0 {
+		return "0"
+	}
+	return formatBytes(int64(*b))
+}
+
+func (b *byteSize) Set(value string) error {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	number := strings.TrimRight(s, "KMGTIB ")
+	unit := strings.TrimSpace(strings.TrimPrefix(s, number))
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q (e.g. 512MB or 2GB)", value)
+	}
+	shift, ok := map[string]uint{"": 0, "B": 0, "K": 10, "KB": 10, "KIB": 10, "M": 20, "MB": 20, "MIB": 20, "G": 30, "GB": 30, "GIB": 30, "T": 40, "TB": 40, "TIB": 40}[unit]
+	if !ok {
+		return fmt.Errorf("invalid size %q (e.g. 512MB or 2GB)", value)
+	}
+	*b = byteSize(n * float64(uint64(1)<<shift))
+	return nil
+}
+
+// pageUse remembers when this process last read or cached each page, for the quota's LRU
+// order; pages it hasn't touched count as used when they were downloaded.
+var pageUse = struct {
+	sync.Mutex
+	at map[string]time.Time
+}{at: make(map[string]time.Time)}
+
+// quotaCheck wakes enforceCacheQuota after a page is cached.
+var quotaCheck = make(chan struct{}, 1)
+
+// notePageUse records a use of a cached page when the quota is enabled.
+func notePageUse(langSlug, pagePath string) {
+	if cacheMaxSize <= 0 {
+		return
+	}
+	pageUse.Lock()
+	pageUse.at[langSlug+"/"+pagePath] = time.Now()
+	pageUse.Unlock()
+}
+
+// notePageCached records a newly cached page and asks for a quota check.
+func notePageCached(langSlug, pagePath string) {
+	if cacheMaxSize <= 0 {
+		return
+	}
+	notePageUse(langSlug, pagePath)
+	select {
+	case quotaCheck <- struct{}{}:
+	default:
+	}
+}
+
+// enforceCacheQuota keeps the cache directory under cacheMaxSize until ctx is cancelled.
+func enforceCacheQuota(ctx context.Context) {
+	ticker := time.NewTicker(quotaCheckInterval)
+	defer ticker.Stop()
+	warned := false
+	for {
+		over, err := evictPages(int64(cacheMaxSize))
+		if err != nil {
+			log.Printf("Failed to enforce -cache-max-size: %v\n", err)
+		}
+		if over > 0 && !warned {
+			log.Printf("Warning: the cache is %s over -cache-max-size with no pages left to evict: indexes, snapshots and the metadata store are never evicted\n", formatBytes(over))
+		}
+		warned = over > 0
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-quotaCheck:
+		}
+	}
+}
+
+// evictPages removes the least recently used cached pages while the cache is above maxBytes,
+// down to quotaLowWater of it, and logs each. It returns how far the cache is still above
+// maxBytes.
+func evictPages(maxBytes int64) (int64, error) {
+	total, err := cacheSize()
+	if err != nil || total <= maxBytes {
+		return 0, err
+	}
+	units, err := collectableUnits()
+	if err != nil {
+		return total - maxBytes, err
+	}
+	var pages []cacheUnit
+	pageUse.Lock()
+	for _, unit := range units {
+		if !unit.page {
+			continue
+		}
+		if used, ok := pageUse.at[unit.lang+"/"+unit.name]; ok && used.After(unit.modified) {
+			unit.modified = used
+		}
+		pages = append(pages, unit)
+	}
+	pageUse.Unlock()
+	sort.Slice(pages, func(i, j int) bool { return pages[i].modified.Before(pages[j].modified) })
+
+	target := int64(float64(maxBytes) * quotaLowWater)
+	evicted, freed := 0, int64(0)
+	for _, unit := range pages {
+		if total <= target {
+			break
+		}
+		if err := removeCacheUnit(unit); err != nil {
+			return total - maxBytes, err
+		}
+		pageUse.Lock()
+		delete(pageUse.at, unit.lang+"/"+unit.name)
+		pageUse.Unlock()
+		log.Printf("Evicted cached page %s/%s (%s, last used %s)\n", unit.lang, unit.name, formatBytes(unit.bytes), unit.modified.Format(time.RFC3339))
+		evicted++
+		freed += unit.bytes
+		total -= unit.bytes
+	}
+	if evicted > 0 {
+		log.Printf("Evicted %d cached pages (%s) to keep the cache under -cache-max-size %s\n", evicted, formatBytes(freed), formatBytes(maxBytes))
+	}
+	return max(total-maxBytes, 0), nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Limits for read_many.
+const (
+	defaultReadManyBudget = 100000
+	maxReadManyEntries    = 20
+	readManyConcurrency   = 4
+)
+
+// PageRef names one documentation page.
+type PageRef struct {
+	Lang string `json:"lang"`
+	Path string `json:"path"`
+}
+
+// ManyPage is one page returned by read_many.
+type ManyPage struct {
+	Lang      string      `json:"lang"`
+	Path      string      `json:"path"`
+	Content   string      `json:"content"`
+	Bytes     int         `json:"bytes"`
+	Truncated bool        `json:"truncated"`
+	Next      *ResumeCall `json:"next,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// ReadMany fetches several pages concurrently and fits them into maxTotalBytes. When the pages
+// don't fit, the budget is shared fairly: pages smaller than an equal share are returned whole
+// and the rest split what remains, each cut at the last section boundary that fits. A
+// maxTotalBytes of 0 disables the budget.
+func ReadMany(ctx context.Context, refs []PageRef, maxTotalBytes int) []ManyPage {
+	pages := make([]ManyPage, len(refs))
+	contents := make([]string, len(refs))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, readManyConcurrency)
+	for i, ref := range refs {
+		pages[i] = ManyPage{Lang: ref.Lang, Path: ref.Path}
+		if !isLanguageAllowed(ctx, ref.Lang) {
+			pages[i].Error = fmt.Sprintf("Language '%s' is not allowed by this server configuration.", ref.Lang)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, ref PageRef) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			content, err := ReadDocContent(ctx, ref.Lang, ref.Path)
+			if err != nil {
+				pages[i].Error = err.Error()
+				return
+			}
+			contents[i] = content
+		}(i, ref)
+	}
+	wg.Wait()
+
+	sizes := make([]int, len(contents))
+	for i, content := range contents {
+		sizes[i] = len(content)
+		pages[i].Bytes = len(content)
+	}
+	budgets := allocateBudget(sizes, maxTotalBytes)
+	for i, content := range contents {
+		if len(content) <= budgets[i] {
+			pages[i].Content = content
+			continue
+		}
+		pages[i].Content = cutAtSection(content, budgets[i])
+		pages[i].Truncated = true
+		pages[i].Next = &ResumeCall{
+			Name: "read_doc_content",
+			Arguments: map[string]any{
+				"lang":   pages[i].Lang,
+				"path":   pages[i].Path,
+				"offset": len(pages[i].Content),
+			},
+		}
+	}
+	return pages
+}
+
+// allocateBudget splits total bytes among pages of the given sizes by water-filling: every page
+// gets up to an equal share, and what small pages leave over is shared by the larger ones.
+// A total of 0 gives every page its full size.
+func allocateBudget(sizes []int, total int) []int {
+	budgets := make([]int, len(sizes))
+	sum := 0
+	for _, size := range sizes {
+		sum += size
+	}
+	if total <= 0 || sum <= total {
+		copy(budgets, sizes)
+		return budgets
+	}
+
+	order := make([]int, 0, len(sizes))
+	for i, size := range sizes {
+		if size > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.Slice(order, func(a, b int) bool { return sizes[order[a]] < sizes[order[b]] })
+	remaining := total
+	for n, i := range order {
+		share := remaining / (len(order) - n)
+		if sizes[i] < share {
+			share = sizes[i]
+		}
+		budgets[i] = share
+		remaining -= share
+	}
+	return budgets
+}
+
+// sectionStart matches the opening tag of a heading, where a new section of a page begins.
+var sectionStart = regexp.MustCompile(`(?i)<h[1-6][\s>]`)
+
+// blockEnd matches the closing tag of a block element, a weaker boundary than a heading.
+var blockEnd = regexp.MustCompile(`(?i)</(p|pre|ul|ol|dl|table|div|section|blockquote)>`)
+
+// cutAtSection returns the longest prefix of content within limit bytes that ends at a section
+// boundary: before a heading if possible, else after a block element, else at a rune boundary.
+// The cut never splits a code block or table; when one starts the page, it is kept whole.
+func cutAtSection(content string, limit int) string {
+	if limit >= len(content) {
+		return content
+	}
+	if limit <= 0 {
+		return ""
+	}
+	window := content[:limit]
+	if locs := sectionStart.FindAllStringIndex(window, -1); len(locs) > 0 {
+		if cut := locs[len(locs)-1][0]; cut > 0 {
+			return content[:cut]
+		}
+	}
+	if locs := blockEnd.FindAllStringIndex(window, -1); len(locs) > 0 {
+		return content[:safeCut(content, 0, locs[len(locs)-1][1])]
+	}
+	chunk, _ := sliceContent(content, 0, limit)
+	return strings.TrimRight(chunk, " \t\n")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+
+	"devdocsmcp/internal/docs/page"
+)
+
+// RelatedEntries holds the entries related to a single documentation entry.
+type RelatedEntries struct {
+	Siblings []DocEntry `json:"siblings"`
+	Links    []DocEntry `json:"links"`
+}
+
+// FindRelatedEntries returns the index entries sharing the directory prefix of entryPath
+// and the index entries that the entry's page links to.
+func FindRelatedEntries(ctx context.Context, langSlug, entryPath string) (*RelatedEntries, error) {
+	doc, err := fetchIndex(ctx, langSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	entryPath = stripFragment(entryPath)
+	related := &RelatedEntries{}
+
+	dir := path.Dir(entryPath)
+	for _, entry := range doc.Entries {
+		entryBase := stripFragment(entry.Path)
+		if entryBase == entryPath {
+			continue
+		}
+		if path.Dir(entryBase) == dir {
+			related.Siblings = append(related.Siblings, entry)
+		}
+	}
+
+	content, err := ReadDocContent(ctx, langSlug, entryPath)
+	if err != nil {
+		return nil, err
+	}
+	links, err := page.Links(content)
+	if err != nil {
+		return nil, err
+	}
+
+	byPath := make(map[string]DocEntry, len(doc.Entries))
+	for _, entry := range doc.Entries {
+		if _, ok := byPath[entry.Path]; !ok {
+			byPath[entry.Path] = entry
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, link := range links {
+		target, ok := resolveEntryLink(entryPath, link)
+		if !ok || target == entryPath || seen[target] {
+			continue
+		}
+		entry, ok := byPath[target]
+		if !ok {
+			// Fall back to the page itself when the link points at an anchor that isn't indexed
+			entry, ok = byPath[stripFragment(target)]
+		}
+		if ok {
+			seen[target] = true
+			related.Links = append(related.Links, entry)
+		}
+	}
+
+	return related, nil
+}
+
+// resolveEntryLink resolves an href found on the page at entryPath to an index entry path.
+// External links and links to non-page resources are rejected.
+func resolveEntryLink(entryPath, href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "", false
+	}
+	target := u.Path
+	if target == "" {
+		target = entryPath
+	} else if !strings.HasPrefix(target, "/") {
+		target = path.Join(path.Dir(entryPath), target)
+	}
+	target = strings.TrimPrefix(target, "/")
+	if target == "" || target == "." || strings.HasPrefix(target, "..") {
+		return "", false
+	}
+	if u.Fragment != "" {
+		target += "#" + u.Fragment
+	}
+	return target, true
+}
+
+// stripFragment removes a trailing '#fragment' from an entry path.
+func stripFragment(entryPath string) string {
+	if i := strings.Index(entryPath, "#"); i >= 0 {
+		return entryPath[:i]
+	}
+	return entryPath
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// serverConfigPath and serverLangFlag are the server's -config and -lang values, kept so a
+// reload re-reads the same sources.
+var serverConfigPath, serverLangFlag string
+
+// reloadMu serialises reloads.
+var reloadMu sync.Mutex
+
+// ReloadResult reports what a configuration reload changed.
+type ReloadResult struct {
+	Langs   []string `json:"langs"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Tokens  int      `json:"tokens"`
+}
+
+// reloadConfig re-reads the config file and the language list, the cache encryption key and
+// the per-token allow-lists, and applies them without restarting the server or dropping
+// sessions. The -lang flag, if given, still takes precedence over the config file's langs. On
+// any error the running configuration is kept.
+func reloadConfig() (*ReloadResult, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	cfg, err := readConfig(serverConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	list := serverLangFlag
+	if list == "" {
+		list = strings.Join(cfg.Langs, ",")
+	}
+	if list == "" {
+		return nil, fmt.Errorf("the config file lists no langs")
+	}
+	langs := cfg.ExpandBundles(strings.Split(list, ","))
+	if err := validateLangs(langs); err != nil {
+		return nil, err
+	}
+	cipher, err := cacheCipherFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if err := applyConfig(cfg); err != nil {
+		return nil, err
+	}
+	// Without a key any longer, files are written in plaintext again
+	cacheCipher.Store(cipher)
+
+	before := servedLanguages()
+	initAllowedLanguages(strings.Join(langs, ","))
+	initTokenGrants(cfg.Tokens)
+	after := servedLanguages()
+
+	result := &ReloadResult{Langs: sortedKeys(after), Added: []string{}, Removed: []string{}, Tokens: len(cfg.Tokens)}
+	for lang := range after {
+		if !before[lang] {
+			result.Added = append(result.Added, lang)
+		}
+	}
+	for lang := range before {
+		if !after[lang] {
+			result.Removed = append(result.Removed, lang)
+		}
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+	return result, nil
+}
+
+// reloadOnHangup reloads the configuration whenever the process receives SIGHUP, until ctx is
+// cancelled.
+func reloadOnHangup(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			result, err := reloadConfig()
+			if err != nil {
+				log.Printf("Config reload failed, keeping the current configuration: %v\n", err)
+				continue
+			}
+			log.Printf("Config reloaded: added %v, removed %v\n", result.Added, result.Removed)
+		}
+	}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func handleReloadConfig(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !hasFullAccess(ctx) {
+		return mcp.NewToolResultError("reload_config requires the server's admin token."), nil
+	}
+	result, err := reloadConfig()
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("config reload failed, keeping the current configuration: %v", err)), nil
+	}
+	log.Printf("Config reloaded by tool call: added %v, removed %v\n", result.Added, result.Removed)
+	return newJSONResult(result, nil), nil
+}
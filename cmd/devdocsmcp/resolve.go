@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+
+	"devdocsmcp/internal/docs/manifest"
+)
+
+// maxSlugCandidates is the number of candidates resolve_slug returns besides the best slug.
+const maxSlugCandidates = 5
+
+// SlugCandidate is a documentation set matching a resolve_slug query.
+type SlugCandidate struct {
+	manifest.Resolution
+	Allowed bool `json:"allowed"`
+}
+
+// SlugResolution is the result of resolving a human name to a devdocs slug.
+type SlugResolution struct {
+	Query      string          `json:"query"`
+	Slug       string          `json:"slug"`
+	Candidates []SlugCandidate `json:"candidates"`
+}
+
+// ResolveSlug maps a human name and optional version, such as "React 18" or "Postgres latest",
+// to the canonical devdocs slug. The best candidate the caller is allowed to use wins.
+func ResolveSlug(ctx context.Context, query string) (*SlugResolution, error) {
+	docsets, err := loadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SlugResolution{Query: query, Candidates: []SlugCandidate{}}
+	for _, r := range manifest.Resolve(docsets, query) {
+		candidate := SlugCandidate{Resolution: r, Allowed: isLanguageAllowed(ctx, r.Slug)}
+		if result.Slug == "" && candidate.Allowed {
+			result.Slug = r.Slug
+		}
+		if len(result.Candidates) < maxSlugCandidates {
+			result.Candidates = append(result.Candidates, candidate)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// resourceScheme prefixes the URIs of the resources this server exposes:
+// devdocs://<slug> for a documentation set, devdocs://<slug>/toc for its table of contents and
+// devdocs://<slug>/<path> for one of its pages.
+const resourceScheme = "devdocs://"
+
+// registerResources adds the docset and page resource templates to s.
+func registerResources(s *server.MCPServer) {
+	s.AddResourceTemplate(
+		mcp.NewResourceTemplate(resourceScheme+"{lang}", "Documentation set",
+			mcp.WithTemplateDescription("Metadata and statistics of a documentation set, as returned by doc_info."),
+			mcp.WithTemplateMIMEType("application/json"),
+		),
+		handleDocsetResource,
+	)
+	s.AddResourceTemplate(
+		mcp.NewResourceTemplate(resourceScheme+"{lang}/{+path}", "Documentation page",
+			mcp.WithTemplateDescription("The HTML content of a documentation entry."),
+			mcp.WithTemplateMIMEType("text/html"),
+		),
+		handlePageResource,
+	)
+	// The page template matches this URI too, and hands it to the same handler
+	s.AddResourceTemplate(
+		mcp.NewResourceTemplate(resourceScheme+"{lang}/"+tocPath, "Documentation table of contents",
+			mcp.WithTemplateDescription("The navigation tree of a documentation set, as returned by get_docset_toc without a depth limit."),
+			mcp.WithTemplateMIMEType("application/json"),
+		),
+		handleTOCResource,
+	)
+}
+
+// parseResourceURI splits a devdocs:// URI into its docset slug and, for pages, entry path.
+func parseResourceURI(uri string) (slug, entryPath string, ok bool) {
+	rest, found := strings.CutPrefix(uri, resourceScheme)
+	if !found || rest == "" {
+		return "", "", false
+	}
+	slug, entryPath, _ = strings.Cut(rest, "/")
+	return slug, entryPath, slug != ""
+}
+
+func handleDocsetResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	lang, _, _ := parseResourceURI(request.Params.URI)
+	if !isLanguageAllowed(ctx, lang) {
+		return nil, fmt.Errorf("language '%s' is not allowed by this server configuration", lang)
+	}
+	info, err := GetDocInfo(ctx, lang)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode doc info: %w", err)
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		URI:      request.Params.URI,
+		MIMEType: "application/json",
+		Text:     string(data),
+	}}, nil
+}
+
+func handlePageResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	lang, path, _ := parseResourceURI(request.Params.URI)
+	if path == tocPath {
+		return handleTOCResource(ctx, request)
+	}
+	if !isLanguageAllowed(ctx, lang) {
+		return nil, fmt.Errorf("language '%s' is not allowed by this server configuration", lang)
+	}
+	content, err := ReadDocContent(ctx, lang, path)
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		URI:      request.Params.URI,
+		MIMEType: "text/html",
+		Text:     content,
+	}}, nil
+}
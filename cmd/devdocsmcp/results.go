@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// newJSONResult encodes payload as the text of a tool result. The warnings are attached to the
+// result's _meta and, for object payloads, also added to the payload as a "warnings" array so
+// agents reading only the text still see them.
+func newJSONResult(payload any, warnings []string) *mcp.CallToolResult {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
+	}
+	if warnings == nil {
+		warnings = []string{}
+	}
+
+	if len(data) >= 2 && data[0] == '{' {
+		encoded, err := json.Marshal(warnings)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error())
+		}
+		var b bytes.Buffer
+		b.Write(data[:len(data)-1])
+		if !bytes.Equal(data, []byte("{}")) {
+			b.WriteByte(',')
+		}
+		b.WriteString(`"warnings":`)
+		b.Write(encoded)
+		b.WriteByte('}')
+		data = b.Bytes()
+	}
+
+	result := mcp.NewToolResultText(string(data))
+	result.Meta = map[string]any{"warnings": warnings}
+	return result
+}
+
+// newTextResult returns text as a tool result. Non-empty warnings are attached to the result's
+// _meta and appended as a separate JSON content block ({"warnings": [...]}).
+func newTextResult(text string, warnings []string) *mcp.CallToolResult {
+	if warnings == nil {
+		warnings = []string{}
+	}
+	result := mcp.NewToolResultText(text)
+	result.Meta = map[string]any{"warnings": warnings}
+	if len(warnings) > 0 {
+		if data, err := json.Marshal(map[string][]string{"warnings": warnings}); err == nil {
+			result.Content = append(result.Content, mcp.NewTextContent(string(data)))
+		}
+	}
+	return result
+}
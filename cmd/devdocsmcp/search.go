@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"devdocsmcp/internal/docs/classify"
+	"devdocsmcp/internal/docs/match"
+	"devdocsmcp/internal/docs/searchquery"
+)
+
+// Search modes accepted by SearchDoc.
+const (
+	modeExact    = "exact"
+	modePrefix   = "prefix"
+	modeFuzzy    = "fuzzy"
+	modeFulltext = "fulltext"
+)
+
+// SearchOptions controls how SearchDoc matches entries.
+type SearchOptions struct {
+	// Mode is one of exact, prefix, fuzzy or fulltext. Empty means fulltext.
+	Mode string
+	// Kind restricts results to reference or guide entries. Empty means both.
+	Kind string
+	// Type restricts results to one entry type (e.g. "Method"), compared case-insensitively.
+	Type string
+	// PathPrefix restricts results to entries whose path starts with it (e.g. "net/http").
+	PathPrefix string
+	// Revision searches a snapshot instead of the current index (see resolveRevision).
+	Revision string
+
+	// pageHits are the full-text hits of a fulltext search by docset, when SearchNamespace
+	// searched the indexes of its docsets at once; SearchDoc searches the index of its docset
+	// itself without them.
+	pageHits map[string][]pageHit
+	// contentHits are the entries whose page matched the query in the docset's local full-text
+	// index, by entry path; set by SearchDoc for fulltext searches.
+	contentHits map[string]pageHit
+}
+
+// checkQuery reports a malformed query of a fulltext search, before any index is searched.
+func checkQuery(query string, opts SearchOptions) error {
+	if (opts.Mode != modeFulltext && opts.Mode != "") || !searchquery.HasSyntax(query) {
+		return nil
+	}
+	_, err := searchquery.Parse(query)
+	return err
+}
+
+// filterEntries returns the entries of the kind, type and path prefix of opts.
+func filterEntries(entries []DocEntry, opts SearchOptions) ([]DocEntry, error) {
+	if opts.Kind != "" {
+		if !classify.Valid(opts.Kind) {
+			return nil, fmt.Errorf("unknown kind %q (expected %s or %s)", opts.Kind, classify.Reference, classify.Guide)
+		}
+		filtered := make([]DocEntry, 0, len(entries))
+		for _, entry := range entries {
+			if entry.Kind == opts.Kind {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+	if opts.Type != "" {
+		filtered := make([]DocEntry, 0, len(entries))
+		for _, entry := range entries {
+			if strings.EqualFold(entry.Type, opts.Type) {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+	if prefix := strings.TrimPrefix(opts.PathPrefix, "/"); prefix != "" {
+		filtered := make([]DocEntry, 0, len(entries))
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Path, prefix) {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+	return entries, nil
+}
+
+// matchEntries returns the entries matching query according to opts.Mode.
+func matchEntries(entries []DocEntry, query string, opts SearchOptions) ([]DocEntry, error) {
+	entries, err := filterEntries(entries, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []DocEntry
+	lowerQuery := strings.ToLower(query)
+
+	switch opts.Mode {
+	case modeExact:
+		for _, entry := range entries {
+			if strings.ToLower(entry.Name) == lowerQuery {
+				results = append(results, entry)
+			}
+		}
+	case modePrefix:
+		for _, entry := range entries {
+			if strings.HasPrefix(strings.ToLower(entry.Name), lowerQuery) || strings.HasPrefix(strings.ToLower(path.Base(entry.Path)), lowerQuery) {
+				results = append(results, entry)
+			}
+		}
+	case modeFuzzy:
+		// Typo-tolerant lookup, ranked by edit distance
+		fuzziness := match.DefaultFuzziness(query)
+		for _, entry := range entries {
+			if _, ok := match.Fuzzy(query, entry.Name, fuzziness); ok {
+				results = append(results, entry)
+			}
+		}
+	case modeFulltext, "":
+		matchName := func(entry DocEntry) bool {
+			return strings.Contains(strings.ToLower(entry.Name), lowerQuery) || strings.Contains(strings.ToLower(entry.Path), lowerQuery)
+		}
+		if searchquery.HasSyntax(query) {
+			expr, err := searchquery.Parse(query)
+			if err != nil {
+				return nil, err
+			}
+			matchName = func(entry DocEntry) bool {
+				return expr.Eval(func(t *searchquery.Expr) bool { return entryMatchesTerm(entry, t) })
+			}
+		}
+		for _, entry := range entries {
+			hit, found := opts.contentHits[entry.Path]
+			if found || matchName(entry) {
+				entry.Score, entry.Snippet = hit.Score, hit.Snippet
+				results = append(results, entry)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown search mode %q (expected %s, %s, %s or %s)", opts.Mode, modeExact, modePrefix, modeFuzzy, modeFulltext)
+	}
+
+	sortResults(results, query, opts.Mode)
+	for i := range results {
+		results[i].Highlights = entryHighlights(results[i], lowerQuery, opts.Mode)
+	}
+	return results, nil
+}
+
+// entryMatchesTerm reports whether an entry matches a term of a query in the search syntax.
+// Terms without a field match a substring of the name or path, like plain fulltext queries;
+// content: terms only match through the full-text index.
+func entryMatchesTerm(entry DocEntry, t *searchquery.Expr) bool {
+	value := strings.ToLower(t.Value)
+	switch t.Field {
+	case searchquery.FieldTitle:
+		return strings.Contains(strings.ToLower(entry.Name), value)
+	case searchquery.FieldType:
+		return strings.EqualFold(entry.Type, t.Value)
+	case searchquery.FieldPath:
+		return strings.HasPrefix(entry.Path, strings.TrimPrefix(t.Value, "/"))
+	case searchquery.FieldContent:
+		return false
+	}
+	return strings.Contains(strings.ToLower(entry.Name), value) || strings.Contains(strings.ToLower(entry.Path), value)
+}
+
+// Defaults for search_doc paging.
+const (
+	defaultSearchLimit = 20
+	defaultMaxResults  = 1000
+	defaultBatchLimit  = 5
+	maxBatchQueries    = 50
+)
+
+// SearchPage is one page of search results as returned by search_doc.
+type SearchPage struct {
+	TotalMatches int        `json:"total_matches"`
+	Offset       int        `json:"offset"`
+	Limit        int        `json:"limit"`
+	Results      []DocEntry `json:"results"`
+	// NextCursor resumes the search after the last result of this page.
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Next       *ResumeCall `json:"next,omitempty"`
+	// Facets count all the matches by entry type and docset; set by search_doc.
+	Facets *SearchFacets `json:"facets,omitempty"`
+	// DidYouMean suggests corrected queries when nothing matched; set by search_doc.
+	DidYouMean []string `json:"did_you_mean,omitempty"`
+
+	hasMore bool
+}
+
+// paginate slices results to the requested page. A limit of 0 returns everything from offset,
+// and a maxResults of 0 disables the paging depth cap.
+func paginate(results []DocEntry, offset, limit, maxResults int) SearchPage {
+	return pageAt(results, offset, offset, limit, maxResults)
+}
+
+// pageAt returns the page of results starting at index start, which is the position-th match
+// of the search: the two differ when a cursor resumes a search whose results changed since the
+// previous page. maxResults caps the position, not the index.
+func pageAt(results []DocEntry, start, position, limit, maxResults int) SearchPage {
+	if start < 0 {
+		start = 0
+	}
+	if position < 0 {
+		position = 0
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	reachable := len(results)
+	if maxResults > 0 && start+maxResults-position < reachable {
+		reachable = max(start+maxResults-position, 0)
+	}
+
+	page := SearchPage{TotalMatches: len(results), Offset: position, Limit: limit, Results: []DocEntry{}}
+	if start >= reachable {
+		return page
+	}
+	end := reachable
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	page.Results = results[start:end]
+	page.hasMore = end < reachable
+	return page
+}
+
+// BatchResult holds the results for one query of a search_batch call.
+type BatchResult struct {
+	Query        string     `json:"query"`
+	TotalMatches int        `json:"total_matches"`
+	Results      []DocEntry `json:"results"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// SearchBatch runs several queries against one documentation set, fetching its index only once.
+// Each query returns at most limit results (0 for all).
+func SearchBatch(ctx context.Context, langSlug string, queries []string, opts SearchOptions, limit int) ([]BatchResult, error) {
+	doc, err := fetchIndex(ctx, langSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := make([]BatchResult, 0, len(queries))
+	for _, query := range queries {
+		result := BatchResult{Query: query, Results: []DocEntry{}}
+		matches, err := matchEntries(doc.Entries, query, opts)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			page := paginate(matches, 0, limit, 0)
+			result.TotalMatches = page.TotalMatches
+			result.Results = page.Results
+		}
+		batch = append(batch, result)
+	}
+	return batch, nil
+}
@@ -0,0 +1,411 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"devdocsmcp/internal/docs/indexer"
+	"devdocsmcp/internal/docs/match"
+	"devdocsmcp/internal/docs/mirror"
+	"devdocsmcp/internal/docs/page"
+)
+
+// fullTextIndexing reports whether this build includes the full-text (bleve) indexer; the
+// minimal build leaves it out.
+const fullTextIndexing = true
+
+// shardReleaseInterval is how often open full-text indexes are checked for a writer waiting
+// to update them.
+const shardReleaseInterval = time.Second
+
+// searchedShards are the full-text indexes searched in each download directory, opened
+// read-only by searchShards and kept open.
+var (
+	searchedShardsMu sync.Mutex
+	searchedShards   = make(map[string]*indexer.Shards)
+)
+
+// searchShards returns the full-text indexes of the docsets downloaded into dir for searching.
+// They stay open across searches, so a search doesn't pay for opening them, and follow the
+// rebuilds of 'download' and 'update'. As their read lock would block those commands from
+// writing, an index whose marker was removed, which they do first, is closed within
+// shardReleaseInterval, and is opened again once the marker is back.
+func searchShards(dir string) *indexer.Shards {
+	searchedShardsMu.Lock()
+	defer searchedShardsMu.Unlock()
+	if shards, ok := searchedShards[dir]; ok {
+		return shards
+	}
+	shards := indexer.OpenShardsReadOnly(searchIndexRoot(dir), searchIndexTimeout)
+	searchedShards[dir] = shards
+	go releaseWrittenShards(dir, shards)
+	onShutdown(func() {
+		if err := shards.Close(); err != nil {
+			log.Printf("Failed to close the full-text indexes in %s: %v\n", dir, err)
+		}
+	})
+	return shards
+}
+
+// releaseWrittenShards closes, every shardReleaseInterval, the open shards of dir whose index
+// lost its marker, i.e. is being written or was removed.
+func releaseWrittenShards(dir string, shards *indexer.Shards) {
+	ticker := time.NewTicker(shardReleaseInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, slug := range shards.Opened() {
+			if hasSearchIndex(dir, slug) {
+				continue
+			}
+			if err := shards.Release(slug); err != nil {
+				log.Printf("Failed to release the full-text index of %s: %v\n", slug, err)
+			}
+		}
+	}
+}
+
+// buildSearchIndex indexes every page of a docset downloaded into dir, replacing its previous
+// full-text index, and returns the number of pages indexed. Each page is indexed with the
+// title and type of the index.json entry standing for it, and the slug and version of the
+// docset.
+func buildSearchIndex(dir, slug string) (int, error) {
+	paths, err := downloadedPages(dir, slug)
+	if err != nil {
+		return 0, err
+	}
+	pages, err := openDocsetPages(dir, slug)
+	if err != nil {
+		return 0, err
+	}
+	analyzer := indexAnalyzer(slug)
+	if err := indexer.CheckAnalyzer(analyzer); err != nil {
+		return 0, fmt.Errorf("invalid index_analyzers entry for %s in the config: %w", slug, err)
+	}
+
+	indexDir := searchIndexDir(dir, slug)
+	marker := filepath.Join(indexDir, searchIndexMarker)
+	if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to reset the full-text index of %s: %w", slug, err)
+	}
+	warnPlaintextIndex(dir)
+	shards := indexer.NewShards(searchIndexRoot(dir))
+	defer shards.Close()
+	idx, err := shards.Shard(slug)
+	if err != nil {
+		return 0, err
+	}
+	idx.Analyzer = analyzer
+	idx.BatchSize = indexBatchSize
+
+	log.Printf("Indexing %d pages of %s with the %s analyzer\n", len(paths), slug, analyzer)
+	err = idx.Reindex(func(add func(doc indexer.Document) error) error {
+		return pages.read(paths, add)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := markSearchIndex(dir, slug, analyzer); err != nil {
+		return 0, err
+	}
+	return len(paths), nil
+}
+
+// docsetPages reads the pages of a docset downloaded into dir for the full-text index.
+type docsetPages struct {
+	dir, slug, version string
+	// entries are the entries standing for the pages, by page path.
+	entries map[string]DocEntry
+}
+
+// openDocsetPages reads the index.json and the version of a docset downloaded into dir.
+func openDocsetPages(dir, slug string) (*docsetPages, error) {
+	data, err := readCacheFile(filepath.Join(dir, slug, "index.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index.json of %s: %w", slug, err)
+	}
+	var doc Doc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode index.json of %s: %w", slug, err)
+	}
+	pages := &docsetPages{dir: dir, slug: slug, entries: pageEntries(doc.Entries)}
+	// The version is informative: a download directory without docs.json still indexes
+	if docsets, err := readPackDocsets(dir, []string{slug}); err == nil {
+		pages.version = docsets[0].Version
+	}
+	return pages, nil
+}
+
+// document reads a page for the full-text index.
+func (p *docsetPages) document(pagePath string) (indexer.Document, error) {
+	file, err := mirror.PageFile(filepath.Join(p.dir, p.slug), pagePath)
+	if err != nil {
+		return indexer.Document{}, fmt.Errorf("failed to read page %s of %s: %w", pagePath, p.slug, err)
+	}
+	content, err := readCacheFile(file)
+	if err != nil {
+		return indexer.Document{}, fmt.Errorf("failed to read page %s of %s: %w", pagePath, p.slug, err)
+	}
+	entry := p.entries[pagePath]
+	return indexer.Document{
+		Path:     pagePath,
+		Lang:     p.slug,
+		Version:  p.version,
+		Title:    entry.Name,
+		Type:     entry.Type,
+		Headings: pageHeadings(content),
+		Content:  page.PlainText(string(content)),
+	}, nil
+}
+
+// pageHeadings returns the headings of a page, one per line; a page that can't be split into
+// sections has none.
+func pageHeadings(content []byte) string {
+	sections, err := page.Sections(bytes.NewReader(content))
+	if err != nil {
+		return ""
+	}
+	var headings []string
+	for _, section := range sections {
+		if section.Heading != "" {
+			headings = append(headings, section.Heading)
+		}
+	}
+	return strings.Join(headings, "\n")
+}
+
+// read reads pages with indexWorkers goroutines extracting their text, and passes them to add
+// in no particular order. It stops at the first error.
+func (p *docsetPages) read(paths []string, add func(doc indexer.Document) error) error {
+	type result struct {
+		doc indexer.Document
+		err error
+	}
+	jobs := make(chan string)
+	results := make(chan result, indexWorkers)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(jobs)
+		for _, pagePath := range paths {
+			select {
+			case jobs <- pagePath:
+			case <-done:
+				return
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for range max(indexWorkers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pagePath := range jobs {
+				doc, err := p.document(pagePath)
+				select {
+				case results <- result{doc, err}:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		if r.err != nil {
+			return r.err
+		}
+		if err := add(r.doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexAnalyzer returns the analyzer of the page text configured for the full-text index of a
+// docset.
+func indexAnalyzer(slug string) string {
+	if cfg := currentConfig(); cfg != nil && cfg.IndexAnalyzers[slug] != "" {
+		return cfg.IndexAnalyzers[slug]
+	}
+	return indexer.AnalyzerCode
+}
+
+// openSearchIndex opens the full-text index of a docset downloaded into dir.
+func openSearchIndex(dir, slug string) (fullTextIndex, error) {
+	if !hasSearchIndex(dir, slug) {
+		return nil, fmt.Errorf("%s has no full-text index in %s; run 'download -lang %s -dest %s' first", slug, dir, slug, dir)
+	}
+	shards := indexer.NewShards(searchIndexRoot(dir))
+	idx, err := shards.Shard(slug)
+	if err != nil {
+		return nil, err
+	}
+	return searchIndex{shards, idx}, nil
+}
+
+// searchIndex is a full-text index opened by openSearchIndex.
+type searchIndex struct {
+	shards *indexer.Shards
+	idx    *indexer.Indexer
+}
+
+func (s searchIndex) Search(query string) ([]pageHit, error) {
+	hits, err := s.idx.Search(query)
+	if err != nil {
+		return nil, err
+	}
+	return pageHits(hits), nil
+}
+
+func (s searchIndex) Close() error {
+	return s.shards.Close()
+}
+
+// searchPageText searches the page text of docsets downloaded into dir in their full-text
+// indexes at once, ranking their pages together, in the indexes of searchShards.
+func searchPageText(dir string, slugs []string, text, pathPrefix string) ([]pageHit, error) {
+	hits, err := searchShards(dir).SearchText(slugs, text, pathPrefix, maxContentHits)
+	if err != nil {
+		return nil, err
+	}
+	return pageHits(hits), nil
+}
+
+// suggestIndexTerms looks up the words of the page titles and headings in the full-text
+// indexes of docsets downloaded into dir within the fuzziness of each word's length, up to n
+// per word, in the pages of the kind, type and path prefix of opts.
+func suggestIndexTerms(dir string, slugs, words []string, n int, opts SearchOptions) (map[string][]indexTerm, error) {
+	shards := searchShards(dir)
+	filter := indexer.TermFilter{Kind: opts.Kind, Type: opts.Type, PathPrefix: opts.PathPrefix}
+	result := make(map[string][]indexTerm, len(words))
+	for _, word := range words {
+		suggestions, err := shards.SuggestTerms(slugs, word, match.DefaultFuzziness(word), n, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, suggestion := range suggestions {
+			result[word] = append(result[word], indexTerm{term: suggestion.Term, count: suggestion.Count})
+		}
+	}
+	return result, nil
+}
+
+// pageHits converts the hits of a full-text index into {lang, path} page identifiers.
+func pageHits(hits []indexer.Hit) []pageHit {
+	result := make([]pageHit, 0, len(hits))
+	for _, hit := range hits {
+		lang := hit.Lang
+		if lang == "" {
+			lang = hit.Shard
+		}
+		result = append(result, pageHit{Lang: lang, Path: hit.Path, Score: hit.Score, Title: hit.Title, Type: hit.Type, Snippet: hit.Snippet})
+	}
+	return result
+}
+
+// updateSearchIndex brings the full-text index of a docset downloaded into dir up to date with
+// the written (added or modified) and deleted pages of an update, then reconciles it with the
+// page files, removing any other page whose file is gone. The index is marked incomplete
+// meanwhile, so an interrupted update is followed by a full rebuild.
+func updateSearchIndex(dir, slug string, written, deleted []string) error {
+	indexDir := searchIndexDir(dir, slug)
+	marker := filepath.Join(indexDir, searchIndexMarker)
+	analyzer := searchIndexAnalyzer(dir, slug)
+	if err := os.Remove(marker); err != nil {
+		return fmt.Errorf("failed to reset the full-text index of %s: %w", slug, err)
+	}
+	pages, err := openDocsetPages(dir, slug)
+	if err != nil {
+		return err
+	}
+	warnPlaintextIndex(dir)
+	shards := indexer.NewShards(searchIndexRoot(dir))
+	defer shards.Close()
+	idx, err := shards.Shard(slug)
+	if err != nil {
+		return err
+	}
+	idx.BatchSize = indexBatchSize
+
+	docs := make([]indexer.Document, 0, len(written))
+	err = pages.read(written, func(doc indexer.Document) error {
+		docs = append(docs, doc)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := idx.Update(docs, deleted); err != nil {
+		return err
+	}
+	stale, err := idx.Reconcile(func(pagePath string) bool {
+		file, err := mirror.PageFile(filepath.Join(dir, slug), pagePath)
+		if err != nil {
+			return false
+		}
+		_, err = os.Stat(file)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(stale) > 0 {
+		log.Printf("Removed %d pages of %s without a file from the full-text index: %s\n", len(stale), slug, strings.Join(stale, ", "))
+	}
+	return markSearchIndex(dir, slug, analyzer)
+}
+
+// pruneSearchIndexes removes the full-text indexes of docsets that are no longer downloaded
+// into dir, e.g. whose directory was deleted, and returns their slugs.
+func pruneSearchIndexes(dir string) ([]string, error) {
+	shards := indexer.NewShards(searchIndexRoot(dir))
+	defer shards.Close()
+	slugs, err := shards.Slugs()
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, slug := range slugs {
+		if _, err := os.Stat(filepath.Join(dir, slug, "index.json")); !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := shards.Delete(slug); err != nil {
+			return removed, err
+		}
+		removed = append(removed, slug)
+	}
+	return removed, nil
+}
+
+// inspectSearchIndex opens the full-text index of a docset downloaded into dir read-only and
+// returns the number of its documents and, when listPaths is set, their paths.
+func inspectSearchIndex(dir, slug string, listPaths bool) (uint64, []string, error) {
+	shards := indexer.OpenShardsReadOnly(searchIndexRoot(dir), searchIndexTimeout)
+	defer shards.Close()
+	idx, err := shards.Shard(slug)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	count, err := idx.DocCount()
+	if err != nil || !listPaths {
+		return count, nil, err
+	}
+	paths, err := idx.Paths()
+	return count, paths, err
+}
@@ -0,0 +1,51 @@
+//go:build minimal
+
+package main
+
+import "errors"
+
+// fullTextIndexing reports whether this build includes the full-text (bleve) indexer; the
+// minimal build leaves it out.
+const fullTextIndexing = false
+
+var errNoFullText = errors.New("full-text indexing is not included in this minimal build")
+
+// buildSearchIndex is unavailable in the minimal build.
+func buildSearchIndex(dir, slug string) (int, error) {
+	return 0, errNoFullText
+}
+
+// updateSearchIndex is unavailable in the minimal build.
+func updateSearchIndex(dir, slug string, written, deleted []string) error {
+	return errNoFullText
+}
+
+// searchPageText is unavailable in the minimal build.
+func searchPageText(dir string, slugs []string, text, pathPrefix string) ([]pageHit, error) {
+	return nil, errNoFullText
+}
+
+// suggestIndexTerms is unavailable in the minimal build.
+func suggestIndexTerms(dir string, slugs, words []string, n int, opts SearchOptions) (map[string][]indexTerm, error) {
+	return nil, errNoFullText
+}
+
+// indexAnalyzer is unused in the minimal build, which builds no full-text index.
+func indexAnalyzer(slug string) string {
+	return ""
+}
+
+// openSearchIndex is unavailable in the minimal build.
+func openSearchIndex(dir, slug string) (fullTextIndex, error) {
+	return nil, errNoFullText
+}
+
+// inspectSearchIndex is unavailable in the minimal build.
+func inspectSearchIndex(dir, slug string, listPaths bool) (uint64, []string, error) {
+	return 0, nil, errNoFullText
+}
+
+// pruneSearchIndexes leaves full-text indexes alone in the minimal build.
+func pruneSearchIndexes(dir string) ([]string, error) {
+	return nil, nil
+}
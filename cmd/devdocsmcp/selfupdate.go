@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// releasesAPI is the GitHub API endpoint listing the project's releases.
+const releasesAPI = "https://api.github.com/repos/kelvinzer0/DevDocsMCP/releases"
+
+// updatePublicKey is the base64 ed25519 public key release checksums are signed with, set at
+// link time with -X main.updatePublicKey=... by the release workflow. Builds without it can't
+// verify releases, so they refuse to self-update.
+var updatePublicKey = ""
+
+// errNoUpdateKey is returned by builds without updatePublicKey, which can't verify releases.
+var errNoUpdateKey = errors.New("this build has no update public key to verify releases with; download the release from https://github.com/kelvinzer0/DevDocsMCP/releases instead")
+
+// release is the part of a GitHub release that self-update needs.
+type release struct {
+	TagName string         `json:"tag_name"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// find returns the asset with the given name.
+func (r *release) find(name string) (releaseAsset, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return releaseAsset{}, false
+}
+
+// releaseAssetName is the name of the release binary for this platform, as built by the
+// release workflow.
+func releaseAssetName() string {
+	name := fmt.Sprintf("devdocsmcp_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// runSelfUpdate implements the 'self-update' command.
+func runSelfUpdate(args []string) {
+	cmd := flag.NewFlagSet("self-update", flag.ExitOnError)
+	check := cmd.Bool("check", false, "Only report whether a newer release is available")
+	tag := cmd.String("version", "", "Install this release tag instead of the latest (e.g. v1.2.0)")
+	force := cmd.Bool("force", false, "Reinstall even if the release matches the running version")
+	cmd.Parse(args)
+
+	if updatePublicKey == "" && !*check {
+		log.Fatalf("Error: %v", errNoUpdateKey)
+	}
+	rel, err := fetchRelease(*tag)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	current := buildInfo().Version
+	if rel.TagName == current && !*force {
+		fmt.Printf("devdocsmcp %s is up to date.\n", current)
+		return
+	}
+	if *check {
+		fmt.Printf("devdocsmcp %s is available (running %s). Run 'devdocsmcp self-update' to install it.\n", rel.TagName, current)
+		return
+	}
+
+	path, err := selfUpdate(rel)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("Updated %s from %s to %s.\n", path, current, rel.TagName)
+}
+
+// fetchRelease returns the release with the given tag, or the latest release if tag is empty.
+func fetchRelease(tag string) (*release, error) {
+	url := releasesAPI + "/latest"
+	if tag != "" {
+		url = releasesAPI + "/tags/" + tag
+	}
+	data, err := downloadUpdate(url)
+	if err != nil {
+		return nil, err
+	}
+	var rel release
+	if err := json.Unmarshal(data, &rel); err != nil {
+		return nil, fmt.Errorf("failed to decode release from %s: %w", url, err)
+	}
+	return &rel, nil
+}
+
+// selfUpdate downloads the release binary for this platform, verifies it against the published
+// SHA-256 checksum and its signature and replaces the running executable with it. It returns
+// the path of the replaced executable.
+func selfUpdate(rel *release) (string, error) {
+	// The checksum comes from the same release as the binary, so only its signature vouches for it
+	if updatePublicKey == "" {
+		return "", errNoUpdateKey
+	}
+	name := releaseAssetName()
+	asset, ok := rel.find(name)
+	if !ok {
+		return "", fmt.Errorf("release %s has no binary for %s/%s (%s)", rel.TagName, runtime.GOOS, runtime.GOARCH, name)
+	}
+	sumAsset, ok := rel.find(name + ".sha256")
+	if !ok {
+		return "", fmt.Errorf("release %s publishes no checksum for %s; refusing to install an unverified binary", rel.TagName, name)
+	}
+
+	sumFile, err := downloadUpdate(sumAsset.URL)
+	if err != nil {
+		return "", err
+	}
+	sigAsset, ok := rel.find(name + ".sha256.sig")
+	if !ok {
+		return "", fmt.Errorf("release %s publishes no signature for %s; refusing to install an unverified binary", rel.TagName, name)
+	}
+	sig, err := downloadUpdate(sigAsset.URL)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyChecksumSignature(sumFile, sig); err != nil {
+		return "", err
+	}
+	want, err := parseChecksum(sumFile)
+	if err != nil {
+		return "", fmt.Errorf("invalid checksum file for %s: %w", name, err)
+	}
+
+	binary, err := downloadUpdate(asset.URL)
+	if err != nil {
+		return "", err
+	}
+	got := sha256.Sum256(binary)
+	if !bytes.Equal(got[:], want) {
+		return "", fmt.Errorf("checksum mismatch for %s: expected %x, got %x", name, want, got)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the running executable: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return "", fmt.Errorf("failed to locate the running executable: %w", err)
+	}
+	return exe, replaceExecutable(exe, binary)
+}
+
+// parseChecksum reads the hex SHA-256 digest of a sha256sum-style line ("<digest>  <file>").
+func parseChecksum(data []byte) ([]byte, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return nil, errors.New("empty checksum file")
+	}
+	sum, err := hex.DecodeString(fields[0])
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("%q is not a SHA-256 digest", fields[0])
+	}
+	return sum, nil
+}
+
+// verifyChecksumSignature checks a base64 ed25519 signature of a checksum file against
+// updatePublicKey.
+func verifyChecksumSignature(sumFile, sig []byte) error {
+	key, err := base64.StdEncoding.DecodeString(updatePublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("the update public key built into this binary is invalid")
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("invalid checksum signature: %w", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), sumFile, signature) {
+		return errors.New("checksum signature verification failed")
+	}
+	return nil
+}
+
+// replaceExecutable atomically swaps the file at exe for binary. The new file is written next
+// to exe so the final rename stays on one file system. Windows can't overwrite a running
+// executable, so there the old one is moved aside first.
+func replaceExecutable(exe string, binary []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", exe, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".devdocsmcp-update-*")
+	if err != nil {
+		return fmt.Errorf("failed to write the new binary next to %s (try running with more privileges): %w", exe, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write the new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write the new binary: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0111); err != nil {
+		return fmt.Errorf("failed to make the new binary executable: %w", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return fmt.Errorf("failed to move %s aside: %w", exe, err)
+		}
+		if err := os.Rename(tmp.Name(), exe); err != nil {
+			os.Rename(old, exe)
+			return fmt.Errorf("failed to install the new binary: %w", err)
+		}
+		return nil
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		return fmt.Errorf("failed to install the new binary: %w", err)
+	}
+	return nil
+}
+
+// downloadUpdate fetches url for self-update.
+func downloadUpdate(url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "devdocsmcp/"+buildInfo().Version)
+	if strings.HasPrefix(url, releasesAPI) {
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status code %d - %s", url, resp.StatusCode, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body from %s: %w", url, err)
+	}
+	return data, nil
+}
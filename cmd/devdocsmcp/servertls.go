@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TLS settings of the HTTP transports, set by the server's -tls-* flags.
+var (
+	tlsCertFile   string
+	tlsKeyFile    string
+	tlsSelfSigned bool
+)
+
+// selfSignedValidity is how long a generated development certificate is valid.
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// serverTLSConfig returns the TLS configuration of the HTTP transports, or nil to serve plain
+// HTTP. A certificate given with -tls-cert/-tls-key wins over -tls-self-signed.
+func serverTLSConfig() (*tls.Config, error) {
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		return nil, fmt.Errorf("-tls-cert and -tls-key must be given together")
+	}
+	certFile, keyFile := tlsCertFile, tlsKeyFile
+	if certFile == "" {
+		if !tlsSelfSigned {
+			return nil, nil
+		}
+		var err error
+		if certFile, keyFile, err = selfSignedCertificate(); err != nil {
+			return nil, err
+		}
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}, nil
+}
+
+// selfSignedCertificate returns a self-signed certificate for localhost and this host's name,
+// generating it under the cache directory on first use and whenever it is about to expire,
+// so clients that trusted it keep working across restarts.
+func selfSignedCertificate() (certFile, keyFile string, err error) {
+	dir := filepath.Join(cacheDir(), "tls")
+	certFile, keyFile = filepath.Join(dir, "self-signed.pem"), filepath.Join(dir, "self-signed-key.pem")
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil && time.Until(leaf.NotAfter) > 24*time.Hour {
+			return certFile, keyFile, nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate TLS key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate certificate serial: %w", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"devdocsmcp development"}, CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(selfSignedValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode TLS key: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", fmt.Errorf("failed to create TLS directory: %w", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return "", "", fmt.Errorf("failed to write certificate: %w", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return "", "", fmt.Errorf("failed to write TLS key: %w", err)
+	}
+	log.Printf("Generated a self-signed development certificate at %s; add it to your client's trust store\n", certFile)
+	return certFile, keyFile, nil
+}
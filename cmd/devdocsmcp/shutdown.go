@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// defaultDrainTimeout is how long in-flight requests may finish after a shutdown signal.
+const defaultDrainTimeout = 10 * time.Second
+
+// drainTimeout is set by the server's -drain-timeout flag.
+var drainTimeout = defaultDrainTimeout
+
+// fetchCtx is the context of every upstream fetch; cancelFetches aborts those still running
+// when the drain timeout expires.
+var fetchCtx, cancelFetches = context.WithCancel(context.Background())
+
+// inFlight counts tool calls being handled, so shutdown can wait for them.
+var inFlight sync.WaitGroup
+
+// draining is set once shutdown begins: new tool calls are refused and /readyz fails.
+var draining atomic.Bool
+
+// streamsCtx is cancelled to end the long-lived HTTP requests (SSE streams) once in-flight
+// calls have drained, as they would otherwise hold the listener open until the drain deadline.
+var streamsCtx, closeStreams = context.WithCancel(context.Background())
+
+var (
+	shutdownMu    sync.Mutex
+	shutdownHooks []func()
+)
+
+var (
+	deadlineOnce sync.Once
+	deadline     time.Time
+)
+
+// drainDeadline returns the moment draining must end: drainTimeout after its first call,
+// which happens when shutdown begins.
+func drainDeadline() time.Time {
+	deadlineOnce.Do(func() { deadline = time.Now().Add(drainTimeout) })
+	return deadline
+}
+
+// onShutdown registers f to run during shutdown, after in-flight calls have drained. Hooks
+// run in reverse order of registration.
+func onShutdown(f func()) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	shutdownHooks = append(shutdownHooks, f)
+}
+
+// fetchURL GETs url with the given extra headers (which may be nil), aborting if the server
+// shuts down before the response arrives. At most maxFetches fetches run at once; the caller
+// must close the response body to free its slot.
+func fetchURL(url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	return limitedFetch(req)
+}
+
+// refuseWhileDraining is a tool handler middleware refusing new calls once shutdown began, so
+// clients retry them elsewhere instead of losing them halfway.
+func refuseWhileDraining(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if draining.Load() {
+			return mcp.NewToolResultError("the server is shutting down and accepts no new calls; reconnect or retry on another instance"), nil
+		}
+		return next(ctx, request)
+	}
+}
+
+// beginDrain starts the shutdown of the HTTP transports: new tool calls are refused, and the
+// connected clients are told with a logging notification how long in-flight calls have left.
+func beginDrain(s *server.MCPServer) {
+	if draining.Swap(true) {
+		return
+	}
+	until := drainDeadline()
+	log.Printf("Draining: refusing new tool calls, in-flight calls may finish until %s\n", until.Format(time.TimeOnly))
+	s.SendNotificationToAllClients("notifications/message", map[string]any{
+		"level":  "warning",
+		"logger": "devdocsmcp",
+		"data":   fmt.Sprintf("The server is shutting down: new tool calls are refused, calls in progress may finish within %v. Reconnect to continue.", time.Until(until).Round(time.Second)),
+	})
+}
+
+// closeOnDrain ends the requests of next, such as SSE streams, when closeStreams is called.
+func closeOnDrain(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stop := context.AfterFunc(streamsCtx, cancel)
+		defer stop()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// waitInFlight waits for the in-flight tool calls until the given time, reporting whether they
+// all finished.
+func waitInFlight(until time.Time) bool {
+	done := make(chan struct{})
+	go func() {
+		inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(time.Until(until)):
+		return false
+	}
+}
+
+// trackInFlight is a tool handler middleware counting the calls in progress.
+func trackInFlight(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		inFlight.Add(1)
+		defer inFlight.Done()
+		return next(ctx, request)
+	}
+}
+
+// drain waits for in-flight tool calls until the drain deadline, then cancels the upstream
+// fetches of those still running so they return promptly, and finally runs the shutdown hooks.
+func drain() {
+	if !waitInFlight(drainDeadline()) {
+		log.Println("Drain timeout reached; cancelling in-flight fetches")
+		cancelFetches()
+		if !waitInFlight(time.Now().Add(time.Second)) {
+			log.Println("Some tool calls did not finish; exiting anyway")
+		}
+	}
+	cancelFetches()
+
+	// Let background cache writes land before anything is closed
+	cacheWrites.Wait()
+
+	shutdownMu.Lock()
+	hooks := shutdownHooks
+	shutdownHooks = nil
+	shutdownMu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
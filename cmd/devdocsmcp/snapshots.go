@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"devdocsmcp/internal/docs/manifest"
+)
+
+// Revision describes a snapshot of a documentation set kept in the local snapshot store.
+type Revision struct {
+	Slug    string `json:"slug"`
+	Version string `json:"version,omitempty"`
+	Mtime   int64  `json:"mtime"`
+	Date    string `json:"date"`
+	Pages   int    `json:"pages"`
+	SavedAt string `json:"saved_at"`
+}
+
+// snapshotsDir returns the directory holding the snapshots of a documentation set.
+func snapshotsDir(langSlug string) string {
+	return filepath.Join(cacheDir(), "snapshots", langSlug)
+}
+
+// snapshotDir returns the directory of one snapshot, named after the revision's mtime.
+func snapshotDir(langSlug string, mtime int64) string {
+	return filepath.Join(snapshotsDir(langSlug), strconv.FormatInt(mtime, 10))
+}
+
+// snapshotPageFile maps a page path to its file in a snapshot, rejecting paths that escape it.
+func snapshotPageFile(dir, pagePath string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(pagePath))
+	if clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid page path %q", pagePath)
+	}
+	return filepath.Join(dir, "pages", clean+".html"), nil
+}
+
+// SaveSnapshot downloads the current revision of a documentation set (its index and every page)
+// into the snapshot store, so it can still be served after devdocs moves on to a newer release.
+func SaveSnapshot(langSlug string) (*Revision, error) {
+	docsets, err := loadManifest()
+	if err != nil {
+		return nil, err
+	}
+	docset, ok := manifest.Find(docsets, langSlug)
+	if !ok {
+		return nil, fmt.Errorf("documentation set %s is not listed in the devdocs manifest", langSlug)
+	}
+
+	index, err := fetchRaw(fmt.Sprintf("%s/index.json?%d", langSlug, docset.Mtime))
+	if err != nil {
+		return nil, err
+	}
+	db, err := fetchRaw(fmt.Sprintf("%s/db.json?%d", langSlug, docset.Mtime))
+	if err != nil {
+		return nil, err
+	}
+	var pages map[string]string
+	if err := json.Unmarshal(db, &pages); err != nil {
+		return nil, fmt.Errorf("failed to decode db.json for %s: %w", langSlug, err)
+	}
+
+	// The snapshot is staged and moved into place whole, so an interrupted save leaves no
+	// partial revision behind
+	dir := snapshotDir(langSlug, docset.Mtime)
+	tx, err := beginCacheTx(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write snapshot of %s: %w", langSlug, err)
+	}
+	for pagePath, content := range pages {
+		file, err := snapshotPageFile(dir, pagePath)
+		if err != nil {
+			log.Printf("Snapshot: skipping page %s/%s: %v\n", langSlug, pagePath, err)
+			continue
+		}
+		name, _ := filepath.Rel(dir, file)
+		if err := tx.Write(filepath.ToSlash(name), []byte(content)); err != nil {
+			tx.Abort()
+			return nil, fmt.Errorf("failed to write snapshot of %s: %w", langSlug, err)
+		}
+	}
+	if err := tx.Write("index.json", index); err != nil {
+		tx.Abort()
+		return nil, fmt.Errorf("failed to write snapshot of %s: %w", langSlug, err)
+	}
+
+	revision := &Revision{
+		Slug:    langSlug,
+		Version: docset.Version,
+		Mtime:   docset.Mtime,
+		Date:    time.Unix(docset.Mtime, 0).UTC().Format(time.RFC3339),
+		Pages:   len(pages),
+		SavedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	meta, err := json.Marshal(revision)
+	if err != nil {
+		tx.Abort()
+		return nil, fmt.Errorf("failed to encode snapshot metadata: %w", err)
+	}
+	if err := tx.Write("revision.json", meta); err != nil {
+		tx.Abort()
+		return nil, fmt.Errorf("failed to write snapshot of %s: %w", langSlug, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot of %s: %w", langSlug, err)
+	}
+	return revision, nil
+}
+
+// fetchRaw downloads url, a path on the documentation hosts, and returns its body.
+func fetchRaw(url string) ([]byte, error) {
+	log.Printf("Fetching %s\n", url)
+	resp, err := fetchUpstream(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status code %d - %s", url, resp.StatusCode, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body from %s: %w", url, err)
+	}
+	return data, nil
+}
+
+// ListRevisions returns the snapshots of a documentation set, newest first.
+func ListRevisions(langSlug string) ([]Revision, error) {
+	dirs, err := os.ReadDir(snapshotsDir(langSlug))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Revision{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots of %s: %w", langSlug, err)
+	}
+	revisions := []Revision{}
+	for _, dir := range dirs {
+		data, err := readCacheFile(filepath.Join(snapshotsDir(langSlug), dir.Name(), "revision.json"))
+		if err != nil {
+			continue
+		}
+		var revision Revision
+		if err := json.Unmarshal(data, &revision); err != nil {
+			log.Printf("Ignoring corrupt snapshot %s/%s: %v\n", langSlug, dir.Name(), err)
+			continue
+		}
+		revisions = append(revisions, revision)
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Mtime > revisions[j].Mtime })
+	return revisions, nil
+}
+
+// resolveRevision picks the snapshot a revision argument refers to: a Unix mtime names one
+// exactly, while a date (2006-01-02) or RFC 3339 time selects the newest snapshot published at
+// or before it.
+func resolveRevision(langSlug, spec string) (*Revision, error) {
+	revisions, err := ListRevisions(langSlug)
+	if err != nil {
+		return nil, err
+	}
+	available := func() string {
+		if len(revisions) == 0 {
+			return fmt.Sprintf("no snapshots are stored; create one with 'devdocsmcp snapshot save -lang %s'", langSlug)
+		}
+		dates := make([]string, len(revisions))
+		for i, r := range revisions {
+			dates[i] = fmt.Sprintf("%d (%s)", r.Mtime, r.Date)
+		}
+		return "available: " + strings.Join(dates, ", ")
+	}
+
+	if mtime, err := strconv.ParseInt(spec, 10, 64); err == nil {
+		for i := range revisions {
+			if revisions[i].Mtime == mtime {
+				return &revisions[i], nil
+			}
+		}
+		return nil, fmt.Errorf("no snapshot of %s with revision %d; %s", langSlug, mtime, available())
+	}
+
+	var at time.Time
+	if day, err := time.Parse(time.DateOnly, spec); err == nil {
+		at = day.Add(24*time.Hour - time.Second)
+	} else if at, err = time.Parse(time.RFC3339, spec); err != nil {
+		return nil, fmt.Errorf("invalid revision %q (expected a Unix mtime, a date like 2024-01-31 or an RFC 3339 time)", spec)
+	}
+	for i := range revisions {
+		if revisions[i].Mtime <= at.Unix() {
+			return &revisions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no snapshot of %s from %s or earlier; %s", langSlug, spec, available())
+}
+
+// fetchIndexAt returns the index of a documentation set at a revision, or the current index
+// when revision is empty.
+func fetchIndexAt(ctx context.Context, langSlug, revision string) (*Doc, error) {
+	if revision == "" {
+		return fetchIndex(ctx, langSlug)
+	}
+	r, err := resolveRevision(langSlug, revision)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readCacheFile(filepath.Join(snapshotDir(langSlug, r.Mtime), "index.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot index of %s: %w", langSlug, err)
+	}
+	var doc Doc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot index of %s: %w", langSlug, err)
+	}
+	annotateEntries(langSlug, &doc)
+	return &doc, nil
+}
+
+// ReadDocContentAt reads a page of a documentation set at a revision, or the current page when
+// revision is empty.
+func ReadDocContentAt(ctx context.Context, langSlug, entryPath, revision string) (string, error) {
+	if revision == "" {
+		return ReadDocContent(ctx, langSlug, entryPath)
+	}
+	r, err := resolveRevision(langSlug, revision)
+	if err != nil {
+		return "", err
+	}
+	pagePath := pagePathOf(ctx, langSlug, entryPath, revision)
+	file, err := snapshotPageFile(snapshotDir(langSlug, r.Mtime), pagePath)
+	if err != nil {
+		return "", err
+	}
+	data, err := readCacheFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("page %s does not exist in the %s snapshot of %s", entryPath, r.Date, langSlug)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read snapshot page %s of %s: %w", entryPath, langSlug, err)
+	}
+	return string(data), nil
+}
+
+// runSnapshot implements the 'snapshot' command.
+func runSnapshot(args []string) {
+	if len(args) < 1 || (args[0] != "save" && args[0] != "list") {
+		log.Fatal("Error: usage: devdocsmcp snapshot save|list -lang <comma_separated_languages>")
+	}
+
+	cmd := flag.NewFlagSet("snapshot "+args[0], flag.ExitOnError)
+	langs := cmd.String("lang", "", "Comma-separated list of language slugs or bundles")
+	configPath := cmd.String("config", "", "Path to the config file (default: $DEVDOCSMCP_CONFIG or the user config directory)")
+	cmd.Parse(args[1:])
+
+	if err := loadConfig(*configPath); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *langs == "" {
+		log.Fatal("Error: -lang is required for the snapshot command.")
+	}
+	slugs := currentConfig().ExpandBundles(strings.Split(*langs, ","))
+	if err := validateLangs(slugs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	for _, slug := range slugs {
+		if args[0] == "save" {
+			revision, err := SaveSnapshot(slug)
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			fmt.Printf("Saved %s revision %d (%s, %d pages)\n", slug, revision.Mtime, revision.Date, revision.Pages)
+			continue
+		}
+		revisions, err := ListRevisions(slug)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("%s:\n", slug)
+		if len(revisions) == 0 {
+			fmt.Println("  (no snapshots)")
+		}
+		for _, r := range revisions {
+			fmt.Printf("  %d  %s  %s  %d pages\n", r.Mtime, r.Date, r.Version, r.Pages)
+		}
+	}
+}
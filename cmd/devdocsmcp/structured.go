@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"devdocsmcp/internal/docs/page"
+)
+
+// Output formats accepted by read_doc_content.
+const (
+	formatHTML       = "html"
+	formatStructured = "structured"
+)
+
+// PageMetadata describes the page a structured read came from.
+type PageMetadata struct {
+	Lang        string       `json:"lang"`
+	Path        string       `json:"path"`
+	URL         string       `json:"url"`
+	Summary     string       `json:"summary,omitempty"`
+	Bytes       int          `json:"bytes"`
+	Breadcrumbs []Breadcrumb `json:"breadcrumbs"`
+}
+
+// StructuredPage is a documentation page parsed into headed sections.
+type StructuredPage struct {
+	Title    string         `json:"title"`
+	Sections []page.Section `json:"sections"`
+	Metadata PageMetadata   `json:"metadata"`
+}
+
+// StructurePage parses the HTML of an entry's page into its title, sections (with their code
+// blocks) and metadata. A page that can't be parsed still yields its plain text, with warnings
+// saying what is missing.
+func StructurePage(langSlug, entryPath, content string) (*StructuredPage, []string) {
+	start := time.Now()
+	defer traceSpan("parse", langSlug, entryPath, start)
+	var warnings []string
+	title, summary, err := page.Summary(content)
+	if err != nil {
+		title = path.Base(stripFragment(entryPath))
+		warnings = append(warnings, fmt.Sprintf("failed to parse %s/%s (%v); title and summary are missing", langSlug, entryPath, err))
+	}
+	sections, err := page.Sections(strings.NewReader(content))
+	if err != nil {
+		sections = page.PlainSections(content)
+		warnings = append(warnings, fmt.Sprintf("failed to split %s/%s into sections (%v); returning its plain text as one section", langSlug, entryPath, err))
+	}
+	if sections == nil {
+		sections = []page.Section{}
+	}
+	return &StructuredPage{
+		Title:    title,
+		Sections: sections,
+		Metadata: PageMetadata{
+			Lang:        langSlug,
+			Path:        entryPath,
+			URL:         devdocsURL + langSlug + "/" + entryPath,
+			Summary:     summary,
+			Bytes:       len(content),
+			Breadcrumbs: readBreadcrumbs(langSlug, entryPath, content),
+		},
+	}, warnings
+}
+
+// addFilterArguments adds the content filter options of a read to the arguments of a resume
+// call, so the next piece is read with the same filter.
+func addFilterArguments(arguments map[string]any, filter page.Filter) {
+	if filter.OmitExamples {
+		arguments["omit_examples"] = true
+	}
+	if filter.ExamplesOnly {
+		arguments["examples_only"] = true
+	}
+	if filter.OmitTables {
+		arguments["omit_tables"] = true
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"devdocsmcp/internal/docs/manifest"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// defaultRevisionCheckInterval is how often subscribed docsets are checked for a new revision.
+const defaultRevisionCheckInterval = time.Hour
+
+// revisionCheckInterval is set by the server's -refresh-interval flag.
+var revisionCheckInterval = defaultRevisionCheckInterval
+
+// subscriptions tracks which sessions want to hear about changes to which resources.
+type subscriptions struct {
+	mu        sync.Mutex
+	byURI     map[string]map[string]bool // resource URI -> session IDs
+	revisions map[string]int64           // docset slug -> last seen manifest mtime
+}
+
+var resourceSubscriptions = &subscriptions{
+	byURI:     make(map[string]map[string]bool),
+	revisions: make(map[string]int64),
+}
+
+// Subscription reports the state of a resource subscription.
+type Subscription struct {
+	URI        string `json:"uri"`
+	Subscribed bool   `json:"subscribed"`
+	Revision   int64  `json:"revision,omitempty"`
+}
+
+func (subs *subscriptions) subscribe(ctx context.Context, uri, sessionID string) (*Subscription, error) {
+	slug, _, ok := parseResourceURI(uri)
+	if !ok {
+		return nil, fmt.Errorf("invalid resource URI %q (expected %s<slug> or %s<slug>/<path>)", uri, resourceScheme, resourceScheme)
+	}
+	if !isLanguageAllowed(ctx, slug) {
+		return nil, fmt.Errorf("Language '%s' is not allowed by this server configuration.", slug)
+	}
+
+	// Record the revision the subscriber is looking at so later changes can be detected
+	var revision int64
+	if docsets, err := loadManifest(); err == nil {
+		if docset, ok := manifest.Find(docsets, slug); ok {
+			revision = docset.Mtime
+		}
+	}
+
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	if subs.byURI[uri] == nil {
+		subs.byURI[uri] = make(map[string]bool)
+	}
+	subs.byURI[uri][sessionID] = true
+	if _, known := subs.revisions[slug]; !known && revision != 0 {
+		subs.revisions[slug] = revision
+	}
+	return &Subscription{URI: uri, Subscribed: true, Revision: subs.revisions[slug]}, nil
+}
+
+func (subs *subscriptions) unsubscribe(uri, sessionID string) *Subscription {
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	delete(subs.byURI[uri], sessionID)
+	if len(subs.byURI[uri]) == 0 {
+		delete(subs.byURI, uri)
+	}
+	return &Subscription{URI: uri, Subscribed: false}
+}
+
+// slugs returns the docsets with at least one subscribed resource.
+func (subs *subscriptions) slugs() []string {
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	seen := make(map[string]bool)
+	var slugs []string
+	for uri := range subs.byURI {
+		if slug, _, ok := parseResourceURI(uri); ok && !seen[slug] {
+			seen[slug] = true
+			slugs = append(slugs, slug)
+		}
+	}
+	sort.Strings(slugs)
+	return slugs
+}
+
+// checkRevisions refreshes the manifest and, for every subscribed docset whose revision
+// changed, drops the cached index and notifies the subscribers of its resources.
+func (subs *subscriptions) checkRevisions(s *server.MCPServer) {
+	slugs := subs.slugs()
+	if len(slugs) == 0 {
+		return
+	}
+	// A zero TTL forces a download, falling back to the cached copy if devdocs.io is unreachable
+	docsets, err := loadUpstreamManifest(filepath.Join(cacheDir(), "docs.json"), 0)
+	if err != nil {
+		log.Printf("Revision check failed: %v\n", err)
+		return
+	}
+	for _, slug := range slugs {
+		docset, ok := manifest.Find(docsets, slug)
+		if !ok {
+			continue
+		}
+		subs.mu.Lock()
+		previous, known := subs.revisions[slug]
+		subs.revisions[slug] = docset.Mtime
+		subs.mu.Unlock()
+		if known && previous != docset.Mtime {
+			log.Printf("Documentation set %s has a new revision (%d -> %d)\n", slug, previous, docset.Mtime)
+			invalidateIndex(slug)
+			subs.notify(s, slug)
+		}
+	}
+}
+
+// notify sends notifications/resources/updated for every subscribed resource of a docset.
+// Subscriptions of sessions that have gone away are dropped.
+func (subs *subscriptions) notify(s *server.MCPServer, slug string) {
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	for uri, sessions := range subs.byURI {
+		if uriSlug, _, _ := parseResourceURI(uri); uriSlug != slug {
+			continue
+		}
+		for sessionID := range sessions {
+			err := s.SendNotificationToSpecificClient(sessionID, mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+			if errors.Is(err, server.ErrSessionNotFound) {
+				delete(sessions, sessionID)
+			} else if err != nil {
+				log.Printf("Failed to notify session %s about %s: %v\n", sessionID, uri, err)
+			}
+		}
+		if len(sessions) == 0 {
+			delete(subs.byURI, uri)
+		}
+	}
+}
+
+// watchRevisions checks subscribed docsets for new revisions every interval. A non-positive
+// interval, or offline mode, disables the checks.
+func watchRevisions(s *server.MCPServer, interval time.Duration) {
+	if interval <= 0 || offline {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		resourceSubscriptions.checkRevisions(s)
+	}
+}
+
+func handleSubscribeResource(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	uri, err := request.RequireString("uri")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return mcp.NewToolResultError("subscriptions require a client session"), nil
+	}
+
+	subscription, err := resourceSubscriptions.subscribe(ctx, uri, session.SessionID())
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return newJSONResult(subscription, nil), nil
+}
+
+func handleUnsubscribeResource(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	uri, err := request.RequireString("uri")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return mcp.NewToolResultError("subscriptions require a client session"), nil
+	}
+
+	return newJSONResult(resourceSubscriptions.unsubscribe(uri, session.SessionID()), nil), nil
+}
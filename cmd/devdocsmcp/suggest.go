@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+
+	"devdocsmcp/internal/docs/match"
+	"devdocsmcp/internal/docs/searchquery"
+)
+
+const (
+	// maxQuerySuggestions is how many corrected queries a search without matches suggests.
+	maxQuerySuggestions = 3
+	// minSuggestLength is the length below which words are too short to correct.
+	minSuggestLength = 3
+)
+
+// indexTerm is a term of a full-text index close to a query word, with the number of pages
+// holding it.
+type indexTerm struct {
+	term  string
+	count uint64
+}
+
+// wordCandidate is a possible correction of a query word.
+type wordCandidate struct {
+	word     string
+	distance int
+	count    uint64
+}
+
+// suggestQueries returns up to maxQuerySuggestions corrections of a query that matched nothing
+// in the docsets of langs with the filters of opts. Each word of the query is replaced by the
+// closest word of an entry name or, in docsets with a full-text index, of a page title or
+// heading, so "useffect" suggests "useEffect". Only the entries and pages of the kind, type and
+// path prefix of opts are considered, so a suggestion can find something with the same filters.
+// Operators, quoted phrases and field prefixes are kept as they are. It returns nil when there
+// is nothing to correct.
+func suggestQueries(ctx context.Context, langs []string, query string, opts SearchOptions) []string {
+	tokens := strings.Fields(query)
+	var words []string
+	for _, token := range tokens {
+		if _, word := splitFieldPrefix(token); isCorrectable(word) {
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	vocabulary := make(map[string]*wordCandidate)
+	for _, lang := range langs {
+		doc, err := fetchIndexAt(ctx, lang, opts.Revision)
+		if err != nil {
+			continue
+		}
+		entries, err := filterEntries(doc.Entries, opts)
+		if err != nil {
+			return nil
+		}
+		for _, entry := range entries {
+			addNameWords(vocabulary, entry.Name)
+		}
+	}
+	var indexTerms map[string][]indexTerm
+	if opts.Revision == "" && fullTextIndexing {
+		var indexed []string
+		for _, lang := range langs {
+			if hasSearchIndex(mirrorDir, lang) {
+				indexed = append(indexed, lang)
+			}
+		}
+		if len(indexed) > 0 {
+			var err error
+			if indexTerms, err = suggestIndexTerms(mirrorDir, indexed, words, maxQuerySuggestions, opts); err != nil {
+				log.Printf("Failed to look up spelling suggestions in the full-text index of %s: %v\n", strings.Join(indexed, ", "), err)
+			}
+		}
+	}
+
+	corrections := make(map[string][]string, len(words))
+	corrected := false
+	for _, word := range words {
+		candidates := correctWord(word, vocabulary, indexTerms[word])
+		corrections[word] = candidates
+		if len(candidates) > 0 && candidates[0] != word {
+			corrected = true
+		}
+	}
+	if !corrected {
+		return nil
+	}
+
+	var suggestions []string
+	seen := map[string]bool{query: true}
+	for k := 0; k < maxQuerySuggestions; k++ {
+		parts := make([]string, len(tokens))
+		for i, token := range tokens {
+			prefix, word := splitFieldPrefix(token)
+			parts[i] = token
+			if candidates := corrections[word]; isCorrectable(word) && len(candidates) > 0 {
+				parts[i] = prefix + candidates[min(k, len(candidates)-1)]
+			}
+		}
+		suggestion := strings.Join(parts, " ")
+		if !seen[suggestion] {
+			seen[suggestion] = true
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions
+}
+
+// splitFieldPrefix splits a field prefix such as title: off a query token.
+func splitFieldPrefix(token string) (prefix, word string) {
+	if colon := strings.IndexByte(token, ':'); colon > 0 {
+		switch strings.ToLower(token[:colon]) {
+		case searchquery.FieldTitle, searchquery.FieldType, searchquery.FieldPath, searchquery.FieldContent:
+			return token[:colon+1], token[colon+1:]
+		}
+	}
+	return "", token
+}
+
+// isCorrectable reports whether a query word is a plain word long enough to correct, as
+// opposed to an operator or part of a quoted phrase or group.
+func isCorrectable(word string) bool {
+	if len([]rune(word)) < minSuggestLength || word == "AND" || word == "OR" || word == "NOT" {
+		return false
+	}
+	for _, r := range word {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// addNameWords adds an entry name and its words, with their case, to the vocabulary.
+func addNameWords(vocabulary map[string]*wordCandidate, name string) {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	for _, word := range words {
+		lower := strings.ToLower(word)
+		if candidate, ok := vocabulary[lower]; ok {
+			candidate.count++
+			continue
+		}
+		vocabulary[lower] = &wordCandidate{word: word, count: 1}
+	}
+}
+
+// correctWord returns the corrections of a query word, best first: the word itself when an
+// entry name has it, else the words of entry names and of the titles and headings of indexed
+// pages within the fuzziness of its length, closest first, then most frequent.
+func correctWord(word string, vocabulary map[string]*wordCandidate, terms []indexTerm) []string {
+	lower := strings.ToLower(word)
+	if _, ok := vocabulary[lower]; ok {
+		return []string{word}
+	}
+	fuzziness := match.DefaultFuzziness(word)
+	best := make(map[string]wordCandidate)
+	for key, candidate := range vocabulary {
+		if distance := match.Distance(lower, key); distance <= fuzziness {
+			best[key] = wordCandidate{word: candidate.word, distance: distance, count: candidate.count}
+		}
+	}
+	for _, term := range terms {
+		distance := match.Distance(lower, term.term)
+		if distance == 0 {
+			return []string{word}
+		}
+		if distance > fuzziness {
+			continue
+		}
+		// Entry names spell the term with its case
+		if candidate, ok := best[term.term]; ok {
+			candidate.count += term.count
+			best[term.term] = candidate
+			continue
+		}
+		best[term.term] = wordCandidate{word: term.term, distance: distance, count: term.count}
+	}
+
+	candidates := make([]wordCandidate, 0, len(best))
+	for _, candidate := range best {
+		candidates = append(candidates, candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		if candidates[i].count != candidates[j].count {
+			return candidates[i].count > candidates[j].count
+		}
+		return candidates[i].word < candidates[j].word
+	})
+	corrections := make([]string, 0, maxQuerySuggestions)
+	for _, candidate := range candidates {
+		if len(corrections) == maxQuerySuggestions {
+			break
+		}
+		corrections = append(corrections, candidate.word)
+	}
+	return corrections
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"devdocsmcp/internal/docs/page"
+)
+
+// devdocsURL is the public devdocs.io site that entries link back to.
+const devdocsURL = "https://devdocs.io/"
+
+// EntrySummary is the short description of a documentation entry.
+type EntrySummary struct {
+	Lang    string     `json:"lang"`
+	Path    string     `json:"path"`
+	Title   string     `json:"title"`
+	Summary string     `json:"summary"`
+	URL     string     `json:"url"`
+	Read    ResumeCall `json:"read"`
+}
+
+// SummarizeEntry returns the title and first meaningful paragraph of an entry, with a link
+// back to the full page and the tool call that reads it.
+func SummarizeEntry(ctx context.Context, langSlug, entryPath string) (*EntrySummary, []string, error) {
+	content, err := ReadDocContent(ctx, langSlug, entryPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	var warnings []string
+	title, summary, err := page.Summary(content)
+	if err != nil {
+		title, summary = plainSummary(content)
+		warnings = append(warnings, fmt.Sprintf("failed to parse %s/%s (%v); summarized its plain text", langSlug, entryPath, err))
+	}
+	return &EntrySummary{
+		Lang:    langSlug,
+		Path:    entryPath,
+		Title:   title,
+		Summary: summary,
+		URL:     devdocsURL + langSlug + "/" + entryPath,
+		Read: ResumeCall{
+			Name:      "read_doc_content",
+			Arguments: map[string]any{"lang": langSlug, "path": entryPath},
+		},
+	}, warnings, nil
+}
+
+// plainSummary stands in for page.Summary on a page that can't be parsed: the first line of
+// its plain text as the title and the next line as the summary.
+func plainSummary(content string) (title, summary string) {
+	lines := strings.SplitN(page.PlainText(content), "\n", 3)
+	title = lines[0]
+	if len(lines) > 1 {
+		summary = lines[1]
+	}
+	return title, summary
+}
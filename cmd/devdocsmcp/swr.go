@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// staleWhileRevalidate is set by the server's -stale-while-revalidate flag: expired cached
+// indexes and pages are served at once, and refreshed in the background, rather than waiting
+// for a slow or unreachable documentation host.
+var staleWhileRevalidate bool
+
+// StaleCopy describes an expired cached file a tool call was answered from.
+type StaleCopy struct {
+	Lang string `json:"lang"`
+	// Path is the file's upstream path, e.g. "go/fmt/index.html".
+	Path         string    `json:"path"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+var (
+	refreshingMu sync.Mutex
+	// refreshing holds the upstream paths being refreshed in the background.
+	refreshing = make(map[string]bool)
+)
+
+// refreshInBackground runs refresh, which downloads upstream path again, unless it is already
+// running. Short-lived commands and shutdown wait for it with cacheWrites.
+func refreshInBackground(upstreamPath string, refresh func() error) {
+	refreshingMu.Lock()
+	if refreshing[upstreamPath] {
+		refreshingMu.Unlock()
+		return
+	}
+	refreshing[upstreamPath] = true
+	refreshingMu.Unlock()
+
+	cacheWrites.Add(1)
+	go func() {
+		defer cacheWrites.Done()
+		defer func() {
+			refreshingMu.Lock()
+			delete(refreshing, upstreamPath)
+			refreshingMu.Unlock()
+		}()
+		if err := refresh(); err != nil {
+			log.Printf("Failed to refresh %s in the background: %v\n", upstreamPath, err)
+		}
+	}()
+}
+
+// staleCall collects the stale copies a tool call in progress was answered from. reportStale
+// carries it in the call's context, so copies served to other calls at the same time aren't
+// reported.
+type staleCall struct {
+	mu     sync.Mutex
+	copies []StaleCopy
+}
+
+type staleCallKey struct{}
+
+// noteStale records in the tool call of ctx, if any, that it was answered from the stale copy
+// of upstream path of lang, downloaded at the given time.
+func noteStale(ctx context.Context, lang, upstreamPath string, downloaded time.Time) {
+	call, ok := ctx.Value(staleCallKey{}).(*staleCall)
+	if !ok {
+		return
+	}
+	call.mu.Lock()
+	call.copies = append(call.copies, StaleCopy{Lang: lang, Path: upstreamPath, DownloadedAt: downloaded})
+	call.mu.Unlock()
+}
+
+// reportStale is a tool handler middleware marking the results answered from stale copies: their
+// metadata lists the copies under "stale", with a warning.
+func reportStale(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !staleWhileRevalidate {
+			return next(ctx, request)
+		}
+
+		call := &staleCall{}
+		result, err := next(context.WithValue(ctx, staleCallKey{}, call), request)
+
+		// Work the call left running in the background may still note copies
+		call.mu.Lock()
+		copies := append([]StaleCopy(nil), call.copies...)
+		call.mu.Unlock()
+		if result == nil || len(copies) == 0 {
+			return result, err
+		}
+		if result.Meta == nil {
+			result.Meta = make(map[string]any)
+		}
+		result.Meta["stale"] = copies
+		warnings, _ := result.Meta["warnings"].([]string)
+		for _, c := range copies {
+			warnings = append(warnings, fmt.Sprintf("%s is a stale copy downloaded %s ago; it is being refreshed", c.Path, time.Since(c.DownloadedAt).Round(time.Second)))
+		}
+		result.Meta["warnings"] = warnings
+		return result, err
+	}
+}
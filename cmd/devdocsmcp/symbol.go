@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"devdocsmcp/internal/docs/match"
+)
+
+// Match tiers used by FindSymbol, best first.
+const (
+	symbolExact           = "exact"
+	symbolCaseInsensitive = "case-insensitive"
+	symbolWordBoundary    = "word-boundary"
+
+	defaultSymbolAlternates = 5
+)
+
+// SymbolMatch is the result of a find_symbol lookup.
+type SymbolMatch struct {
+	Query      string         `json:"query"`
+	Best       *DocEntry      `json:"best"`
+	Match      string         `json:"match,omitempty"`
+	Alternates []SymbolResult `json:"alternates"`
+}
+
+// SymbolResult is a candidate entry together with the tier it matched at.
+type SymbolResult struct {
+	DocEntry
+	Match string `json:"match"`
+}
+
+// FindSymbol looks up a symbol by name: exact case-sensitive matches rank first, then
+// case-insensitive ones, then names containing the symbol as a whole word (e.g. "map" matches
+// "Array.prototype.map()" but not "WeakMap"). It returns the best entry and up to maxAlternates others.
+func FindSymbol(ctx context.Context, langSlug, symbol string, maxAlternates int) (*SymbolMatch, error) {
+	doc, err := fetchIndex(ctx, langSlug)
+	if err != nil {
+		return nil, err
+	}
+	return findSymbolIn(doc.Entries, symbol, maxAlternates), nil
+}
+
+func findSymbolIn(entries []DocEntry, symbol string, maxAlternates int) *SymbolMatch {
+	type candidate struct {
+		result SymbolResult
+		rank   int
+	}
+
+	lowerSymbol := strings.ToLower(symbol)
+	symbolTokens := match.Tokens(symbol)
+
+	var candidates []candidate
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name, "()")
+		switch {
+		case name == symbol || entry.Name == symbol:
+			candidates = append(candidates, candidate{SymbolResult{entry, symbolExact}, 0})
+		case strings.ToLower(name) == lowerSymbol:
+			candidates = append(candidates, candidate{SymbolResult{entry, symbolCaseInsensitive}, 1})
+		default:
+			if rank, ok := wordBoundaryRank(match.Tokens(entry.Name), symbolTokens); ok {
+				candidates = append(candidates, candidate{SymbolResult{entry, symbolWordBoundary}, 2 + rank})
+			}
+		}
+	}
+
+	// Within a tier, prefer shorter names: "map" over "Array.prototype.flatMap"-style noise
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].rank != candidates[j].rank {
+			return candidates[i].rank < candidates[j].rank
+		}
+		return len(candidates[i].result.Name) < len(candidates[j].result.Name)
+	})
+
+	result := &SymbolMatch{Query: symbol, Alternates: []SymbolResult{}}
+	if len(candidates) == 0 {
+		return result
+	}
+	best := candidates[0].result
+	result.Best = &best.DocEntry
+	result.Match = best.Match
+	for _, c := range candidates[1:] {
+		if len(result.Alternates) >= maxAlternates {
+			break
+		}
+		result.Alternates = append(result.Alternates, c.result)
+	}
+	return result
+}
+
+// wordBoundaryRank reports whether the symbol's tokens appear as a contiguous run of whole words
+// in the name's tokens. Matches at the end of the name (the member itself, as in
+// "Array.prototype.map") rank 0, matches elsewhere rank 1.
+func wordBoundaryRank(nameTokens, symbolTokens []string) (int, bool) {
+	if len(symbolTokens) == 0 || len(symbolTokens) > len(nameTokens) {
+		return 0, false
+	}
+	found := false
+	for start := 0; start+len(symbolTokens) <= len(nameTokens); start++ {
+		matched := true
+		for i, token := range symbolTokens {
+			if nameTokens[start+i] != token {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if start+len(symbolTokens) == len(nameTokens) {
+			return 0, true
+		}
+		found = true
+	}
+	if found {
+		return 1, true
+	}
+	return 0, false
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// tocPath is the pseudo page path of a docset's table of contents resource.
+const tocPath = "toc"
+
+// defaultTOCDepth keeps the get_docset_toc tool's default answer to sections, their
+// directories and pages, leaving out the entries inside pages of large docsets.
+const defaultTOCDepth = 3
+
+// TOCNode is a node of a documentation set's navigation tree. Sections (entry types) hold
+// directories and pages, pages hold the entries pointing into them.
+type TOCNode struct {
+	Name string `json:"name"`
+	// Path is set for nodes that are entries and can be read with read_doc_content.
+	Path string `json:"path,omitempty"`
+	// Entries is the number of entries in the node's subtree, including the node itself.
+	Entries  int        `json:"entries"`
+	Children []*TOCNode `json:"children,omitempty"`
+	// Truncated is set when the children were dropped because of a depth limit.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// TOC is the table of contents of a documentation set.
+type TOC struct {
+	Lang     string     `json:"lang"`
+	Entries  int        `json:"entries"`
+	Sections []*TOCNode `json:"sections"`
+}
+
+// GetDocsetTOC reconstructs the navigation tree of a documentation set from its entries: one
+// section per entry type, in the order devdocs lists them, holding the entries arranged by
+// their path hierarchy. entryType optionally restricts the tree to one section, and a positive
+// maxDepth drops the nodes below that depth (sections are depth 1).
+func GetDocsetTOC(ctx context.Context, langSlug, entryType string, maxDepth int) (*TOC, error) {
+	doc, err := fetchIndex(ctx, langSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	sections := make(map[string]*tocBuilder)
+	var order []string
+	for _, t := range doc.Types {
+		if _, ok := sections[t.Name]; !ok {
+			sections[t.Name] = newTOCBuilder(t.Name)
+			order = append(order, t.Name)
+		}
+	}
+	for _, entry := range doc.Entries {
+		if entryType != "" && !strings.EqualFold(entry.Type, entryType) {
+			continue
+		}
+		section, ok := sections[entry.Type]
+		if !ok {
+			section = newTOCBuilder(entry.Type)
+			sections[entry.Type] = section
+			order = append(order, entry.Type)
+		}
+		section.add(entry)
+	}
+
+	toc := &TOC{Lang: langSlug, Sections: []*TOCNode{}}
+	for _, name := range order {
+		section := sections[name]
+		if section.root.Entries == 0 {
+			continue
+		}
+		node := section.build()
+		if maxDepth > 0 {
+			limitDepth(node, maxDepth)
+		}
+		toc.Sections = append(toc.Sections, node)
+		toc.Entries += node.Entries
+	}
+	if entryType != "" && len(toc.Sections) == 0 {
+		return nil, fmt.Errorf("documentation set %s has no entries of type %q", langSlug, entryType)
+	}
+	return toc, nil
+}
+
+// tocBuilder arranges the entries of one section by path.
+type tocBuilder struct {
+	root  *TOCNode
+	nodes map[string]*TOCNode // by page path or directory
+}
+
+func newTOCBuilder(name string) *tocBuilder {
+	if name == "" {
+		name = "Other"
+	}
+	return &tocBuilder{root: &TOCNode{Name: name}, nodes: make(map[string]*TOCNode)}
+}
+
+// add places an entry under its page, creating the page and its directories as needed.
+// Entries with a fragment become children of their page.
+func (b *tocBuilder) add(entry DocEntry) {
+	pagePath, _, hasFragment := strings.Cut(entry.Path, "#")
+	page := b.node(pagePath)
+	if hasFragment {
+		page.Children = append(page.Children, &TOCNode{Name: entry.Name, Path: entry.Path})
+	} else if page.Path == "" {
+		page.Name, page.Path = entry.Name, entry.Path
+	} else {
+		// Another entry names the same page; list it next to the first
+		b.parent(pagePath).Children = append(b.parent(pagePath).Children, &TOCNode{Name: entry.Name, Path: entry.Path})
+	}
+	b.root.Entries++
+}
+
+// node returns the node of a page or directory path, creating it and its ancestors.
+func (b *tocBuilder) node(p string) *TOCNode {
+	if n, ok := b.nodes[p]; ok {
+		return n
+	}
+	n := &TOCNode{Name: path.Base(p)}
+	b.nodes[p] = n
+	parent := b.parent(p)
+	parent.Children = append(parent.Children, n)
+	return n
+}
+
+// parent returns the node a path hangs from: its directory's node, or the section.
+func (b *tocBuilder) parent(p string) *TOCNode {
+	dir := path.Dir(p)
+	if dir == "." || dir == "/" || dir == p {
+		return b.root
+	}
+	return b.node(dir)
+}
+
+// build collapses directories that hold a single node, counts entries and sorts children.
+func (b *tocBuilder) build() *TOCNode {
+	finishTOCNode(b.root)
+	b.root.Entries = 0
+	for _, child := range b.root.Children {
+		b.root.Entries += child.Entries
+	}
+	return b.root
+}
+
+func finishTOCNode(n *TOCNode) {
+	for i, child := range n.Children {
+		finishTOCNode(child)
+		// A directory with one child adds depth without information
+		for child.Path == "" && len(child.Children) == 1 {
+			only := child.Children[0]
+			only.Name = child.Name + "/" + only.Name
+			if only.Path != "" {
+				only.Name = only.Name[strings.LastIndex(only.Name, "/")+1:]
+			}
+			child = only
+		}
+		n.Children[i] = child
+	}
+	n.Entries = 0
+	if n.Path != "" {
+		n.Entries = 1
+	}
+	for _, child := range n.Children {
+		n.Entries += child.Entries
+	}
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		return strings.ToLower(n.Children[i].Name) < strings.ToLower(n.Children[j].Name)
+	})
+}
+
+// limitDepth drops the nodes below depth, marking the nodes that lost children.
+func limitDepth(n *TOCNode, depth int) {
+	if depth <= 1 {
+		if len(n.Children) > 0 {
+			n.Children, n.Truncated = nil, true
+		}
+		return
+	}
+	for _, child := range n.Children {
+		limitDepth(child, depth-1)
+	}
+}
+
+func handleTOCResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	lang, _, _ := parseResourceURI(request.Params.URI)
+	if !isLanguageAllowed(ctx, lang) {
+		return nil, fmt.Errorf("language '%s' is not allowed by this server configuration", lang)
+	}
+	toc, err := GetDocsetTOC(ctx, lang, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(toc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode table of contents: %w", err)
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		URI:      request.Params.URI,
+		MIMEType: "application/json",
+		Text:     string(data),
+	}}, nil
+}
+
+func handleGetDocsetTOC(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lang, err := request.RequireString("lang")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if !isLanguageAllowed(ctx, lang) {
+		return mcp.NewToolResultError(fmt.Sprintf("Language '%s' is not allowed by this server configuration.", lang)), nil
+	}
+
+	entryType := request.GetString("type", "")
+	maxDepth := request.GetInt("max_depth", defaultTOCDepth)
+
+	toc, err := GetDocsetTOC(ctx, lang, entryType, maxDepth)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var warnings []string
+	if maxDepth > 0 && tocTruncated(toc.Sections) {
+		warnings = append(warnings, fmt.Sprintf("tree cut at depth %d; pass type to expand one section or raise max_depth", maxDepth))
+	}
+	return newJSONResult(toc, warnings), nil
+}
+
+func tocTruncated(nodes []*TOCNode) bool {
+	for _, n := range nodes {
+		if n.Truncated || tocTruncated(n.Children) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Transports accepted by the server's -transport and -transports flags.
+const (
+	transportStdio          = "stdio"
+	transportSSE            = "sse"
+	transportStreamableHTTP = "streamable-http"
+)
+
+// Endpoints of the HTTP transports.
+const (
+	sseEndpoint        = "/sse"
+	messageEndpoint    = "/message"
+	streamableEndpoint = "/mcp"
+)
+
+// validateTransport checks a -transport value.
+func validateTransport(transport string) error {
+	switch transport {
+	case transportStdio, transportSSE, transportStreamableHTTP:
+		return nil
+	default:
+		return fmt.Errorf("unknown transport %q (expected %s, %s or %s)", transport, transportStdio, transportSSE, transportStreamableHTTP)
+	}
+}
+
+// parseTransports validates a comma-separated -transports value, dropping duplicates.
+func parseTransports(list string) ([]string, error) {
+	var transports []string
+	seen := make(map[string]bool)
+	for _, transport := range strings.Split(list, ",") {
+		transport = strings.TrimSpace(transport)
+		if transport == "" || seen[transport] {
+			continue
+		}
+		if err := validateTransport(transport); err != nil {
+			return nil, err
+		}
+		seen[transport] = true
+		transports = append(transports, transport)
+	}
+	if len(transports) == 0 {
+		return nil, fmt.Errorf("no transport given")
+	}
+	return transports, nil
+}
+
+// transportHandler returns the HTTP handler serving s over the given HTTP transports. Each
+// transport has its own endpoints, so they can share one listener.
+func transportHandler(s *server.MCPServer, transports []string) http.Handler {
+	mux := http.NewServeMux()
+	for _, transport := range transports {
+		switch transport {
+		case transportSSE:
+			sse := server.NewSSEServer(s,
+				server.WithSSEEndpoint(sseEndpoint),
+				server.WithMessageEndpoint(messageEndpoint),
+			)
+			mux.Handle(sseEndpoint, sse.SSEHandler())
+			mux.Handle(messageEndpoint, sse.MessageHandler())
+		case transportStreamableHTTP:
+			mux.Handle(streamableEndpoint, server.NewStreamableHTTPServer(s, server.WithEndpointPath(streamableEndpoint)))
+		}
+	}
+	return mux
+}
+
+// serve runs s over every given transport at once; the HTTP transports share a listener on
+// port, or on the -listen address (TCP or a Unix socket). All transports use the same server,
+// and therefore the same caches and index. It returns when stdio is the only transport and its
+// client disconnects, when any transport fails, or when ctx is cancelled. In the last case the
+// HTTP transports are drained first: new tool calls are refused, clients are notified, and
+// in-flight calls may finish until the drain deadline before the streams and the listener are
+// closed.
+func serve(ctx context.Context, s *server.MCPServer, transports []string, port string) error {
+	var stdio bool
+	var httpTransports []string
+	for _, transport := range transports {
+		if transport == transportStdio {
+			stdio = true
+		} else {
+			httpTransports = append(httpTransports, transport)
+		}
+	}
+	if len(httpTransports) == 0 {
+		return serveStdio(ctx, s)
+	}
+
+	tlsConfig, err := serverTLSConfig()
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+
+	addr := httpListenAddr(port)
+	ln, err := listenHTTP(addr)
+	if err != nil {
+		return err
+	}
+
+	errs := make(chan error, 2)
+	if stdio {
+		go func() {
+			if err := serveStdio(ctx, s); err != nil && ctx.Err() == nil {
+				errs <- fmt.Errorf("stdio transport: %w", err)
+				return
+			}
+			log.Println("Stdio client disconnected; still serving HTTP")
+		}()
+	}
+
+	for _, transport := range httpTransports {
+		switch transport {
+		case transportSSE:
+			log.Printf("Serving MCP over SSE on %s (stream %s, messages %s)\n", listenURL(scheme, addr), sseEndpoint, messageEndpoint)
+		case transportStreamableHTTP:
+			log.Printf("Serving MCP over streamable HTTP on %s%s\n", listenURL(scheme, addr), streamableEndpoint)
+		}
+	}
+	log.Printf("Health probes on %s and %s\n", healthzEndpoint, readyzEndpoint)
+	if webUI {
+		log.Printf("Web UI on %s%s\n", listenURL(scheme, addr), webEndpoint)
+	}
+	if len(currentGrants()) == 0 {
+		log.Printf("Warning: no -auth-token, $%s or configured tokens; anyone who can reach %s can use this server\n", authTokenEnv, addr)
+	}
+	httpServer := &http.Server{
+		Handler:   healthHandler(webAuth(requireBearer(usageHandler(webHandler(closeOnDrain(transportHandler(s, httpTransports))))))),
+		TLSConfig: tlsConfig,
+	}
+	go func() {
+		if tlsConfig != nil {
+			errs <- httpServer.ServeTLS(ln, "", "")
+		} else {
+			errs <- httpServer.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	beginDrain(s)
+	if waitInFlight(drainDeadline()) {
+		log.Println("In-flight calls finished; closing the streams")
+	}
+	closeStreams()
+	shutdownCtx, cancel := context.WithDeadline(context.Background(), drainDeadline())
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP requests still active at the drain deadline; closing connections: %v\n", err)
+		cancelFetches()
+		httpServer.Close()
+	}
+	return nil
+}
+
+// serveStdio serves s on stdin and stdout until the client disconnects or ctx is cancelled.
+func serveStdio(ctx context.Context, s *server.MCPServer) error {
+	err := server.NewStdioServer(s).Listen(ctx, os.Stdin, os.Stdout)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Truncation marker styles accepted by the server's -truncation-marker flag.
+const (
+	markerJSON = "json"
+	markerText = "text"
+	markerOff  = "off"
+)
+
+// truncationMarkerStyle controls how truncated tool output announces how to continue.
+var truncationMarkerStyle = markerJSON
+
+// defaultMaxResponseBytes caps a page returned by read_doc_content when the server's
+// -max-response-bytes flag is not given.
+const defaultMaxResponseBytes = 200000
+
+// maxResponseBytes is set by the server's -max-response-bytes flag; 0 disables the cap.
+var maxResponseBytes = defaultMaxResponseBytes
+
+// ResumeCall is the exact tool call that fetches the next piece of a truncated output.
+type ResumeCall struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+// TruncationMarker is appended to truncated tool output.
+type TruncationMarker struct {
+	Truncated  bool       `json:"truncated"`
+	Reason     string     `json:"reason"`
+	NextOffset int        `json:"next_offset,omitempty"`
+	Next       ResumeCall `json:"next"`
+}
+
+func setTruncationMarkerStyle(style string) error {
+	switch style {
+	case markerJSON, markerText, markerOff:
+		truncationMarkerStyle = style
+		return nil
+	default:
+		return fmt.Errorf("unknown truncation marker style %q (expected %s, %s or %s)", style, markerJSON, markerText, markerOff)
+	}
+}
+
+// formatTruncationMarker renders the marker for a truncated output according to the configured style.
+// nextOffset is the byte offset the output continues at, or 0 if it is not paged by offset.
+// It returns an empty string when markers are disabled.
+func formatTruncationMarker(reason string, nextOffset int, next ResumeCall) string {
+	switch truncationMarkerStyle {
+	case markerOff:
+		return ""
+	case markerText:
+		keys := make([]string, 0, len(next.Arguments))
+		for k := range next.Arguments {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		args := make([]string, 0, len(keys))
+		for _, k := range keys {
+			args = append(args, fmt.Sprintf("%s=%v", k, next.Arguments[k]))
+		}
+		return fmt.Sprintf("\n\n[Output truncated (%s). To continue, call %s with %s]", reason, next.Name, strings.Join(args, ", "))
+	default:
+		data, err := json.Marshal(TruncationMarker{Truncated: true, Reason: reason, NextOffset: nextOffset, Next: next})
+		if err != nil {
+			return ""
+		}
+		return "\n\n" + string(data)
+	}
+}
+
+// sliceContent returns the piece of content starting at byte offset with at most maxLength bytes.
+// A maxLength of 0 means no limit. The boundaries are moved back to the nearest rune start so
+// multi-byte characters are never split, and the end is moved out of code blocks and tables
+// (see safeCut). The returned next offset is 0 when nothing remains.
+func sliceContent(content string, offset, maxLength int) (string, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(content) {
+		return "", 0
+	}
+	for offset > 0 && !utf8.RuneStart(content[offset]) {
+		offset--
+	}
+	if maxLength <= 0 || offset+maxLength >= len(content) {
+		return content[offset:], 0
+	}
+	end := offset + maxLength
+	for end > offset && !utf8.RuneStart(content[end]) {
+		end--
+	}
+	if end == offset {
+		// maxLength is smaller than a single rune; return that rune to guarantee progress
+		_, size := utf8.DecodeRuneInString(content[offset:])
+		end = offset + size
+	}
+	end = safeCut(content, offset, end)
+	if end >= len(content) {
+		return content[offset:], 0
+	}
+	return content[offset:end], end
+}
+
+// capChunk applies -max-response-bytes to a chunk returned by sliceContent for content,
+// cutting it at a section boundary (see cutAtSection) when it is too large. It returns the
+// chunk, the offset it continues at (0 when nothing remains) and whether the cap applied.
+func capChunk(content, chunk string, next int) (string, int, bool) {
+	if maxResponseBytes <= 0 || len(chunk) <= maxResponseBytes {
+		return chunk, next, false
+	}
+	start := len(content) - len(chunk)
+	if next > 0 {
+		start = next - len(chunk)
+	}
+	chunk = cutAtSection(chunk, maxResponseBytes)
+	if start+len(chunk) >= len(content) {
+		return chunk, 0, false
+	}
+	return chunk, start + len(chunk), true
+}
+
+// protectedTag matches the opening and closing tags of elements that must not be split.
+var protectedTag = regexp.MustCompile(`(?i)<(/?)(pre|table)\b`)
+
+// codeFence matches a Markdown code fence line.
+var codeFence = regexp.MustCompile("(?m)^[ \t]*```")
+
+// protectedRegion returns the outermost 'pre' element, table or fenced code block that
+// strictly contains byte position pos. An unterminated region extends to the end of content.
+func protectedRegion(content string, pos int) (start, end int, ok bool) {
+	depth := 0
+	for _, loc := range protectedTag.FindAllStringSubmatchIndex(content, -1) {
+		if depth == 0 && loc[0] >= pos {
+			break
+		}
+		closing := loc[3] > loc[2]
+		switch {
+		case !closing:
+			if depth == 0 {
+				start = loc[0]
+			}
+			depth++
+		case depth > 0:
+			depth--
+			if depth == 0 {
+				closeEnd := strings.IndexByte(content[loc[1]:], '>')
+				end = loc[1] + closeEnd + 1
+				if closeEnd < 0 {
+					end = len(content)
+				}
+				if start < pos && pos < end {
+					return start, end, true
+				}
+			}
+		}
+	}
+	if depth > 0 && start < pos {
+		return start, len(content), true
+	}
+
+	fences := codeFence.FindAllStringIndex(content, -1)
+	for i := 0; i < len(fences) && fences[i][0] < pos; i += 2 {
+		start, end = fences[i][0], len(content)
+		if i+1 < len(fences) {
+			end = fences[i+1][1]
+			if nl := strings.IndexByte(content[end:], '\n'); nl >= 0 {
+				end += nl + 1
+			} else {
+				end = len(content)
+			}
+		}
+		if start < pos && pos < end {
+			return start, end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// safeCut moves a cut at byte position end of the chunk starting at start out of any code block
+// or table it would split: back to the block's start when that still leaves a non-empty chunk,
+// otherwise forward past the block's end.
+func safeCut(content string, start, end int) int {
+	regionStart, regionEnd, ok := protectedRegion(content, end)
+	if !ok {
+		return end
+	}
+	if regionStart > start {
+		return regionStart
+	}
+	return regionEnd
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"devdocsmcp/internal/docs/manifest"
+)
+
+// defaultDocsBaseURL is the devdocs host serving documentation sets.
+const defaultDocsBaseURL = "https://documents.devdocs.io/"
+
+// docsBaseURLEnv names the environment variable listing the documentation hosts when
+// -docs-base-url is not given.
+const docsBaseURLEnv = "DEVDOCSMCP_DOCS_BASE_URL"
+
+// Failover timing: a host that fails is skipped for upstreamRetryDelay, doubling with every
+// consecutive failure up to upstreamMaxRetryDelay.
+const (
+	upstreamRetryDelay    = 10 * time.Second
+	upstreamMaxRetryDelay = 5 * time.Minute
+)
+
+// docsBaseURLFlag is set by the server's -docs-base-url flag and wins over the environment
+// and the config file.
+var docsBaseURLFlag string
+
+// upstream is a documentation host and its health.
+type upstream struct {
+	url string
+
+	mu        sync.Mutex
+	failures  int
+	downUntil time.Time
+	lastError string
+	lastOK    time.Time
+}
+
+// UpstreamStatus reports the health of a documentation host.
+type UpstreamStatus struct {
+	URL       string `json:"url"`
+	Healthy   bool   `json:"healthy"`
+	Failures  int    `json:"failures,omitempty"`
+	RetryAt   string `json:"retry_at,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+	LastOK    string `json:"last_ok,omitempty"`
+}
+
+var (
+	upstreamsMu sync.RWMutex
+	upstreams   = []*upstream{{url: defaultDocsBaseURL}}
+)
+
+// initUpstreams sets the documentation hosts from -docs-base-url, $DEVDOCSMCP_DOCS_BASE_URL or
+// configured (the config file's docs_base_urls), in that order of precedence, falling back to
+// devdocs. Hosts that are kept keep their health.
+func initUpstreams(configured []string) error {
+	var urls []string
+	switch {
+	case docsBaseURLFlag != "":
+		urls = strings.Split(docsBaseURLFlag, ",")
+	case os.Getenv(docsBaseURLEnv) != "":
+		urls = strings.Split(os.Getenv(docsBaseURLEnv), ",")
+	case len(configured) > 0:
+		urls = configured
+	default:
+		urls = []string{defaultDocsBaseURL}
+	}
+
+	upstreamsMu.Lock()
+	defer upstreamsMu.Unlock()
+	previous := make(map[string]*upstream, len(upstreams))
+	for _, u := range upstreams {
+		previous[u.url] = u
+	}
+	var next []*upstream
+	seen := make(map[string]bool)
+	for _, raw := range urls {
+		base, err := normalizeBaseURL(raw)
+		if err != nil {
+			return err
+		}
+		if base == "" || seen[base] {
+			continue
+		}
+		seen[base] = true
+		if u, ok := previous[base]; ok {
+			next = append(next, u)
+		} else {
+			next = append(next, &upstream{url: base})
+		}
+	}
+	if len(next) == 0 {
+		return fmt.Errorf("no documentation base URL given")
+	}
+	upstreams = next
+	return nil
+}
+
+// docsBaseURL returns the primary documentation host, the first configured of upstreams. It
+// names the documentation source in messages and is the source of 'mirror sync'.
+func docsBaseURL() string {
+	upstreamsMu.RLock()
+	defer upstreamsMu.RUnlock()
+	return upstreams[0].url
+}
+
+// normalizeBaseURL checks a documentation base URL and gives it a trailing slash.
+func normalizeBaseURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid documentation base URL %q (expected an http or https URL)", raw)
+	}
+	return strings.TrimSuffix(raw, "/") + "/", nil
+}
+
+// currentUpstreams returns the documentation hosts in the order to try them: healthy hosts in
+// configured order, then the hosts being skipped, soonest retry first, as a last resort.
+func currentUpstreams() []*upstream {
+	upstreamsMu.RLock()
+	defer upstreamsMu.RUnlock()
+	now := time.Now()
+	var healthy, down []*upstream
+	for _, u := range upstreams {
+		if u.healthy(now) {
+			healthy = append(healthy, u)
+		} else {
+			down = append(down, u)
+		}
+	}
+	for i := 1; i < len(down); i++ {
+		for j := i; j > 0 && down[j].retryAt().Before(down[j-1].retryAt()); j-- {
+			down[j], down[j-1] = down[j-1], down[j]
+		}
+	}
+	return append(healthy, down...)
+}
+
+func (u *upstream) healthy(now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return !now.Before(u.downUntil)
+}
+
+func (u *upstream) retryAt() time.Time {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.downUntil
+}
+
+// succeeded records an answer from the host.
+func (u *upstream) succeeded() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.failures, u.downUntil, u.lastOK = 0, time.Time{}, time.Now()
+}
+
+// failed records a failure of the host and skips it for a while.
+func (u *upstream) failed(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.failures++
+	delay := upstreamRetryDelay << min(u.failures-1, 10)
+	if delay > upstreamMaxRetryDelay {
+		delay = upstreamMaxRetryDelay
+	}
+	u.downUntil = time.Now().Add(delay)
+	u.lastError = err.Error()
+}
+
+func (u *upstream) status(now time.Time) UpstreamStatus {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	status := UpstreamStatus{URL: u.url, Healthy: !now.Before(u.downUntil), Failures: u.failures, LastError: u.lastError}
+	if !status.Healthy {
+		status.RetryAt = u.downUntil.UTC().Format(time.RFC3339)
+	}
+	if !u.lastOK.IsZero() {
+		status.LastOK = u.lastOK.UTC().Format(time.RFC3339)
+	}
+	return status
+}
+
+// upstreamStatuses reports the health of every documentation host, in configured order.
+func upstreamStatuses() []UpstreamStatus {
+	upstreamsMu.RLock()
+	defer upstreamsMu.RUnlock()
+	now := time.Now()
+	statuses := make([]UpstreamStatus, len(upstreams))
+	for i, u := range upstreams {
+		statuses[i] = u.status(now)
+	}
+	return statuses
+}
+
+// fetchUpstream GETs path (e.g. "go/index.json") from the documentation hosts, failing over to
+// the next host when one can't be reached or answers with a server error. A host that doesn't
+// have the file (404) is not marked unhealthy, since mirrors may carry a subset of the docsets;
+// the 404 is returned only if no host has it.
+func fetchUpstream(path string) (*http.Response, error) {
+	return fetchUpstreamIf(path, Validators{})
+}
+
+// fetchUpstreamIf is fetchUpstream as a conditional GET: when v is set, a host may answer 304
+// Not Modified instead of sending the file again. Unconditional fetches of indexes and pages
+// are first tried on the peers found with -peers.
+func fetchUpstreamIf(path string, v Validators) (*http.Response, error) {
+	if offline {
+		return nil, errOffline
+	}
+	if v == (Validators{}) {
+		if resp := fetchFromPeers(path); resp != nil {
+			return resp, nil
+		}
+	}
+	var notFound *http.Response
+	var problems []string
+	hosts := currentUpstreams()
+	for i, u := range hosts {
+		start := time.Now()
+		resp, err := fetchURL(u.url+path, v.header())
+		traceSpan("upstream", langOfPath(path), upstreamDetail(u.url+path, resp, err), start)
+		if err == nil && (resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests) {
+			resp.Body.Close()
+			err = fmt.Errorf("%s answered %s", u.url, resp.Status)
+		}
+		if err != nil {
+			if fetchCtx.Err() != nil {
+				return nil, err
+			}
+			u.failed(err)
+			problems = append(problems, err.Error())
+			if i+1 < len(hosts) {
+				log.Printf("Documentation host %s failed (%v); trying %s\n", u.url, err, hosts[i+1].url)
+			}
+			continue
+		}
+		u.succeeded()
+		if resp.StatusCode == http.StatusNotFound && i+1 < len(hosts) {
+			if notFound != nil {
+				notFound.Body.Close()
+			}
+			notFound = resp
+			continue
+		}
+		if notFound != nil {
+			notFound.Body.Close()
+		}
+		return resp, nil
+	}
+	if notFound != nil {
+		return notFound, nil
+	}
+	if len(problems) == 1 {
+		return nil, fmt.Errorf("%s", problems[0])
+	}
+	return nil, fmt.Errorf("every documentation host failed: %s", strings.Join(problems, "; "))
+}
+
+// probeUpstreams checks the documentation hosts with a HEAD request until one answers,
+// recording their health. It returns nil if a host answered.
+func probeUpstreams(ctx context.Context) error {
+	var problems []string
+	for _, u := range currentUpstreams() {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= http.StatusInternalServerError {
+				err = fmt.Errorf("%s answered %s", u.url, resp.Status)
+			}
+		}
+		if err == nil {
+			u.succeeded()
+			return nil
+		}
+		u.failed(err)
+		problems = append(problems, err.Error())
+	}
+	return fmt.Errorf("%s", strings.Join(problems, "; "))
+}
+
+// manifestURL returns where the devdocs manifest of a documentation host is read: devdocs.io
+// for the devdocs host, otherwise docs.json at the host's root, where 'mirror sync' puts it.
+func manifestURL(base string) string {
+	if base == defaultDocsBaseURL {
+		return manifest.DefaultURL
+	}
+	return base + "docs.json"
+}
+
+// loadUpstreamManifest loads the devdocs manifest through the cache at cachePath (see
+// manifest.Load), trying the manifest of each documentation host in failover order.
+func loadUpstreamManifest(cachePath string, ttl time.Duration) ([]manifest.Docset, error) {
+	var urls []string
+	for _, u := range currentUpstreams() {
+		urls = append(urls, manifestURL(u.url))
+	}
+	return manifest.LoadFirst(urls, cachePath, ttl)
+}
+
+// cachedCopy is a cached copy of an upstream file that revalidate can mark fresh again.
+type cachedCopy interface {
+	exists() bool
+	touch() error
+}
+
+// cachedFile is a cached copy kept in a file of the cache directory.
+type cachedFile string
+
+func (f cachedFile) exists() bool {
+	_, err := os.Stat(string(f))
+	return err == nil
+}
+
+func (f cachedFile) touch() error {
+	now := time.Now()
+	return os.Chtimes(string(f), now, now)
+}
+
+// revalidate fetches path for a cached copy that is no longer fresh. If validators were
+// recorded when the copy was downloaded, the fetch is conditional; when upstream answers 304
+// Not Modified, the copy is marked fresh again and revalidate returns a nil response, so the
+// caller reloads it from the cache. Otherwise the response carries the new content (or an error
+// status), and the caller records its validators with recordValidators.
+func revalidate(path string, copy cachedCopy) (*http.Response, error) {
+	v, ok := lookupValidators(path)
+	if !ok || cacheTTL <= 0 || !copy.exists() {
+		return fetchUpstream(path)
+	}
+	resp, err := fetchUpstreamIf(path, v)
+	if err != nil || resp.StatusCode != http.StatusNotModified {
+		return resp, err
+	}
+	resp.Body.Close()
+	if err := copy.touch(); err != nil {
+		log.Printf("Failed to refresh cached copy of %s: %v\n", path, err)
+		forgetValidators(path)
+		return fetchUpstream(path)
+	}
+	log.Printf("%s has not changed upstream; keeping the cached copy\n", path)
+	return nil, nil
+}
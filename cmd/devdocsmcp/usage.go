@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// usageEndpoint serves the usage report to holders of the admin token, next to the transports.
+const usageEndpoint = "/admin/usage"
+
+// usageRetention is how long the usage of an idle session is kept.
+const usageRetention = 24 * time.Hour
+
+// SessionUsage is what one MCP session has consumed.
+type SessionUsage struct {
+	Session string `json:"session"`
+	// Token names the bearer token the session authenticated with (see the config's tokens).
+	Token       string         `json:"token,omitempty"`
+	Client      string         `json:"client,omitempty"`
+	Calls       int            `json:"calls"`
+	Errors      int            `json:"errors"`
+	BytesServed int64          `json:"bytes_served"`
+	Tools       map[string]int `json:"tools"`
+	Langs       []string       `json:"langs"`
+	FirstSeen   string         `json:"first_seen"`
+	LastSeen    string         `json:"last_seen"`
+}
+
+// UsageReport is the result of the session_usage tool and the usage endpoint.
+type UsageReport struct {
+	Since       string         `json:"since"`
+	Calls       int            `json:"calls"`
+	BytesServed int64          `json:"bytes_served"`
+	Sessions    []SessionUsage `json:"sessions"`
+}
+
+type sessionUsage struct {
+	token, client string
+	calls, errors int
+	bytes         int64
+	tools         map[string]int
+	langs         map[string]bool
+	first, last   time.Time
+}
+
+// usageTracker accumulates per-session usage. It is safe for concurrent use.
+type usageTracker struct {
+	mu       sync.Mutex
+	sessions map[string]*sessionUsage
+}
+
+var toolUsage = &usageTracker{sessions: make(map[string]*sessionUsage)}
+
+// record adds one tool call to the usage of its session.
+func (t *usageTracker) record(ctx context.Context, request mcp.CallToolRequest, result *mcp.CallToolResult, now time.Time) {
+	var sessionID, client string
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		sessionID = session.SessionID()
+		if withInfo, ok := session.(server.SessionWithClientInfo); ok {
+			info := withInfo.GetClientInfo()
+			client = strings.TrimSpace(info.Name + " " + info.Version)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	usage, ok := t.sessions[sessionID]
+	if !ok {
+		t.prune(now)
+		usage = &sessionUsage{tools: make(map[string]int), langs: make(map[string]bool), first: now}
+		t.sessions[sessionID] = usage
+	}
+	usage.token = grantName(ctx)
+	if client != "" {
+		usage.client = client
+	}
+	usage.calls++
+	usage.tools[request.Params.Name]++
+	usage.last = now
+	if result == nil || result.IsError {
+		usage.errors++
+	}
+	if result != nil {
+		for _, content := range result.Content {
+			if text, ok := content.(mcp.TextContent); ok {
+				usage.bytes += int64(len(text.Text))
+			}
+		}
+	}
+	served := servedLanguages()
+	for _, lang := range requestLangs(request) {
+		if served[lang] {
+			usage.langs[lang] = true
+		}
+	}
+}
+
+// prune forgets sessions idle for longer than usageRetention. Callers hold t.mu.
+func (t *usageTracker) prune(now time.Time) {
+	for id, usage := range t.sessions {
+		if now.Sub(usage.last) > usageRetention {
+			delete(t.sessions, id)
+		}
+	}
+}
+
+// report returns the usage of every tracked session, busiest first.
+func (t *usageTracker) report() UsageReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report := UsageReport{Since: startedAt.UTC().Format(time.RFC3339), Sessions: []SessionUsage{}}
+	for id, usage := range t.sessions {
+		tools := make(map[string]int, len(usage.tools))
+		for name, calls := range usage.tools {
+			tools[name] = calls
+		}
+		report.Sessions = append(report.Sessions, SessionUsage{
+			Session:     id,
+			Token:       usage.token,
+			Client:      usage.client,
+			Calls:       usage.calls,
+			Errors:      usage.errors,
+			BytesServed: usage.bytes,
+			Tools:       tools,
+			Langs:       sortedKeys(usage.langs),
+			FirstSeen:   usage.first.UTC().Format(time.RFC3339),
+			LastSeen:    usage.last.UTC().Format(time.RFC3339),
+		})
+		report.Calls += usage.calls
+		report.BytesServed += usage.bytes
+	}
+	sort.Slice(report.Sessions, func(i, j int) bool {
+		a, b := report.Sessions[i], report.Sessions[j]
+		if a.BytesServed != b.BytesServed {
+			return a.BytesServed > b.BytesServed
+		}
+		return a.Session < b.Session
+	})
+	return report
+}
+
+// requestLangs returns the languages a tool call names: its lang argument, which some tools
+// accept as a comma-separated list, and the lang of each of its entries.
+func requestLangs(request mcp.CallToolRequest) []string {
+	var langs []string
+	args := request.GetArguments()
+	if lang, ok := args["lang"].(string); ok {
+		for _, l := range strings.Split(lang, ",") {
+			langs = append(langs, strings.TrimSpace(l))
+		}
+	}
+	if entries, ok := args["entries"].([]any); ok {
+		for _, raw := range entries {
+			if entry, ok := raw.(map[string]any); ok {
+				if lang, ok := entry["lang"].(string); ok {
+					langs = append(langs, lang)
+				}
+			}
+		}
+	}
+	return langs
+}
+
+// recordUsage is a tool handler middleware accounting every call, including rejected ones, to
+// its session.
+func recordUsage(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		toolUsage.record(ctx, request, result, time.Now())
+		return result, err
+	}
+}
+
+func handleSessionUsage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !hasFullAccess(ctx) {
+		return mcp.NewToolResultError("session_usage requires the server's admin token."), nil
+	}
+	return newJSONResult(toolUsage.report(), nil), nil
+}
+
+// usageHandler serves the usage report at usageEndpoint and passes other requests to next.
+// It must run behind requireBearer; tokens restricted to some languages are refused.
+func usageHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != usageEndpoint {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !hasFullAccess(r.Context()) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toolUsage.report())
+	})
+}
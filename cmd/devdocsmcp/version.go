@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Build metadata, set at link time:
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+//
+// When they are not set, the VCS information Go embeds in the binary is used instead.
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// startedAt is when the process started, for the server's uptime.
+var startedAt = time.Now()
+
+// BuildInfo describes the running binary.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+	// Variant is "minimal" for builds with the minimal tag, which leave out the full-text
+	// indexer, and "full" otherwise.
+	Variant string `json:"variant"`
+}
+
+// buildInfo returns the metadata of the running binary.
+func buildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Variant:   "full",
+	}
+	if !fullTextIndexing {
+		info.Variant = "minimal"
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	settings := make(map[string]string, len(bi.Settings))
+	for _, setting := range bi.Settings {
+		settings[setting.Key] = setting.Value
+	}
+	if info.Commit == "" && settings["vcs.revision"] != "" {
+		info.Commit = settings["vcs.revision"]
+		if settings["vcs.modified"] == "true" {
+			info.Commit += "-dirty"
+		}
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = settings["vcs.time"]
+	}
+	return info
+}
+
+// runVersion implements the 'version' command.
+func runVersion() {
+	info := buildInfo()
+	fmt.Printf("devdocsmcp %s\n", info.Version)
+	if info.Commit != "" {
+		fmt.Printf("  commit:     %s\n", info.Commit)
+	}
+	if info.BuildDate != "" {
+		fmt.Printf("  built:      %s\n", info.BuildDate)
+	}
+	fmt.Printf("  go version: %s\n", info.GoVersion)
+	fmt.Printf("  platform:   %s\n", info.Platform)
+	fmt.Printf("  variant:    %s\n", info.Variant)
+}
+
+// ServerInfo describes the running server.
+type ServerInfo struct {
+	BuildInfo
+	Uptime     string   `json:"uptime"`
+	Transports []string `json:"transports"`
+	Langs      []string `json:"langs"`
+	// Upstreams are the documentation hosts and their health, in failover order.
+	Upstreams []UpstreamStatus `json:"upstreams"`
+}
+
+// activeTransports are the transports the server was started with, for server_info.
+var activeTransports []string
+
+func handleServerInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	info := ServerInfo{
+		BuildInfo:  buildInfo(),
+		Uptime:     time.Since(startedAt).Round(time.Second).String(),
+		Transports: activeTransports,
+		Langs:      callerLanguages(ctx),
+		Upstreams:  upstreamStatuses(),
+	}
+	return newJSONResult(info, nil), nil
+}
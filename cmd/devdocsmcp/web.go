@@ -0,0 +1,252 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Endpoints of the -web UI, served next to the HTTP transports.
+const (
+	webEndpoint      = "/ui/"
+	webReadEndpoint  = "/ui/read"
+	webLoginEndpoint = "/ui/login"
+)
+
+// webTokenCookie carries the bearer token of a browser that signed in to the UI, since
+// browsers can't send an Authorization header on their own.
+const webTokenCookie = "devdocsmcp_token"
+
+// webPageSize is the number of search results per page of the UI.
+const webPageSize = 50
+
+// webUI is set by the server's -web flag.
+var webUI bool
+
+var webTemplates = template.Must(template.New("layout").Parse(`{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} · DevDocsMCP</title>
+<style>
+body { font: 15px/1.5 system-ui, sans-serif; margin: 0 auto; max-width: 60rem; padding: 1rem; color: #222; }
+form { display: flex; gap: .5rem; flex-wrap: wrap; margin-bottom: 1rem; }
+input[type=search], input[type=password] { flex: 1; min-width: 12rem; padding: .3rem; }
+ol { padding-left: 2rem; }
+li { margin: .2rem 0; }
+.meta { color: #777; font-size: 85%; }
+.error { color: #b00; }
+iframe { border: 1px solid #ccc; width: 100%; height: 75vh; }
+</style>
+</head>
+<body>
+<h1><a href="{{.Home}}">DevDocsMCP</a></h1>
+{{end}}
+{{define "search"}}{{template "head" .}}
+<form action="{{.Home}}" method="get">
+<select name="lang">{{range .Langs}}<option{{if eq . $.Lang}} selected{{end}}>{{.}}</option>{{end}}</select>
+<input type="search" name="q" value="{{.Query}}" placeholder="Search entries" autofocus>
+<select name="mode">{{range .Modes}}<option{{if eq . $.Mode}} selected{{end}}>{{.}}</option>{{end}}</select>
+<button>Search</button>
+</form>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .Query}}{{if .Page}}<p class="meta">{{.Page.TotalMatches}} matches</p>
+<ol start="{{.Start}}">{{range .Page.Results}}
+<li><a href="{{$.ReadURL}}?lang={{$.Lang}}&amp;path={{.Path}}">{{.Name}}</a> <span class="meta">{{.Type}} · {{.Path}}</span></li>{{end}}
+</ol>
+{{if .NextOffset}}<p><a href="{{.Home}}?lang={{.Lang}}&amp;q={{.Query}}&amp;mode={{.Mode}}&amp;offset={{.NextOffset}}">More results</a></p>{{end}}{{end}}{{end}}
+</body>
+</html>
+{{end}}
+{{define "read"}}{{template "head" .}}
+<p><strong>{{.Lang}}</strong> / {{.Path}}{{if .URL}} · <a href="{{.URL}}">devdocs.io</a>{{end}}</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{else}}<iframe sandbox srcdoc="{{.Content}}" title="{{.Path}}"></iframe>{{end}}
+</body>
+</html>
+{{end}}
+{{define "login"}}{{template "head" .}}
+<form action="{{.Login}}" method="post">
+<input type="password" name="token" placeholder="Bearer token" autofocus>
+<button>Sign in</button>
+</form>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+</body>
+</html>
+{{end}}`))
+
+// webPage is the data of every UI template.
+type webPage struct {
+	Title   string
+	Home    string
+	ReadURL string
+	Login   string
+	Error   string
+
+	Langs []string
+	Modes []string
+	Lang  string
+	Query string
+	Mode  string
+	Page  *SearchPage
+	Start int
+	// NextOffset is the offset of the next page of results, or 0 on the last page.
+	NextOffset int
+
+	Path    string
+	URL     string
+	Content string
+}
+
+func newWebPage(title string) webPage {
+	return webPage{Title: title, Home: webEndpoint, ReadURL: webReadEndpoint, Login: webLoginEndpoint}
+}
+
+// webHandler serves the UI when -web is set and passes other requests to next. It must run
+// behind requireBearer, so the UI shows exactly the languages the caller's token may use.
+func webHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if webUI && r.URL.Path == strings.TrimSuffix(webEndpoint, "/") {
+			http.Redirect(w, r, webEndpoint, http.StatusMovedPermanently)
+			return
+		}
+		if !webUI || !strings.HasPrefix(r.URL.Path, webEndpoint) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		switch r.URL.Path {
+		case webEndpoint:
+			handleWebSearch(w, r)
+		case webReadEndpoint:
+			handleWebRead(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// handleWebSearch serves the search form and one page of results, found by the same search
+// as the search_doc tool.
+func handleWebSearch(w http.ResponseWriter, r *http.Request) {
+	page := newWebPage("Search")
+	page.Langs = callerLanguages(r.Context())
+	page.Modes = []string{modeFulltext, modePrefix, modeExact, modeFuzzy}
+	page.Lang, page.Query, page.Mode = r.FormValue("lang"), strings.TrimSpace(r.FormValue("q")), r.FormValue("mode")
+	if page.Lang == "" && len(page.Langs) > 0 {
+		page.Lang = page.Langs[0]
+	}
+	if page.Mode == "" {
+		page.Mode = modeFulltext
+	}
+	if page.Query != "" {
+		page.Title = page.Query + " in " + page.Lang
+		offset, _ := strconv.Atoi(r.FormValue("offset"))
+		if !isLanguageAllowed(r.Context(), page.Lang) {
+			page.Error = "Language '" + page.Lang + "' is not allowed by this server configuration."
+		} else if results, err := SearchDoc(r.Context(), page.Lang, page.Query, SearchOptions{Mode: page.Mode}); err != nil {
+			page.Error = err.Error()
+		} else {
+			p := paginate(results, offset, webPageSize, 0)
+			page.Page, page.Start = &p, p.Offset+1
+			if p.hasMore {
+				page.NextOffset = p.Offset + len(p.Results)
+			}
+		}
+	}
+	renderWeb(w, "search", page, http.StatusOK)
+}
+
+// handleWebRead shows a documentation page as read_doc_content returns it. The page is shown
+// in a sandboxed frame, so its markup can't run scripts or reach the UI.
+func handleWebRead(w http.ResponseWriter, r *http.Request) {
+	page := newWebPage("Read")
+	page.Lang, page.Path = r.FormValue("lang"), r.FormValue("path")
+	page.Title = page.Path + " in " + page.Lang
+	status := http.StatusOK
+	switch {
+	case page.Lang == "" || page.Path == "":
+		page.Error, status = "lang and path are required.", http.StatusBadRequest
+	case !isLanguageAllowed(r.Context(), page.Lang):
+		page.Error, status = "Language '"+page.Lang+"' is not allowed by this server configuration.", http.StatusForbidden
+	default:
+		content, err := ReadDocContentAt(r.Context(), page.Lang, stripFragment(page.Path), "")
+		if err != nil {
+			page.Error, status = err.Error(), http.StatusBadGateway
+			break
+		}
+		page.Content = content
+		if entry, err := GetEntryURL(r.Context(), page.Lang, page.Path); err == nil {
+			page.URL = entry.URL
+		}
+	}
+	renderWeb(w, "read", page, status)
+}
+
+func renderWeb(w http.ResponseWriter, name string, page webPage, status int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	if err := webTemplates.ExecuteTemplate(w, name, page); err != nil {
+		log.Printf("Failed to render %s page: %v\n", name, err)
+	}
+}
+
+// webAuth lets browsers use the UI on a server that requires bearer tokens: a token entered
+// on the sign-in page is kept in a cookie and turned back into an Authorization header for
+// UI requests, and UI requests without a valid token are sent to the sign-in page. It must run
+// in front of requireBearer; other requests pass through untouched.
+func webAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		grants := currentGrants()
+		if !webUI || len(grants) == 0 || !strings.HasPrefix(r.URL.Path, webEndpoint) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path == webLoginEndpoint {
+			handleWebLogin(w, r, grants)
+			return
+		}
+		if r.Header.Get("Authorization") == "" {
+			if cookie, err := r.Cookie(webTokenCookie); err == nil {
+				r.Header.Set("Authorization", "Bearer "+cookie.Value)
+			}
+		}
+		if _, ok := authenticate(r.Header.Get("Authorization"), grants); !ok {
+			http.Redirect(w, r, webLoginEndpoint, http.StatusSeeOther)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleWebLogin serves the sign-in form and, on a valid token, stores it in a cookie limited
+// to the UI.
+func handleWebLogin(w http.ResponseWriter, r *http.Request, grants []tokenGrant) {
+	page := newWebPage("Sign in")
+	if r.Method != http.MethodPost {
+		renderWeb(w, "login", page, http.StatusOK)
+		return
+	}
+	token := strings.TrimSpace(r.FormValue("token"))
+	if _, ok := authenticate("Bearer "+token, grants); !ok {
+		page.Error = "Invalid token."
+		renderWeb(w, "login", page, http.StatusUnauthorized)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     webTokenCookie,
+		Value:    token,
+		Path:     webEndpoint,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, webEndpoint, http.StatusSeeOther)
+}
@@ -4,15 +4,17 @@ go 1.24.3
 
 require (
 	github.com/blevesearch/bleve/v2 v2.5.2
+	github.com/blevesearch/bleve_index_api v1.2.8
 	github.com/mark3labs/mcp-go v0.35.0
 	github.com/sirupsen/logrus v1.9.3
+	go.etcd.io/bbolt v1.4.0
 	golang.org/x/net v0.42.0
+	golang.org/x/sys v0.34.0
 )
 
 require (
 	github.com/RoaringBitmap/roaring/v2 v2.4.5 // indirect
 	github.com/bits-and-blooms/bitset v1.22.0 // indirect
-	github.com/blevesearch/bleve_index_api v1.2.8 // indirect
 	github.com/blevesearch/geo v0.2.3 // indirect
 	github.com/blevesearch/go-faiss v1.0.25 // indirect
 	github.com/blevesearch/go-porterstemmer v1.0.3 // indirect
@@ -36,6 +38,4 @@ require (
 	github.com/mschoch/smat v0.2.0 // indirect
 	github.com/spf13/cast v1.7.1 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
-	go.etcd.io/bbolt v1.4.0 // indirect
-	golang.org/x/sys v0.34.0 // indirect
 )
@@ -0,0 +1,118 @@
+// Package atrest encrypts locally stored documentation with AES-256-GCM.
+package atrest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// KeySize is the length in bytes of an encryption key.
+const KeySize = 32
+
+// Keychain service and account names under which the key is looked up in the OS keychain.
+const (
+	KeychainService = "devdocsmcp"
+	KeychainAccount = "cache-key"
+)
+
+// magic prefixes every sealed file so encrypted and plain files can be told apart.
+var magic = []byte("DDMCPENC1\n")
+
+// ErrNotSealed is returned by Open for data that was not produced by Seal.
+var ErrNotSealed = errors.New("data is not encrypted")
+
+// Cipher seals and opens data with one key.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// New returns a Cipher for a KeySize-byte key.
+func New(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts plain with a fresh random nonce.
+func (c *Cipher) Seal(plain []byte) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(fmt.Sprintf("atrest: failed to generate nonce: %v", err))
+	}
+	out := make([]byte, 0, len(magic)+len(nonce)+len(plain)+c.aead.Overhead())
+	out = append(out, magic...)
+	out = append(out, nonce...)
+	return c.aead.Seal(out, nonce, plain, magic)
+}
+
+// Open decrypts data produced by Seal. It fails if the data was tampered with or sealed
+// with another key.
+func (c *Cipher) Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return nil, ErrNotSealed
+	}
+	data = data[len(magic):]
+	if len(data) < c.aead.NonceSize() {
+		return nil, errors.New("encrypted data is truncated")
+	}
+	nonce, sealed := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, magic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data (wrong key?): %w", err)
+	}
+	return plain, nil
+}
+
+// IsSealed reports whether data looks like the output of Seal.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// ParseKey decodes a base64 or hex encoded key.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := hex.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("encryption key must be %d bytes encoded as base64 or hex", KeySize)
+}
+
+// KeychainKey reads the key stored in the OS keychain: the login keychain on macOS
+// ('security') or the Secret Service on Linux ('secret-tool').
+func KeychainKey() ([]byte, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", KeychainService, "-a", KeychainAccount, "-w")
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", KeychainService, "account", KeychainAccount)
+	default:
+		return nil, fmt.Errorf("reading the encryption key from the keychain is not supported on %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key from the keychain (service %q, account %q): %w", KeychainService, KeychainAccount, err)
+	}
+	return ParseKey(string(out))
+}
@@ -0,0 +1,239 @@
+package cachestore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// openTimeout bounds how long an operation waits for another process using the database.
+const openTimeout = time.Second
+
+// Bolt stores the pages of each docset in a bucket of one bbolt database, keyed by page path.
+// A value is the time the page was stored, in Unix nanoseconds (8 bytes, big endian),
+// followed by the page.
+//
+// bbolt locks its file for as long as it is open, so Bolt opens the database for each
+// operation rather than holding it: reads share the file with each other, and a write waits
+// for the reads and writes of other processes sharing the cache directory. Opening takes tens
+// of microseconds, little next to fetching a page.
+type Bolt struct {
+	path string
+	// mu orders the operations of this process, whose opens would otherwise wait on each
+	// other's file locks.
+	mu sync.RWMutex
+}
+
+// OpenBolt returns the store of the database at path, creating the database if needed.
+func OpenBolt(path string) (*Bolt, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	b := &Bolt{path: path}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := b.update(func(tx *bolt.Tx) error { return nil }); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// open opens the database for one operation, read-only unless write is set.
+func (b *Bolt) open(write bool) (*bolt.DB, error) {
+	db, err := bolt.Open(b.path, 0600, &bolt.Options{Timeout: openTimeout, ReadOnly: !write})
+	if err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, fmt.Errorf("failed to open page cache %s: locked by another process", b.path)
+		}
+		return nil, fmt.Errorf("failed to open page cache %s: %w", b.path, err)
+	}
+	return db, nil
+}
+
+// view runs fn in a read-only transaction.
+func (b *Bolt) view(fn func(tx *bolt.Tx) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	db, err := b.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// update runs fn in a read-write transaction.
+func (b *Bolt) update(fn func(tx *bolt.Tx) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	db, err := b.open(true)
+	if err != nil {
+		return err
+	}
+	if err := db.Update(fn); err != nil {
+		db.Close()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close page cache %s: %w", b.path, err)
+	}
+	return nil
+}
+
+func encodeValue(stored time.Time, data []byte) []byte {
+	value := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(value, uint64(stored.UnixNano()))
+	copy(value[8:], data)
+	return value
+}
+
+func decodeValue(value []byte) (time.Time, []byte, error) {
+	if len(value) < 8 {
+		return time.Time{}, nil, errors.New("corrupt page cache entry")
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(value))), value[8:], nil
+}
+
+// get runs fn with the raw value of a page, valid only during fn.
+func (b *Bolt) get(lang, name string, fn func(value []byte) error) error {
+	if err := checkKey(lang, name); err != nil {
+		return err
+	}
+	return b.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(lang))
+		if bucket == nil {
+			return notExist(lang, name)
+		}
+		value := bucket.Get([]byte(name))
+		if value == nil {
+			return notExist(lang, name)
+		}
+		return fn(value)
+	})
+}
+
+func (b *Bolt) Get(lang, name string) ([]byte, time.Time, error) {
+	var data []byte
+	var stored time.Time
+	err := b.get(lang, name, func(value []byte) error {
+		t, page, err := decodeValue(value)
+		if err != nil {
+			return err
+		}
+		stored, data = t, append([]byte(nil), page...)
+		return nil
+	})
+	return data, stored, err
+}
+
+func (b *Bolt) Stat(lang, name string) (Info, error) {
+	var info Info
+	err := b.get(lang, name, func(value []byte) error {
+		stored, page, err := decodeValue(value)
+		if err != nil {
+			return err
+		}
+		info = Info{Name: name, Size: int64(len(page)), Stored: stored}
+		return nil
+	})
+	return info, err
+}
+
+func (b *Bolt) Put(lang, name string, data []byte) error {
+	if err := checkKey(lang, name); err != nil {
+		return err
+	}
+	return b.update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(lang))
+		if err != nil {
+			return fmt.Errorf("failed to store page %s/%s: %w", lang, name, err)
+		}
+		return bucket.Put([]byte(name), encodeValue(time.Now(), data))
+	})
+}
+
+func (b *Bolt) Touch(lang, name string) error {
+	if err := checkKey(lang, name); err != nil {
+		return err
+	}
+	return b.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(lang))
+		if bucket == nil {
+			return notExist(lang, name)
+		}
+		value := bucket.Get([]byte(name))
+		if value == nil {
+			return notExist(lang, name)
+		}
+		_, page, err := decodeValue(value)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(name), encodeValue(time.Now(), page))
+	})
+}
+
+func (b *Bolt) Delete(lang, name string) error {
+	if err := checkKey(lang, name); err != nil {
+		return err
+	}
+	return b.update(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(lang)); bucket != nil {
+			return bucket.Delete([]byte(name))
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) DeleteLang(lang string) error {
+	if err := checkKey(lang, "index"); err != nil {
+		return err
+	}
+	return b.update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket([]byte(lang)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return fmt.Errorf("failed to remove cached pages of %s: %w", lang, err)
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) Walk(lang string, fn func(Info) error) error {
+	if err := checkKey(lang, "index"); err != nil {
+		return err
+	}
+	return b.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(lang))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			stored, page, err := decodeValue(value)
+			if err != nil {
+				return nil
+			}
+			return fn(Info{Name: string(key), Size: int64(len(page)), Stored: stored})
+		})
+	})
+}
+
+func (b *Bolt) Langs() ([]string, error) {
+	var langs []string
+	err := b.view(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			langs = append(langs, string(name))
+			return nil
+		})
+	})
+	return langs, err
+}
+
+// Close releases nothing, as the database is only open during operations.
+func (b *Bolt) Close() error {
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package cachestore keeps the cached pages of documentation sets in a selectable backend:
+// plain files, one per page, or a single embedded bbolt database that is easier to back up
+// and move. Data is stored as given; encryption at rest is up to the caller.
+package cachestore
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Store holds cached pages keyed by docset slug and page path. Missing pages are reported
+// with an error matching fs.ErrNotExist. Implementations are safe for concurrent use.
+type Store interface {
+	// Get returns a page and when it was stored.
+	Get(lang, name string) ([]byte, time.Time, error)
+	// Stat describes a page without reading it.
+	Stat(lang, name string) (Info, error)
+	// Put stores a page, replacing it atomically.
+	Put(lang, name string, data []byte) error
+	// Touch marks a page as stored now, e.g. when upstream reports it unchanged.
+	Touch(lang, name string) error
+	// Delete removes a page; removing a missing page is not an error.
+	Delete(lang, name string) error
+	// DeleteLang removes every page of a docset.
+	DeleteLang(lang string) error
+	// Walk calls fn for every page of a docset.
+	Walk(lang string, fn func(Info) error) error
+	// Langs returns the docsets with pages, in no particular order.
+	Langs() ([]string, error)
+	Close() error
+}
+
+// Info describes a stored page.
+type Info struct {
+	Name   string
+	Size   int64
+	Stored time.Time
+}
+
+// checkKey rejects docset slugs and page paths that would escape their place in a store.
+func checkKey(lang, name string) error {
+	if lang == "" || lang == "." || lang == ".." || strings.ContainsAny(lang, `/\`) {
+		return fmt.Errorf("invalid docset slug %q", lang)
+	}
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("invalid page path %q", name)
+	}
+	return nil
+}
+
+// notExist returns the error for a missing page.
+func notExist(lang, name string) error {
+	return fmt.Errorf("page %s/%s is not cached: %w", lang, name, fs.ErrNotExist)
+}
+
+// Discard stores nothing: every page is missing. It stands in for a backend that can't be
+// opened, so the cache is bypassed rather than failing every read.
+type Discard struct{}
+
+func (Discard) Get(lang, name string) ([]byte, time.Time, error) {
+	return nil, time.Time{}, notExist(lang, name)
+}
+func (Discard) Stat(lang, name string) (Info, error)        { return Info{}, notExist(lang, name) }
+func (Discard) Put(lang, name string, data []byte) error    { return nil }
+func (Discard) Touch(lang, name string) error               { return notExist(lang, name) }
+func (Discard) Delete(lang, name string) error              { return nil }
+func (Discard) DeleteLang(lang string) error                { return nil }
+func (Discard) Walk(lang string, fn func(Info) error) error { return nil }
+func (Discard) Langs() ([]string, error)                    { return nil, nil }
+func (Discard) Close() error                                { return nil }
@@ -0,0 +1,158 @@
+package cachestore
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Files stores each page as a file, Root/<lang>/pages/<path>.html; the modification time of
+// the file is when the page was stored.
+type Files struct {
+	Root string
+}
+
+func (f *Files) file(lang, name string) (string, error) {
+	if err := checkKey(lang, name); err != nil {
+		return "", err
+	}
+	return filepath.Join(f.pagesDir(lang), filepath.Clean(filepath.FromSlash(name))+".html"), nil
+}
+
+func (f *Files) pagesDir(lang string) string {
+	return filepath.Join(f.Root, lang, "pages")
+}
+
+func (f *Files) Get(lang, name string) ([]byte, time.Time, error) {
+	file, err := f.file(lang, name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return data, info.ModTime(), nil
+}
+
+func (f *Files) Stat(lang, name string) (Info, error) {
+	file, err := f.file(lang, name)
+	if err != nil {
+		return Info{}, err
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Name: name, Size: info.Size(), Stored: info.ModTime()}, nil
+}
+
+func (f *Files) Put(lang, name string, data []byte) error {
+	file, err := f.file(lang, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func (f *Files) Touch(lang, name string) error {
+	file, err := f.file(lang, name)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return os.Chtimes(file, now, now)
+}
+
+func (f *Files) Delete(lang, name string) error {
+	file, err := f.file(lang, name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove cached page: %w", err)
+	}
+	return nil
+}
+
+func (f *Files) DeleteLang(lang string) error {
+	if err := checkKey(lang, "index"); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(f.pagesDir(lang)); err != nil {
+		return fmt.Errorf("failed to remove cached pages of %s: %w", lang, err)
+	}
+	return nil
+}
+
+func (f *Files) Walk(lang string, fn func(Info) error) error {
+	if err := checkKey(lang, "index"); err != nil {
+		return err
+	}
+	dir := f.pagesDir(lang)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(path, ".html") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		return fn(Info{Name: strings.TrimSuffix(filepath.ToSlash(rel), ".html"), Size: info.Size(), Stored: info.ModTime()})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list cached pages of %s: %w", lang, err)
+	}
+	return nil
+}
+
+func (f *Files) Langs() ([]string, error) {
+	dirs, err := os.ReadDir(f.Root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	var langs []string
+	for _, dir := range dirs {
+		if _, err := os.Stat(f.pagesDir(dir.Name())); err == nil {
+			langs = append(langs, dir.Name())
+		}
+	}
+	return langs, nil
+}
+
+func (f *Files) Close() error {
+	return nil
+}
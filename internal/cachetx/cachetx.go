@@ -0,0 +1,310 @@
+// Package cachetx commits multi-file changes to an on-disk cache atomically: files are written
+// to a staging directory and moved into place only once the whole change is ready, and a
+// journal recorded before the moves lets a change interrupted by a crash be completed on the
+// next start, so readers never see a docset half written or half removed. Each transaction
+// holds a lock on its staging directory while it runs, so processes sharing the cache only
+// recover the transactions of processes that are gone.
+package cachetx
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"devdocsmcp/internal/filelock"
+)
+
+// journalFile is written into a staging directory when its transaction commits, and lockFile
+// is locked by the process running the transaction.
+const (
+	journalFile = "journal.json"
+	lockFile    = ".lock"
+)
+
+// Tx is a change to the files of one directory. Writes are staged until Commit; nothing changes
+// in the directory before then, and Abort discards them.
+type Tx struct {
+	// Seal, if set, transforms the data of every write (e.g. encrypts it).
+	Seal func([]byte) []byte
+	// ApplyMeta, if set, applies the metadata of SetMeta once the files are in place. Commit
+	// calls it before releasing the journal, so if it fails or the process dies first, Recover
+	// replays it; it must therefore be idempotent.
+	ApplyMeta func(meta json.RawMessage) error
+
+	dir       string
+	staging   string
+	lock      *filelock.Lock
+	journal   journal
+	committed bool
+}
+
+// journal records what a committed transaction does, to complete it after a crash.
+type journal struct {
+	Dir string `json:"dir"`
+	// Writes are file names relative to Dir, staged under the same names.
+	Writes []string `json:"writes,omitempty"`
+	// Removes are files or directories relative to Dir, removed after the writes are in place.
+	Removes []string `json:"removes,omitempty"`
+	// Meta is opaque data the committer applies once the files are in place, such as a
+	// metadata store record. Recover hands it to its replay function.
+	Meta json.RawMessage `json:"meta,omitempty"`
+}
+
+// Begin starts a transaction changing dir, staging its files under stagingRoot, which must be
+// on the same file system as dir.
+func Begin(stagingRoot, dir string) (*Tx, error) {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, fmt.Errorf("failed to start cache transaction: %w", err)
+	}
+	staging := filepath.Join(stagingRoot, time.Now().UTC().Format("20060102T150405")+"-"+hex.EncodeToString(id[:]))
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	lock, err := filelock.TryLock(filepath.Join(staging, lockFile))
+	if err != nil {
+		os.RemoveAll(staging)
+		return nil, fmt.Errorf("failed to start cache transaction: %w", err)
+	}
+	return &Tx{dir: dir, staging: staging, lock: lock, journal: journal{Dir: dir}}, nil
+}
+
+// Write stages the file name (relative to the transaction's directory, with slashes) with data.
+func (tx *Tx) Write(name string, data []byte) error {
+	file, err := tx.path(tx.staging, name)
+	if err != nil {
+		return err
+	}
+	if tx.Seal != nil {
+		data = tx.Seal(data)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return fmt.Errorf("failed to stage %s: %w", name, err)
+	}
+	tx.journal.Writes = append(tx.journal.Writes, name)
+	return nil
+}
+
+// Link stages the file name as a hard link to source, an existing file outside the transaction
+// such as a content-addressed blob, so identical files share their storage. Where hard links
+// aren't supported, source is copied. Seal doesn't apply: source is stored as is.
+func (tx *Tx) Link(name, source string) error {
+	file, err := tx.path(tx.staging, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	if err := os.Link(source, file); err != nil {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return fmt.Errorf("failed to stage %s: %w", name, err)
+		}
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", name, err)
+		}
+	}
+	tx.journal.Writes = append(tx.journal.Writes, name)
+	return nil
+}
+
+// Remove schedules the removal of the file or directory name on commit.
+func (tx *Tx) Remove(name string) error {
+	if _, err := tx.path(tx.dir, name); err != nil {
+		return err
+	}
+	tx.journal.Removes = append(tx.journal.Removes, name)
+	return nil
+}
+
+// SetMeta attaches data Commit applies with ApplyMeta, and Recover replays after a crash.
+func (tx *Tx) SetMeta(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache transaction metadata: %w", err)
+	}
+	tx.journal.Meta = data
+	return nil
+}
+
+// Commit records the journal, then moves the staged files into place, performs the removals
+// and applies the metadata. Once the journal is recorded the transaction is durable: if the
+// process dies before Commit returns, or a step fails, Recover completes it.
+func (tx *Tx) Commit() error {
+	data, err := json.Marshal(tx.journal)
+	if err != nil {
+		tx.Abort()
+		return fmt.Errorf("failed to encode cache journal: %w", err)
+	}
+	if err := writeSynced(filepath.Join(tx.staging, journalFile), data); err != nil {
+		tx.Abort()
+		return err
+	}
+	tx.committed = true
+	if err := apply(tx.staging, tx.journal); err != nil {
+		return err
+	}
+	if len(tx.journal.Meta) > 0 && tx.ApplyMeta != nil {
+		if err := tx.ApplyMeta(tx.journal.Meta); err != nil {
+			return err
+		}
+	}
+	return release(tx.staging, tx.lock)
+}
+
+// Abort discards the staged files. It is a no-op once Commit has recorded the journal, so a
+// commit that fails halfway is left for Recover to complete.
+func (tx *Tx) Abort() {
+	if !tx.committed {
+		release(tx.staging, tx.lock)
+	}
+}
+
+// release unlocks a staging directory and removes it. The lock goes first, as some systems
+// can't remove a locked file.
+func release(staging string, lock *filelock.Lock) error {
+	lock.Unlock()
+	return os.RemoveAll(staging)
+}
+
+// path resolves name under root, rejecting names that escape it.
+func (tx *Tx) path(root, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) || clean == journalFile || clean == lockFile {
+		return "", fmt.Errorf("invalid cache file name %q", name)
+	}
+	return filepath.Join(root, clean), nil
+}
+
+// apply performs a journaled transaction, leaving its staging directory to be released. It is
+// idempotent, so an interrupted apply can be repeated: files already moved are no longer staged
+// and are skipped.
+func apply(staging string, j journal) error {
+	for _, name := range j.Writes {
+		from := filepath.Join(staging, filepath.FromSlash(name))
+		to := filepath.Join(j.Dir, filepath.FromSlash(name))
+		if _, err := os.Stat(from); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		if err := os.Rename(from, to); err != nil {
+			return fmt.Errorf("failed to move %s into place: %w", name, err)
+		}
+	}
+	for _, name := range j.Removes {
+		if err := os.RemoveAll(filepath.Join(j.Dir, filepath.FromSlash(name))); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Recover completes the transactions under stagingRoot that committed but whose process died
+// before they were applied, calling replay with the metadata of each before releasing its
+// journal, so a failed replay is tried again by the next Recover, and discards the staged
+// files of transactions that never committed. Transactions whose process still holds their
+// lock are left alone, and so are uncommitted staging directories younger than minAge, which
+// may belong to a process that can't lock files or is just starting a transaction. It returns
+// how many transactions were completed.
+func Recover(stagingRoot string, minAge time.Duration, replay func(meta json.RawMessage) error) (int, error) {
+	entries, err := os.ReadDir(stagingRoot)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read staging directory: %w", err)
+	}
+	completed := 0
+	var problems []string
+	for _, entry := range entries {
+		staging := filepath.Join(stagingRoot, entry.Name())
+		info, err := entry.Info()
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		_, err = os.Stat(filepath.Join(staging, lockFile))
+		if errors.Is(err, os.ErrNotExist) && time.Since(info.ModTime()) < minAge {
+			continue
+		}
+		lock, err := filelock.TryLock(filepath.Join(staging, lockFile))
+		if errors.Is(err, filelock.ErrLocked) {
+			continue
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", entry.Name(), err))
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(staging, journalFile))
+		if errors.Is(err, os.ErrNotExist) {
+			if time.Since(info.ModTime()) < minAge {
+				// Possibly locked a moment from now by a transaction just beginning
+				lock.Unlock()
+			} else {
+				release(staging, lock)
+			}
+			continue
+		}
+		var j journal
+		if err == nil {
+			if err = json.Unmarshal(data, &j); err != nil {
+				// A journal is renamed into place whole, so this one can't be completed
+				release(staging, lock)
+				continue
+			}
+			err = apply(staging, j)
+		}
+		if err == nil && len(j.Meta) > 0 && replay != nil {
+			err = replay(j.Meta)
+		}
+		if err == nil {
+			err = release(staging, lock)
+		} else {
+			lock.Unlock()
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", entry.Name(), err))
+			continue
+		}
+		completed++
+	}
+	if len(problems) > 0 {
+		return completed, fmt.Errorf("failed to recover cache transactions: %s", strings.Join(problems, "; "))
+	}
+	return completed, nil
+}
+
+// writeSynced atomically writes data to path and flushes it to disk.
+func writeSynced(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to write cache journal: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write cache journal: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write cache journal: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write cache journal: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write cache journal: %w", err)
+	}
+	return nil
+}
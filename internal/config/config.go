@@ -0,0 +1,262 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config holds the user settings read from the devdocsmcp config file.
+type Config struct {
+	// Bundles maps a framework name to the docsets it implies. Entries here override the
+	// built-in bundle with the same name; an empty list disables that bundle, so the name is
+	// used as a plain docset slug again.
+	Bundles map[string][]string `json:"bundles,omitempty"`
+	// Bridges are other documentation MCP servers whose tools are federated into this server.
+	Bridges []Bridge `json:"bridges,omitempty"`
+	// Langs are the docsets (or bundles) served when the server is started without -lang.
+	Langs []string `json:"langs,omitempty"`
+	// SourceTLS holds TLS settings for documentation sources, keyed by host name
+	// (optionally with ":port"), e.g. an internal mirror behind a private CA.
+	SourceTLS map[string]TLS `json:"source_tls,omitempty"`
+	// DocsBaseURLs are the documentation hosts, e.g. devdocs mirrors, tried in order with
+	// failover to the next when one is down. Empty means documents.devdocs.io.
+	DocsBaseURLs []string `json:"docs_base_urls,omitempty"`
+	// Tokens are extra bearer tokens for the HTTP transports, each limited to its own
+	// languages, so several teams can share one server.
+	Tokens []Token `json:"tokens,omitempty"`
+	// Namespaces are named scopes of a monorepo, e.g. "frontend" and "backend", so each
+	// subproject searches only the documentation of its own stack.
+	Namespaces map[string]Namespace `json:"namespaces,omitempty"`
+	// CacheBackend selects where downloaded pages are cached: "files" (the default) or
+	// "bbolt", a single database file that is easier to back up.
+	CacheBackend string `json:"cache_backend,omitempty"`
+	// IndexAnalyzers selects the analyzer of the page text in the full-text index of a
+	// downloaded docset, by slug: "code" (the default), "en" or "standard".
+	IndexAnalyzers map[string]string `json:"index_analyzers,omitempty"`
+
+	// ResolveMember maps a bundle member to the docset slug it currently stands for, e.g.
+	// "python" to "python~3.13", or returns "" to keep the member as written. Nil keeps every
+	// member as written.
+	ResolveMember func(member string) string `json:"-"`
+}
+
+// Namespace is the documentation one part of a monorepo uses.
+type Namespace struct {
+	Description string `json:"description,omitempty"`
+	// Langs are the docsets (or bundles) of the namespace, searched in this order.
+	Langs []string `json:"langs,omitempty"`
+	// Bridges name the bridges serving the namespace's own documentation sources.
+	Bridges []string `json:"bridges,omitempty"`
+}
+
+// Token is a bearer token that may only use some of the served languages.
+type Token struct {
+	// Name identifies the token's holder in logs.
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	// Langs are the docsets (or bundles) the token may use.
+	Langs []string `json:"langs"`
+}
+
+// TLS configures how connections to one source are secured.
+type TLS struct {
+	// CAFile is a PEM bundle of extra certificate authorities to trust.
+	CAFile string `json:"ca_file,omitempty"`
+	// CertFile and KeyFile are a PEM client certificate and key for mutual TLS.
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// InsecureSkipVerify disables certificate verification. For testing only.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// Bridge configures a connection to another MCP server. Either Command (a stdio server to
+// launch) or URL (a network server) must be set.
+type Bridge struct {
+	// Name namespaces the bridged tools: tool "search" becomes "<name>__search".
+	Name    string            `json:"name"`
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	// Transport selects "streamable-http" (the default) or "sse" for URL bridges.
+	Transport string            `json:"transport,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	// Tools optionally restricts which of the server's tools are federated.
+	Tools []string `json:"tools,omitempty"`
+	// TLS secures the connection to a URL bridge.
+	TLS *TLS `json:"tls,omitempty"`
+}
+
+// defaultBundles are the framework bundles known without any configuration. Members name
+// docset families rather than versions, so a bundle never points at a retired release.
+var defaultBundles = map[string][]string{
+	"rails":   {"ruby", "rails", "postgresql"},
+	"django":  {"python", "django", "postgresql"},
+	"nextjs":  {"react", "node", "javascript"},
+	"laravel": {"php", "laravel", "mysql"},
+}
+
+// DefaultPath returns the location of the config file, honouring DEVDOCSMCP_CONFIG.
+func DefaultPath() (string, error) {
+	if path := os.Getenv("DEVDOCSMCP_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config directory: %w", err)
+	}
+	return filepath.Join(dir, "devdocsmcp", "config.json"), nil
+}
+
+// Load reads the config file at path. A missing file yields the default configuration.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	bundles := make(map[string][]string, len(defaultBundles)+len(cfg.Bundles))
+	for name, slugs := range defaultBundles {
+		bundles[name] = slugs
+	}
+	for name, slugs := range cfg.Bundles {
+		if len(slugs) == 0 {
+			delete(bundles, name)
+			continue
+		}
+		bundles[name] = slugs
+	}
+	cfg.Bundles = bundles
+
+	names := make(map[string]bool, len(cfg.Bridges))
+	for _, bridge := range cfg.Bridges {
+		if bridge.Name == "" {
+			return nil, fmt.Errorf("invalid config file %s: bridge without a name", path)
+		}
+		if names[bridge.Name] {
+			return nil, fmt.Errorf("invalid config file %s: duplicate bridge %q", path, bridge.Name)
+		}
+		names[bridge.Name] = true
+		if (bridge.Command == "") == (bridge.URL == "") {
+			return nil, fmt.Errorf("invalid config file %s: bridge %q needs exactly one of command or url", path, bridge.Name)
+		}
+		if bridge.TLS != nil && bridge.URL == "" {
+			return nil, fmt.Errorf("invalid config file %s: bridge %q sets tls but has no url", path, bridge.Name)
+		}
+	}
+
+	tokens := make(map[string]bool, len(cfg.Tokens))
+	for _, token := range cfg.Tokens {
+		if token.Name == "" {
+			return nil, fmt.Errorf("invalid config file %s: token without a name", path)
+		}
+		if token.Token == "" {
+			return nil, fmt.Errorf("invalid config file %s: token %q is empty", path, token.Name)
+		}
+		if tokens[token.Token] {
+			return nil, fmt.Errorf("invalid config file %s: token %q is listed twice", path, token.Name)
+		}
+		tokens[token.Token] = true
+		if len(token.Langs) == 0 {
+			return nil, fmt.Errorf("invalid config file %s: token %q has no langs", path, token.Name)
+		}
+	}
+
+	for name, namespace := range cfg.Namespaces {
+		if name == "" {
+			return nil, fmt.Errorf("invalid config file %s: namespace without a name", path)
+		}
+		if len(namespace.Langs) == 0 && len(namespace.Bridges) == 0 {
+			return nil, fmt.Errorf("invalid config file %s: namespace %q has neither langs nor bridges", path, name)
+		}
+		for _, bridge := range namespace.Bridges {
+			if !names[bridge] {
+				return nil, fmt.Errorf("invalid config file %s: namespace %q lists unknown bridge %q", path, name, bridge)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// ExpandBundles replaces every bundle name in slugs with the docsets it implies, recursively,
+// keeping the first occurrence of each docset. Names that aren't bundles, including bundles
+// without members, are kept as-is; bundle members that aren't bundles themselves go through
+// ResolveMember.
+func (c *Config) ExpandBundles(slugs []string) []string {
+	var expanded []string
+	seen := make(map[string]bool)
+	expanding := make(map[string]bool)
+
+	var expand func(name string, member bool)
+	expand = func(name string, member bool) {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return
+		}
+		members := c.Bundles[name]
+		if len(members) == 0 || expanding[name] {
+			if member && c.ResolveMember != nil {
+				if resolved := c.ResolveMember(name); resolved != "" {
+					name = resolved
+				}
+			}
+			if !seen[name] {
+				seen[name] = true
+				expanded = append(expanded, name)
+			}
+			return
+		}
+		expanding[name] = true
+		for _, m := range members {
+			expand(m, true)
+		}
+		expanding[name] = false
+	}
+
+	for _, slug := range slugs {
+		expand(slug, false)
+	}
+	return expanded
+}
+
+// SaveLangs sets the langs of the config file at path, creating the file if needed. Every
+// other setting in the file is preserved as written.
+func SaveLangs(path string, langs []string) error {
+	settings := map[string]json.RawMessage{}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	encoded, err := json.Marshal(langs)
+	if err != nil {
+		return fmt.Errorf("failed to encode langs: %w", err)
+	}
+	settings["langs"] = encoded
+	data, err = json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package classify
+
+import (
+	"strings"
+
+	"devdocsmcp/internal/docs/match"
+)
+
+// Kinds of documentation content.
+const (
+	// Reference is API reference material: functions, methods, properties, elements...
+	Reference = "reference"
+	// Guide is explanatory material: tutorials, guides, introductions, how-tos...
+	Guide = "guide"
+)
+
+// guideWords are words that mark guide or tutorial content when found in an entry's type,
+// name or path.
+var guideWords = map[string]bool{
+	"guide": true, "guides": true, "tutorial": true, "tutorials": true,
+	"introduction": true, "intro": true, "overview": true, "learn": true,
+	"learning": true, "concepts": true, "howto": true, "basics": true,
+	"handbook": true, "cookbook": true, "faq": true, "walkthrough": true,
+	"quickstart": true, "primer": true, "recipes": true, "topics": true,
+}
+
+// Kind classifies an entry as Reference or Guide using heuristics on its type, name and path.
+// Entry types carry the strongest signal (devdocs files tutorials under types such as
+// "Guides" or "Getting Started"), so they are checked first.
+func Kind(name, path, entryType string) string {
+	if hasGuideWord(entryType) {
+		return Guide
+	}
+	// "how to" and "getting started" spelt as separate words
+	lowerName := strings.ToLower(name)
+	if strings.HasPrefix(lowerName, "how to") || strings.Contains(lowerName, "getting started") {
+		return Guide
+	}
+	if hasGuideWord(name) || hasGuideWord(path) {
+		return Guide
+	}
+	return Reference
+}
+
+// Valid reports whether k names a known kind.
+func Valid(k string) bool {
+	return k == Reference || k == Guide
+}
+
+func hasGuideWord(s string) bool {
+	for _, token := range match.Tokens(s) {
+		if guideWords[token] {
+			return true
+		}
+	}
+	return false
+}
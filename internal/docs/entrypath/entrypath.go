@@ -0,0 +1,163 @@
+// Package entrypath maps entry paths as agents write them to the page paths of a devdocs
+// docset, fixing the quirks of the scraper type (docs.json's "type") that produced it.
+package entrypath
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Clean normalizes the syntax of an entry path: it drops a leading slash, a fragment or query,
+// and an ".html" extension, and maps a directory ("fmt/") to its index page.
+func Clean(entryPath string) string {
+	p := strings.TrimSpace(entryPath)
+	if i := strings.IndexAny(p, "#?"); i >= 0 {
+		p = p[:i]
+	}
+	p = strings.TrimPrefix(p, "/")
+	p = strings.TrimSuffix(p, ".html")
+	if p == "" || strings.HasSuffix(p, "/") {
+		p += "index"
+	}
+	return p
+}
+
+// quirks returns the page paths a cleaned entry path may stand for in docsets of a scraper
+// type, besides the path itself.
+var quirks = map[string]func(p string) []string{
+	// Classes are written as qualified names ("java.util.Map.Entry"), pages are
+	// "java.base/java/util/map.entry"; the module prefix is found by Pages.Resolve
+	"openjdk": func(p string) []string {
+		if strings.Contains(p, "/") {
+			return []string{strings.ToLower(p)}
+		}
+		parts := strings.Split(p, ".")
+		pkg := 0
+		for pkg < len(parts)-1 && !startsUpper(parts[pkg]) {
+			pkg++
+		}
+		return []string{strings.ToLower(strings.Join(parts[:pkg], "/") + "/" + strings.Join(parts[pkg:], "."))}
+	},
+	// Man pages are written "ls(1)" or "ls.1", pages are "man1/ls.1"
+	"man": func(p string) []string {
+		m := manPage.FindStringSubmatch(p)
+		if m == nil {
+			return nil
+		}
+		name, section := m[1], m[2]+m[3]
+		return []string{"man" + section + "/" + name + "." + section, name + "." + section}
+	},
+	// Ruby classes and modules are written "File::Stat", pages are "file/stat"
+	"rdoc": rubyConstant,
+	"yard": rubyConstant,
+	// nginx modules are written without their section ("ngx_http_core_module"); the section
+	// ("http/") is found by Pages.Resolve
+	"nginx": func(p string) []string {
+		return []string{strings.ToLower(p)}
+	},
+}
+
+var manPage = regexp.MustCompile(`^(?:man\w*/)?([^/()]+?)(?:\((\w+)\)|\.(\d\w*))$`)
+
+func rubyConstant(p string) []string {
+	if !strings.Contains(p, "::") {
+		return nil
+	}
+	return []string{strings.ToLower(strings.ReplaceAll(p, "::", "/"))}
+}
+
+func startsUpper(s string) bool {
+	for _, r := range s {
+		return unicode.IsUpper(r)
+	}
+	return false
+}
+
+// Candidates returns the page paths entryPath may stand for in a docset of the given scraper
+// type, most likely first: the cleaned path, the quirks of the type, and the same with or
+// without a trailing "index" page.
+func Candidates(docType, entryPath string) []string {
+	p := Clean(entryPath)
+	candidates := []string{p}
+	if quirk, ok := quirks[docType]; ok {
+		candidates = append(candidates, quirk(p)...)
+	}
+	seen := make(map[string]bool)
+	var all []string
+	for _, c := range candidates {
+		for _, variant := range []string{c, indexVariant(c)} {
+			if variant != "" && !seen[variant] {
+				seen[variant] = true
+				all = append(all, variant)
+			}
+		}
+	}
+	return all
+}
+
+// indexVariant returns "fmt/index" for "fmt" and the reverse.
+func indexVariant(p string) string {
+	if path.Base(p) == "index" {
+		if dir := path.Dir(p); dir != "." {
+			return dir
+		}
+		return ""
+	}
+	return p + "/index"
+}
+
+// Pages is the set of page paths of a docset.
+type Pages struct {
+	exact  map[string]bool
+	folded map[string]string
+}
+
+// NewPages returns the set of the given page paths.
+func NewPages(paths []string) *Pages {
+	pages := &Pages{exact: make(map[string]bool, len(paths)), folded: make(map[string]string, len(paths))}
+	for _, p := range paths {
+		pages.exact[p] = true
+		if _, ok := pages.folded[strings.ToLower(p)]; !ok {
+			pages.folded[strings.ToLower(p)] = p
+		}
+	}
+	return pages
+}
+
+// Resolve returns the page entryPath stands for in a docset of the given scraper type: the
+// first candidate that is a page, compared exactly, then ignoring case, then as the only page
+// ending with it (a missing module or section prefix). It reports false, with the cleaned path,
+// when none is.
+func (pages *Pages) Resolve(docType, entryPath string) (string, bool) {
+	candidates := Candidates(docType, entryPath)
+	for _, c := range candidates {
+		if pages.exact[c] {
+			return c, true
+		}
+	}
+	for _, c := range candidates {
+		if p, ok := pages.folded[strings.ToLower(c)]; ok {
+			return p, true
+		}
+	}
+	for _, c := range candidates {
+		suffix := "/" + strings.ToLower(c)
+		match := ""
+		for folded, p := range pages.folded {
+			if !strings.HasSuffix(folded, suffix) {
+				continue
+			}
+			if match != "" {
+				match = ""
+				break
+			}
+			match = p
+		}
+		if match != "" {
+			return match, true
+		}
+	}
+	return candidates[0], false
+}
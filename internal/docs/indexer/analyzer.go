@@ -0,0 +1,258 @@
+package indexer
+
+import (
+	"fmt"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/token/porter"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/v2/registry"
+)
+
+// Analyzers of the page text, chosen per index with Indexer.Analyzer.
+const (
+	// AnalyzerCode keeps identifiers such as strconv.ParseInt, :nth-child() or
+	// angularjs~1.8 whole, and also indexes their parts (strconv, parseint, parse, int), so
+	// symbols are found whole or by any part. Plain English words are stemmed. The default.
+	AnalyzerCode = "code"
+	// AnalyzerEnglish is bleve's English analyzer: words split at punctuation and stemmed.
+	AnalyzerEnglish = "en"
+	// AnalyzerStandard splits words at punctuation without stemming, e.g. for docs that
+	// aren't in English.
+	AnalyzerStandard = "standard"
+)
+
+// Names of the components of AnalyzerCode in the bleve registry.
+const (
+	codeTokenizerName = "devdocs_code"
+	codePartsName     = "devdocs_code_parts"
+	stemWordsName     = "devdocs_stem_words"
+	// codeWordsName is AnalyzerCode without stop words and stemming, for the Words field.
+	codeWordsName = "devdocs_code_words"
+)
+
+// lowerKeywordName is the analyzer of the Type field: the whole value, lower-cased, so type:
+// queries ignore case.
+const lowerKeywordName = "devdocs_lower_keyword"
+
+// CheckAnalyzer reports an unknown analyzer name.
+func CheckAnalyzer(name string) error {
+	switch name {
+	case AnalyzerCode, AnalyzerEnglish, AnalyzerStandard:
+		return nil
+	}
+	return fmt.Errorf("unknown analyzer %q (expected %s, %s or %s)", name, AnalyzerCode, AnalyzerEnglish, AnalyzerStandard)
+}
+
+// codeToken matches an identifier: words joined by . - ~ # / or colons, optionally led by
+// colons (::before, :hover) and followed by () (:nth-child()).
+var codeToken = regexp.MustCompile(`(?:::?)?[\p{L}\p{N}_$]+(?:(?:[.\-~#/]|::?)[\p{L}\p{N}_$]+)*(?:\(\))?`)
+
+// codeTokenizer splits text into identifiers and words.
+type codeTokenizer struct{}
+
+func (codeTokenizer) Tokenize(input []byte) analysis.TokenStream {
+	matches := codeToken.FindAllIndex(input, -1)
+	stream := make(analysis.TokenStream, 0, len(matches))
+	for i, match := range matches {
+		stream = append(stream, &analysis.Token{
+			Term:     input[match[0]:match[1]],
+			Start:    match[0],
+			End:      match[1],
+			Position: i + 1,
+			Type:     analysis.AlphaNumeric,
+		})
+	}
+	return stream
+}
+
+// codeParts follows each identifier with its parts at the same position: the identifier
+// without leading colons and trailing (), the words between its separators, and the humps
+// of camelCase words.
+type codeParts struct{}
+
+func (codeParts) Filter(input analysis.TokenStream) analysis.TokenStream {
+	output := make(analysis.TokenStream, 0, len(input))
+	for _, token := range input {
+		output = append(output, token)
+		seen := map[string]bool{string(token.Term): true}
+		add := func(start, end int) {
+			term := token.Term[start:end]
+			if len(term) == 0 || seen[string(term)] {
+				return
+			}
+			seen[string(term)] = true
+			// Later filters change terms in place, so parts don't share the identifier's bytes
+			output = append(output, &analysis.Token{
+				Term:     append([]byte(nil), term...),
+				Start:    token.Start + start,
+				End:      token.Start + end,
+				Position: token.Position,
+				Type:     token.Type,
+			})
+		}
+
+		start, end := 0, len(token.Term)
+		for start < end && token.Term[start] == ':' {
+			start++
+		}
+		if end-start > 2 && token.Term[end-2] == '(' && token.Term[end-1] == ')' {
+			end -= 2
+		}
+		add(start, end)
+		for _, word := range splitWords(token.Term, start, end) {
+			add(word[0], word[1])
+			humps := splitHumps(token.Term, word[0], word[1])
+			if len(humps) > 1 {
+				for _, hump := range humps {
+					add(hump[0], hump[1])
+				}
+			}
+		}
+	}
+	return output
+}
+
+// splitWords returns the byte ranges of the words of term[start:end] between separators.
+func splitWords(term []byte, start, end int) [][2]int {
+	var words [][2]int
+	wordStart := start
+	for i := start; i < end; {
+		r, size := utf8.DecodeRune(term[i:])
+		if isWordRune(r) {
+			i += size
+			continue
+		}
+		if i > wordStart {
+			words = append(words, [2]int{wordStart, i})
+		}
+		i += size
+		wordStart = i
+	}
+	if end > wordStart {
+		words = append(words, [2]int{wordStart, end})
+	}
+	return words
+}
+
+// splitHumps returns the byte ranges of the humps of a camelCase word: ParseInt gives Parse
+// and Int, HTTPServer gives HTTP and Server, utf8Decode gives utf8 and Decode.
+func splitHumps(term []byte, start, end int) [][2]int {
+	var humps [][2]int
+	humpStart := start
+	var prev rune
+	for i := start; i < end; {
+		r, size := utf8.DecodeRune(term[i:])
+		next, _ := utf8.DecodeRune(term[i+size : end])
+		split := i > humpStart && unicode.IsUpper(r) &&
+			(!unicode.IsUpper(prev) || (i+size < end && unicode.IsLower(next)))
+		if r == '_' || r == '$' {
+			if i > humpStart {
+				humps = append(humps, [2]int{humpStart, i})
+			}
+			humpStart = i + size
+		} else if split {
+			humps = append(humps, [2]int{humpStart, i})
+			humpStart = i
+		}
+		prev = r
+		i += size
+	}
+	if end > humpStart {
+		humps = append(humps, [2]int{humpStart, end})
+	}
+	return humps
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_' || r == '$'
+}
+
+// stemWords stems the plain words of a stream, leaving identifiers with separators, digits
+// or underscores as they are.
+type stemWords struct {
+	stemmer analysis.TokenFilter
+}
+
+func (f stemWords) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		if isPlainWord(token.Term) {
+			f.stemmer.Filter(analysis.TokenStream{token})
+		}
+	}
+	return input
+}
+
+func isPlainWord(term []byte) bool {
+	for _, r := range string(term) {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return len(term) > 0
+}
+
+func codeAnalyzerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Analyzer, error) {
+	return codeAnalyzer(cache, codePartsName, lowercase.Name, en.StopName, stemWordsName)
+}
+
+func codeWordsAnalyzerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Analyzer, error) {
+	return codeAnalyzer(cache, codePartsName, lowercase.Name)
+}
+
+// codeAnalyzer builds an analyzer splitting text with the code tokenizer, then running the
+// named token filters.
+func codeAnalyzer(cache *registry.Cache, filterNames ...string) (analysis.Analyzer, error) {
+	tokenizer, err := cache.TokenizerNamed(codeTokenizerName)
+	if err != nil {
+		return nil, err
+	}
+	filters := make([]analysis.TokenFilter, 0, len(filterNames))
+	for _, name := range filterNames {
+		filter, err := cache.TokenFilterNamed(name)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return &analysis.DefaultAnalyzer{Tokenizer: tokenizer, TokenFilters: filters}, nil
+}
+
+func init() {
+	register := func(err error) {
+		if err != nil {
+			panic(err)
+		}
+	}
+	register(registry.RegisterTokenizer(codeTokenizerName, func(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
+		return codeTokenizer{}, nil
+	}))
+	register(registry.RegisterTokenFilter(codePartsName, func(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+		return codeParts{}, nil
+	}))
+	register(registry.RegisterTokenFilter(stemWordsName, func(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+		stemmer, err := cache.TokenFilterNamed(porter.Name)
+		if err != nil {
+			return nil, err
+		}
+		return stemWords{stemmer: stemmer}, nil
+	}))
+	register(registry.RegisterAnalyzer(AnalyzerCode, codeAnalyzerConstructor))
+	register(registry.RegisterAnalyzer(codeWordsName, codeWordsAnalyzerConstructor))
+	register(registry.RegisterAnalyzer(lowerKeywordName, func(config map[string]interface{}, cache *registry.Cache) (analysis.Analyzer, error) {
+		tokenizer, err := cache.TokenizerNamed(single.Name)
+		if err != nil {
+			return nil, err
+		}
+		filter, err := cache.TokenFilterNamed(lowercase.Name)
+		if err != nil {
+			return nil, err
+		}
+		return &analysis.DefaultAnalyzer{Tokenizer: tokenizer, TokenFilters: []analysis.TokenFilter{filter}}, nil
+	}))
+}
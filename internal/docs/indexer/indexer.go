@@ -1,37 +1,220 @@
 package indexer
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"devdocsmcp/internal/docs/classify"
+	"devdocsmcp/internal/docs/searchquery"
 
 	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search"
+	bleveQuery "github.com/blevesearch/bleve/v2/search/query"
+	bleveIndex "github.com/blevesearch/bleve_index_api"
 )
 
+// currentFile names the file in the index root that points at the live index generation.
+const currentFile = "CURRENT"
+
 // Indexer stores an inverted index for searching using Bleve.
+//
+// The index lives in generation directories under the index root. Searches always go through
+// a bleve alias, so a reindex builds a complete new generation in the background and then swaps
+// it in atomically: concurrent queries see either the old or the new snapshot, never a
+// half-built one.
 type Indexer struct {
-	index bleve.Index
+	// Analyzer is the analyzer of the page text (see CheckAnalyzer) in the generations built
+	// by Reindex; empty means AnalyzerCode. Existing generations keep the analyzer they were
+	// built with.
+	Analyzer string
+	// BatchSize is how many documents Reindex, Update and AddDocuments send to bleve in one
+	// batch; 0 means DefaultBatchSize.
+	BatchSize int
+
+	root  string
+	alias bleve.IndexAlias
+
+	mu         sync.RWMutex // guards index and generation; held exclusively while swapping
+	index      bleve.Index
+	generation string
+
+	reindexMu sync.Mutex // serialises reindexing
+
+	tombMu sync.Mutex
+	// tombstones are the paths deleted while a reindex is in progress, removed from the new
+	// generation before it is swapped in; nil when no reindex is in progress.
+	tombstones map[string]bool
 }
 
 // NewIndexer creates a new Indexer instance.
 func NewIndexer(indexPath string) (*Indexer, error) {
+	if err := os.MkdirAll(indexPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	generation, err := readCurrent(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	if generation == "" {
+		generation = newGeneration()
+	}
+
+	index, err := openOrCreate(filepath.Join(indexPath, generation))
+	if err != nil {
+		return nil, err
+	}
+	if err := writeCurrent(indexPath, generation); err != nil {
+		index.Close()
+		return nil, err
+	}
+
+	fmt.Printf("Bleve index opened/created at %s\n", indexPath)
+	return &Indexer{
+		root:       indexPath,
+		alias:      bleve.NewIndexAlias(index),
+		index:      index,
+		generation: generation,
+	}, nil
+}
+
+// OpenReadOnly opens the live generation of the index at indexPath for searching only, waiting
+// at most timeout while another process is writing to it. Only the Search methods and Close
+// may be used on the result.
+func OpenReadOnly(indexPath string, timeout time.Duration) (*Indexer, error) {
+	generation, err := readCurrent(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	if generation == "" {
+		return nil, fmt.Errorf("no index in %s", indexPath)
+	}
+	index, err := openReadOnlyGeneration(indexPath, generation, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Indexer{
+		root:       indexPath,
+		alias:      bleve.NewIndexAlias(index),
+		index:      index,
+		generation: generation,
+	}, nil
+}
+
+// openReadOnlyGeneration opens a generation of the index at indexPath for searching only,
+// waiting at most timeout while another process is writing to it.
+func openReadOnlyGeneration(indexPath, generation string, timeout time.Duration) (bleve.Index, error) {
+	index, err := bleve.OpenUsing(filepath.Join(indexPath, generation), map[string]interface{}{
+		"read_only":    true,
+		"bolt_timeout": timeout.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index: %w", err)
+	}
+	return index, nil
+}
+
+// refresh moves an index opened with OpenReadOnly to the live generation when a reindex in
+// another process swapped a new one in since, the way Reindex swaps generations in. It leaves
+// the index alone when no generation is recorded any more.
+func (i *Indexer) refresh(timeout time.Duration) error {
+	generation, err := readCurrent(i.root)
+	if err != nil {
+		return err
+	}
+	i.mu.RLock()
+	current := i.generation
+	i.mu.RUnlock()
+	if generation == "" || generation == current {
+		return nil
+	}
+	next, err := openReadOnlyGeneration(i.root, generation, timeout)
+	if err != nil {
+		return err
+	}
+	i.mu.Lock()
+	previous := i.index
+	i.alias.Swap([]bleve.Index{next}, []bleve.Index{previous})
+	i.index, i.generation = next, generation
+	i.mu.Unlock()
+	if err := previous.Close(); err != nil {
+		return fmt.Errorf("failed to close previous index generation %s: %w", current, err)
+	}
+	return nil
+}
+
+// newIndexMapping builds the mapping of an index generation whose page text is analyzed by
+// analyzer.
+func newIndexMapping(analyzer string) mapping.IndexMapping {
 	// Create a new mapping
 	indexMapping := bleve.NewIndexMapping()
 
 	// Create a document mapping for the default type
 	docMapping := bleve.NewDocumentMapping()
 
-	// Add a text field mapping for content with English analyzer
+	// Add a text field mapping for content with the chosen analyzer
 	textFieldMapping := bleve.NewTextFieldMapping()
-	textFieldMapping.Analyzer = "en"
+	textFieldMapping.Analyzer = analyzer
 	docMapping.AddFieldMappingsAt("Content", textFieldMapping)
 
-	indexMapping.AddDocumentMapping("", docMapping) // Add to default type
+	// Headings are the text of the page's headings, analyzed like the text they head
+	headingsFieldMapping := bleve.NewTextFieldMapping()
+	headingsFieldMapping.Analyzer = analyzer
+	docMapping.AddFieldMappingsAt("Headings", headingsFieldMapping)
+
+	// Titles are names such as strconv.ParseInt, whatever the language of the text
+	titleFieldMapping := bleve.NewTextFieldMapping()
+	titleFieldMapping.Analyzer = AnalyzerCode
+	docMapping.AddFieldMappingsAt("Title", titleFieldMapping)
+
+	// Words are the words of the title and headings as written, lower-cased but not stemmed,
+	// so spelling suggestions (see SuggestTerms) are words a search can match
+	wordsFieldMapping := bleve.NewTextFieldMapping()
+	wordsFieldMapping.Analyzer = codeWordsName
+	wordsFieldMapping.Store = false
+	wordsFieldMapping.IncludeInAll = false
+	wordsFieldMapping.IncludeTermVectors = false
+	docMapping.AddFieldMappingsAt("Words", wordsFieldMapping)
+
+	// Type is the devdocs entry type (e.g. Method), matched whole regardless of case
+	typeFieldMapping := bleve.NewKeywordFieldMapping()
+	typeFieldMapping.Analyzer = lowerKeywordName
+	docMapping.AddFieldMappingsAt("Type", typeFieldMapping)
 
+	// Kind is matched exactly so searches can filter on reference or guide pages
+	kindFieldMapping := bleve.NewKeywordFieldMapping()
+	docMapping.AddFieldMappingsAt("Kind", kindFieldMapping)
+
+	// Path is kept whole so searches can be scoped to a path prefix
+	pathFieldMapping := bleve.NewKeywordFieldMapping()
+	docMapping.AddFieldMappingsAt("Path", pathFieldMapping)
+
+	// Lang and Version identify the documentation set of a page, matched exactly
+	docMapping.AddFieldMappingsAt("Lang", bleve.NewKeywordFieldMapping())
+	docMapping.AddFieldMappingsAt("Version", bleve.NewKeywordFieldMapping())
+
+	// Documents carry no type, so they are all mapped by the default mapping
+	indexMapping.DefaultMapping = docMapping
+	// BM25 normalizes term frequencies by field length, so a word on a short page weighs more
+	// than the same word once in a long one
+	indexMapping.ScoringModel = bleveIndex.BM25Scoring
+	return indexMapping
+}
+
+// openOrCreate opens the bleve index at path, creating it if it doesn't exist.
+func openOrCreate(path string) (bleve.Index, error) {
 	// Create a new index
-	index, err := bleve.New(indexPath, indexMapping)
+	index, err := bleve.New(path, newIndexMapping(AnalyzerCode))
 	if err != nil {
 		if err == bleve.ErrorIndexPathExists {
 			// If index already exists, open it
-			index, err = bleve.Open(indexPath)
+			index, err = bleve.Open(path)
 			if err != nil {
 				return nil, fmt.Errorf("failed to open existing index: %w", err)
 			}
@@ -39,63 +222,373 @@ func NewIndexer(indexPath string) (*Indexer, error) {
 			return nil, fmt.Errorf("failed to create new index: %w", err)
 		}
 	}
+	return index, nil
+}
 
-	fmt.Printf("Bleve index opened/created at %s\n", indexPath)
-	return &Indexer{
-		index: index,
-	}, nil
+// Document is a page to index. Path identifies it within its documentation set: it is the
+// devdocs page path (e.g. net/http/index), not a file, so together with Lang it is the
+// {lang, path} the API uses. Title and Type describe the page, e.g. from its devdocs entry,
+// and Version is the version of its documentation set; all three may be empty. Headings is
+// the text of the page's headings, one per line, and Content its whole text.
+//
+// ID is the key of the document in the index, and defaults to Path. An index holding one
+// documentation set (see Shards) leaves it empty, as paths are unique there; an index shared
+// by several documentation sets or versions sets it, e.g. to lang@version/path, so their pages
+// don't replace each other. Hits report the Path either way.
+type Document struct {
+	ID       string
+	Path     string
+	Lang     string
+	Version  string
+	Title    string
+	Type     string
+	Headings string
+	Content  string
 }
 
 // AddDocument adds a document's content to the index.
 func (i *Indexer) AddDocument(filePath, content string) error {
-	data := struct {
-		Path    string
-		Content string
+	return i.IndexDocument(Document{Path: filePath, Content: content})
+}
+
+// IndexDocument adds a document to the index, replacing the previous version of its path.
+func (i *Indexer) IndexDocument(doc Document) error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return addDocument(i.index, doc)
+}
+
+func addDocument(index bleve.Index, doc Document) error {
+	err := index.Index(doc.key(), documentData(doc))
+	if err != nil {
+		return fmt.Errorf("failed to index document %s: %w", doc.key(), err)
+	}
+	return nil
+}
+
+// key returns the ID of the document in the index.
+func (doc Document) key() string {
+	if doc.ID != "" {
+		return doc.ID
+	}
+	return doc.Path
+}
+
+// documentData is what the index stores of a document.
+func documentData(doc Document) any {
+	return struct {
+		Path     string
+		Lang     string
+		Version  string
+		Title    string
+		Type     string
+		Headings string
+		Content  string
+		Words    string
+		Kind     string
 	}{
-		Path:    filePath,
-		Content: content,
+		Path:     doc.Path,
+		Lang:     doc.Lang,
+		Version:  doc.Version,
+		Title:    doc.Title,
+		Type:     doc.Type,
+		Headings: doc.Headings,
+		Content:  doc.Content,
+		Words:    doc.Title + "\n" + doc.Headings,
+		Kind:     classify.Kind(doc.Title, doc.Path, doc.Type),
 	}
+}
+
+// Reindex builds a fresh index generation by calling build with a function that adds documents
+// to it, then atomically swaps the new generation in and deletes the old one. Searches keep
+// using the old generation until the swap. Documents added with AddDocument while a reindex is
+// in progress go to the old generation and are discarded by the swap; documents deleted
+// meanwhile are remembered as tombstones and deleted from the new generation too.
+// If build returns an error, the new generation is discarded and the old one stays live.
+func (i *Indexer) Reindex(build func(add func(doc Document) error) error) error {
+	i.reindexMu.Lock()
+	defer i.reindexMu.Unlock()
 
-	err := i.index.Index(filePath, data)
+	analyzer := i.Analyzer
+	if analyzer == "" {
+		analyzer = AnalyzerCode
+	}
+	if err := CheckAnalyzer(analyzer); err != nil {
+		return err
+	}
+	generation := newGeneration()
+	path := filepath.Join(i.root, generation)
+	next, err := bleve.New(path, newIndexMapping(analyzer))
 	if err != nil {
-		return fmt.Errorf("failed to index document %s: %w", filePath, err)
+		return fmt.Errorf("failed to create index generation %s: %w", generation, err)
+	}
+	i.tombMu.Lock()
+	i.tombstones = make(map[string]bool)
+	i.tombMu.Unlock()
+	defer func() {
+		i.tombMu.Lock()
+		i.tombstones = nil
+		i.tombMu.Unlock()
+	}()
+
+	batch := next.NewBatch()
+	err = build(func(doc Document) error {
+		if err := batch.Index(doc.key(), documentData(doc)); err != nil {
+			return fmt.Errorf("failed to index document %s: %w", doc.key(), err)
+		}
+		if batch.Size() < i.batchSize() {
+			return nil
+		}
+		return flushBatch(next, batch)
+	})
+	if err == nil {
+		err = flushBatch(next, batch)
+	}
+	if err != nil {
+		next.Close()
+		os.RemoveAll(path)
+		return fmt.Errorf("reindex aborted: %w", err)
+	}
+
+	// Holding mu keeps deletions out until the swap, so no tombstone is missed
+	i.mu.Lock()
+	if err := i.buryTombstones(next); err != nil {
+		i.mu.Unlock()
+		next.Close()
+		os.RemoveAll(path)
+		return err
+	}
+	if err := writeCurrent(i.root, generation); err != nil {
+		i.mu.Unlock()
+		next.Close()
+		os.RemoveAll(path)
+		return err
+	}
+	previous, previousGeneration := i.index, i.generation
+	i.alias.Swap([]bleve.Index{next}, []bleve.Index{previous})
+	i.index, i.generation = next, generation
+	i.mu.Unlock()
+
+	if err := previous.Close(); err != nil {
+		return fmt.Errorf("failed to close previous index generation %s: %w", previousGeneration, err)
+	}
+	if err := os.RemoveAll(filepath.Join(i.root, previousGeneration)); err != nil {
+		return fmt.Errorf("failed to remove previous index generation %s: %w", previousGeneration, err)
 	}
 	return nil
 }
 
-// Search searches the index for a given query and returns matching file paths.
-func (i *Indexer) Search(query string) ([]string, error) {
-	queryRequest := bleve.NewSearchRequest(bleve.NewQueryStringQuery(query))
-	searchResult, err := i.index.Search(queryRequest)
+// Search searches the index for a given query string and returns the best matching documents,
+// best first.
+func (i *Indexer) Search(query string) ([]Hit, error) {
+	return i.searchHits(bleve.NewQueryStringQuery(query), "", 10)
+}
+
+// Hit is a search result: a document with its score, the documentation set, version, title
+// and entry type it was indexed with, and highlighted fragments of the fields that matched.
+type Hit struct {
+	Path    string
+	Score   float64
+	Lang    string
+	Version string
+	Title   string
+	Type    string
+	// Snippet is the best excerpt of the document showing the match, with the matches wrapped
+	// in <mark> tags: a fragment of the content, else of the headings, else of the title. It
+	// may be empty.
+	Snippet string
+	// Fragments maps a field name (e.g. "Content") to excerpts with the matches wrapped in
+	// <mark> tags.
+	Fragments map[string][]string
+	// Shard is the docset whose shard the document is in; only set by Shards.SearchText.
+	Shard string
+
+	// index is the bleve index (a generation directory) the hit comes from.
+	index string
+}
+
+// SearchText searches the page content and returns at most size hits, best first, with
+// highlighted fragments. Plain text matches pages with any of its words; text using the
+// syntax of package searchquery (phrases, AND, OR, NOT, field prefixes) is parsed, and a
+// malformed query is an error. A non-empty pathPrefix restricts the search to documents whose
+// path starts with it.
+func (i *Indexer) SearchText(text, pathPrefix string, size int) ([]Hit, error) {
+	q, err := textQuery(text)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search index: %w", err)
+		return nil, err
 	}
+	return i.searchHits(q, pathPrefix, size)
+}
 
-	var matchingPaths []string
-	for _, hit := range searchResult.Hits {
-		matchingPaths = append(matchingPaths, hit.ID)
+// Boosts of the fields a term without a field prefix is matched in, so pages named after or
+// with a heading about the term rank above pages that merely mention it.
+const (
+	titleBoost    = 5
+	headingsBoost = 3
+	contentBoost  = 1
+)
+
+// textQuery returns the query of SearchText for text.
+func textQuery(text string) (bleveQuery.Query, error) {
+	if !searchquery.HasSyntax(text) {
+		return boostedQuery(text, false), nil
+	}
+	expr, err := searchquery.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return exprQuery(expr), nil
+}
+
+// exprQuery converts a parsed query to a bleve query.
+func exprQuery(expr *searchquery.Expr) bleveQuery.Query {
+	switch expr.Op {
+	case searchquery.And, searchquery.Or:
+		args := make([]bleveQuery.Query, 0, len(expr.Args))
+		for _, arg := range expr.Args {
+			args = append(args, exprQuery(arg))
+		}
+		if expr.Op == searchquery.And {
+			return bleve.NewConjunctionQuery(args...)
+		}
+		return bleve.NewDisjunctionQuery(args...)
+	case searchquery.Not:
+		// bleve can't search for what is missing alone, so NOT excludes from every document
+		boolQuery := bleve.NewBooleanQuery()
+		boolQuery.AddMust(bleve.NewMatchAllQuery())
+		boolQuery.AddMustNot(exprQuery(expr.Args[0]))
+		return boolQuery
+	}
+
+	switch expr.Field {
+	case searchquery.FieldType:
+		termQuery := bleve.NewTermQuery(strings.ToLower(expr.Value))
+		termQuery.SetField("Type")
+		return termQuery
+	case searchquery.FieldPath:
+		prefixQuery := bleve.NewPrefixQuery(strings.TrimPrefix(expr.Value, "/"))
+		prefixQuery.SetField("Path")
+		return prefixQuery
 	}
+	switch expr.Field {
+	case searchquery.FieldTitle:
+		return fieldQuery("Title", expr.Value, expr.Phrase, 1)
+	case searchquery.FieldContent:
+		return fieldQuery("Content", expr.Value, expr.Phrase, 1)
+	}
+	return boostedQuery(expr.Value, expr.Phrase)
+}
 
-	return matchingPaths, nil
+// boostedQuery matches text in the title, headings or content of pages, weighing each
+// field by its boost.
+func boostedQuery(text string, phrase bool) bleveQuery.Query {
+	return bleve.NewDisjunctionQuery(
+		fieldQuery("Title", text, phrase, titleBoost),
+		fieldQuery("Headings", text, phrase, headingsBoost),
+		fieldQuery("Content", text, phrase, contentBoost),
+	)
 }
 
-// SearchFuzzy performs a fuzzy search on the index.
-func (i *Indexer) SearchFuzzy(query string) ([]string, error) {
-	queryRequest := bleve.NewSearchRequest(bleve.NewFuzzyQuery(query))
-	searchResult, err := i.index.Search(queryRequest)
+// fieldQuery matches the words or, for a phrase, the words in order, of text in field.
+func fieldQuery(field, text string, phrase bool, boost float64) bleveQuery.Query {
+	if phrase {
+		phraseQuery := bleve.NewMatchPhraseQuery(text)
+		phraseQuery.SetField(field)
+		phraseQuery.SetBoost(boost)
+		return phraseQuery
+	}
+	matchQuery := bleve.NewMatchQuery(text)
+	matchQuery.SetField(field)
+	matchQuery.SetBoost(boost)
+	return matchQuery
+}
+
+func (i *Indexer) searchHits(q bleveQuery.Query, pathPrefix string, size int) ([]Hit, error) {
+	return searchHits(i.alias, q, pathPrefix, size)
+}
+
+// searchHits runs a search in index, which may be an alias of several indexes.
+func searchHits(index bleve.Index, q bleveQuery.Query, pathPrefix string, size int) ([]Hit, error) {
+	if pathPrefix != "" {
+		prefixQuery := bleve.NewPrefixQuery(pathPrefix)
+		prefixQuery.SetField("Path")
+		q = bleve.NewConjunctionQuery(q, prefixQuery)
+	}
+	queryRequest := bleve.NewSearchRequestOptions(q, size, 0, false)
+	queryRequest.Highlight = bleve.NewHighlight()
+	queryRequest.Fields = []string{"Path", "Lang", "Version", "Title", "Type"}
+	searchResult, err := index.Search(queryRequest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fuzzy search index: %w", err)
+		return nil, fmt.Errorf("failed to search index: %w", err)
 	}
 
-	var matchingPaths []string
+	hits := make([]Hit, 0, len(searchResult.Hits))
 	for _, hit := range searchResult.Hits {
-		matchingPaths = append(matchingPaths, hit.ID)
+		h := Hit{Path: hitPath(hit), Score: hit.Score, Fragments: hit.Fragments, index: hit.Index}
+		h.Lang, _ = hit.Fields["Lang"].(string)
+		h.Version, _ = hit.Fields["Version"].(string)
+		h.Title, _ = hit.Fields["Title"].(string)
+		h.Type, _ = hit.Fields["Type"].(string)
+		for _, field := range []string{"Content", "Headings", "Title"} {
+			if fragments := hit.Fragments[field]; len(fragments) > 0 {
+				h.Snippet = fragments[0]
+				break
+			}
+		}
+		hits = append(hits, h)
 	}
+	return hits, nil
+}
 
-	return matchingPaths, nil
+// hitPath returns the page path of a hit whose Path field was loaded, falling back to its ID.
+func hitPath(hit *search.DocumentMatch) string {
+	if path, ok := hit.Fields["Path"].(string); ok && path != "" {
+		return path
+	}
+	return hit.ID
 }
 
 // Close closes the Bleve index.
 func (i *Indexer) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	return i.index.Close()
-}
\ No newline at end of file
+}
+
+// newGeneration returns a unique, sortable name for an index generation directory.
+func newGeneration() string {
+	return fmt.Sprintf("gen-%d", time.Now().UnixNano())
+}
+
+// readCurrent returns the live generation recorded in root, or "" if none is recorded yet.
+func readCurrent(root string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(root, currentFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read current index generation: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeCurrent atomically records generation as the live generation in root, through a
+// temporary file of its own so concurrent writers don't interleave.
+func writeCurrent(root, generation string) error {
+	tmp, err := os.CreateTemp(root, currentFile+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to record current index generation: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(generation + "\n")
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record current index generation: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(root, currentFile)); err != nil {
+		return fmt.Errorf("failed to record current index generation: %w", err)
+	}
+	return nil
+}
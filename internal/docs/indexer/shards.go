@@ -0,0 +1,190 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// Shards manages one index per documentation set (shard) under a root directory, each an
+// Indexer with its own generations in <root>/<slug>. Docsets are indexed, rebuilt and deleted
+// independently, and Search runs one aliased search across any set of them.
+type Shards struct {
+	root string
+	// timeout is how long read-only shards wait for a writer; 0 opens shards for writing.
+	timeout time.Duration
+
+	mu     sync.Mutex
+	shards map[string]*Indexer
+}
+
+// NewShards manages the shards under root, opening them for writing as they are used.
+func NewShards(root string) *Shards {
+	return &Shards{root: root, shards: make(map[string]*Indexer)}
+}
+
+// OpenShardsReadOnly manages the shards under root for searching only, opening each as it is
+// used and waiting at most timeout while another process is writing to it. Open shards follow
+// the generation in their CURRENT file: one rebuilt by another process since it was opened is
+// reopened on its next use, so the shards may be kept open for as long as they are searched.
+func OpenShardsReadOnly(root string, timeout time.Duration) *Shards {
+	return &Shards{root: root, timeout: timeout, shards: make(map[string]*Indexer)}
+}
+
+// Dir returns the directory of the shard of a docset.
+func (s *Shards) Dir(slug string) string {
+	return filepath.Join(s.root, slug)
+}
+
+// checkSlug rejects slugs that would escape the root.
+func checkSlug(slug string) error {
+	if slug == "" || slug == "." || slug == ".." || strings.ContainsAny(slug, `/\`) {
+		return fmt.Errorf("invalid docset slug %q", slug)
+	}
+	return nil
+}
+
+// Shard returns the index of a docset, opening it (or, unless read-only, creating it) on first
+// use. It stays open until Release, Delete or Close.
+func (s *Shards) Shard(slug string) (*Indexer, error) {
+	if err := checkSlug(slug); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if idx, ok := s.shards[slug]; ok {
+		if s.timeout > 0 {
+			if err := idx.refresh(s.timeout); err != nil {
+				return nil, fmt.Errorf("failed to reopen the index of %s: %w", slug, err)
+			}
+		}
+		return idx, nil
+	}
+	var idx *Indexer
+	var err error
+	if s.timeout > 0 {
+		idx, err = OpenReadOnly(s.Dir(slug), s.timeout)
+	} else {
+		idx, err = NewIndexer(s.Dir(slug))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the index of %s: %w", slug, err)
+	}
+	s.shards[slug] = idx
+	return idx, nil
+}
+
+// Slugs returns the docsets with a shard under the root, sorted.
+func (s *Shards) Slugs() ([]string, error) {
+	entries, err := os.ReadDir(s.root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes in %s: %w", s.root, err)
+	}
+	var slugs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			slugs = append(slugs, entry.Name())
+		}
+	}
+	sort.Strings(slugs)
+	return slugs, nil
+}
+
+// Opened returns the docsets whose shard is open, sorted.
+func (s *Shards) Opened() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slugs := make([]string, 0, len(s.shards))
+	for slug := range s.shards {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	return slugs
+}
+
+// Release closes the shard of a docset if it is open, leaving it on disk, e.g. so another
+// process can write to it. Shard opens it again.
+func (s *Shards) Release(slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, ok := s.shards[slug]
+	if !ok {
+		return nil
+	}
+	delete(s.shards, slug)
+	if err := idx.Close(); err != nil {
+		return fmt.Errorf("failed to close the index of %s: %w", slug, err)
+	}
+	return nil
+}
+
+// Delete closes the shard of a docset and removes it from disk, leaving the other shards
+// untouched.
+func (s *Shards) Delete(slug string) error {
+	if err := checkSlug(slug); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if idx, ok := s.shards[slug]; ok {
+		delete(s.shards, slug)
+		if err := idx.Close(); err != nil {
+			return fmt.Errorf("failed to close the index of %s: %w", slug, err)
+		}
+	}
+	if err := os.RemoveAll(s.Dir(slug)); err != nil {
+		return fmt.Errorf("failed to remove the index of %s: %w", slug, err)
+	}
+	return nil
+}
+
+// SearchText searches the page content of the shards of slugs at once, like
+// Indexer.SearchText, and returns at most size hits across them, best first, each with the
+// slug of its shard.
+func (s *Shards) SearchText(slugs []string, text, pathPrefix string, size int) ([]Hit, error) {
+	q, err := textQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	alias := bleve.NewIndexAlias()
+	for _, slug := range slugs {
+		idx, err := s.Shard(slug)
+		if err != nil {
+			return nil, err
+		}
+		alias.Add(idx.alias)
+	}
+	hits, err := searchHits(alias, q, pathPrefix, size)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hits {
+		// Hits name the generation directory they come from, which lies in its shard's
+		hits[i].Shard = filepath.Base(filepath.Dir(hits[i].index))
+	}
+	return hits, nil
+}
+
+// Close closes every open shard.
+func (s *Shards) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for slug, idx := range s.shards {
+		if err := idx.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close the index of %s: %w", slug, err))
+		}
+		delete(s.shards, slug)
+	}
+	return errors.Join(errs...)
+}
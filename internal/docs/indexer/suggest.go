@@ -0,0 +1,187 @@
+package indexer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"devdocsmcp/internal/docs/match"
+
+	"github.com/blevesearch/bleve/v2"
+	bleveQuery "github.com/blevesearch/bleve/v2/search/query"
+	bleveIndex "github.com/blevesearch/bleve_index_api"
+)
+
+// maxFuzziness is the largest edit distance bleve's fuzzy term dictionaries support.
+const maxFuzziness = 2
+
+// suggestField is the field whose terms SuggestTerms looks up: the words of titles and
+// headings, not stemmed, so every suggestion is a word as the documentation writes it.
+const suggestField = "Words"
+
+// TermSuggestion is an indexed term close to a word, e.g. a correction of a misspelt query.
+type TermSuggestion struct {
+	// Term is the term as indexed: a lower-cased word of a title or heading.
+	Term     string
+	Distance int
+	// Count is the number of documents holding the term, only counting those the filter of
+	// the lookup lets through when it has one.
+	Count uint64
+}
+
+// TermFilter restricts term suggestions to the terms of some documents, like the filters of a
+// search, so a suggested word can match: documents of a kind, of an entry type (compared
+// regardless of case) and under a path prefix. Its zero value lets every document through.
+type TermFilter struct {
+	Kind       string
+	Type       string
+	PathPrefix string
+}
+
+// query returns the query matching the documents the filter lets through, or nil for all.
+func (f TermFilter) query() bleveQuery.Query {
+	var queries []bleveQuery.Query
+	if f.Kind != "" {
+		kindQuery := bleve.NewTermQuery(f.Kind)
+		kindQuery.SetField("Kind")
+		queries = append(queries, kindQuery)
+	}
+	if f.Type != "" {
+		typeQuery := bleve.NewTermQuery(strings.ToLower(f.Type))
+		typeQuery.SetField("Type")
+		queries = append(queries, typeQuery)
+	}
+	if prefix := strings.TrimPrefix(f.PathPrefix, "/"); prefix != "" {
+		prefixQuery := bleve.NewPrefixQuery(prefix)
+		prefixQuery.SetField("Path")
+		queries = append(queries, prefixQuery)
+	}
+	if len(queries) == 0 {
+		return nil
+	}
+	return bleve.NewConjunctionQuery(queries...)
+}
+
+// SuggestTerms returns up to n terms of the index within fuzziness edits (at most 2) of word
+// held by documents the filter lets through, closest first, then most frequent. Like the
+// Search methods it may be used on read-only indexes.
+func (i *Indexer) SuggestTerms(word string, fuzziness, n int, filter TermFilter) ([]TermSuggestion, error) {
+	found := make(map[string]*TermSuggestion)
+	i.mu.RLock()
+	err := fuzzyTerms(i.index, word, fuzziness, found)
+	i.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return filterTerms(i.alias, rankTerms(found), n, filter)
+}
+
+// SuggestTerms looks up the terms close to word in the shards of slugs at once, like
+// Indexer.SuggestTerms, adding up the counts of terms found in several shards.
+func (s *Shards) SuggestTerms(slugs []string, word string, fuzziness, n int, filter TermFilter) ([]TermSuggestion, error) {
+	found := make(map[string]*TermSuggestion)
+	alias := bleve.NewIndexAlias()
+	for _, slug := range slugs {
+		idx, err := s.Shard(slug)
+		if err != nil {
+			return nil, err
+		}
+		alias.Add(idx.alias)
+		idx.mu.RLock()
+		err = fuzzyTerms(idx.index, word, fuzziness, found)
+		idx.mu.RUnlock()
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up terms in the index of %s: %w", slug, err)
+		}
+	}
+	return filterTerms(alias, rankTerms(found), n, filter)
+}
+
+// fuzzyTerms adds the terms of index within fuzziness edits of word to found.
+func fuzzyTerms(index bleve.Index, word string, fuzziness int, found map[string]*TermSuggestion) error {
+	word = strings.ToLower(word)
+	fuzziness = min(max(fuzziness, 0), maxFuzziness)
+	advanced, err := index.Advanced()
+	if err != nil {
+		return fmt.Errorf("failed to open index: %w", err)
+	}
+	reader, err := advanced.Reader()
+	if err != nil {
+		return fmt.Errorf("failed to open index reader: %w", err)
+	}
+	defer reader.Close()
+	fuzzyReader, ok := reader.(bleveIndex.IndexReaderFuzzy)
+	if !ok {
+		return fmt.Errorf("the index does not support fuzzy term lookups")
+	}
+
+	dict, err := fuzzyReader.FieldDictFuzzy(suggestField, word, fuzziness, "")
+	if err != nil {
+		return fmt.Errorf("failed to read the terms of %s: %w", suggestField, err)
+	}
+	for {
+		entry, err := dict.Next()
+		if err != nil {
+			dict.Close()
+			return fmt.Errorf("failed to read the terms of %s: %w", suggestField, err)
+		}
+		if entry == nil {
+			break
+		}
+		if suggestion, ok := found[entry.Term]; ok {
+			suggestion.Count += entry.Count
+			continue
+		}
+		found[entry.Term] = &TermSuggestion{Term: entry.Term, Distance: match.Distance(word, entry.Term), Count: entry.Count}
+	}
+	if err := dict.Close(); err != nil {
+		return fmt.Errorf("failed to read the terms of %s: %w", suggestField, err)
+	}
+	return nil
+}
+
+// rankTerms returns the found terms, closest first, then most frequent.
+func rankTerms(found map[string]*TermSuggestion) []TermSuggestion {
+	terms := make([]TermSuggestion, 0, len(found))
+	for _, suggestion := range found {
+		terms = append(terms, *suggestion)
+	}
+	sort.Slice(terms, func(a, b int) bool {
+		if terms[a].Distance != terms[b].Distance {
+			return terms[a].Distance < terms[b].Distance
+		}
+		if terms[a].Count != terms[b].Count {
+			return terms[a].Count > terms[b].Count
+		}
+		return terms[a].Term < terms[b].Term
+	})
+	return terms
+}
+
+// filterTerms returns the first n of the ranked terms held by documents of index the filter
+// lets through, with the number of those documents.
+func filterTerms(index bleve.Index, terms []TermSuggestion, n int, filter TermFilter) ([]TermSuggestion, error) {
+	filterQuery := filter.query()
+	if filterQuery == nil {
+		return terms[:min(n, len(terms))], nil
+	}
+	kept := make([]TermSuggestion, 0, n)
+	for _, term := range terms {
+		if len(kept) == n {
+			break
+		}
+		termQuery := bleve.NewTermQuery(term.Term)
+		termQuery.SetField(suggestField)
+		request := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(termQuery, filterQuery), 0, 0, false)
+		result, err := index.Search(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count the documents holding %s: %w", term.Term, err)
+		}
+		if result.Total == 0 {
+			continue
+		}
+		term.Count = result.Total
+		kept = append(kept, term)
+	}
+	return kept, nil
+}
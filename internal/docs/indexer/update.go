@@ -0,0 +1,149 @@
+package indexer
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// DefaultBatchSize is how many documents are sent to bleve in one batch unless
+// Indexer.BatchSize says otherwise. Batches are analyzed in parallel and written at once,
+// which is much faster than indexing documents one by one.
+const DefaultBatchSize = 500
+
+func (i *Indexer) batchSize() int {
+	if i.BatchSize > 0 {
+		return i.BatchSize
+	}
+	return DefaultBatchSize
+}
+
+// flushBatch writes a batch to index and empties it.
+func flushBatch(index bleve.Index, batch *bleve.Batch) error {
+	if batch.Size() == 0 {
+		return nil
+	}
+	if err := index.Batch(batch); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	batch.Reset()
+	return nil
+}
+
+// AddDocuments adds or replaces documents in batches.
+func (i *Indexer) AddDocuments(docs []Document) error {
+	return i.Update(docs, nil)
+}
+
+// DeleteDocument removes a document from the index by its ID (see Document). Removing a
+// missing document is not an error.
+func (i *Indexer) DeleteDocument(path string) error {
+	return i.Update(nil, []string{path})
+}
+
+// Update adds or replaces the documents of put and removes the paths of del, in batches.
+// Removed paths also become tombstones of a reindex in progress.
+func (i *Indexer) Update(put []Document, del []string) error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	i.tombMu.Lock()
+	if i.tombstones != nil {
+		for _, path := range del {
+			i.tombstones[path] = true
+		}
+	}
+	i.tombMu.Unlock()
+
+	batch := i.index.NewBatch()
+	for _, doc := range put {
+		if err := batch.Index(doc.key(), documentData(doc)); err != nil {
+			return fmt.Errorf("failed to index document %s: %w", doc.key(), err)
+		}
+		if batch.Size() >= i.batchSize() {
+			if err := flushBatch(i.index, batch); err != nil {
+				return err
+			}
+		}
+	}
+	for _, path := range del {
+		batch.Delete(path)
+		if batch.Size() >= i.batchSize() {
+			if err := flushBatch(i.index, batch); err != nil {
+				return err
+			}
+		}
+	}
+	return flushBatch(i.index, batch)
+}
+
+// Reconcile removes the documents for which exists reports false, e.g. pages whose files
+// disappeared, and returns their paths.
+func (i *Indexer) Reconcile(exists func(path string) bool) ([]string, error) {
+	paths, err := i.Paths()
+	if err != nil {
+		return nil, err
+	}
+	var stale []string
+	for _, path := range paths {
+		if !exists(path) {
+			stale = append(stale, path)
+		}
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+	if err := i.Update(nil, stale); err != nil {
+		return nil, err
+	}
+	return stale, nil
+}
+
+// Paths returns the IDs of every document in the index, sorted; these are the paths of indexes
+// whose documents have no ID of their own.
+func (i *Indexer) Paths() ([]string, error) {
+	const page = 1000
+	var paths []string
+	request := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), page, 0, false)
+	request.SortBy([]string{"_id"})
+	for {
+		result, err := i.alias.Search(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list indexed documents: %w", err)
+		}
+		for _, hit := range result.Hits {
+			paths = append(paths, hit.ID)
+		}
+		if len(result.Hits) < page {
+			return paths, nil
+		}
+		request.SearchAfter = []string{result.Hits[len(result.Hits)-1].ID}
+	}
+}
+
+// DocCount returns the number of documents in the index.
+func (i *Indexer) DocCount() (uint64, error) {
+	count, err := i.alias.DocCount()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count indexed documents: %w", err)
+	}
+	return count, nil
+}
+
+// buryTombstones deletes the paths deleted during a reindex from its new generation. The
+// caller must hold mu exclusively.
+func (i *Indexer) buryTombstones(next bleve.Index) error {
+	i.tombMu.Lock()
+	defer i.tombMu.Unlock()
+	if len(i.tombstones) == 0 {
+		return nil
+	}
+	batch := next.NewBatch()
+	for path := range i.tombstones {
+		batch.Delete(path)
+	}
+	if err := next.Batch(batch); err != nil {
+		return fmt.Errorf("failed to delete documents from the new index generation: %w", err)
+	}
+	return nil
+}
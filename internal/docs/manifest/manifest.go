@@ -0,0 +1,169 @@
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"devdocsmcp/internal/docs/match"
+)
+
+// DefaultURL is the location of the devdocs.io manifest listing every available docset.
+const DefaultURL = "https://devdocs.io/docs.json"
+
+// Docset describes one documentation set as listed in docs.json.
+type Docset struct {
+	Name        string            `json:"name"`
+	Slug        string            `json:"slug"`
+	Type        string            `json:"type"`
+	Version     string            `json:"version"`
+	Release     string            `json:"release"`
+	Mtime       int64             `json:"mtime"`
+	DBSize      int64             `json:"db_size"`
+	Links       map[string]string `json:"links,omitempty"`
+	Attribution string            `json:"attribution,omitempty"`
+}
+
+// Fetch downloads and decodes the manifest at url.
+func Fetch(url string) ([]Docset, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest from %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch manifest from %s: status code %d - %s", url, resp.StatusCode, resp.Status)
+	}
+
+	var docsets []Docset
+	if err := json.NewDecoder(resp.Body).Decode(&docsets); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest from %s: %w", url, err)
+	}
+	return docsets, nil
+}
+
+// Find returns the docset with the given slug.
+func Find(docsets []Docset, slug string) (Docset, bool) {
+	for _, d := range docsets {
+		if d.Slug == slug {
+			return d, true
+		}
+	}
+	return Docset{}, false
+}
+
+// Load returns the manifest from cachePath if it is younger than ttl, otherwise fetches it from
+// url and refreshes the cache. When the fetch fails, a stale cached copy is used if one exists.
+func Load(url, cachePath string, ttl time.Duration) ([]Docset, error) {
+	return LoadFirst([]string{url}, cachePath, ttl)
+}
+
+// LoadFirst is Load for a manifest published at several URLs, e.g. devdocs mirrors: they are
+// tried in order until one answers, and the cached copy is used only if none does.
+func LoadFirst(urls []string, cachePath string, ttl time.Duration) ([]Docset, error) {
+	info, statErr := os.Stat(cachePath)
+	if statErr == nil && time.Since(info.ModTime()) < ttl {
+		if docsets, err := readCache(cachePath); err == nil {
+			return docsets, nil
+		}
+	}
+
+	var docsets []Docset
+	err := errors.New("no manifest URL given")
+	for _, url := range urls {
+		if docsets, err = Fetch(url); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		if statErr == nil {
+			if cached, cacheErr := readCache(cachePath); cacheErr == nil {
+				return cached, nil
+			}
+		}
+		return nil, err
+	}
+
+	if data, err := json.Marshal(docsets); err == nil {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+			writeCache(cachePath, data)
+		}
+	}
+	return docsets, nil
+}
+
+// writeCache atomically replaces the cached manifest, through a temporary file of its own so
+// processes sharing the cache don't write into each other's.
+func writeCache(cachePath string, data []byte) {
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), ".tmp-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil && closeErr == nil {
+		os.Rename(tmp.Name(), cachePath)
+	}
+}
+
+func readCache(cachePath string) ([]Docset, error) {
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	var docsets []Docset
+	if err := json.Unmarshal(data, &docsets); err != nil {
+		return nil, fmt.Errorf("failed to decode cached manifest %s: %w", cachePath, err)
+	}
+	return docsets, nil
+}
+
+// Suggest returns up to n slugs closest to slug, best first. Slugs sharing the name part
+// before the '~' version separator (e.g. "angular" for "angular~16") rank ahead of slugs that
+// are merely a small edit distance away.
+func Suggest(docsets []Docset, slug string, n int) []string {
+	type candidate struct {
+		slug  string
+		score int
+	}
+
+	lowerSlug := strings.ToLower(slug)
+	base := strings.SplitN(lowerSlug, "~", 2)[0]
+	maxDistance := max(2, len([]rune(lowerSlug))/3)
+
+	var candidates []candidate
+	for _, d := range docsets {
+		candidateBase := strings.SplitN(d.Slug, "~", 2)[0]
+		switch {
+		case candidateBase == base:
+			candidates = append(candidates, candidate{d.Slug, 0})
+		case strings.HasPrefix(d.Slug, lowerSlug):
+			candidates = append(candidates, candidate{d.Slug, 1})
+		default:
+			distance := match.Distance(lowerSlug, d.Slug)
+			if baseDistance := match.Distance(base, candidateBase); baseDistance < distance {
+				distance = baseDistance
+			}
+			if distance <= maxDistance {
+				candidates = append(candidates, candidate{d.Slug, 1 + distance})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score < candidates[j].score })
+
+	suggestions := make([]string, 0, n)
+	for _, c := range candidates {
+		if len(suggestions) == n {
+			break
+		}
+		suggestions = append(suggestions, c.slug)
+	}
+	return suggestions
+}
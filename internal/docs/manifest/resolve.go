@@ -0,0 +1,218 @@
+package manifest
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"devdocsmcp/internal/docs/match"
+)
+
+// nameAliases maps common ways of naming a technology to its devdocs slug name.
+var nameAliases = map[string]string{
+	"postgres":    "postgresql",
+	"pg":          "postgresql",
+	"js":          "javascript",
+	"ecmascript":  "javascript",
+	"ts":          "typescript",
+	"golang":      "go",
+	"py":          "python",
+	"nodejs":      "node",
+	"k8s":         "kubernetes",
+	"vuejs":       "vue",
+	"reactjs":     "react",
+	"ror":         "rails",
+	"rubyonrails": "rails",
+	"c++":         "cpp",
+}
+
+// versionPattern matches a trailing version such as "18", "3.12", "v20" or "latest".
+var versionPattern = regexp.MustCompile(`^(?:v?(\d+(?:\.\d+)*)|latest)$`)
+
+// Resolution is a docset matched by Resolve.
+type Resolution struct {
+	Slug    string `json:"slug"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Release string `json:"release,omitempty"`
+}
+
+// Resolve maps a human description such as "React 18", "Postgres 15", "python latest" or
+// "node@20" to the matching docsets, best first. Without a version (or with "latest") the
+// newest docset of the best matching name ranks first.
+func Resolve(docsets []Docset, query string) []Resolution {
+	name, version := splitNameVersion(query)
+	if name == "" {
+		return nil
+	}
+
+	// Score each slug name ("react" for "react~18") against the requested name
+	type family struct {
+		score   int
+		docsets []Docset
+	}
+	families := make(map[string]*family)
+	for _, d := range docsets {
+		base := strings.SplitN(d.Slug, "~", 2)[0]
+		f, ok := families[base]
+		if !ok {
+			score, matched := nameScore(name, base, d.Name)
+			if !matched {
+				continue
+			}
+			f = &family{score: score}
+			families[base] = f
+		}
+		f.docsets = append(f.docsets, d)
+	}
+
+	bases := make([]string, 0, len(families))
+	for base := range families {
+		bases = append(bases, base)
+	}
+	sort.Slice(bases, func(i, j int) bool {
+		if families[bases[i]].score != families[bases[j]].score {
+			return families[bases[i]].score < families[bases[j]].score
+		}
+		return bases[i] < bases[j]
+	})
+
+	var resolutions []Resolution
+	for _, base := range bases {
+		for _, d := range orderByVersion(families[base].docsets, version) {
+			resolutions = append(resolutions, Resolution{Slug: d.Slug, Name: d.Name, Version: d.Version, Release: d.Release})
+		}
+	}
+	return resolutions
+}
+
+// splitNameVersion splits "React 18", "react~18", "node@20" or "python latest" into a
+// normalised name and a version ("" when absent, "latest" for the latest alias).
+func splitNameVersion(query string) (string, string) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	for _, sep := range []string{"~", "@"} {
+		if i := strings.LastIndex(query, sep); i > 0 {
+			return normaliseName(query[:i]), strings.TrimPrefix(query[i+1:], "v")
+		}
+	}
+
+	fields := strings.Fields(query)
+	version := ""
+	if len(fields) > 1 {
+		if m := versionPattern.FindStringSubmatch(fields[len(fields)-1]); m != nil {
+			version = m[1]
+			if version == "" {
+				version = "latest"
+			}
+			fields = fields[:len(fields)-1]
+		}
+	}
+	return normaliseName(strings.Join(fields, " ")), version
+}
+
+// normaliseName strips separators so "Node.js", "node js" and "nodejs" compare equal.
+func normaliseName(name string) string {
+	replacer := strings.NewReplacer(" ", "", ".", "", "-", "", "_", "")
+	name = replacer.Replace(strings.ToLower(name))
+	if alias, ok := nameAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// nameScore rates how well the requested name matches a slug name or display name.
+func nameScore(name, base, displayName string) (int, bool) {
+	display := normaliseName(displayName)
+	switch {
+	case name == base || name == display:
+		return 0, true
+	case strings.HasPrefix(base, name) || strings.HasPrefix(display, name):
+		return 1, true
+	}
+	distance := min(match.Distance(name, base), match.Distance(name, display))
+	if distance <= max(1, len(name)/4) {
+		return 1 + distance, true
+	}
+	return 0, false
+}
+
+// Latest returns the docset named by name: the docset with that slug, or else the current
+// release of the family, so "python" stands for "python~3.13" when devdocs only lists versions.
+func Latest(docsets []Docset, name string) (Docset, bool) {
+	if d, ok := Find(docsets, name); ok {
+		return d, true
+	}
+	var family []Docset
+	for _, d := range docsets {
+		if strings.SplitN(d.Slug, "~", 2)[0] == name {
+			family = append(family, d)
+		}
+	}
+	if len(family) == 0 {
+		return Docset{}, false
+	}
+	return orderByVersion(family, "")[0], true
+}
+
+// orderByVersion sorts a family of docsets so the one matching version comes first, followed
+// by the rest newest first. For "" or "latest", the unversioned slug (devdocs' alias for the
+// current release) or else the newest version comes first.
+func orderByVersion(docsets []Docset, version string) []Docset {
+	ordered := append([]Docset(nil), docsets...)
+	rank := func(d Docset) int {
+		slugVersion := ""
+		if parts := strings.SplitN(d.Slug, "~", 2); len(parts) == 2 {
+			slugVersion = parts[1]
+		}
+		switch {
+		case version == "" || version == "latest":
+			if slugVersion == "" {
+				return 0
+			}
+			return 1
+		case slugVersion == version || d.Version == version:
+			return 0
+		case strings.HasPrefix(slugVersion, version+".") || strings.HasPrefix(d.Version, version+"."):
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, rj := rank(ordered[i]), rank(ordered[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return compareVersions(slugVersionOf(ordered[i]), slugVersionOf(ordered[j])) > 0
+	})
+	return ordered
+}
+
+func slugVersionOf(d Docset) string {
+	if parts := strings.SplitN(d.Slug, "~", 2); len(parts) == 2 {
+		return parts[1]
+	}
+	return d.Version
+}
+
+// compareVersions compares dotted numeric versions, returning -1, 0 or 1.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
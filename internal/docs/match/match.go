@@ -0,0 +1,58 @@
+package match
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Distance returns the Levenshtein edit distance between a and b, counted in runes.
+func Distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// Tokens splits a symbol or entry name into lower-cased words on punctuation and whitespace,
+// e.g. "Array.prototype.map()" becomes ["array", "prototype", "map"].
+func Tokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// Fuzzy reports the smallest edit distance between query and either the whole candidate or one
+// of its tokens, and whether that distance is within fuzziness. Comparison is case-insensitive.
+func Fuzzy(query, candidate string, fuzziness int) (int, bool) {
+	query = strings.ToLower(query)
+	best := Distance(query, strings.ToLower(candidate))
+	for _, token := range Tokens(candidate) {
+		if d := Distance(query, token); d < best {
+			best = d
+		}
+	}
+	return best, best <= fuzziness
+}
+
+// DefaultFuzziness picks an edit distance suited to the query length: short queries only
+// tolerate a single typo, mirroring the fuzziness bleve applies to fuzzy queries.
+func DefaultFuzziness(query string) int {
+	if len([]rune(query)) <= 4 {
+		return 1
+	}
+	return 2
+}
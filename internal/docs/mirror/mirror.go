@@ -0,0 +1,493 @@
+package mirror
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"devdocsmcp/internal/atrest"
+	"devdocsmcp/internal/cachetx"
+	"devdocsmcp/internal/docs/manifest"
+)
+
+// metaFile is written into each mirrored docset directory to record the mirrored revision.
+const metaFile = ".mirror.json"
+
+// stagingDir, under the mirror root, holds the revisions being synced until they are complete.
+const stagingDir = ".staging"
+
+// blobsDir, under the mirror root, stores page bodies by the SHA-256 of their content. Page
+// files are hard links to their blob, so a page identical in several docsets or revisions
+// (e.g. node~18 and node~20) takes its space once; the page hashes of each docset's metaFile
+// tell which blobs are in use.
+const blobsDir = ".blobs"
+
+// stagingRecoveryAge is how old an unfinished sync must be before Sync completes or discards
+// it; younger ones may belong to another process still syncing.
+const stagingRecoveryAge = 10 * time.Minute
+
+// Mirror maintains a local copy of documents.devdocs.io laid out exactly like the upstream host,
+// so it can be served over HTTP and used as another instance's base URL.
+type Mirror struct {
+	Dir         string
+	DocsBaseURL string
+	ManifestURL string
+	// Slugs restricts the mirror to these docsets. Empty means every docset in the manifest.
+	Slugs []string
+	// Cipher, if set, encrypts every mirrored file at rest. Handler decrypts on the fly.
+	Cipher *atrest.Cipher
+}
+
+// SyncResult summarises one sync pass.
+type SyncResult struct {
+	Updated []string
+	Current []string
+	Failed  map[string]error
+	// Changes counts the page changes of each updated docset.
+	Changes map[string]Changes
+}
+
+// Changes counts how the pages of a docset changed between two mirrored revisions. Only added
+// and modified pages are written, and removed pages are deleted; unchanged pages are left alone.
+type Changes struct {
+	Added     int
+	Modified  int
+	Removed   int
+	Unchanged int
+	// Written lists the paths of the added and modified pages, Deleted those of the removed ones.
+	Written []string
+	Deleted []string
+}
+
+func (c Changes) String() string {
+	return fmt.Sprintf("%d added, %d modified, %d removed, %d unchanged", c.Added, c.Modified, c.Removed, c.Unchanged)
+}
+
+type docsetMeta struct {
+	Slug  string `json:"slug"`
+	Mtime int64  `json:"mtime"`
+	Pages int    `json:"pages"`
+	// Hashes maps each page path of db.json to the SHA-256 of its content, so the next revision
+	// only rewrites the pages that changed.
+	Hashes map[string]string `json:"hashes,omitempty"`
+}
+
+// NewMirror creates a mirror rooted at dir that copies docsets from docsBaseURL.
+func NewMirror(dir, docsBaseURL string, slugs []string) *Mirror {
+	return &Mirror{
+		Dir:         dir,
+		DocsBaseURL: strings.TrimSuffix(docsBaseURL, "/") + "/",
+		ManifestURL: manifest.DefaultURL,
+		Slugs:       slugs,
+	}
+}
+
+// Sync fetches the manifest and downloads every selected docset whose mtime differs from the
+// mirrored copy. The manifest itself is stored as docs.json at the mirror root.
+func (m *Mirror) Sync() (*SyncResult, error) {
+	if _, err := cachetx.Recover(filepath.Join(m.Dir, stagingDir), stagingRecoveryAge, nil); err != nil {
+		log.Printf("Mirror: %v\n", err)
+	}
+	docsets, err := manifest.Fetch(m.ManifestURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create mirror directory %s: %w", m.Dir, err)
+	}
+	data, err := json.Marshal(docsets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := m.writeFile(filepath.Join(m.Dir, "docs.json"), data); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(m.Slugs))
+	for _, slug := range m.Slugs {
+		wanted[slug] = true
+	}
+
+	result := &SyncResult{Failed: make(map[string]error), Changes: make(map[string]Changes)}
+	for _, docset := range docsets {
+		if len(wanted) > 0 && !wanted[docset.Slug] {
+			continue
+		}
+		delete(wanted, docset.Slug)
+		if err := checkSlug(docset.Slug); err != nil {
+			log.Printf("Mirror: skipping docset %q of the manifest: %v\n", docset.Slug, err)
+			result.Failed[docset.Slug] = err
+			continue
+		}
+
+		meta, err := m.readMeta(docset.Slug)
+		if err == nil && meta.Mtime == docset.Mtime {
+			result.Current = append(result.Current, docset.Slug)
+			continue
+		}
+		changes, err := m.syncDocset(docset, meta)
+		if err != nil {
+			log.Printf("Mirror: failed to sync %s: %v\n", docset.Slug, err)
+			result.Failed[docset.Slug] = err
+			continue
+		}
+		result.Updated = append(result.Updated, docset.Slug)
+		result.Changes[docset.Slug] = changes
+	}
+	for slug := range wanted {
+		result.Failed[slug] = fmt.Errorf("docset %s is not listed in the manifest", slug)
+	}
+	if len(result.Updated) > 0 {
+		if err := m.removeUnusedBlobs(); err != nil {
+			log.Printf("Mirror: %v\n", err)
+		}
+	}
+	return result, nil
+}
+
+// Handler serves the mirror read-only with the same URL layout as documents.devdocs.io.
+func (m *Mirror) Handler() http.Handler {
+	files := http.FileServer(http.Dir(m.Dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "mirror is read-only", http.StatusMethodNotAllowed)
+			return
+		}
+		clean := path.Clean("/" + r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/"+metaFile) || strings.HasPrefix(clean, "/"+stagingDir+"/") || strings.HasPrefix(clean, "/"+blobsDir+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		if m.Cipher == nil {
+			files.ServeHTTP(w, r)
+			return
+		}
+		m.serveDecrypted(w, r)
+	})
+}
+
+// serveDecrypted serves one file of an encrypted mirror. Directory listings are not offered.
+func (m *Mirror) serveDecrypted(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + r.URL.Path)
+	file := filepath.Join(m.Dir, filepath.FromSlash(name))
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := m.readFile(file)
+	if err != nil {
+		log.Printf("Mirror: failed to serve %s: %v\n", name, err)
+		http.Error(w, "failed to read mirrored file", http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, name, info.ModTime(), bytes.NewReader(data))
+}
+
+// readFile reads a mirrored file, decrypting it if the mirror is encrypted.
+func (m *Mirror) readFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || m.Cipher == nil {
+		return data, err
+	}
+	return m.Cipher.Open(data)
+}
+
+// writeFile atomically writes a mirrored file, encrypting it if the mirror is encrypted.
+func (m *Mirror) writeFile(path string, data []byte) error {
+	if m.Cipher != nil {
+		data = m.Cipher.Seal(data)
+	}
+	return writeFileAtomic(path, data)
+}
+
+// syncDocset mirrors a new revision of docset. previous is the metadata of the mirrored
+// revision, or nil if there is none; its page hashes let the sync write only changed pages.
+func (m *Mirror) syncDocset(docset manifest.Docset, previous *docsetMeta) (Changes, error) {
+	log.Printf("Mirror: syncing %s (mtime %d)\n", docset.Slug, docset.Mtime)
+
+	var changes Changes
+	index, err := m.fetch(docset.Slug + "/index.json")
+	if err != nil {
+		return changes, err
+	}
+	db, err := m.fetch(docset.Slug + "/db.json")
+	if err != nil {
+		return changes, err
+	}
+	var pages map[string]string
+	if err := json.Unmarshal(db, &pages); err != nil {
+		return changes, fmt.Errorf("failed to decode db.json for %s: %w", docset.Slug, err)
+	}
+
+	// The revision is staged and moved into place in one transaction, so readers of the mirror
+	// never see the pages of one revision with the index of another
+	dir := filepath.Join(m.Dir, docset.Slug)
+	tx, err := cachetx.Begin(filepath.Join(m.Dir, stagingDir), dir)
+	if err != nil {
+		return changes, err
+	}
+	defer tx.Abort()
+	if m.Cipher != nil {
+		tx.Seal = m.Cipher.Seal
+	}
+	old := m.pageHashes(dir, previous)
+	hashes := make(map[string]string, len(pages))
+	shared := 0
+	for pagePath, content := range pages {
+		file, err := PageFile(dir, pagePath)
+		if err != nil {
+			log.Printf("Mirror: skipping page %s/%s: %v\n", docset.Slug, pagePath, err)
+			continue
+		}
+		hash := hashPage(content)
+		hashes[pagePath] = hash
+		oldHash, existed := old[pagePath]
+		if existed && oldHash == hash {
+			changes.Unchanged++
+			continue
+		}
+		blob, stored, err := m.storeBlob(hash, content)
+		if err != nil {
+			return changes, err
+		}
+		if stored {
+			shared++
+		}
+		name, _ := filepath.Rel(dir, file)
+		if err := tx.Link(filepath.ToSlash(name), blob); err != nil {
+			return changes, err
+		}
+		if existed {
+			changes.Modified++
+		} else {
+			changes.Added++
+		}
+		changes.Written = append(changes.Written, pagePath)
+	}
+	for pagePath := range old {
+		if _, ok := hashes[pagePath]; ok {
+			continue
+		}
+		if file, err := PageFile(dir, pagePath); err == nil {
+			name, _ := filepath.Rel(dir, file)
+			if err := tx.Remove(filepath.ToSlash(name)); err != nil {
+				return changes, err
+			}
+		}
+		changes.Removed++
+		changes.Deleted = append(changes.Deleted, pagePath)
+	}
+	if err := tx.Write("db.json", db); err != nil {
+		return changes, err
+	}
+	if err := tx.Write("index.json", index); err != nil {
+		return changes, err
+	}
+
+	meta, err := json.Marshal(docsetMeta{Slug: docset.Slug, Mtime: docset.Mtime, Pages: len(pages), Hashes: hashes})
+	if err != nil {
+		return changes, fmt.Errorf("failed to encode mirror metadata for %s: %w", docset.Slug, err)
+	}
+	if err := tx.Write(metaFile, meta); err != nil {
+		return changes, err
+	}
+	if err := tx.Commit(); err != nil {
+		return changes, err
+	}
+	log.Printf("Mirror: %s: %s; %d written pages already stored for other docsets or revisions\n", docset.Slug, changes, shared)
+	return changes, nil
+}
+
+// storeBlob returns the blob of a page body with the given hash, writing it unless it is
+// already stored, as reported.
+func (m *Mirror) storeBlob(hash, content string) (string, bool, error) {
+	file := filepath.Join(m.Dir, blobsDir, hash[:2], hash)
+	if _, err := os.Stat(file); err == nil {
+		return file, true, nil
+	}
+	if err := m.writeFile(file, []byte(content)); err != nil {
+		return "", false, err
+	}
+	return file, false, nil
+}
+
+// removeUnusedBlobs removes the blobs no page of a mirrored docset has the hash of anymore.
+func (m *Mirror) removeUnusedBlobs() error {
+	slugs, err := m.Mirrored()
+	if err != nil {
+		return err
+	}
+	used := make(map[string]bool)
+	for _, slug := range slugs {
+		meta, err := m.readMeta(slug)
+		if err != nil {
+			return fmt.Errorf("failed to read the page hashes of %s: %w", slug, err)
+		}
+		for _, hash := range meta.Hashes {
+			used[hash] = true
+		}
+	}
+	removed := 0
+	root := filepath.Join(m.Dir, blobsDir)
+	err = filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() || used[d.Name()] {
+			return err
+		}
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove unused blobs: %w", err)
+	}
+	if removed > 0 {
+		log.Printf("Mirror: removed %d unused page blobs\n", removed)
+	}
+	return nil
+}
+
+// pageHashes returns the page hashes of the mirrored revision of the docset in dir. Mirrors
+// written before hashes were recorded are hashed from the db.json on disk, whose pages were all
+// written by that sync.
+func (m *Mirror) pageHashes(dir string, previous *docsetMeta) map[string]string {
+	if previous == nil {
+		return nil
+	}
+	if previous.Hashes != nil {
+		return previous.Hashes
+	}
+	data, err := m.readFile(filepath.Join(dir, "db.json"))
+	if err != nil {
+		return nil
+	}
+	var pages map[string]string
+	if err := json.Unmarshal(data, &pages); err != nil {
+		return nil
+	}
+	hashes := make(map[string]string, len(pages))
+	for pagePath, content := range pages {
+		hashes[pagePath] = hashPage(content)
+	}
+	return hashes
+}
+
+func hashPage(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Mirrored returns the slugs of the docsets in the mirror, sorted. A mirror directory that
+// doesn't exist yet holds none.
+func (m *Mirror) Mirrored() ([]string, error) {
+	entries, err := os.ReadDir(m.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mirror directory %s: %w", m.Dir, err)
+	}
+	var slugs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := m.readMeta(entry.Name()); err == nil {
+			slugs = append(slugs, entry.Name())
+		}
+	}
+	return slugs, nil
+}
+
+func (m *Mirror) readMeta(slug string) (*docsetMeta, error) {
+	data, err := m.readFile(filepath.Join(m.Dir, slug, metaFile))
+	if err != nil {
+		return nil, err
+	}
+	var meta docsetMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func (m *Mirror) fetch(path string) ([]byte, error) {
+	url := m.DocsBaseURL + path
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status code %d - %s", url, resp.StatusCode, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body from %s: %w", url, err)
+	}
+	return data, nil
+}
+
+// checkSlug rejects docset slugs of the manifest that aren't a plain directory name of the
+// mirror root, where they would escape it or clash with its own entries such as .blobs.
+func checkSlug(slug string) error {
+	if slug == "" || strings.ContainsAny(slug, `/\`) || strings.HasPrefix(slug, ".") {
+		return fmt.Errorf("invalid docset slug %q", slug)
+	}
+	return nil
+}
+
+// PageFile maps a db.json page path to its file in the docset directory dir, rejecting paths
+// that escape it.
+func PageFile(dir, pagePath string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(pagePath))
+	if clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errors.New("page path escapes the docset directory")
+	}
+	return filepath.Join(dir, clean+".html"), nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it into place,
+// so readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(path), err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move %s into place: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package page
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ErrConflictingFilters is returned by Filter.Apply when both OmitExamples and ExamplesOnly
+// are set.
+var ErrConflictingFilters = errors.New("omit_examples and examples_only exclude each other")
+
+// Filter selects parts of a page. The zero value keeps the whole page.
+type Filter struct {
+	// OmitExamples drops the code examples ('pre' blocks), leaving the prose.
+	OmitExamples bool
+	// ExamplesOnly keeps only the code examples, each under the headings it appears below.
+	ExamplesOnly bool
+	// OmitTables drops tables.
+	OmitTables bool
+}
+
+// IsZero reports whether f keeps the whole page.
+func (f Filter) IsZero() bool {
+	return f == Filter{}
+}
+
+// Apply returns the HTML of content with the parts f drops removed. The markup that is kept is
+// copied verbatim. The page is tokenized, not parsed into a DOM, so this is safe for very large
+// pages.
+func (f Filter) Apply(content string) (string, error) {
+	if f.OmitExamples && f.ExamplesOnly {
+		return "", ErrConflictingFilters
+	}
+	if f.IsZero() {
+		return content, nil
+	}
+
+	var out strings.Builder
+	// Headings seen in examples-only mode, innermost last; each is written before the first
+	// example below it.
+	type heading struct {
+		level   int
+		html    string
+		written bool
+	}
+	var headings []heading
+	var headingHTML strings.Builder
+	inHeading := 0
+	// dropped is the element being dropped and depth its nesting, as tables and 'pre' blocks
+	// may contain elements of their own name.
+	dropped, depth := "", 0
+	preDepth := 0
+
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if errors.Is(z.Err(), io.EOF) {
+				return out.String(), nil
+			}
+			return "", z.Err()
+		}
+		raw := string(z.Raw())
+		var name string
+		if tt == html.StartTagToken || tt == html.EndTagToken || tt == html.SelfClosingTagToken {
+			nameBytes, _ := z.TagName()
+			name = string(nameBytes)
+		}
+
+		if dropped != "" {
+			if name == dropped {
+				switch tt {
+				case html.StartTagToken:
+					depth++
+				case html.EndTagToken:
+					depth--
+				}
+				if depth == 0 {
+					dropped = ""
+				}
+			}
+			continue
+		}
+		if tt == html.StartTagToken && ((name == "pre" && f.OmitExamples) || (name == "table" && f.OmitTables)) {
+			dropped, depth = name, 1
+			continue
+		}
+		if !f.ExamplesOnly {
+			out.WriteString(raw)
+			continue
+		}
+
+		// Examples only: keep 'pre' blocks and the headings above them
+		if level := headingLevel(name); level > 0 && preDepth == 0 {
+			if tt == html.StartTagToken && inHeading == 0 {
+				inHeading = level
+				headingHTML.Reset()
+			}
+			if inHeading > 0 {
+				headingHTML.WriteString(raw)
+			}
+			if tt == html.EndTagToken && inHeading == level {
+				inHeading = 0
+				for len(headings) > 0 && headings[len(headings)-1].level >= level {
+					headings = headings[:len(headings)-1]
+				}
+				headings = append(headings, heading{level: level, html: headingHTML.String()})
+			}
+			continue
+		}
+		if inHeading > 0 {
+			headingHTML.WriteString(raw)
+			continue
+		}
+		if name == "pre" {
+			switch tt {
+			case html.StartTagToken:
+				if preDepth == 0 {
+					for i := range headings {
+						if !headings[i].written {
+							out.WriteString(headings[i].html + "\n")
+							headings[i].written = true
+						}
+					}
+				}
+				preDepth++
+			case html.EndTagToken:
+				if preDepth > 0 {
+					preDepth--
+					if preDepth == 0 {
+						out.WriteString(raw + "\n")
+						continue
+					}
+				}
+			}
+		}
+		if preDepth > 0 {
+			out.WriteString(raw)
+		}
+	}
+}
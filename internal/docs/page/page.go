@@ -0,0 +1,213 @@
+package page
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// Links returns the 'href' attributes of every 'a' tag in an HTML document, in document order.
+func Links(content string) ([]string, error) {
+	if useStreaming(content) {
+		return StreamLinks(strings.NewReader(content))
+	}
+
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	var links []string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			for _, a := range n.Attr {
+				if a.Key == "href" && a.Val != "" {
+					links = append(links, a.Val)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return links, nil
+}
+
+// blockElements start a new line when extracting text.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "br": true,
+	"dd": true, "details": true, "div": true, "dl": true, "dt": true, "figcaption": true,
+	"figure": true, "footer": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "header": true, "hr": true, "li": true, "main": true,
+	"nav": true, "ol": true, "p": true, "pre": true, "section": true, "summary": true,
+	"table": true, "tr": true, "ul": true,
+}
+
+// Text extracts the readable text of an HTML document, one block element per line.
+// Whitespace inside blocks is collapsed, except within 'pre' elements.
+func Text(content string) (string, error) {
+	if useStreaming(content) {
+		var b strings.Builder
+		if err := StreamText(strings.NewReader(content), &b); err != nil {
+			return "", fmt.Errorf("failed to tokenize HTML: %w", err)
+		}
+		return b.String(), nil
+	}
+
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	var lines []string
+	var line strings.Builder
+	flush := func() {
+		// Trim only the right side so indentation inside 'pre' blocks survives
+		if text := strings.TrimRightFunc(line.String(), unicode.IsSpace); strings.TrimSpace(text) != "" {
+			lines = append(lines, text)
+		}
+		line.Reset()
+	}
+
+	var f func(*html.Node, bool)
+	f = func(n *html.Node, pre bool) {
+		switch n.Type {
+		case html.TextNode:
+			if pre {
+				for i, part := range strings.Split(n.Data, "\n") {
+					if i > 0 {
+						flush()
+					}
+					line.WriteString(part)
+				}
+			} else {
+				writeCollapsed(&line, n.Data)
+			}
+			return
+		case html.ElementNode:
+			if n.Data == "script" || n.Data == "style" {
+				return
+			}
+			if n.Data == "pre" {
+				pre = true
+			}
+			if (n.Data == "td" || n.Data == "th") && line.Len() > 0 {
+				line.WriteString(" ")
+			}
+		}
+
+		block := n.Type == html.ElementNode && blockElements[n.Data]
+		if block {
+			flush()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c, pre)
+		}
+		if block {
+			flush()
+		}
+	}
+	f(doc, false)
+	flush()
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// writeCollapsed appends text to line with runs of whitespace collapsed to a single space.
+// No leading space is written at the start of a line.
+func writeCollapsed(line *strings.Builder, text string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		if text != "" && line.Len() > 0 && !strings.HasSuffix(line.String(), " ") {
+			line.WriteString(" ")
+		}
+		return
+	}
+	if unicode.IsSpace(rune(text[0])) && line.Len() > 0 && !strings.HasSuffix(line.String(), " ") {
+		line.WriteString(" ")
+	}
+	line.WriteString(strings.Join(fields, " "))
+	if unicode.IsSpace(rune(text[len(text)-1])) {
+		line.WriteString(" ")
+	}
+}
+
+// minSummaryLength is the shortest paragraph, in bytes, that Summary considers meaningful.
+const minSummaryLength = 20
+
+// Summary returns the text of the page's first 'h1' and of the first meaningful paragraph that
+// follows it. If the page has no 'h1', the first meaningful paragraph of the page is used.
+// Short paragraphs such as badges or "Deprecated" notices are skipped unless nothing else exists.
+func Summary(content string) (title, summary string, err error) {
+	if useStreaming(content) {
+		return StreamSummary(strings.NewReader(content))
+	}
+
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	var fallback string
+	seenTitle := false
+	var f func(*html.Node) bool
+	f = func(n *html.Node) bool {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "h1":
+				if !seenTitle {
+					seenTitle = true
+					title = nodeText(n)
+					// Paragraphs before the title belong to navigation, not the entry
+					fallback = ""
+				}
+				return false
+			case "p":
+				text := nodeText(n)
+				if len(text) >= minSummaryLength {
+					summary = text
+					return true
+				}
+				if fallback == "" {
+					fallback = text
+				}
+				return false
+			case "script", "style", "nav":
+				return false
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if f(c) {
+				return true
+			}
+		}
+		return false
+	}
+	if !f(doc) {
+		summary = fallback
+	}
+	return title, summary, nil
+}
+
+// nodeText returns the whitespace-collapsed text content of n.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style") {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,72 @@
+package page
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// PlainText extracts the text of content without parsing it, for pages the other functions
+// of this package fail on. Tags and comments are dropped along with the contents of scripts
+// and styles, block elements start a new line, entities are decoded and whitespace is
+// collapsed. It never fails: an unterminated tag or comment ends the text.
+func PlainText(content string) string {
+	var b strings.Builder
+	for len(content) > 0 {
+		i := strings.IndexByte(content, '<')
+		if i < 0 {
+			b.WriteString(content)
+			break
+		}
+		b.WriteString(content[:i])
+		content = content[i:]
+
+		if strings.HasPrefix(content, "<!--") {
+			end := strings.Index(content, "-->")
+			if end < 0 {
+				break
+			}
+			content = content[end+len("-->"):]
+			continue
+		}
+		end := strings.IndexByte(content, '>')
+		if end < 0 {
+			break
+		}
+		closing := strings.HasPrefix(content[1:end], "/")
+		name := strings.ToLower(strings.TrimLeft(content[1:end], "/"))
+		if j := strings.IndexAny(name, " \t\r\n/"); j >= 0 {
+			name = name[:j]
+		}
+		content = content[end+1:]
+
+		if !closing && (name == "script" || name == "style") {
+			// Skip to the closing tag; the contents are not text
+			i := strings.Index(strings.ToLower(content), "</"+name)
+			if i < 0 {
+				break
+			}
+			content = content[i:]
+			continue
+		}
+		if blockElements[name] {
+			b.WriteString("\n")
+		} else if name == "td" || name == "th" {
+			b.WriteString(" ")
+		}
+	}
+
+	var lines []string
+	for _, line := range strings.Split(html.UnescapeString(b.String()), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PlainSections returns the text of content as PlainText in a single section without a
+// heading, standing in for Sections when a page can't be split.
+func PlainSections(content string) []Section {
+	return []Section{{Breadcrumbs: []string{}, Text: PlainText(content)}}
+}
@@ -0,0 +1,178 @@
+package page
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// Section is the part of a page between one heading and the next heading of any level.
+type Section struct {
+	Heading string `json:"heading"`
+	Level   int    `json:"level"`
+	// Anchor is the heading's id attribute, usable as a #fragment.
+	Anchor string `json:"anchor,omitempty"`
+	// Breadcrumbs are the headings enclosing this section, outermost first, ending with its own.
+	Breadcrumbs []string `json:"breadcrumbs"`
+	Text        string   `json:"text"`
+	// Code holds the contents of the section's 'pre' blocks, with their whitespace intact.
+	Code []string `json:"code,omitempty"`
+}
+
+// headingLevel returns the level of an h1-h6 tag name, or 0 for other tags.
+func headingLevel(name string) int {
+	if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+		return int(name[1] - '0')
+	}
+	return 0
+}
+
+// Sections splits an HTML document into sections by heading. Text before the first heading
+// forms a section with level 0 and no heading. The document is tokenized, not parsed into a
+// DOM, so this is safe for very large pages.
+func Sections(r io.Reader) ([]Section, error) {
+	var sections []Section
+	var stack []Section // enclosing headings, for breadcrumbs
+
+	current := Section{Breadcrumbs: []string{}}
+	var body, line, heading, code strings.Builder
+	inHeading := 0
+	preDepth, skipDepth := 0, 0
+
+	flushLine := func() {
+		text := strings.TrimRightFunc(line.String(), unicode.IsSpace)
+		line.Reset()
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		if body.Len() > 0 {
+			body.WriteString("\n")
+		}
+		body.WriteString(text)
+	}
+	finishSection := func() {
+		flushLine()
+		current.Text = body.String()
+		body.Reset()
+		if current.Level > 0 || current.Text != "" {
+			sections = append(sections, current)
+		}
+	}
+
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if errors.Is(z.Err(), io.EOF) {
+				finishSection()
+				return sections, nil
+			}
+			return sections, z.Err()
+		case html.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+			data := string(z.Text())
+			switch {
+			case inHeading > 0:
+				writeCollapsed(&heading, data)
+			case preDepth > 0:
+				code.WriteString(data)
+				for i, part := range strings.Split(data, "\n") {
+					if i > 0 {
+						flushLine()
+					}
+					line.WriteString(part)
+				}
+			default:
+				writeCollapsed(&line, data)
+			}
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			nameBytes, hasAttr := z.TagName()
+			name := string(nameBytes)
+			start := tt != html.EndTagToken
+
+			if level := headingLevel(name); level > 0 {
+				if tt == html.StartTagToken && inHeading == 0 {
+					finishSection()
+					inHeading = level
+					heading.Reset()
+					current = Section{Level: level}
+					for hasAttr {
+						var key, val []byte
+						key, val, hasAttr = z.TagAttr()
+						if string(key) == "id" {
+							current.Anchor = string(val)
+						}
+					}
+				} else if tt == html.EndTagToken && inHeading == level {
+					inHeading = 0
+					current.Heading = strings.TrimSpace(heading.String())
+					for len(stack) > 0 && stack[len(stack)-1].Level >= current.Level {
+						stack = stack[:len(stack)-1]
+					}
+					stack = append(stack, current)
+					current.Breadcrumbs = make([]string, len(stack))
+					for i, s := range stack {
+						current.Breadcrumbs[i] = s.Heading
+					}
+				}
+				continue
+			}
+
+			switch name {
+			case "script", "style":
+				if tt == html.StartTagToken {
+					skipDepth++
+				} else if tt == html.EndTagToken && skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			case "pre":
+				if tt == html.StartTagToken {
+					preDepth++
+				} else if tt == html.EndTagToken && preDepth > 0 {
+					preDepth--
+					if preDepth == 0 {
+						if block := strings.Trim(code.String(), "\n"); strings.TrimSpace(block) != "" {
+							current.Code = append(current.Code, block)
+						}
+						code.Reset()
+					}
+				}
+			case "td", "th":
+				if start && line.Len() > 0 {
+					line.WriteString(" ")
+				}
+			}
+			if inHeading == 0 && blockElements[name] {
+				flushLine()
+			}
+		}
+	}
+}
+
+// MatchSections returns the sections whose heading or text contains every whitespace-separated
+// term of query, compared case-insensitively.
+func MatchSections(sections []Section, query string) []Section {
+	terms := strings.Fields(strings.ToLower(query))
+	matches := []Section{}
+	for _, section := range sections {
+		haystack := strings.ToLower(section.Heading + "\n" + section.Text)
+		matched := len(terms) > 0
+		for _, term := range terms {
+			if !strings.Contains(haystack, term) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, section)
+		}
+	}
+	return matches
+}
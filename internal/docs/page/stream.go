@@ -0,0 +1,185 @@
+package page
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// StreamingThreshold is the page size, in bytes, above which pages are processed with the
+// streaming tokenizer instead of a full DOM parse. Multi-megabyte single-page references (the
+// Lua manual, for instance) would otherwise build a DOM many times their own size.
+const StreamingThreshold = 1 << 20
+
+// useStreaming reports whether content is large enough to be tokenized rather than parsed.
+func useStreaming(content string) bool {
+	return len(content) > StreamingThreshold
+}
+
+// StreamLinks is the streaming equivalent of Links: it tokenizes r without building a DOM.
+func StreamLinks(r io.Reader) ([]string, error) {
+	var links []string
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if errors.Is(z.Err(), io.EOF) {
+				return links, nil
+			}
+			return links, z.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "a" {
+				continue
+			}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				if string(key) == "href" && len(val) > 0 {
+					links = append(links, string(val))
+				}
+			}
+		}
+	}
+}
+
+// StreamText is the streaming equivalent of Text: it emits one line per block element while
+// holding only the current line in memory.
+func StreamText(r io.Reader, w io.Writer) error {
+	var line strings.Builder
+	wroteLine := false
+	flush := func() error {
+		text := strings.TrimRightFunc(line.String(), unicode.IsSpace)
+		line.Reset()
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		if wroteLine {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		wroteLine = true
+		_, err := io.WriteString(w, text)
+		return err
+	}
+
+	preDepth, skipDepth := 0, 0
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if errors.Is(z.Err(), io.EOF) {
+				return flush()
+			}
+			return z.Err()
+		case html.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+			data := string(z.Text())
+			if preDepth > 0 {
+				for i, part := range strings.Split(data, "\n") {
+					if i > 0 {
+						if err := flush(); err != nil {
+							return err
+						}
+					}
+					line.WriteString(part)
+				}
+			} else {
+				writeCollapsed(&line, data)
+			}
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			nameBytes, _ := z.TagName()
+			name := string(nameBytes)
+			start := tt != html.EndTagToken
+			switch name {
+			case "script", "style":
+				if tt == html.StartTagToken {
+					skipDepth++
+				} else if tt == html.EndTagToken && skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			case "pre":
+				if tt == html.StartTagToken {
+					preDepth++
+				} else if tt == html.EndTagToken && preDepth > 0 {
+					preDepth--
+				}
+			case "td", "th":
+				if start && line.Len() > 0 {
+					line.WriteString(" ")
+				}
+			}
+			if blockElements[name] {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// StreamSummary is the streaming equivalent of Summary.
+func StreamSummary(r io.Reader) (title, summary string, err error) {
+	var fallback string
+	var text strings.Builder
+	seenTitle, inTitle, inParagraph := false, false, false
+	skipDepth := 0
+
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if errors.Is(z.Err(), io.EOF) {
+				return title, fallback, nil
+			}
+			return title, fallback, z.Err()
+		case html.TextToken:
+			if skipDepth == 0 && (inTitle || inParagraph) {
+				text.Write(z.Text())
+			}
+		case html.StartTagToken, html.EndTagToken:
+			nameBytes, _ := z.TagName()
+			name := string(nameBytes)
+			switch name {
+			case "script", "style", "nav":
+				if tt == html.StartTagToken {
+					skipDepth++
+				} else if skipDepth > 0 {
+					skipDepth--
+				}
+			case "h1":
+				if tt == html.StartTagToken && !seenTitle {
+					inTitle = true
+					text.Reset()
+				} else if tt == html.EndTagToken && inTitle {
+					inTitle, seenTitle = false, true
+					title = strings.Join(strings.Fields(text.String()), " ")
+					fallback = ""
+				}
+			case "p":
+				if tt == html.StartTagToken && !inTitle && skipDepth == 0 {
+					inParagraph = true
+					text.Reset()
+				} else if tt == html.EndTagToken && inParagraph {
+					inParagraph = false
+					paragraph := strings.Join(strings.Fields(text.String()), " ")
+					if len(paragraph) >= minSummaryLength {
+						return title, paragraph, nil
+					}
+					if fallback == "" {
+						fallback = paragraph
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"devdocsmcp/internal/docs/indexer"
+
+	"golang.org/x/net/html"
+)
+
+// Defaults of the write-behind index pipeline.
+const (
+	// DefaultIndexQueueSize is how many downloaded pages may wait for the indexer before the
+	// crawler blocks on it.
+	DefaultIndexQueueSize = 256
+	// DefaultIndexWorkers is the number of goroutines adding pages to the index.
+	DefaultIndexWorkers = 2
+	// DefaultProgressInterval is how often DownloadDoc reports the progress of both pipelines.
+	DefaultProgressInterval = 5 * time.Second
+)
+
+// Progress counts the work of the download and index pipelines of a crawl.
+type Progress struct {
+	Downloaded     int64
+	DownloadFailed int64
+	// Fetching is the number of pages being downloaded.
+	Fetching    int64
+	Indexed     int64
+	IndexFailed int64
+	// IndexQueued is the number of downloaded pages waiting for the indexer.
+	IndexQueued int64
+}
+
+func (p Progress) String() string {
+	return fmt.Sprintf("download: %d done, %d failed, %d in flight; index: %d done, %d failed, %d queued",
+		p.Downloaded, p.DownloadFailed, p.Fetching, p.Indexed, p.IndexFailed, p.IndexQueued)
+}
+
+// pipelineStats holds the live counters behind Progress.
+type pipelineStats struct {
+	downloaded, downloadFailed, fetching atomic.Int64
+	indexed, indexFailed, indexQueued    atomic.Int64
+}
+
+func (st *pipelineStats) snapshot() Progress {
+	return Progress{
+		Downloaded:     st.downloaded.Load(),
+		DownloadFailed: st.downloadFailed.Load(),
+		Fetching:       st.fetching.Load(),
+		Indexed:        st.indexed.Load(),
+		IndexFailed:    st.indexFailed.Load(),
+		IndexQueued:    st.indexQueued.Load(),
+	}
+}
+
+// indexJob is a downloaded page waiting to be indexed. Its text is extracted by the index
+// worker, off the crawl path.
+type indexJob struct {
+	// filePath is where the page was saved; the index identifies it by lang, version and
+	// pagePath instead (see pageDocumentID).
+	filePath      string
+	pagePath      string
+	lang, version string
+	doc           *html.Node
+}
+
+// indexPipeline decouples indexing from downloading: fetchers hand pages to a bounded queue
+// and return to the crawl, while workers extract their text and add them to the index. A full
+// queue blocks the fetchers, so a slow index applies backpressure instead of buffering an
+// unbounded number of pages in memory.
+type indexPipeline struct {
+	queue chan indexJob
+	wg    sync.WaitGroup
+}
+
+// startIndexing starts the index workers of a crawl.
+func (s *Scraper) startIndexing() *indexPipeline {
+	size, workers := s.IndexQueueSize, s.IndexWorkers
+	if size <= 0 {
+		size = DefaultIndexQueueSize
+	}
+	if workers <= 0 {
+		workers = DefaultIndexWorkers
+	}
+	p := &indexPipeline{queue: make(chan indexJob, size)}
+	for w := 0; w < workers; w++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range p.queue {
+				s.stats.indexQueued.Add(-1)
+				err := s.Indexer.IndexDocument(indexer.Document{
+					ID:       pageDocumentID(job.lang, job.version, job.pagePath),
+					Path:     job.pagePath,
+					Lang:     job.lang,
+					Version:  job.version,
+					Title:    extractTitle(job.doc),
+					Headings: extractHeadings(job.doc),
+					Content:  extractText(job.doc),
+				})
+				if err != nil {
+					s.stats.indexFailed.Add(1)
+					fmt.Printf("Error indexing %s: %v\n", job.filePath, err)
+					continue
+				}
+				s.stats.indexed.Add(1)
+			}
+		}()
+	}
+	return p
+}
+
+// enqueueIndex hands a downloaded page to the index workers, blocking while the queue is full.
+func (s *Scraper) enqueueIndex(job indexJob) {
+	s.stats.indexQueued.Add(1)
+	s.index.queue <- job
+}
+
+// pageIndexPath returns the page path a downloaded page is indexed under, from its path
+// relative to the documentation host: without the .html extension, and ending in index for a
+// directory, as devdocs names pages.
+func pageIndexPath(relativePath string) string {
+	if relativePath == "" || strings.HasSuffix(relativePath, "/") {
+		return relativePath + "index"
+	}
+	return strings.TrimSuffix(relativePath, ".html")
+}
+
+// pageDocumentID returns the ID a downloaded page is indexed under. A Scraper may index several
+// documentation sets, and versions of one, into the same index, so unlike its page path the ID
+// includes both: lang@version/path, or lang/path without a version.
+func pageDocumentID(lang, version, pagePath string) string {
+	if version != "" {
+		lang += "@" + version
+	}
+	return lang + "/" + pagePath
+}
+
+// extractHeadings returns the text of the h1-h6 headings of a page, one per line.
+func extractHeadings(doc *html.Node) string {
+	var headings []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && len(n.Data) == 2 && n.Data[0] == 'h' && n.Data[1] >= '1' && n.Data[1] <= '6' {
+			if heading := strings.Join(strings.Fields(extractText(n)), " "); heading != "" {
+				headings = append(headings, heading)
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return strings.Join(headings, "\n")
+}
+
+// extractTitle returns the title of a page: its <title>, or else its first <h1>.
+func extractTitle(doc *html.Node) string {
+	var title, heading string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.Data == "title" && title == "":
+				title = strings.TrimSpace(extractText(n))
+			case n.Data == "h1" && heading == "":
+				heading = strings.TrimSpace(extractText(n))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	if title != "" {
+		return title
+	}
+	return heading
+}
+
+// finish waits for the queued pages to be indexed.
+func (p *indexPipeline) finish() {
+	close(p.queue)
+	p.wg.Wait()
+}
+
+// Progress returns the progress of the current or last crawl.
+func (s *Scraper) Progress() Progress {
+	return s.stats.snapshot()
+}
+
+// reportProgress prints the progress of both pipelines every interval until done is closed.
+func (s *Scraper) reportProgress(interval time.Duration, done <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			fmt.Printf("Progress: %s\n", s.Progress())
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package scraper
+
+import (
+	"sync"
+	"time"
+)
+
+// Politeness bounds how hard the crawler may hit an upstream host.
+type Politeness struct {
+	// MinConcurrency and MaxConcurrency bound the number of requests in flight.
+	MinConcurrency int
+	MaxConcurrency int
+	// TargetLatency is the response time above which the host is considered to be slowing down.
+	TargetLatency time.Duration
+	// MinDelay and MaxDelay bound the pause between the starts of two requests.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// DefaultPoliteness starts cautiously and lets robust hosts earn up to 16 parallel requests.
+var DefaultPoliteness = Politeness{
+	MinConcurrency: 1,
+	MaxConcurrency: 16,
+	TargetLatency:  2 * time.Second,
+	MinDelay:       0,
+	MaxDelay:       10 * time.Second,
+}
+
+// latencySmoothing is the weight of the newest sample in the latency moving average.
+const latencySmoothing = 0.2
+
+// backoffDelay is the delay introduced on the first sign of trouble when none was in place.
+const backoffDelay = 250 * time.Millisecond
+
+// Throttle adapts crawl concurrency and delay to the upstream host AIMD-style: every healthy
+// response additively raises the concurrency limit (by about one per round of requests) and
+// shortens the delay, while an error, a throttling status or a slow response halves the limit
+// and doubles the delay.
+type Throttle struct {
+	policy Politeness
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	limit     float64
+	inFlight  int
+	delay     time.Duration
+	lastStart time.Time
+	latency   time.Duration // exponential moving average
+}
+
+// NewThrottle returns a Throttle starting at the policy's minimum concurrency.
+func NewThrottle(policy Politeness) *Throttle {
+	if policy.MinConcurrency < 1 {
+		policy.MinConcurrency = 1
+	}
+	if policy.MaxConcurrency < policy.MinConcurrency {
+		policy.MaxConcurrency = policy.MinConcurrency
+	}
+	t := &Throttle{policy: policy, limit: float64(policy.MinConcurrency), delay: policy.MinDelay}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+// Acquire blocks until another request may start.
+func (t *Throttle) Acquire() {
+	t.mu.Lock()
+	for t.inFlight >= int(t.limit) {
+		t.cond.Wait()
+	}
+	t.inFlight++
+	// Space request starts by the current delay; reserving the slot before sleeping keeps
+	// concurrent callers from starting together.
+	start := t.lastStart.Add(t.delay)
+	now := time.Now()
+	if start.Before(now) {
+		start = now
+	}
+	t.lastStart = start
+	t.mu.Unlock()
+
+	time.Sleep(time.Until(start))
+}
+
+// Release records the outcome of a request started with Acquire. overloaded reports a failure
+// attributable to the host: a network error, 429 Too Many Requests or a 5xx status.
+func (t *Throttle) Release(latency time.Duration, overloaded bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight--
+
+	if t.latency == 0 {
+		t.latency = latency
+	} else {
+		t.latency = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(t.latency))
+	}
+
+	if overloaded || t.latency > t.policy.TargetLatency {
+		t.limit /= 2
+		if t.delay == 0 {
+			t.delay = backoffDelay
+		} else {
+			t.delay *= 2
+		}
+	} else {
+		t.limit += 1 / t.limit
+		t.delay -= t.delay / 4
+		if t.delay < time.Millisecond {
+			t.delay = 0
+		}
+	}
+	t.limit = clamp(t.limit, float64(t.policy.MinConcurrency), float64(t.policy.MaxConcurrency))
+	if t.delay < t.policy.MinDelay {
+		t.delay = t.policy.MinDelay
+	}
+	if t.delay > t.policy.MaxDelay {
+		t.delay = t.policy.MaxDelay
+	}
+	t.cond.Broadcast()
+}
+
+// State returns the current concurrency limit, delay and smoothed latency.
+func (t *Throttle) State() (concurrency int, delay, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return int(t.limit), t.delay, t.latency
+}
+
+func clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/net/html"
 	"devdocsmcp/internal/docs/indexer"
@@ -29,6 +30,16 @@ type Scraper struct {
 	mu           sync.Mutex
 	wg           sync.WaitGroup
 	Indexer      *indexer.Indexer // Add Indexer to Scraper
+	Throttle     *Throttle        // Adapts request rate to the upstream host
+
+	// IndexQueueSize and IndexWorkers size the write-behind index pipeline (see indexPipeline).
+	IndexQueueSize int
+	IndexWorkers   int
+	// ProgressInterval is how often DownloadDoc prints progress; 0 disables the reports.
+	ProgressInterval time.Duration
+
+	index *indexPipeline
+	stats pipelineStats
 }
 
 // NewScraper creates a new Scraper instance.
@@ -37,6 +48,11 @@ func NewScraper(downloadPath string, idx *indexer.Indexer) *Scraper {
 		DownloadPath: downloadPath,
 		visitedURLs:  make(map[string]bool),
 		Indexer:      idx,
+		Throttle:     NewThrottle(DefaultPoliteness),
+
+		IndexQueueSize:   DefaultIndexQueueSize,
+		IndexWorkers:     DefaultIndexWorkers,
+		ProgressInterval: DefaultProgressInterval,
 	}
 }
 
@@ -50,6 +66,11 @@ func (s *Scraper) DownloadDoc(doc Doc, maxDepth int) error {
 	}
 	initialHost := initialURL.Host
 
+	s.stats = pipelineStats{}
+	s.index = s.startIndexing()
+	done := make(chan struct{})
+	go s.reportProgress(s.ProgressInterval, done)
+
 	queue := make(chan struct {
 		url   string
 		depth int
@@ -82,24 +103,40 @@ func (s *Scraper) DownloadDoc(doc Doc, maxDepth int) error {
 			continue
 		}
 
+		s.stats.fetching.Add(1)
 		go s.fetchAndProcess(current.url, current.depth, initialHost, doc.Name, doc.Version, queue)
 	}
 
+	// Every page is downloaded; wait for the indexer to catch up
+	s.index.finish()
+	close(done)
+	fmt.Printf("Finished %s %s: %s\n", doc.Name, doc.Version, s.Progress())
 	return nil
 }
 
 func (s *Scraper) fetchAndProcess(currentURL string, currentDepth int, initialHost, docName, docVersion string, queue chan<- struct { url string; depth int }) {
 	defer s.wg.Done()
+	defer s.stats.fetching.Add(-1)
+	downloaded := false
+	defer func() {
+		if !downloaded {
+			s.stats.downloadFailed.Add(1)
+		}
+	}()
 
 	fmt.Printf("Downloading (depth %d): %s\n", currentDepth, currentURL)
 
+	s.Throttle.Acquire()
+	start := time.Now()
 	resp, err := http.Get(currentURL)
 	if err != nil {
+		s.Throttle.Release(time.Since(start), true)
 		fmt.Printf("Error downloading %s: %v\n", currentURL, err)
 		return
 	}
 	body, err := ioutil.ReadAll(resp.Body)
 	resp.Body.Close() // Close body immediately after reading
+	s.Throttle.Release(time.Since(start), err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500)
 	if err != nil {
 		fmt.Printf("Error reading response body for %s: %v\n", currentURL, err)
 		return
@@ -121,6 +158,7 @@ func (s *Scraper) fetchAndProcess(currentURL string, currentDepth int, initialHo
 	// Clean up path for file system, e.g., remove leading slashes, replace invalid chars
 	relativePath = strings.TrimPrefix(relativePath, initialHost)
 	relativePath = strings.TrimPrefix(relativePath, "/")
+	pagePath := pageIndexPath(relativePath)
 	relativePath = strings.ReplaceAll(relativePath, ":", "_") // Replace colon for Windows compatibility
 
 	if strings.HasSuffix(relativePath, "/") || relativePath == "" {
@@ -144,6 +182,8 @@ func (s *Scraper) fetchAndProcess(currentURL string, currentDepth int, initialHo
 	}
 
 	fmt.Printf("Saved: %s\n", filePath)
+	downloaded = true
+	s.stats.downloaded.Add(1)
 
 	// Process the downloaded document (extract links, etc.)
 	docReader := strings.NewReader(string(body))
@@ -153,10 +193,8 @@ func (s *Scraper) fetchAndProcess(currentURL string, currentDepth int, initialHo
 		return
 	}
 
-	// Extract text and add to index
-	plainText := extractText(htmlDoc)
-	// fmt.Printf("Extracted text for %s: %s\n", filePath, plainText[:min(len(plainText), 100)]) // Removed for brevity
-	s.Indexer.AddDocument(filePath, plainText)
+	// Hand the page to the index pipeline, which extracts its text and indexes it off the crawl path
+	s.enqueueIndex(indexJob{filePath: filePath, pagePath: pagePath, lang: docName, version: docVersion, doc: htmlDoc})
 
 	links := extractLinks(htmlDoc, currentURL)
 	for _, link := range links {
@@ -0,0 +1,375 @@
+// Package searchquery parses the query syntax of full-text searches: quoted phrases, the
+// operators AND, OR and NOT, parentheses, and field prefixes such as title: or type:. It only
+// builds the expression; the full-text index and the entry search each evaluate it their way.
+package searchquery
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Fields a term can be scoped to with a prefix such as title:parse.
+const (
+	FieldTitle   = "title"
+	FieldType    = "type"
+	FieldPath    = "path"
+	FieldContent = "content"
+)
+
+// Limits of a query, so a query can't make a search arbitrarily expensive: maxTerms caps its
+// terms, maxDepth the nesting of parentheses and NOT, and maxQueryBytes its length.
+const (
+	maxTerms      = 64
+	maxDepth      = 32
+	maxQueryBytes = 4096
+)
+
+// Op is the kind of an Expr.
+type Op int
+
+const (
+	Term Op = iota
+	And
+	Or
+	Not
+)
+
+// Expr is a parsed query.
+type Expr struct {
+	Op Op
+	// Field is the field a Term is scoped to, "" for the default (the page text and entry
+	// names); Value is its word or phrase.
+	Field  string
+	Value  string
+	Phrase bool
+	// Args are the operands of And, Or and Not.
+	Args []*Expr
+}
+
+// Eval evaluates e with term deciding whether each Term matches.
+func (e *Expr) Eval(term func(t *Expr) bool) bool {
+	switch e.Op {
+	case And:
+		for _, arg := range e.Args {
+			if !arg.Eval(term) {
+				return false
+			}
+		}
+		return true
+	case Or:
+		for _, arg := range e.Args {
+			if arg.Eval(term) {
+				return true
+			}
+		}
+		return false
+	case Not:
+		return !e.Args[0].Eval(term)
+	}
+	return term(e)
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenPhrase
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind tokenKind
+	// field is the field prefix of a word or phrase, or "field:" alone with an empty text
+	// when the prefix has no value.
+	field, text string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenOpen:
+		return "("
+	case tokenClose:
+		return ")"
+	case tokenPhrase:
+		return t.field + `"` + t.text + `"`
+	}
+	return t.field + t.text
+}
+
+// HasSyntax reports whether text uses the query syntax. Text without it is plain words, which
+// full-text searches match as before: any of them, best matches first.
+func HasSyntax(text string) bool {
+	if len(text) > maxQueryBytes {
+		return true
+	}
+	tokens, err := lex(text)
+	if err != nil {
+		return true
+	}
+	for _, t := range tokens {
+		if t.kind != tokenWord || t.field != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses a query. Terms next to each other must all match, as if joined by AND; NOT
+// binds tighter than AND, and AND tighter than OR. Operators are only recognized in upper
+// case, so "and" or "not" are plain words.
+func Parse(text string) (*Expr, error) {
+	if len(text) > maxQueryBytes {
+		return nil, fmt.Errorf("invalid query: the query is longer than %d bytes", maxQueryBytes)
+	}
+	tokens, err := lex(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("invalid query: the query is empty")
+	}
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected ) without a matching (")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	return expr, nil
+}
+
+// lex splits text into tokens.
+func lex(text string) ([]token, error) {
+	var tokens []token
+	depth := 0
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '"':
+			end := strings.IndexByte(text[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("missing closing quote after %s", text[i:])
+			}
+			phrase := text[i+1 : i+1+end]
+			if strings.TrimSpace(phrase) == "" {
+				return nil, fmt.Errorf("empty quoted phrase")
+			}
+			tokens = append(tokens, token{kind: tokenPhrase, text: phrase})
+			i += end + 2
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenOpen})
+			depth++
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenClose})
+			depth--
+			i++
+		default:
+			end := i
+			for end < len(text) && text[end] != '"' && !unicode.IsSpace(rune(text[end])) {
+				end++
+			}
+			word := text[i:end]
+			i = end
+			// Closing parentheses end a group unless the word opened them, as in :nth-child()
+			closers := 0
+			for depth > 0 && strings.HasSuffix(word, ")") && strings.Count(word, "(") < strings.Count(word, ")") {
+				word = word[:len(word)-1]
+				closers++
+				depth--
+			}
+			if word != "" {
+				tokens = append(tokens, wordToken(word))
+			}
+			for ; closers > 0; closers-- {
+				tokens = append(tokens, token{kind: tokenClose})
+			}
+		}
+	}
+	// A field prefix directly followed by a quoted phrase scopes the phrase
+	merged := tokens[:0]
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind == tokenWord && t.field != "" && t.text == "" && i+1 < len(tokens) && tokens[i+1].kind == tokenPhrase && tokens[i+1].field == "" {
+			i++
+			t = token{kind: tokenPhrase, field: t.field, text: tokens[i].text}
+		}
+		merged = append(merged, t)
+	}
+	return merged, nil
+}
+
+// wordToken classifies a word: an operator, a field-prefixed term or a plain word. Only the
+// known fields are prefixes, so words such as std::vector or a:hover stay words.
+func wordToken(word string) token {
+	switch word {
+	case "AND":
+		return token{kind: tokenAnd}
+	case "OR":
+		return token{kind: tokenOr}
+	case "NOT":
+		return token{kind: tokenNot}
+	}
+	if colon := strings.IndexByte(word, ':'); colon > 0 {
+		switch field := strings.ToLower(word[:colon]); field {
+		case FieldTitle, FieldType, FieldPath, FieldContent:
+			return token{kind: tokenWord, field: field + ":", text: word[colon+1:]}
+		}
+	}
+	return token{kind: tokenWord, text: word}
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	terms  int
+	// depth is the nesting of the parentheses and NOT being parsed.
+	depth int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// startsOperand reports whether the next token can begin an operand.
+func (p *parser) startsOperand() bool {
+	t, ok := p.peek()
+	return ok && (t.kind == tokenWord || t.kind == tokenPhrase || t.kind == tokenNot || t.kind == tokenOpen)
+}
+
+// operand returns the error of an operator without an operand after it.
+func (p *parser) operand(operator string) error {
+	if p.startsOperand() {
+		return nil
+	}
+	if t, ok := p.peek(); ok {
+		return fmt.Errorf("%s must be followed by a search term, not %s", operator, t)
+	}
+	return fmt.Errorf("%s must be followed by a search term", operator)
+}
+
+func (p *parser) parseOr() (*Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	args := []*Expr{left}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokenOr {
+			break
+		}
+		p.pos++
+		if err := p.operand("OR"); err != nil {
+			return nil, err
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, right)
+	}
+	if len(args) == 1 {
+		return left, nil
+	}
+	return &Expr{Op: Or, Args: args}, nil
+}
+
+func (p *parser) parseAnd() (*Expr, error) {
+	if t, ok := p.peek(); ok && (t.kind == tokenAnd || t.kind == tokenOr) {
+		return nil, fmt.Errorf("%s must come between two search terms", t)
+	}
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	args := []*Expr{left}
+	for {
+		t, ok := p.peek()
+		if ok && t.kind == tokenAnd {
+			p.pos++
+			if err := p.operand("AND"); err != nil {
+				return nil, err
+			}
+		} else if !p.startsOperand() {
+			break
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, right)
+	}
+	if len(args) == 1 {
+		return left, nil
+	}
+	return &Expr{Op: And, Args: args}, nil
+}
+
+func (p *parser) parseNot() (*Expr, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("missing search term")
+	}
+	if t.kind == tokenNot || t.kind == tokenOpen {
+		if p.depth++; p.depth > maxDepth {
+			return nil, fmt.Errorf("parentheses and NOT are nested too deeply (at most %d levels)", maxDepth)
+		}
+		defer func() { p.depth-- }()
+	}
+	switch t.kind {
+	case tokenNot:
+		p.pos++
+		if err := p.operand("NOT"); err != nil {
+			return nil, err
+		}
+		arg, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Op: Not, Args: []*Expr{arg}}, nil
+	case tokenOpen:
+		p.pos++
+		if t, ok := p.peek(); ok && t.kind == tokenClose {
+			return nil, fmt.Errorf("empty parentheses")
+		}
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := p.peek(); !ok || t.kind != tokenClose {
+			return nil, fmt.Errorf("missing ) to close a (")
+		}
+		p.pos++
+		return expr, nil
+	case tokenClose:
+		return nil, fmt.Errorf("unexpected ) without a matching (")
+	case tokenWord, tokenPhrase:
+		p.pos++
+		if t.field != "" && t.text == "" {
+			return nil, fmt.Errorf("%s needs a word or a quoted phrase after it, e.g. %sparse or %s\"parse int\"", t.field, t.field, t.field)
+		}
+		if p.terms++; p.terms > maxTerms {
+			return nil, fmt.Errorf("too many search terms (at most %d)", maxTerms)
+		}
+		return &Expr{Op: Term, Field: strings.TrimSuffix(t.field, ":"), Value: t.text, Phrase: t.kind == tokenPhrase}, nil
+	}
+	return nil, fmt.Errorf("%s must come between two search terms", t)
+}
@@ -0,0 +1,46 @@
+// Package filelock takes advisory locks on files, so several processes sharing a directory can
+// tell whether one of them is still working on something. A lock is released when it is
+// unlocked or when its process exits, even if it crashes.
+package filelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrLocked is returned by TryLock when another holder has the lock.
+var ErrLocked = errors.New("locked by another holder")
+
+// Lock is a held lock.
+type Lock struct {
+	f *os.File
+}
+
+// TryLock takes the exclusive lock of the file at path, creating it (and its directory) if
+// needed, without waiting. Two TryLocks of one file conflict even within a process.
+func TryLock(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Lock{f: f}, nil
+}
+
+// Unlock releases the lock. The lock file is left in place: removing it would let another
+// holder lock a new file of the same name while a third still holds the old one.
+func (l *Lock) Unlock() error {
+	if err := unlockFile(l.f); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,117 @@
+// Package httpclient builds HTTP clients with per-source TLS settings: custom CA bundles,
+// client certificates (mTLS) and, for testing only, disabled certificate verification.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"devdocsmcp/internal/config"
+)
+
+// TLSConfig builds the tls.Config described by t. CA files are added to the system roots.
+func TLSConfig(t config.TLS) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if t.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return nil, fmt.Errorf("client certificate needs both cert_file and key_file")
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	cfg.InsecureSkipVerify = t.InsecureSkipVerify
+	return cfg, nil
+}
+
+// NewTransport returns a copy of http.DefaultTransport using the TLS settings t. name
+// identifies the source in the warning logged when certificate verification is disabled.
+func NewTransport(name string, t config.TLS) (*http.Transport, error) {
+	cfg, err := TLSConfig(t)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS settings for %s: %w", name, err)
+	}
+	if cfg.InsecureSkipVerify {
+		log.Printf("WARNING: TLS certificate verification is DISABLED for %s. Anyone on the network path can impersonate it; use ca_file instead outside of testing.\n", name)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	return transport, nil
+}
+
+// hostRouter sends each request through the transport configured for its host. Its routes
+// are replaced atomically, so sources can change while requests are in flight.
+type hostRouter struct {
+	byHost   atomic.Pointer[map[string]*http.Transport]
+	fallback http.RoundTripper
+}
+
+func (r *hostRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if byHost := r.byHost.Load(); byHost != nil {
+		if rt, ok := (*byHost)[strings.ToLower(req.URL.Host)]; ok {
+			return rt.RoundTrip(req)
+		}
+		if rt, ok := (*byHost)[strings.ToLower(req.URL.Hostname())]; ok {
+			return rt.RoundTrip(req)
+		}
+	}
+	return r.fallback.RoundTrip(req)
+}
+
+var (
+	// router is the hostRouter of http.DefaultClient, installed by the first InstallSources.
+	router        *hostRouter
+	installRouter sync.Once
+)
+
+// InstallSources makes http.DefaultClient, which every documentation fetch goes through, use
+// the TLS settings of sources, keyed by host name (optionally with ":port"). Hosts without
+// settings keep the default transport. The first call installs a router in the client, which
+// must happen before the client is used concurrently; later calls, e.g. on a config reload,
+// only swap its routes and may run while requests are in flight.
+func InstallSources(sources map[string]config.TLS) error {
+	byHost := make(map[string]*http.Transport, len(sources))
+	for host, t := range sources {
+		transport, err := NewTransport(host, t)
+		if err != nil {
+			return err
+		}
+		byHost[strings.ToLower(host)] = transport
+	}
+	installRouter.Do(func() {
+		router = &hostRouter{fallback: http.DefaultTransport}
+		http.DefaultClient.Transport = router
+	})
+	if previous := router.byHost.Swap(&byHost); previous != nil {
+		for _, transport := range *previous {
+			transport.CloseIdleConnections()
+		}
+	}
+	return nil
+}
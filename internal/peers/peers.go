@@ -0,0 +1,299 @@
+// Package peers discovers devdocsmcp daemons on the local network with multicast DNS service
+// discovery (RFC 6762 and 6763): each daemon advertises an instance of Service, and Browse
+// lists the instances that answer a query.
+package peers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Service is the DNS-SD service type daemons advertise.
+const Service = "_devdocsmcp._tcp.local."
+
+// recordTTL is the time to live of the records in answers, in seconds.
+const recordTTL = 120
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Peer is a daemon found on the network.
+type Peer struct {
+	// Instance is the name the daemon advertises, unique on the network.
+	Instance string `json:"instance"`
+	// Addr is the host:port its peer cache is served on.
+	Addr string `json:"addr"`
+}
+
+// Advertise answers the queries for Service with the instance name and port until ctx is
+// cancelled. Queries sent from the mDNS port are answered on the multicast group, others (one-shot
+// queries such as Browse's) directly to the sender.
+func Advertise(ctx context.Context, instance string, port int) error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return fmt.Errorf("failed to join the mDNS group: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	host, err := hostName()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to read mDNS query: %w", err)
+		}
+		if !queriesService(buf[:n]) {
+			continue
+		}
+		answer, err := buildAnswer(instance, host, port)
+		if err != nil {
+			return err
+		}
+		to := mdnsGroup
+		if from.Port != mdnsGroup.Port {
+			to = from
+		}
+		// A lost answer is repeated by the next query
+		conn.WriteToUDP(answer, to)
+	}
+}
+
+// Browse queries the network for Service and returns the instances that answer within wait,
+// sorted by name.
+func Browse(ctx context.Context, wait time.Duration) ([]Peer, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mDNS socket: %w", err)
+	}
+	defer conn.Close()
+
+	query, err := buildQuery()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteToUDP(query, mdnsGroup); err != nil {
+		return nil, fmt.Errorf("failed to send mDNS query: %w", err)
+	}
+
+	deadline := time.Now().Add(wait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+	found := make(map[string]Peer)
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			return nil, fmt.Errorf("failed to read mDNS answer: %w", err)
+		}
+		for _, peer := range parseAnswer(buf[:n], from.IP) {
+			found[peer.Instance] = peer
+		}
+	}
+
+	peers := make([]Peer, 0, len(found))
+	for _, peer := range found {
+		peers = append(peers, peer)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Instance < peers[j].Instance })
+	return peers, nil
+}
+
+// buildQuery encodes a PTR query for Service.
+func buildQuery() ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: dnsmessage.MustNewName(Service), Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, fmt.Errorf("failed to encode mDNS query: %w", err)
+	}
+	return b.Finish()
+}
+
+// queriesService reports whether msg is a query asking for Service.
+func queriesService(msg []byte) bool {
+	var p dnsmessage.Parser
+	header, err := p.Start(msg)
+	if err != nil || header.Response {
+		return false
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return false
+	}
+	for _, q := range questions {
+		if strings.EqualFold(q.Name.String(), Service) && (q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildAnswer encodes the records describing an instance: the PTR record of Service naming it,
+// and its SRV, TXT and address records.
+func buildAnswer(instance, host string, port int) ([]byte, error) {
+	instanceName, err := dnsmessage.NewName(instance + "." + Service)
+	if err != nil {
+		return nil, fmt.Errorf("invalid instance name %q: %w", instance, err)
+	}
+	hostName, err := dnsmessage.NewName(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host name %q: %w", host, err)
+	}
+	header := func(name dnsmessage.Name, typ dnsmessage.Type) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: name, Type: typ, Class: dnsmessage.ClassINET, TTL: recordTTL}
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true, Authoritative: true})
+	b.EnableCompression()
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	if err := b.PTRResource(header(dnsmessage.MustNewName(Service), dnsmessage.TypePTR), dnsmessage.PTRResource{PTR: instanceName}); err != nil {
+		return nil, err
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	if err := b.SRVResource(header(instanceName, dnsmessage.TypeSRV), dnsmessage.SRVResource{Port: uint16(port), Target: hostName}); err != nil {
+		return nil, err
+	}
+	if err := b.TXTResource(header(instanceName, dnsmessage.TypeTXT), dnsmessage.TXTResource{TXT: []string{"path=/peer/"}}); err != nil {
+		return nil, err
+	}
+	for _, ip := range localIPv4s() {
+		var a dnsmessage.AResource
+		copy(a.A[:], ip)
+		if err := b.AResource(header(hostName, dnsmessage.TypeA), a); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+// parseAnswer returns the instances of Service an answer from ip describes. Peers are reached
+// at the address the answer came from, on the port of their SRV record.
+func parseAnswer(msg []byte, ip net.IP) []Peer {
+	var p dnsmessage.Parser
+	header, err := p.Start(msg)
+	if err != nil || !header.Response {
+		return nil
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil
+	}
+	instances := make(map[string]bool)
+	ports := make(map[string]uint16)
+	for {
+		h, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			if err = p.SkipAllAuthorities(); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil
+		}
+		if !collectRecord(&p, h, p.SkipAnswer, instances, ports) {
+			return nil
+		}
+	}
+	for {
+		h, err := p.AdditionalHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil || !collectRecord(&p, h, p.SkipAdditional, instances, ports) {
+			break
+		}
+	}
+
+	var peers []Peer
+	for name := range instances {
+		port, ok := ports[name]
+		if !ok {
+			continue
+		}
+		instance := strings.TrimSuffix(name, "."+Service)
+		peers = append(peers, Peer{Instance: instance, Addr: net.JoinHostPort(ip.String(), fmt.Sprint(port))})
+	}
+	return peers
+}
+
+// collectRecord reads the body of the record h, noting the PTR records of Service and the
+// ports of SRV records; skip skips the records of other types in the current section. It
+// reports false if the message is malformed.
+func collectRecord(p *dnsmessage.Parser, h dnsmessage.ResourceHeader, skip func() error, instances map[string]bool, ports map[string]uint16) bool {
+	switch h.Type {
+	case dnsmessage.TypePTR:
+		ptr, err := p.PTRResource()
+		if err != nil {
+			return false
+		}
+		if strings.EqualFold(h.Name.String(), Service) {
+			instances[ptr.PTR.String()] = true
+		}
+	case dnsmessage.TypeSRV:
+		srv, err := p.SRVResource()
+		if err != nil {
+			return false
+		}
+		ports[h.Name.String()] = srv.Port
+	default:
+		if err := skip(); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// hostName returns the mDNS name of this host.
+func hostName() (string, error) {
+	name, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the host name: %w", err)
+	}
+	name, _, _ = strings.Cut(name, ".")
+	return name + ".local.", nil
+}
+
+// localIPv4s returns the IPv4 addresses of the interfaces that are up, except loopback.
+func localIPv4s() []net.IP {
+	var ips []net.IP
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				ips = append(ips, ipnet.IP.To4())
+			}
+		}
+	}
+	return ips
+}
@@ -0,0 +1,356 @@
+// Package store is the embedded key-value store (bbolt) holding the server's metadata:
+// docset records, ETags and statistics.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// SchemaVersion is the version of the store layout written by this build.
+const SchemaVersion = 2
+
+// Buckets of the store.
+const (
+	Docsets = "docsets"
+	ETags   = "etags"
+	Stats   = "stats"
+)
+
+// metaBucket holds bookkeeping such as the schema version.
+const metaBucket = "meta"
+
+var schemaVersionKey = []byte("schema_version")
+
+// buckets lists every bucket a store of the current schema contains.
+var buckets = []string{metaBucket, Docsets, ETags, Stats}
+
+// migrations upgrade a store one schema version at a time: migrations[i] moves a store
+// from version i to version i+1.
+var migrations = []func(tx *bolt.Tx) error{
+	// 0 -> 1: initial layout, the buckets are created by migrate itself.
+	func(tx *bolt.Tx) error { return nil },
+	// 1 -> 2: drop the history, favorites and jobs buckets, which were never used.
+	func(tx *bolt.Tx) error {
+		for _, name := range []string{"history", "favorites", "jobs"} {
+			if err := tx.DeleteBucket([]byte(name)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+				return fmt.Errorf("failed to remove bucket %s: %w", name, err)
+			}
+		}
+		return nil
+	},
+}
+
+// openTimeout bounds how long Open waits for another process holding the store.
+const openTimeout = time.Second
+
+// Store is an open metadata store.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the store at path, creating it if needed, and migrates it to SchemaVersion.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, fmt.Errorf("failed to open store %s: locked by another process", path)
+		}
+		return nil, fmt.Errorf("failed to open store %s: %w", path, err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// migrate creates missing buckets and applies pending migrations.
+func migrate(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return fmt.Errorf("failed to create bucket %s: %w", name, err)
+			}
+		}
+		meta := tx.Bucket([]byte(metaBucket))
+		version, err := readVersion(meta)
+		if err != nil {
+			return err
+		}
+		if version > SchemaVersion {
+			return fmt.Errorf("store schema version %d is newer than supported version %d", version, SchemaVersion)
+		}
+		for ; version < SchemaVersion; version++ {
+			if err := migrations[version](tx); err != nil {
+				return fmt.Errorf("failed to migrate store to version %d: %w", version+1, err)
+			}
+		}
+		return meta.Put(schemaVersionKey, []byte(strconv.Itoa(SchemaVersion)))
+	})
+}
+
+func readVersion(meta *bolt.Bucket) (int, error) {
+	raw := meta.Get(schemaVersionKey)
+	if raw == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(raw))
+	if err != nil {
+		return 0, fmt.Errorf("invalid store schema version %q", raw)
+	}
+	return version, nil
+}
+
+// Close closes the store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Put stores v under key in bucket, JSON-encoded.
+func (s *Store) Put(bucket, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s/%s: %w", bucket, key, err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("unknown bucket %q", bucket)
+		}
+		return b.Put([]byte(key), data)
+	})
+}
+
+// Get decodes the value under key in bucket into v. It reports false when the key is absent.
+func (s *Store) Get(bucket, key string, v any) (bool, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("unknown bucket %q", bucket)
+		}
+		if raw := b.Get([]byte(key)); raw != nil {
+			data = append([]byte(nil), raw...)
+		}
+		return nil
+	})
+	if err != nil || data == nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to decode %s/%s: %w", bucket, key, err)
+	}
+	return true, nil
+}
+
+// Delete removes key from bucket.
+func (s *Store) Delete(bucket, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("unknown bucket %q", bucket)
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// ForEach calls fn with every key and raw JSON value in bucket, in key order.
+func (s *Store) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("unknown bucket %q", bucket)
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+}
+
+// Info describes a store file.
+type Info struct {
+	Path          string         `json:"path"`
+	Size          int64          `json:"size"`
+	SchemaVersion int            `json:"schema_version"`
+	Buckets       map[string]int `json:"buckets"`
+}
+
+// Inspect returns the size, schema version and per-bucket key counts of the store at path.
+func Inspect(path string) (*Info, error) {
+	db, err := openExisting(path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	info := &Info{Path: path, Buckets: map[string]int{}}
+	if fi, err := os.Stat(path); err == nil {
+		info.Size = fi.Size()
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if string(name) == metaBucket {
+				version, err := readVersion(b)
+				info.SchemaVersion = version
+				return err
+			}
+			info.Buckets[string(name)] = b.Stats().KeyN
+			return nil
+		})
+	})
+	return info, err
+}
+
+// Compact rewrites the store at path without free pages, shrinking the file.
+func Compact(path string) error {
+	src, err := openExisting(path, true)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return rewrite(path, func(dst *bolt.DB) error {
+		return bolt.Compact(dst, src, 0)
+	})
+}
+
+// Repair checks the store at path for consistency. When problems are found, every readable
+// key is copied into a fresh store, the damaged file is kept next to it with a ".corrupt"
+// suffix, and the problems are returned.
+func Repair(path string) ([]string, error) {
+	src, err := openExisting(path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	err = src.View(func(tx *bolt.Tx) error {
+		for err := range tx.Check() {
+			problems = append(problems, err.Error())
+		}
+		return nil
+	})
+	if err != nil || len(problems) == 0 {
+		src.Close()
+		return nil, err
+	}
+
+	if err := copyFile(path, path+".corrupt"); err != nil {
+		src.Close()
+		return problems, fmt.Errorf("failed to back up damaged store: %w", err)
+	}
+	err = rewrite(path, func(dst *bolt.DB) error {
+		return salvage(dst, src, &problems)
+	})
+	src.Close()
+	return problems, err
+}
+
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// salvage copies every readable bucket and key from src into dst.
+func salvage(dst, src *bolt.DB, problems *[]string) error {
+	return src.View(func(stx *bolt.Tx) error {
+		var names []string
+		stx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			names = append(names, string(name))
+			return nil
+		})
+		sort.Strings(names)
+		for _, name := range names {
+			if err := salvageBucket(dst, stx, name); err != nil {
+				*problems = append(*problems, fmt.Sprintf("bucket %s: %v", name, err))
+			}
+		}
+		return nil
+	})
+}
+
+// salvageBucket copies one bucket, turning a panic on a corrupt page into an error.
+func salvageBucket(dst *bolt.DB, stx *bolt.Tx, name string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("unreadable: %v", r)
+		}
+	}()
+	return dst.Update(func(dtx *bolt.Tx) error {
+		b, err := dtx.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+		return stx.Bucket([]byte(name)).ForEach(func(k, v []byte) error {
+			if v == nil {
+				return nil // nested buckets are not used
+			}
+			return b.Put(k, v)
+		})
+	})
+}
+
+// openExisting opens a store file that must already exist.
+func openExisting(path string, readOnly bool) (*bolt.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout, ReadOnly: readOnly})
+	if err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, fmt.Errorf("failed to open store %s: locked by another process", path)
+		}
+		return nil, fmt.Errorf("failed to open store %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// rewrite builds a new store next to path with fill and moves it into place.
+func rewrite(path string, fill func(dst *bolt.DB) error) error {
+	tmpPath := path + ".tmp"
+	os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tmpPath, err)
+	}
+	if err := fill(dst); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := migrate(dst); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
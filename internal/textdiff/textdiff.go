@@ -0,0 +1,189 @@
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// opKind is the kind of a single line edit.
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type edit struct {
+	kind opKind
+	line string
+}
+
+// Lines splits text into lines without their trailing newlines.
+func Lines(text string) []string {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// Unified returns a unified diff between a and b with the given number of context lines.
+// It returns an empty string when a and b are identical.
+func Unified(fromName, toName string, a, b []string, context int) string {
+	edits := diff(a, b)
+
+	changed := false
+	for _, e := range edits {
+		if e.kind != opEqual {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Walk the edit script and emit hunks of changes padded with context lines
+	for start := 0; start < len(edits); {
+		// Find the next change
+		for start < len(edits) && edits[start].kind == opEqual {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		hunkStart := max(start-context, 0)
+
+		// Extend the hunk while changes are separated by at most 2*context equal lines
+		end := start
+		for end < len(edits) {
+			if edits[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].kind == opEqual {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end = min(end+context, len(edits))
+				break
+			}
+			end = run
+		}
+
+		// Line numbers of the hunk in a and b
+		aLine, bLine := 1, 1
+		for _, e := range edits[:hunkStart] {
+			if e.kind != opInsert {
+				aLine++
+			}
+			if e.kind != opDelete {
+				bLine++
+			}
+		}
+		aCount, bCount := 0, 0
+		for _, e := range edits[hunkStart:end] {
+			if e.kind != opInsert {
+				aCount++
+			}
+			if e.kind != opDelete {
+				bCount++
+			}
+		}
+		if aCount == 0 {
+			aLine--
+		}
+		if bCount == 0 {
+			bLine--
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+		for _, e := range edits[hunkStart:end] {
+			switch e.kind {
+			case opEqual:
+				out.WriteString(" ")
+			case opDelete:
+				out.WriteString("-")
+			case opInsert:
+				out.WriteString("+")
+			}
+			out.WriteString(e.line)
+			out.WriteString("\n")
+		}
+		start = end
+	}
+	return out.String()
+}
+
+// diff computes a shortest edit script from a to b using Myers' algorithm.
+func diff(a, b []string) []edit {
+	n, m := len(a), len(b)
+	maxD := n + m
+	offset := maxD
+	v := make([]int, 2*maxD+2)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= maxD && !found; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// Backtrack through the recorded frontiers to recover the edit script
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{opEqual, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{opInsert, b[y-1]})
+			} else {
+				edits = append(edits, edit{opDelete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}